@@ -0,0 +1,168 @@
+package main
+
+import (
+	"image/color"
+	"log"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+type ColorblindMode int32
+
+const (
+	COLORBLIND_OFF = iota
+	COLORBLIND_PROTANOPIA
+	COLORBLIND_DEUTERANOPIA
+	COLORBLIND_TRITANOPIA
+	COLORBLIND_MODE_COUNT
+)
+
+var colorblindModeNames = [COLORBLIND_MODE_COUNT]string{"off", "protanopia", "deuteranopia", "tritanopia"}
+
+// Daltonization: the frame is run through a simulation of the color deficiency, and what gets lost
+// is shifted into channels the player can still tell apart. With simulate on, the simulated frame
+// is shown instead, for checking content.
+var colorblindFragmentShader = `
+#version 330
+
+uniform sampler2D source;
+uniform int mode;
+uniform bool simulate;
+
+in vec2 fragTexCoord;
+
+out vec4 outputColor;
+
+const mat3 rgbToLms = mat3(
+    17.8824, 3.45565, 0.0299566,
+    43.5161, 27.1554, 0.184309,
+    4.11935, 3.86714, 1.46709);
+const mat3 lmsToRgb = mat3(
+    0.0809444479, -0.0102485335, -0.000365296938,
+    -0.130504409, 0.0540193266, -0.00412161469,
+    0.116721066, -0.113614708, 0.693511405);
+
+vec3 simulate_deficiency(vec3 color) {
+    vec3 lms = rgbToLms * color;
+    if (mode == 1) {
+        lms.x = 2.02344 * lms.y - 2.52581 * lms.z;
+    } else if (mode == 2) {
+        lms.y = 0.494207 * lms.x + 1.24827 * lms.z;
+    } else {
+        lms.z = -0.395913 * lms.x + 0.801109 * lms.y;
+    }
+    return lmsToRgb * lms;
+}
+
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    vec3 simulated = simulate_deficiency(color.rgb);
+    if (simulate) {
+        outputColor = vec4(clamp(simulated, 0.0, 1.0), color.a);
+        return;
+    }
+
+    vec3 error = color.rgb - simulated;
+    vec3 correction = vec3(0, error.r * 0.7 + error.g, error.r * 0.7 + error.b);
+    outputColor = vec4(clamp(color.rgb + correction, 0.0, 1.0), color.a);
+}
+` + "\x00"
+
+func parse_colorblind_mode(name string) (ColorblindMode, bool) {
+	for mode, mode_name := range colorblindModeNames {
+		if mode_name == name {
+			return ColorblindMode(mode), true
+		}
+	}
+	return COLORBLIND_OFF, false
+}
+
+func load_colorblind_mode() {
+	mode, ok := parse_colorblind_mode(g_Config.String("colorblind"))
+	if !ok {
+		log.Printf("config: unknown colorblind mode %q", g_Config.String("colorblind"))
+	}
+	g_Settings.colorblind_mode = mode
+}
+
+func init_accessibility() {
+	add_post_pass("colorblind", colorblindFragmentShader,
+		func() bool { return g_Settings.colorblind_mode != COLORBLIND_OFF },
+		func(pass *PostPass) {
+			gl.Uniform1i(pass.uniform("mode"), int32(g_Settings.colorblind_mode))
+			simulate := int32(0)
+			if g_Settings.colorblind_simulate {
+				simulate = 1
+			}
+			gl.Uniform1i(pass.uniform("simulate"), simulate)
+		})
+
+	register_command("colorblind", "[mode]: off, protanopia, deuteranopia or tritanopia", func(args []string) {
+		if len(args) == 1 {
+			mode, ok := parse_colorblind_mode(args[0])
+			if !ok {
+				console_print("colorblind: unknown mode %q", args[0])
+				return
+			}
+			g_Settings.colorblind_mode = mode
+		}
+		console_print("colorblind = %s", colorblindModeNames[g_Settings.colorblind_mode])
+	})
+	register_command("colorblind_simulate", "show the frame as seen with the colorblind mode, instead of compensating", func() {
+		g_Settings.colorblind_simulate = !g_Settings.colorblind_simulate
+		console_print("colorblind_simulate = %t", g_Settings.colorblind_simulate)
+	})
+}
+
+// Shapes instead of colors tell interactive entities apart: hazards get a crossed box, pickups and
+// collectibles a ring. Each line is drawn dark and light so it stands out on any background.
+func draw_high_contrast_outlines() {
+	if !g_Settings.high_contrast {
+		return
+	}
+
+	visible := camera_visible_bounds()
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		components := entity.prefab.Components
+		if components.Collider == nil || entity.picked {
+			continue
+		}
+		bb := dynamic_entity_bounding_box(entity)
+		if !bb.intersects(visible) {
+			continue
+		}
+
+		if components.Hazard != nil {
+			draw_hazard_outline(bb)
+		} else if components.Pickup != nil {
+			draw_pickup_outline(bb.center(), bb.size().x/2)
+		}
+	}
+	for _, collectible := range g_Map.collectibles {
+		if !collectible.collected && collectible.bb.intersects(visible) {
+			draw_pickup_outline(collectible.pos, collectibleHalfSize)
+		}
+	}
+}
+
+var highContrastDark = color.RGBA{0, 0, 0, 255}
+var highContrastLight = color.RGBA{255, 255, 255, 255}
+
+const highContrastGap = 0.06
+
+func draw_hazard_outline(bb BoundingBox2D) {
+	outer := bb.expand(Vector2DF{highContrastGap * 2, highContrastGap * 2})
+	inner := bb.expand(Vector2DF{highContrastGap, highContrastGap})
+	draw_rect(outer, highContrastDark)
+	draw_rect(inner, highContrastLight)
+
+	lower, upper := inner.min_corner(), inner.max_corner()
+	draw_line(lower, upper, highContrastLight)
+	draw_line(Vector2DF{lower.x, upper.y}, Vector2DF{upper.x, lower.y}, highContrastLight)
+}
+
+func draw_pickup_outline(center Vector2DF, radius float32) {
+	draw_circle(center, radius+highContrastGap*2, highContrastDark)
+	draw_circle(center, radius+highContrastGap, highContrastLight)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"image/color"
+	"log"
+	"time"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+type Achievement struct {
+	id          string
+	name        string
+	description string
+
+	event GameEventType
+	// Called for every matching event, may accumulate into progress, returns true to unlock
+	condition func(event GameEvent, progress *float32) bool
+}
+
+var g_Achievements = []Achievement{
+	{
+		id: "first_jump", name: "Lift Off", description: "Jump for the first time",
+		event:     EVENT_PLAYER_JUMPED,
+		condition: func(event GameEvent, progress *float32) bool { return true },
+	},
+	{
+		id: "hundred_jumps", name: "Kangaroo", description: "Jump 100 times",
+		event: EVENT_PLAYER_JUMPED,
+		condition: func(event GameEvent, progress *float32) bool {
+			*progress += 1
+			return *progress >= 100
+		},
+	},
+	{
+		id: "hard_landing", name: "Brace for Impact", description: "Land at a very high speed",
+		event:     EVENT_PLAYER_LANDED,
+		condition: func(event GameEvent, progress *float32) bool { return event.magnitude >= 35 },
+	},
+}
+
+type AchievementToast struct {
+	achievement *Achievement
+	shown_at    time.Time
+}
+
+var g_AchievementToasts = []AchievementToast{}
+var g_AchievementToastTexture = uint32(0)
+
+const achievementToastDuration = 4 * time.Second
+
+func init_achievements() {
+	g_AchievementToastTexture = render.SolidTexture(color.RGBA{30, 30, 30, 220})
+
+	for i := range g_Achievements {
+		achievement := &g_Achievements[i]
+
+		subscribe_event(achievement.event, func(event GameEvent) {
+			if _, unlocked := g_SaveData.Achievements[achievement.id]; unlocked {
+				return
+			}
+
+			progress := g_SaveData.AchievementProgress[achievement.id]
+			unlocked := achievement.condition(event, &progress)
+			g_SaveData.AchievementProgress[achievement.id] = progress
+
+			if unlocked {
+				unlock_achievement(achievement)
+			}
+		})
+	}
+
+	register_command("achievements", "list achievements and which are unlocked", func() {
+		for _, achievement := range g_Achievements {
+			status := "locked"
+			if unlocked_at, ok := g_SaveData.Achievements[achievement.id]; ok {
+				status = unlocked_at.Format("2006-01-02")
+			}
+			console_print("%s - %s (%s)", achievement.name, achievement.description, status)
+		}
+	})
+}
+
+func unlock_achievement(achievement *Achievement) {
+	g_SaveData.Achievements[achievement.id] = time.Now()
+	delete(g_SaveData.AchievementProgress, achievement.id)
+	write_save_data()
+
+	log.Printf("achievement unlocked: %s", achievement.name)
+	g_AchievementToasts = append(g_AchievementToasts, AchievementToast{achievement: achievement})
+}
+
+// Toasts are shown one at a time in the top right corner, sliding in and out
+func render_achievement_toasts() {
+	if len(g_AchievementToasts) == 0 {
+		return
+	}
+
+	toast := &g_AchievementToasts[0]
+	if toast.shown_at.IsZero() {
+		toast.shown_at = time.Now()
+	}
+
+	age := time.Since(toast.shown_at)
+	if age > achievementToastDuration {
+		g_AchievementToasts = g_AchievementToasts[1:]
+		return
+	}
+
+	const width, height, slide_time = float32(260), float32(44), float32(0.25)
+	visible := float32(1)
+	seconds := float32(age.Seconds())
+	remaining := float32((achievementToastDuration - age).Seconds())
+	if seconds < slide_time {
+		visible = seconds / slide_time
+	} else if remaining < slide_time {
+		visible = remaining / slide_time
+	}
+
+	x := ui_size().x - width*visible - 8*visible
+	y := float32(8)
+
+	draw_overlay_quad(g_AchievementToastTexture, x, y, width, height)
+	draw_text("Achievement unlocked", x+8, y+6, 1, color.RGBA{255, 210, 80, 255})
+	draw_text(toast.achievement.name, x+8, y+24, 1, color.RGBA{255, 255, 255, 255})
+}
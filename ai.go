@@ -0,0 +1,144 @@
+package main
+
+// What the guard actions remember between steps. Guards fly along their waypoints, pausing at
+// each, chase the player once they see them and go back to their route after losing them.
+type AIState struct {
+	waypoint int
+	idle     float32
+	// Seconds since the player was last seen, only counted once they have been
+	seen      bool
+	unseen    float32
+	last_seen Vector2DF
+
+	path []Vector2DF
+	// Seconds until the path to the player is searched again
+	repath float32
+	// Set while chasing, patrolling after a chase first paths back to the route
+	chased bool
+}
+
+// The player keeps moving, so chase paths go stale quickly
+const aiRepathInterval = 0.5
+
+// Close enough to a point to move on to the next one
+const aiArriveDistance = 0.1
+
+var g_BTActions = map[string]func(context *BTContext) BTStatus{
+	// Succeeds while the player is within sight and not hidden behind blocks
+	"sees_player": func(context *BTContext) BTStatus {
+		state, pos := context.entity.ai, context.entity.pos
+		target := nearest_player(pos).pos
+		if pos.distance(target) <= context.param("sight") && line_of_sight(pos, target) {
+			state.seen = true
+			state.unseen = 0
+			state.last_seen = target
+			return BT_SUCCESS
+		}
+		state.unseen += context.dt
+		return BT_FAILURE
+	},
+	// Succeeds for lose seconds after the player was last seen, needs sees_player ticked every step
+	"recently_saw_player": func(context *BTContext) BTStatus {
+		if state := context.entity.ai; state.seen && state.unseen < context.param("lose") {
+			return BT_SUCCESS
+		}
+		return BT_FAILURE
+	},
+	// Succeeds while the entity's perception is at least at alertness, taking what it noticed as
+	// where the player was last seen
+	"alerted": func(context *BTContext) BTStatus {
+		entity := context.entity
+		if entity.perception == nil || entity.perception.alertness < context.param("alertness") {
+			return BT_FAILURE
+		}
+		entity.ai.last_seen = entity.perception.clue
+		return BT_SUCCESS
+	},
+	// Drifts over to where the player was last seen for a look, at patrol speed
+	"investigate": func(context *BTContext) BTStatus {
+		entity, state := context.entity, context.entity.ai
+		state.chased = true
+		move_towards(entity, state.last_seen, context.param("speed")*context.dt)
+		return BT_RUNNING
+	},
+	// Heads for where the player was last seen
+	"chase": func(context *BTContext) BTStatus {
+		entity, state := context.entity, context.entity.ai
+		state.chased = true
+		state.repath -= context.dt
+		if state.repath <= 0 {
+			state.path = map_navmesh().find_path(entity.pos, state.last_seen)
+			state.repath = aiRepathInterval
+		}
+		step := context.param("chase_speed") * context.dt
+		if len(state.path) > 0 {
+			follow_path(entity, &state.path, step)
+		} else {
+			move_towards(entity, state.last_seen, step)
+		}
+		return BT_RUNNING
+	},
+	"patrol": func(context *BTContext) BTStatus {
+		entity, state := context.entity, context.entity.ai
+		target := guard_waypoint(entity, context.behavior, state.waypoint)
+		step := context.param("speed") * context.dt
+
+		if state.chased {
+			state.chased = false
+			state.idle = 0
+			state.path = map_navmesh().find_path(entity.pos, target)
+		}
+		// Straight back when there's no path, guards fly through blocks rather than get stuck
+		if len(state.path) > 0 {
+			follow_path(entity, &state.path, step)
+			return BT_RUNNING
+		}
+
+		if state.idle > 0 {
+			state.idle -= context.dt
+			return BT_RUNNING
+		}
+		if move_towards(entity, target, step) {
+			state.idle = context.param("idle")
+			state.waypoint = (state.waypoint + 1) % max(len(context.behavior.Waypoints), 1)
+		}
+		return BT_RUNNING
+	},
+}
+
+// Waypoints are relative to the spawn point, without any the guard keeps to it
+func guard_waypoint(entity *DynamicEntity, behavior *BehaviorComponent, index int) Vector2DF {
+	if len(behavior.Waypoints) == 0 {
+		return entity.origin
+	}
+	waypoint := behavior.Waypoints[index%len(behavior.Waypoints)]
+	return entity.origin.add(Vector2DF{waypoint[0], waypoint[1]})
+}
+
+// Returns true once the entity is at target
+func move_towards(entity *DynamicEntity, target Vector2DF, step float32) bool {
+	offset := target.subtract(entity.pos)
+	if offset.length() <= max(step, aiArriveDistance) {
+		entity.pos = target
+		return true
+	}
+	entity.pos = entity.pos.add(offset.normalized().mul_scalar(step))
+	return false
+}
+
+// Moves along the path, dropping the points reached. Returns true once it's all walked.
+func follow_path(entity *DynamicEntity, path *[]Vector2DF, step float32) bool {
+	for len(*path) > 0 {
+		target := (*path)[0]
+		distance := entity.pos.distance(target)
+		if !move_towards(entity, target, step) {
+			return false
+		}
+		*path = (*path)[1:]
+		step -= distance
+		if step <= 0 {
+			break
+		}
+	}
+	return len(*path) == 0
+}
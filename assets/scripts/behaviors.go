@@ -0,0 +1,24 @@
+//go:build ignore
+
+// Gameplay scripts, reloaded while the game runs whenever this file changes.
+// Behavior functions take the frame time and read or assign the entity's
+// x, y, origin_x, origin_y and age, prefab parameters are read with param.
+package scripts
+
+// Flies a figure eight around the spawn point
+func figure_eight(dt float64) {
+	radius := param("range")
+	t := age * param("speed")
+
+	x = origin_x + sin(t)*radius
+	y = origin_y + sin(t*2)*radius*0.5
+}
+
+// Hops along the ground in short arcs
+func hopper(dt float64) {
+	period := 1 / param("speed")
+	phase := age/period - floor(age/period)
+
+	x = origin_x + sin(age*0.5)*param("range")
+	y = origin_y + abs(sin(phase*3.14159))*param("height")
+}
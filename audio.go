@@ -0,0 +1,691 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/jfreymuth/oggvorbis"
+)
+
+const audioSampleRate = 44100
+const audioBufferFrames = 1024
+
+// Interleaved stereo float samples in [-1, 1] at the stream's own sample rate
+type AudioStream interface {
+	read(frames []float32) (int, error)
+	sample_rate() int
+	rewind() error
+	close()
+}
+
+type AudioDevice interface {
+	// Blocks until the device accepts the samples, which paces the mixer
+	write(samples []int16) error
+	close()
+}
+
+type NullAudioDevice struct{}
+
+func (NullAudioDevice) write(samples []int16) error {
+	time.Sleep(time.Duration(len(samples)/2) * time.Second / audioSampleRate)
+	return nil
+}
+
+func (NullAudioDevice) close() {}
+
+// Pipes raw PCM into a system player, there is no native audio library among our dependencies
+type PipeAudioDevice struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	bytes []byte
+}
+
+func new_pipe_audio_device() (AudioDevice, error) {
+	players := [][]string{
+		{"pacat", "--raw", "--format=s16le", "--rate=44100", "--channels=2", "--latency-msec=50"},
+		{"aplay", "-q", "-t", "raw", "-f", "S16_LE", "-r", "44100", "-c", "2"},
+	}
+
+	for _, player := range players {
+		if _, err := exec.LookPath(player[0]); err != nil {
+			continue
+		}
+
+		cmd := exec.Command(player[0], player[1:]...)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+
+		return &PipeAudioDevice{cmd: cmd, stdin: stdin}, nil
+	}
+
+	return nil, errors.New("no audio output available")
+}
+
+func (device *PipeAudioDevice) write(samples []int16) error {
+	device.bytes = device.bytes[:0]
+	for _, sample := range samples {
+		device.bytes = binary.LittleEndian.AppendUint16(device.bytes, uint16(sample))
+	}
+	_, err := device.stdin.Write(device.bytes)
+	return err
+}
+
+func (device *PipeAudioDevice) close() {
+	device.stdin.Close()
+	device.cmd.Wait()
+}
+
+type AudioBus int32
+
+const (
+	AUDIO_BUS_MASTER = iota
+	AUDIO_BUS_MUSIC
+	AUDIO_BUS_SFX
+	AUDIO_BUS_UI
+	AUDIO_BUS_COUNT
+)
+
+var audioBusNames = [AUDIO_BUS_COUNT]string{"master", "music", "sfx", "ui"}
+
+type Voice struct {
+	bus AudioBus
+
+	stream AudioStream
+	// Played once stream ends, then looped (intro + loop music)
+	next AudioStream
+	loop bool
+
+	buffer   []float32
+	buffered int
+	position float64
+	pitch    float64
+
+	gain float32
+
+	// Positional voices follow the emitter, their channel gains are refreshed every frame
+	emitter    func() Vector2DF
+	left_gain  float32
+	right_gain float32
+
+	fade_from     float32
+	fade_to       float32
+	fade_duration float32
+	fade_elapsed  float32
+
+	stop_after_fade bool
+	done            bool
+}
+
+func new_voice(bus AudioBus, stream AudioStream, gain float32, loop bool) *Voice {
+	return &Voice{
+		bus:        bus,
+		stream:     stream,
+		loop:       loop,
+		buffer:     make([]float32, audioBufferFrames*2),
+		pitch:      1,
+		gain:       gain,
+		left_gain:  1,
+		right_gain: 1,
+		fade_from:  1,
+		fade_to:    1,
+	}
+}
+
+func (voice *Voice) fade(to float32, duration float32, stop bool) {
+	voice.fade_from = voice.fade_volume()
+	voice.fade_to = to
+	voice.fade_duration = duration
+	voice.fade_elapsed = 0
+	voice.stop_after_fade = stop
+}
+
+func (voice *Voice) fade_volume() float32 {
+	if voice.fade_elapsed >= voice.fade_duration {
+		return voice.fade_to
+	}
+	return voice.fade_from + (voice.fade_to-voice.fade_from)*voice.fade_elapsed/voice.fade_duration
+}
+
+// Refills the source buffer, keeping the last frame around for interpolation
+func (voice *Voice) refill() bool {
+	keep := 0
+	if voice.buffered > 0 {
+		copy(voice.buffer[0:2], voice.buffer[(voice.buffered-1)*2:voice.buffered*2])
+		keep = 1
+	}
+	voice.position -= float64(voice.buffered - keep)
+	voice.buffered = keep
+
+	// Guards against spinning on a looped stream that yields no frames
+	rewound := false
+
+	for voice.buffered < len(voice.buffer)/2 {
+		n, err := voice.stream.read(voice.buffer[voice.buffered*2:])
+		voice.buffered += n
+		if n > 0 {
+			rewound = false
+		}
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, io.EOF) {
+			log.Println("audio stream:", err)
+			return voice.buffered > keep
+		}
+
+		if voice.next != nil {
+			voice.stream.close()
+			voice.stream, voice.next = voice.next, nil
+			voice.loop = true
+		} else if rewound || !voice.loop || voice.stream.rewind() != nil {
+			return voice.buffered > keep
+		} else {
+			rewound = true
+		}
+	}
+	return true
+}
+
+// Adds the voice's output to the interleaved stereo buffer, with a per channel gain
+func (voice *Voice) mix_into(out []float32, left_gain float32, right_gain float32) {
+	step := voice.pitch * float64(voice.stream.sample_rate()) / audioSampleRate
+	frame_time := float32(1) / audioSampleRate
+
+	for i := 0; i < len(out)/2; i++ {
+		for int(voice.position)+1 >= voice.buffered {
+			if !voice.refill() {
+				voice.done = true
+				return
+			}
+		}
+
+		index := int(voice.position)
+		t := float32(voice.position - float64(index))
+		volume := voice.gain * voice.fade_volume()
+
+		left := voice.buffer[index*2]*(1-t) + voice.buffer[index*2+2]*t
+		right := voice.buffer[index*2+1]*(1-t) + voice.buffer[index*2+3]*t
+
+		out[i*2] += left * volume * left_gain
+		out[i*2+1] += right * volume * right_gain
+
+		voice.position += step
+		voice.fade_elapsed += frame_time
+	}
+
+	if voice.stop_after_fade && voice.fade_elapsed >= voice.fade_duration {
+		voice.done = true
+	}
+}
+
+type Mixer struct {
+	mutex  sync.Mutex
+	voices []*Voice
+
+	// Ducking lowers a bus temporarily, see step_audio_ducking
+	duck_target  [AUDIO_BUS_COUNT]float32
+	duck_current [AUDIO_BUS_COUNT]float32
+
+	focused bool
+
+	device  AudioDevice
+	running bool
+	stopped chan struct{}
+}
+
+var g_Mixer = Mixer{}
+
+func init_audio() {
+	device, err := new_pipe_audio_device()
+	if err != nil {
+		log.Println("audio muted:", err)
+		device = NullAudioDevice{}
+	}
+
+	g_Mixer.device = device
+	g_Mixer.focused = true
+	for bus := range g_Mixer.duck_target {
+		g_Mixer.duck_target[bus] = 1
+		g_Mixer.duck_current[bus] = 1
+	}
+	g_Mixer.running = true
+	g_Mixer.stopped = make(chan struct{})
+
+	go mixer_loop()
+
+	init_music()
+	init_event_sounds("sounds.json")
+
+	register_command("volume", "[bus] [volume]: set a bus volume from 0 to 1, or list them", func(args []string) {
+		if len(args) == 2 {
+			value, err := strconv.ParseFloat(args[1], 32)
+			bus := audio_bus_by_name(args[0])
+			if err != nil || bus < 0 {
+				console_print("usage: volume master|music|sfx|ui 0..1")
+				return
+			}
+			set_audio_bus_volume(bus, float32(value))
+		}
+		for bus, name := range audioBusNames {
+			console_print("%s = %g", name, g_Settings.audio_volumes[bus])
+		}
+	})
+}
+
+func audio_bus_by_name(name string) AudioBus {
+	for bus, bus_name := range audioBusNames {
+		if bus_name == name {
+			return AudioBus(bus)
+		}
+	}
+	return -1
+}
+
+func set_audio_bus_volume(bus AudioBus, volume float32) {
+	g_Mixer.mutex.Lock()
+	defer g_Mixer.mutex.Unlock()
+
+	g_Settings.audio_volumes[bus] = min(max(volume, 0), 1)
+}
+
+func duck_audio_bus(bus AudioBus, level float32) {
+	g_Mixer.mutex.Lock()
+	defer g_Mixer.mutex.Unlock()
+
+	g_Mixer.duck_target[bus] = min(max(level, 0), 1)
+}
+
+func unduck_audio_bus(bus AudioBus) {
+	duck_audio_bus(bus, 1)
+}
+
+// How far the music drops while the console or chat is open or a camera path plays
+const musicDuckLevel = float32(0.35)
+
+// Call once per frame
+func step_audio_ducking() {
+	if g_Console.open || g_Chat.open || camera_path_playing() {
+		duck_audio_bus(AUDIO_BUS_MUSIC, musicDuckLevel)
+	} else {
+		unduck_audio_bus(AUDIO_BUS_MUSIC)
+	}
+}
+
+func set_audio_focus(focused bool) {
+	g_Mixer.mutex.Lock()
+	defer g_Mixer.mutex.Unlock()
+
+	g_Mixer.focused = focused
+}
+
+const audioDuckRate = float32(4) // Full duck range per second
+
+// Must be called with the mixer locked
+func mixer_bus_gains() [AUDIO_BUS_COUNT]float32 {
+	step := audioDuckRate * audioBufferFrames / audioSampleRate
+
+	gains := [AUDIO_BUS_COUNT]float32{}
+	for bus := range gains {
+		current := g_Mixer.duck_current[bus]
+		target := g_Mixer.duck_target[bus]
+		if current < target {
+			current = min(current+step, target)
+		} else {
+			current = max(current-step, target)
+		}
+		g_Mixer.duck_current[bus] = current
+
+		gains[bus] = g_Settings.audio_volumes[bus] * current
+	}
+
+	master := gains[AUDIO_BUS_MASTER]
+	if !g_Mixer.focused && g_Settings.audio_mute_on_focus_loss {
+		master = 0
+	}
+	for bus := range gains {
+		if bus != AUDIO_BUS_MASTER {
+			gains[bus] *= master
+		}
+	}
+
+	return gains
+}
+
+func close_audio() {
+	g_Mixer.mutex.Lock()
+	g_Mixer.running = false
+	g_Mixer.mutex.Unlock()
+
+	<-g_Mixer.stopped
+
+	for _, voice := range g_Mixer.voices {
+		voice.stream.close()
+		if voice.next != nil {
+			voice.next.close()
+		}
+	}
+	g_Mixer.voices = nil
+	g_Mixer.device.close()
+}
+
+func mixer_add_voice(voice *Voice) {
+	g_Mixer.mutex.Lock()
+	defer g_Mixer.mutex.Unlock()
+
+	g_Mixer.voices = append(g_Mixer.voices, voice)
+}
+
+func mixer_loop() {
+	defer close(g_Mixer.stopped)
+
+	mixed := make([]float32, audioBufferFrames*2)
+	samples := make([]int16, audioBufferFrames*2)
+
+	for {
+		clear(mixed)
+
+		g_Mixer.mutex.Lock()
+		if !g_Mixer.running {
+			g_Mixer.mutex.Unlock()
+			return
+		}
+
+		bus_gains := mixer_bus_gains()
+
+		alive := g_Mixer.voices[:0]
+		for _, voice := range g_Mixer.voices {
+			bus_gain := bus_gains[voice.bus]
+			voice.mix_into(mixed, voice.left_gain*bus_gain, voice.right_gain*bus_gain)
+
+			if voice.done {
+				voice.stream.close()
+				continue
+			}
+			alive = append(alive, voice)
+		}
+		clear(g_Mixer.voices[len(alive):])
+		g_Mixer.voices = alive
+		g_Mixer.mutex.Unlock()
+
+		for i, sample := range mixed {
+			samples[i] = int16(min(max(sample, -1), 1) * 32767)
+		}
+
+		if err := g_Mixer.device.write(samples); err != nil {
+			log.Println("audio device:", err)
+			g_Mixer.device.close()
+			g_Mixer.device = NullAudioDevice{}
+		}
+	}
+}
+
+func open_audio_stream(path string) (AudioStream, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		return open_wav_stream(path)
+	case ".ogg":
+		return open_ogg_stream(path)
+	case ".mp3":
+		return open_mp3_stream(path)
+	default:
+		return nil, fmt.Errorf("no decoder for %q, only .wav, .ogg and .mp3 are supported", path)
+	}
+}
+
+// Decodes PCM WAV files incrementally from disk
+type WavStream struct {
+	file *os.File
+
+	channels        int
+	bits_per_sample int
+	rate            int
+
+	data_offset int64
+	data_size   int64
+	remaining   int64
+
+	bytes []byte
+}
+
+func open_wav_stream(path string) (*WavStream, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := parse_wav_header(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return stream, nil
+}
+
+func parse_wav_header(file *os.File) (*WavStream, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF WAVE file")
+	}
+
+	stream := &WavStream{file: file}
+	offset := int64(12)
+	chunk := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			return nil, errors.New("missing data chunk")
+		}
+		offset += 8
+
+		chunk_size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			format := make([]byte, chunk_size)
+			if _, err := io.ReadFull(file, format); err != nil || chunk_size < 16 {
+				return nil, errors.New("invalid fmt chunk")
+			}
+			if binary.LittleEndian.Uint16(format[0:2]) != 1 {
+				return nil, errors.New("only PCM wav is supported")
+			}
+			stream.channels = int(binary.LittleEndian.Uint16(format[2:4]))
+			stream.rate = int(binary.LittleEndian.Uint32(format[4:8]))
+			stream.bits_per_sample = int(binary.LittleEndian.Uint16(format[14:16]))
+		case "data":
+			if stream.channels < 1 || stream.channels > 2 || (stream.bits_per_sample != 8 && stream.bits_per_sample != 16) {
+				return nil, errors.New("unsupported wav format")
+			}
+			stream.data_offset = offset
+			stream.data_size = chunk_size
+			stream.remaining = chunk_size
+			return stream, nil
+		default:
+			if _, err := file.Seek(chunk_size+chunk_size%2, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+		offset += chunk_size + chunk_size%2
+	}
+}
+
+func (stream *WavStream) read(frames []float32) (int, error) {
+	bytes_per_sample := stream.bits_per_sample / 8
+	frame_bytes := bytes_per_sample * stream.channels
+
+	wanted := min(int64(len(frames)/2*frame_bytes), stream.remaining)
+	wanted -= wanted % int64(frame_bytes)
+	if wanted == 0 {
+		return 0, io.EOF
+	}
+
+	if cap(stream.bytes) < int(wanted) {
+		stream.bytes = make([]byte, wanted)
+	}
+	n, err := io.ReadFull(stream.file, stream.bytes[:wanted])
+	stream.remaining -= int64(n)
+	if err != nil {
+		return 0, err
+	}
+
+	count := n / frame_bytes
+	for i := 0; i < count; i++ {
+		for channel := 0; channel < 2; channel++ {
+			source := min(channel, stream.channels-1)
+			offset := i*frame_bytes + source*bytes_per_sample
+
+			if bytes_per_sample == 1 {
+				frames[i*2+channel] = (float32(stream.bytes[offset]) - 128) / 128
+			} else {
+				frames[i*2+channel] = float32(int16(binary.LittleEndian.Uint16(stream.bytes[offset:]))) / 32768
+			}
+		}
+	}
+
+	return count, nil
+}
+
+func (stream *WavStream) sample_rate() int {
+	return stream.rate
+}
+
+func (stream *WavStream) rewind() error {
+	_, err := stream.file.Seek(stream.data_offset, io.SeekStart)
+	stream.remaining = stream.data_size
+	return err
+}
+
+func (stream *WavStream) close() {
+	stream.file.Close()
+}
+
+// Decodes Ogg Vorbis files incrementally from disk, mono is spread to both channels and channels
+// past the second are dropped
+type OggStream struct {
+	file   *os.File
+	reader *oggvorbis.Reader
+
+	samples []float32
+}
+
+func open_ogg_stream(path string) (*OggStream, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := oggvorbis.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return &OggStream{file: file, reader: reader}, nil
+}
+
+func (stream *OggStream) read(frames []float32) (int, error) {
+	channels := stream.reader.Channels()
+
+	wanted := len(frames) / 2 * channels
+	if cap(stream.samples) < wanted {
+		stream.samples = make([]float32, wanted)
+	}
+	n, err := stream.reader.Read(stream.samples[:wanted])
+
+	count := n / channels
+	for i := 0; i < count; i++ {
+		for channel := 0; channel < 2; channel++ {
+			frames[i*2+channel] = stream.samples[i*channels+min(channel, channels-1)]
+		}
+	}
+
+	return count, err
+}
+
+func (stream *OggStream) sample_rate() int {
+	return stream.reader.SampleRate()
+}
+
+func (stream *OggStream) rewind() error {
+	return stream.reader.SetPosition(0)
+}
+
+func (stream *OggStream) close() {
+	stream.file.Close()
+}
+
+// Decodes MP3 files incrementally from disk, the decoder always yields 16 bit stereo
+type Mp3Stream struct {
+	file    *os.File
+	decoder *mp3.Decoder
+
+	bytes []byte
+}
+
+func open_mp3_stream(path string) (*Mp3Stream, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := mp3.NewDecoder(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return &Mp3Stream{file: file, decoder: decoder}, nil
+}
+
+func (stream *Mp3Stream) read(frames []float32) (int, error) {
+	const frame_bytes = 4
+
+	wanted := len(frames) / 2 * frame_bytes
+	if cap(stream.bytes) < wanted {
+		stream.bytes = make([]byte, wanted)
+	}
+	n, err := io.ReadFull(stream.decoder, stream.bytes[:wanted])
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+
+	count := n / frame_bytes
+	for i := 0; i < count*2; i++ {
+		frames[i] = float32(int16(binary.LittleEndian.Uint16(stream.bytes[i*2:]))) / 32768
+	}
+
+	return count, err
+}
+
+func (stream *Mp3Stream) sample_rate() int {
+	return stream.decoder.SampleRate()
+}
+
+func (stream *Mp3Stream) rewind() error {
+	_, err := stream.decoder.Seek(0, io.SeekStart)
+	return err
+}
+
+func (stream *Mp3Stream) close() {
+	stream.file.Close()
+}
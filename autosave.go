@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Snapshot of the run in progress, written on its own next to the progression save. Slots are
+// overwritten in turn, so a save taken at a bad moment still leaves the older ones.
+type Autosave struct {
+	Level string `json:"level"`
+	Seed  int64  `json:"seed"`
+
+	Checkpoint int        `json:"checkpoint"`
+	Position   [2]float32 `json:"position"`
+	Collected  []int      `json:"collected"`
+	GameTime   float32    `json:"game_time"`
+	Completed  bool       `json:"completed"`
+
+	// checkpoint, completed or quit
+	Reason  string    `json:"reason"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+type Autosaves struct {
+	next_slot int
+}
+
+var g_Autosaves = Autosaves{}
+
+const autosaveSlots = 3
+
+func autosave_path(slot int) string {
+	return filepath.Join(save_directory(), fmt.Sprintf("autosave_%d.json", slot))
+}
+
+// Continues after the newest slot
+func init_autosave_slot() {
+	g_Autosaves.next_slot = 0
+	if newest, _, ok := newest_autosave(); ok {
+		g_Autosaves.next_slot = (newest + 1) % autosaveSlots
+	}
+}
+
+func init_autosave() {
+	init_autosave_slot()
+
+	subscribe_event(EVENT_CHECKPOINT_REACHED, func(event GameEvent) { write_autosave("checkpoint") })
+	subscribe_event(EVENT_LEVEL_COMPLETED, func(event GameEvent) { write_autosave("completed") })
+
+	register_command("autosaves", "list the autosave slots", func() {
+		for slot := 0; slot < autosaveSlots; slot++ {
+			if autosave, err := read_autosave(slot); err == nil {
+				console_print("%d: %s checkpoint %d, %s at %s", slot, autosave.Level, autosave.Checkpoint, autosave.Reason, autosave.SavedAt.Format(time.DateTime))
+			}
+		}
+	})
+	register_command("load_autosave", "[slot]: resume the newest autosave or the given slot", func(args []string) {
+		slot, _, ok := newest_autosave()
+		if len(args) == 1 {
+			ok = true
+			if _, err := fmt.Sscan(args[0], &slot); err != nil || slot < 0 || slot >= autosaveSlots {
+				console_print("load_autosave: slot must be from 0 up to %d", autosaveSlots-1)
+				return
+			}
+		}
+		if !ok {
+			console_print("load_autosave: no autosaves")
+			return
+		}
+
+		autosave, err := read_autosave(slot)
+		if err != nil {
+			console_print("load_autosave: %v", err)
+			return
+		}
+		resume_autosave(autosave)
+	})
+}
+
+// Deferred by main, so it runs however the session ends
+func write_quit_autosave() {
+	// Nothing worth keeping while the start screen is up
+	if !g_StartScreen.open {
+		write_autosave("quit")
+	}
+}
+
+// Progression is saved along with it, the same as when a session ends
+func write_autosave(reason string) {
+	if g_Cheats.used {
+		return
+	}
+
+	autosave := Autosave{
+		Level:      g_Level.Id,
+		Seed:       g_MapSeed,
+		Checkpoint: g_Map.reached_checkpoints,
+		Position:   [2]float32{g_Player.pos.x, g_Player.pos.y},
+		Collected:  []int{},
+		GameTime:   g_Speedrun.game_time,
+		Completed:  g_Map.completed,
+		Reason:     reason,
+		SavedAt:    time.Now(),
+	}
+	for i, collectible := range g_Map.collectibles {
+		if collectible.collected {
+			autosave.Collected = append(autosave.Collected, i)
+		}
+	}
+
+	if err := write_save_file(autosave_path(g_Autosaves.next_slot), autosave); err != nil {
+		console_print("autosave: %v", err)
+		return
+	}
+	g_Autosaves.next_slot = (g_Autosaves.next_slot + 1) % autosaveSlots
+	write_save_data()
+}
+
+func read_autosave(slot int) (Autosave, error) {
+	autosave := Autosave{}
+	data, err := os.ReadFile(autosave_path(slot))
+	if err != nil {
+		return autosave, err
+	}
+	if err := json.Unmarshal(data, &autosave); err != nil {
+		return autosave, fmt.Errorf("%s: %w", autosave_path(slot), err)
+	}
+	return autosave, nil
+}
+
+func newest_autosave() (int, Autosave, bool) {
+	newest, found := Autosave{}, -1
+	for slot := 0; slot < autosaveSlots; slot++ {
+		autosave, err := read_autosave(slot)
+		if err == nil && (found < 0 || autosave.SavedAt.After(newest.SavedAt)) {
+			newest, found = autosave, slot
+		}
+	}
+	return found, newest, found >= 0
+}
+
+// Levels that aren't in a pack anymore are rebuilt from the saved seed
+func resume_autosave(autosave Autosave) {
+	level, err := find_level(autosave.Level)
+	if err != nil {
+		level = custom_level(autosave.Level, autosave.Seed)
+	}
+	if g_StartScreen.open {
+		close_start_screen()
+	}
+	load_level(level, func() { restore_autosave(autosave) })
+}
+
+// Puts the player back where the autosave left them once its level is loaded
+func restore_autosave(autosave Autosave) {
+	g_Player.pos = Vector2DF{autosave.Position[0], autosave.Position[1]}
+	g_Player.has_previous_transform = false
+	update_player_bounding_box(&g_Player)
+	g_Stats.last_pos = g_Player.pos
+	// Cut to the player instead of panning over from the spawn
+	g_Camera.pos2D = clamp_camera_to_bounds(g_Player.pos, g_Map.bounds)
+	g_Camera.previous_pos2D = g_Camera.pos2D
+	g_Camera.look_ahead = Vector2DF{}
+	g_Map.reached_checkpoints = min(autosave.Checkpoint, len(g_Map.checkpoints))
+	g_Map.completed = autosave.Completed
+	for _, i := range autosave.Collected {
+		if i >= 0 && i < len(g_Map.collectibles) {
+			g_Map.collectibles[i].collected = true
+		}
+	}
+	g_Speedrun.game_time = autosave.GameTime
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Decides what an entity does each step, run by the tree behavior. The root is ticked from the top
+// every step, so a branch that starts succeeding again takes over right away.
+type BehaviorTree struct {
+	Id   string  `json:"id"`
+	Root *BTNode `json:"root"`
+}
+
+// Composites run their children in order: sequences until one doesn't succeed, selectors until
+// one doesn't fail. Decorators change the result of their only child. Actions are looked up by
+// name in g_BTActions.
+type BTNode struct {
+	Type     string    `json:"type"`
+	Children []*BTNode `json:"children"`
+	Action   string    `json:"action"`
+	// Defaults for the action, the behavior's params override them so prefabs can tune a shared tree
+	Params map[string]float32 `json:"params"`
+}
+
+type BTStatus int32
+
+const (
+	BT_SUCCESS = iota
+	BT_FAILURE
+	BT_RUNNING
+)
+
+const btSequence = "sequence"
+const btSelector = "selector"
+
+// Decorators
+const btInverter = "inverter"
+const btSucceeder = "succeeder"
+
+const btAction = "action"
+
+// What an action sees of the tree behavior ticking it
+type BTContext struct {
+	entity   *DynamicEntity
+	behavior *BehaviorComponent
+	node     *BTNode
+	dt       float32
+}
+
+var g_BehaviorTrees = map[string]*BehaviorTree{}
+
+// Behavior params first, then the node's
+func (context *BTContext) param(name string) float32 {
+	if value, ok := context.behavior.Params[name]; ok {
+		return value
+	}
+	return context.node.Params[name]
+}
+
+func load_behavior_trees(directory string) {
+	entries, err := g_VFS.ReadDir(directory)
+	if err != nil {
+		log.Println("behavior trees:", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		file_name := filepath.Join(directory, entry.Name())
+		data, err := os.ReadFile(asset_path(file_name))
+		if err != nil {
+			log.Println("behavior trees:", err)
+			continue
+		}
+
+		tree := &BehaviorTree{}
+		if err := json.Unmarshal(data, tree); err != nil {
+			log.Printf("behavior trees: %s: %v", file_name, json_error_context(data, err))
+			continue
+		}
+		if tree.Id == "" {
+			tree.Id = strings.TrimSuffix(entry.Name(), ".json")
+		}
+		if err := check_bt_node(tree.Root); err != nil {
+			log.Printf("behavior trees: %s: %v", file_name, err)
+			continue
+		}
+
+		g_BehaviorTrees[tree.Id] = tree
+	}
+}
+
+func check_bt_node(node *BTNode) error {
+	if node == nil {
+		return fmt.Errorf("missing node")
+	}
+
+	switch node.Type {
+	case btSequence, btSelector:
+		if len(node.Children) == 0 {
+			return fmt.Errorf("%s without children", node.Type)
+		}
+	case btInverter, btSucceeder:
+		if len(node.Children) != 1 {
+			return fmt.Errorf("%s needs exactly one child, got %d", node.Type, len(node.Children))
+		}
+	case btAction:
+		if g_BTActions[node.Action] == nil {
+			return fmt.Errorf("unknown action %q", node.Action)
+		}
+	default:
+		return fmt.Errorf("unknown node type %q", node.Type)
+	}
+
+	for _, child := range node.Children {
+		if err := check_bt_node(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tick_bt_node(node *BTNode, context *BTContext) BTStatus {
+	switch node.Type {
+	case btSequence:
+		for _, child := range node.Children {
+			if status := tick_bt_node(child, context); status != BT_SUCCESS {
+				return status
+			}
+		}
+		return BT_SUCCESS
+	case btSelector:
+		for _, child := range node.Children {
+			if status := tick_bt_node(child, context); status != BT_FAILURE {
+				return status
+			}
+		}
+		return BT_FAILURE
+	case btInverter:
+		switch tick_bt_node(node.Children[0], context) {
+		case BT_SUCCESS:
+			return BT_FAILURE
+		case BT_FAILURE:
+			return BT_SUCCESS
+		}
+		return BT_RUNNING
+	case btSucceeder:
+		tick_bt_node(node.Children[0], context)
+		return BT_SUCCESS
+	case btAction:
+		context.node = node
+		return g_BTActions[node.Action](context)
+	}
+	return BT_FAILURE
+}
+
+// Ticks the tree named by the behavior, entities keep what the actions remember in entity.ai
+func step_tree_behavior(entity *DynamicEntity, behavior *BehaviorComponent, dt float32) {
+	tree, ok := g_BehaviorTrees[behavior.Tree]
+	if !ok || entity.picked {
+		return
+	}
+	if entity.ai == nil {
+		entity.ai = &AIState{}
+	}
+	tick_bt_node(tree.Root, &BTContext{entity: entity, behavior: behavior, dt: dt})
+}
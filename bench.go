@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Uniform locations of the world shader, for modes that render outside the main loop
+type SceneUniforms struct {
+	projection int32
+	camera     int32
+	model      int32
+}
+
+// Fills the map with a square grid of blocks plus prefab entities, then times a
+// fixed number of simulation steps and rendered frames
+func run_bench(window *glfw.Window, projection mgl32.Mat4, uniforms SceneUniforms) {
+	blocks := config_int("bench_blocks")
+	entities := config_int("bench_entities")
+	frames := max(config_int("bench_frames"), 1)
+
+	build_bench_scene(blocks, entities, g_Config.String("bench_prefab"))
+
+	simulation_times := make([]time.Duration, 0, frames)
+	render_times := make([]time.Duration, 0, frames)
+
+	for frame := 0; frame < frames && !window.ShouldClose(); frame++ {
+		started := time.Now()
+		store_previous_transforms()
+		step_player(&g_Player, simulationStep)
+		step_camera(simulationStep)
+		step_map(simulationStep)
+		simulation_times = append(simulation_times, time.Since(started))
+
+		started = time.Now()
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+		gl.UniformMatrix4fv(uniforms.projection, 1, false, &projection[0])
+		update_camera_uniforms(uniforms.camera)
+		render_map(uniforms.model)
+		render_dynamic_entities(uniforms.model)
+		render_player(&g_Player, uniforms.model)
+		// Waits for the GPU so the time covers the draws and not just queuing them
+		gl.Finish()
+		render_times = append(render_times, time.Since(started))
+
+		window.SwapBuffers()
+		glfw.PollEvents()
+	}
+
+	fmt.Printf("bench: %d blocks, %d entities, %d frames\n", len(g_Map.entities), len(g_Map.dynamic_entities), len(simulation_times))
+	if g_Config.String("bench_format") == "go" {
+		print_go_bench("Simulation", simulation_times)
+		print_go_bench("Render", render_times)
+		return
+	}
+	print_bench_stats("simulation", simulation_times)
+	print_bench_stats("render", render_times)
+}
+
+func build_bench_scene(blocks int, entities int, prefab string) {
+	tileset := level_tileset()
+	// At least 1, the entities are laid out on the same grid even without blocks
+	side := max(int(math.Ceil(math.Sqrt(float64(blocks)))), 1)
+
+	g_Map.entities = nil
+	for i := 0; i < blocks; i++ {
+		pos := Vector2DF{float32(i%side-side/2) * 2, -float32(i/side) * 2}
+		bb := make_bounding_box_2d_centered(pos, Vector2DF{1, 1})
+		g_Map.entities = append(g_Map.entities, make_static_map_entity(pos, bb, tileset))
+	}
+	g_Map.bounds = compute_map_bounds(g_Map.entities)
+	g_Map.nav = nil
+	g_Map.navmesh = nil
+	g_Map.water = nil
+	g_Map.collectibles = nil
+	// Out of reach so the bench never finishes the level
+	g_Map.goal_x = float32(math.MaxFloat32)
+
+	spawn_level_entities()
+	for i := 0; i < entities; i++ {
+		spawn_prefab(prefab, Vector2DF{float32(i%side-side/2) * 2, float32(i/side)*2 + 4})
+	}
+
+	respawn_player(&g_Player)
+	g_Player.pos = Vector2DF{0, 4}
+}
+
+func bench_percentile(sorted []time.Duration, fraction float64) time.Duration {
+	return sorted[min(int(float64(len(sorted))*fraction), len(sorted)-1)]
+}
+
+func print_bench_stats(name string, times []time.Duration) {
+	if len(times) == 0 {
+		return
+	}
+	sorted := append([]time.Duration(nil), times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	total := time.Duration(0)
+	for _, t := range times {
+		total += t
+	}
+
+	fmt.Printf("%-10s mean %v  median %v  p95 %v  p99 %v  max %v\n", name,
+		total/time.Duration(len(times)), bench_percentile(sorted, 0.5), bench_percentile(sorted, 0.95),
+		bench_percentile(sorted, 0.99), sorted[len(sorted)-1])
+}
+
+// Same line format as go test -bench, so benchstat can compare runs
+func print_go_bench(name string, times []time.Duration) {
+	if len(times) == 0 {
+		return
+	}
+	total := time.Duration(0)
+	for _, t := range times {
+		total += t
+	}
+	fmt.Printf("Benchmark%s-%d\t%d\t%d ns/op\n", name, runtime.GOMAXPROCS(0), len(times), total.Nanoseconds()/int64(len(times)))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Textured quad turned to face the camera, drawn instead of or on top of the entity's cube
+type BillboardComponent struct {
+	Texture string     `json:"texture"`
+	Size    [2]float32 `json:"size"`
+	// Offset from the entity, z moves it towards the camera
+	Offset [3]float32 `json:"offset"`
+	// Only turns around the vertical axis, for things standing on the ground
+	Upright bool `json:"upright"`
+}
+
+type Billboard struct {
+	position mgl32.Vec3
+	size     mgl32.Vec2
+	texture  uint32
+	upright  bool
+}
+
+// Queued during the frame, render_billboards draws them back to front after the opaque geometry
+type Billboards struct {
+	quad    *Mesh
+	pending []Billboard
+
+	// View matrix of the frame, set by update_camera_uniforms
+	camera mgl32.Mat4
+}
+
+var g_Billboards = Billboards{camera: mgl32.Ident4()}
+
+func init_billboards() {
+	// Unit quad in the XY plane, facing +Z
+	vertices := []float32{
+		-0.5, -0.5, 0, 0, 1, 0, 0, 1,
+		0.5, -0.5, 0, 1, 1, 0, 0, 1,
+		0.5, 0.5, 0, 1, 0, 0, 0, 1,
+		-0.5, 0.5, 0, 0, 0, 0, 0, 1,
+	}
+	g_Billboards.quad = new_mesh(vertices, []uint32{0, 1, 2, 0, 2, 3}, 0)
+}
+
+func draw_billboard(position mgl32.Vec3, size mgl32.Vec2, texture uint32, upright bool) {
+	g_Billboards.pending = append(g_Billboards.pending, Billboard{position, size, texture, upright})
+}
+
+// Model matrix turning the quad towards the camera position. Facing the position rather than
+// copying the view rotation keeps billboards at the screen edges from looking sheared.
+func billboard_matrix(billboard Billboard, camera_position mgl32.Vec3) mgl32.Mat4 {
+	forward := camera_position.Sub(billboard.position)
+	if billboard.upright {
+		forward[1] = 0
+	}
+	if forward.Len() < 1e-4 {
+		forward = mgl32.Vec3{0, 0, 1}
+	}
+	forward = forward.Normalize()
+
+	up := mgl32.Vec3{0, 1, 0}
+	right := up.Cross(forward)
+	if right.Len() < 1e-4 {
+		// Looking straight down on it, any right axis works
+		right = mgl32.Vec3{1, 0, 0}
+	}
+	right = right.Normalize()
+	up = forward.Cross(right)
+
+	right = right.Mul(billboard.size[0])
+	up = up.Mul(billboard.size[1])
+	return mgl32.Mat4{
+		right[0], right[1], right[2], 0,
+		up[0], up[1], up[2], 0,
+		forward[0], forward[1], forward[2], 0,
+		billboard.position[0], billboard.position[1], billboard.position[2], 1,
+	}
+}
+
+func draw_entity_billboards() {
+	for _, entity := range g_Map.dynamic_entities {
+		billboard := entity.prefab.Components.Billboard
+		if billboard == nil || entity.picked {
+			continue
+		}
+		texture, err := load_texture(billboard.Texture)
+		if err != nil {
+			continue
+		}
+
+		position := scene_node_render_world(entity.node).Mul4x1(mgl32.Vec4{0, 0, 0, 1}).Vec3()
+		position = position.Add(mgl32.Vec3(billboard.Offset))
+		draw_billboard(position, mgl32.Vec2(billboard.Size), texture, billboard.Upright)
+	}
+}
+
+func render_billboards(model_uniform_location int32) {
+	if len(g_Billboards.pending) == 0 {
+		return
+	}
+
+	camera_position := g_Billboards.camera.Inv().Mul4x1(mgl32.Vec4{0, 0, 0, 1}).Vec3()
+	pending := g_Billboards.pending
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].position.Sub(camera_position).LenSqr() > pending[j].position.Sub(camera_position).LenSqr()
+	})
+
+	// Textures are premultiplied, depth writes stay off so overlapping quads don't cut each other
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
+
+	for _, billboard := range pending {
+		model := billboard_matrix(billboard, camera_position)
+		gl.UniformMatrix4fv(model_uniform_location, 1, false, &model[0])
+		g_Billboards.quad.texture = billboard.texture
+		g_Billboards.quad.draw()
+	}
+
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+	g_Billboards.pending = g_Billboards.pending[:0]
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/noise"
+)
+
+// A region of the generated part of a level with its own look and layout. Biomes sit at a point
+// of the temperature/moisture plane and a position gets the biome closest to its climate there.
+type Biome struct {
+	name string
+
+	temperature float32
+	moisture    float32
+
+	// Block texture, relative to the assets directory, empty uses the pack tileset
+	texture string
+
+	// Gaps are gap_min plus 0 to gap_steps - 1 steps of 2 units, the widest must stay jumpable
+	gap_min   float32
+	gap_steps int
+	// Blocks per platform are blocks_min plus 0 to blocks_variety - 1
+	blocks_min     int
+	blocks_variety int
+
+	// Models placed on top of platforms, each platform gets one with decoration_chance
+	decorations       []string
+	decoration_chance float32
+}
+
+var g_Biomes = []Biome{
+	{
+		name:              "temperate",
+		temperature:       0,
+		moisture:          0,
+		gap_min:           3,
+		gap_steps:         4,
+		blocks_min:        2,
+		blocks_variety:    4,
+		decorations:       []string{"models/signpost.obj"},
+		decoration_chance: 0.15,
+	},
+	{
+		name:              "desert",
+		temperature:       0.6,
+		moisture:          -0.5,
+		texture:           "textures/sand.png",
+		gap_min:           5,
+		gap_steps:         3,
+		blocks_min:        1,
+		blocks_variety:    3,
+		decorations:       []string{"models/signpost.obj"},
+		decoration_chance: 0.1,
+	},
+	{
+		name:              "tundra",
+		temperature:       -0.6,
+		moisture:          0,
+		texture:           "textures/snow.png",
+		gap_min:           3,
+		gap_steps:         3,
+		blocks_min:        3,
+		blocks_variety:    3,
+		decorations:       []string{"models/crystal.gltf"},
+		decoration_chance: 0.4,
+	},
+	{
+		name:              "swamp",
+		temperature:       0.3,
+		moisture:          0.6,
+		texture:           "textures/moss.png",
+		gap_min:           3,
+		gap_steps:         2,
+		blocks_min:        3,
+		blocks_variety:    4,
+		decorations:       []string{"models/crystal.gltf", "models/signpost.obj"},
+		decoration_chance: 0.3,
+	},
+}
+
+// Climate changes slowly compared to the terrain, a biome spans several platforms
+const biomeClimateFrequency = 0.012
+
+var biomeClimateFractal = noise.DefaultFractal(2)
+
+// Temperature and moisture fields of one generated map
+type BiomeMap struct {
+	temperature *noise.Simplex
+	moisture    *noise.Simplex
+}
+
+func new_biome_map(rng *rand.Rand) BiomeMap {
+	return BiomeMap{temperature: noise.NewSimplex(rng.Int63()), moisture: noise.NewSimplex(rng.Int63())}
+}
+
+func init_biomes() {
+	register_command("biome", "biome and climate at the player's position", func() {
+		if g_Map.biomes.temperature == nil {
+			console_print("this map has no biomes")
+			return
+		}
+		temperature, moisture := g_Map.biomes.climate(g_Player.pos)
+		console_print("%s (temperature %.2f, moisture %.2f)", g_Map.biomes.at(g_Player.pos).name, temperature, moisture)
+	})
+}
+
+func (biomes BiomeMap) climate(pos Vector2DF) (float32, float32) {
+	x, y := float64(pos.x)*biomeClimateFrequency, float64(pos.y)*biomeClimateFrequency
+	temperature := biomeClimateFractal.FBm2(biomes.temperature, x, y)
+	moisture := biomeClimateFractal.FBm2(biomes.moisture, x, y)
+	return float32(temperature), float32(moisture)
+}
+
+func (biomes BiomeMap) at(pos Vector2DF) *Biome {
+	temperature, moisture := biomes.climate(pos)
+
+	closest, closest_distance := &g_Biomes[0], float32(math.Inf(1))
+	for i := range g_Biomes {
+		biome := &g_Biomes[i]
+		dt, dm := biome.temperature-temperature, biome.moisture-moisture
+		if distance := dt*dt + dm*dm; distance < closest_distance {
+			closest, closest_distance = biome, distance
+		}
+	}
+	return closest
+}
+
+func (biome *Biome) block_texture(tileset string) string {
+	if biome.texture == "" {
+		return tileset
+	}
+	return asset_path(biome.texture)
+}
+
+// A decoration standing on the block at pos, slightly behind the platforms
+func (biome *Biome) decorate(level_map *Map, rng *rand.Rand, pos Vector2DF) {
+	if len(biome.decorations) == 0 || rng.Float32() >= biome.decoration_chance {
+		return
+	}
+	placement := LevelProp{
+		Model:    biome.decorations[rng.Intn(len(biome.decorations))],
+		X:        pos.x,
+		Y:        pos.y + 1,
+		Z:        -1.5,
+		Scale:    0.5 + 0.3*rng.Float32(),
+		Rotation: [3]float32{0, 0, (rng.Float32() - 0.5) * 20},
+	}
+	level_map.props = append(level_map.props, make_map_prop(placement))
+}
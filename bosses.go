@@ -0,0 +1,229 @@
+package main
+
+import "math"
+
+// Large enemy fought in a walled off arena. It takes stomps through its enemy component and
+// works through its phases as its health drops, cycling the current phase's attacks.
+type BossComponent struct {
+	// Shown above its health bar
+	Name   string      `json:"name"`
+	Phases []BossPhase `json:"phases"`
+	// Trigger volume relative to the boss, min x, min y, max x, max y. The fight starts once the
+	// player is all the way inside and walls keep them there until the boss is defeated.
+	Arena [4]float32 `json:"arena"`
+	// Solid prefab placed just outside of each side of the arena during the fight
+	Wall string `json:"wall"`
+}
+
+type BossPhase struct {
+	// The phase starts once the health fraction drops to this, phases go from highest to lowest
+	Health  float32      `json:"health"`
+	Attacks []BossAttack `json:"attacks"`
+	// Seconds of rest after each attack
+	Cooldown float32 `json:"cooldown"`
+}
+
+type BossAttack struct {
+	// bossAttackSpread or bossAttackCharge
+	Type string `json:"type"`
+
+	// For spreads, a fan of projectiles aimed at the player. Angle is in degrees between the
+	// outermost two.
+	Projectile string  `json:"projectile"`
+	Count      int     `json:"count"`
+	Angle      float32 `json:"angle"`
+
+	// For charges, a rush towards the player stopping at the arena's sides
+	Speed    float32 `json:"speed"`
+	Duration float32 `json:"duration"`
+}
+
+const bossAttackSpread = "spread"
+const bossAttackCharge = "charge"
+
+type BossState struct {
+	active   bool
+	phase    int
+	attack   int
+	cooldown float32
+
+	charge        *BossAttack
+	charge_time   float32
+	charge_facing float32
+
+	// Entity indices of the arena walls, spawned the first time the fight starts
+	walls []int
+}
+
+func init_bosses() {
+	// Dying gives the player another go at the whole fight
+	subscribe_event(EVENT_PLAYER_DIED, func(event GameEvent) {
+		for i := range g_Map.dynamic_entities {
+			entity := &g_Map.dynamic_entities[i]
+			if entity.boss != nil && entity.boss.active {
+				reset_boss_fight(entity)
+			}
+		}
+	})
+}
+
+func boss_arena(entity *DynamicEntity) BoundingBox2D {
+	arena := entity.prefab.Components.Boss.Arena
+	return make_bounding_box_2d_xy(arena[0], arena[2], arena[1], arena[3]).translated(entity.origin)
+}
+
+// Boss of the fight going on, nil outside of one
+func active_boss() *DynamicEntity {
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		if entity.boss != nil && entity.boss.active {
+			return entity
+		}
+	}
+	return nil
+}
+
+func boss_health() Resource {
+	entity := active_boss()
+	if entity == nil {
+		return Resource{}
+	}
+	return Resource{current: entity.health, max: max(entity.prefab.Components.Enemy.Health, 1)}
+}
+
+// Walls and projectiles are appended to the entities, so bosses are stepped by index
+func step_bosses(dt float32) {
+	for i := range g_Map.dynamic_entities {
+		if g_Map.dynamic_entities[i].boss != nil {
+			step_boss(i, dt)
+		}
+	}
+}
+
+func step_boss(index int, dt float32) {
+	entity := &g_Map.dynamic_entities[index]
+	boss, state := entity.prefab.Components.Boss, entity.boss
+
+	if !state.active {
+		player := nearest_player(entity.world_pos)
+		player_half_size := player.bb.size().mul_scalar(0.5)
+		if entity.picked || !boss_arena(entity).expand(player_half_size.mul_scalar(-1)).contains(player.pos) {
+			return
+		}
+		start_boss_fight(index)
+		entity = &g_Map.dynamic_entities[index]
+	}
+
+	if entity.picked {
+		state.active = false
+		set_boss_walls(state, false)
+		emit_event(GameEvent{kind: EVENT_BOSS_DEFEATED, pos: entity.world_pos})
+		return
+	}
+
+	phase := state.phase
+	fraction := boss_health().fraction()
+	for phase+1 < len(boss.Phases) && fraction <= boss.Phases[phase+1].Health {
+		phase++
+	}
+	if phase != state.phase {
+		state.phase = phase
+		state.attack = 0
+		emit_event(GameEvent{kind: EVENT_BOSS_PHASE_STARTED, pos: entity.world_pos, magnitude: float32(phase + 1)})
+	}
+
+	if state.charge_time > 0 {
+		arena := boss_arena(entity)
+		entity.pos.x += state.charge_facing * state.charge.Speed * dt
+		entity.pos.x = min(max(entity.pos.x, arena.min_corner().x), arena.max_corner().x)
+		state.charge_time -= dt
+		return
+	}
+
+	state.cooldown -= dt
+	if state.cooldown > 0 || len(boss.Phases) == 0 || len(boss.Phases[phase].Attacks) == 0 {
+		return
+	}
+	attacks := boss.Phases[phase].Attacks
+	attack := &attacks[state.attack%len(attacks)]
+	state.attack++
+	state.cooldown = boss.Phases[phase].Cooldown
+
+	to_player := nearest_player(entity.world_pos).pos.subtract(entity.world_pos)
+	switch attack.Type {
+	case bossAttackSpread:
+		fire_spread(entity.world_pos, to_player, attack)
+	case bossAttackCharge:
+		state.charge = attack
+		state.charge_time = attack.Duration
+		state.charge_facing = 1
+		if to_player.x < 0 {
+			state.charge_facing = -1
+		}
+	}
+}
+
+func fire_spread(pos Vector2DF, direction Vector2DF, attack *BossAttack) {
+	step := float32(0)
+	if attack.Count > 1 {
+		step = attack.Angle / float32(attack.Count-1)
+	}
+	first := -attack.Angle / 2
+	for i := 0; i < attack.Count; i++ {
+		angle := (first + step*float32(i)) * math.Pi / 180
+		spawn_projectile(attack.Projectile, pos, direction.rotate(angle))
+	}
+}
+
+func start_boss_fight(index int) {
+	entity := &g_Map.dynamic_entities[index]
+	boss := entity.prefab.Components.Boss
+	entity.boss.active = true
+	entity.boss.phase = 0
+	entity.boss.attack = 0
+	entity.boss.cooldown = 0
+
+	if entity.boss.walls != nil {
+		set_boss_walls(entity.boss, true)
+		return
+	}
+
+	wall, ok := g_Prefabs[boss.Wall]
+	if !ok {
+		return
+	}
+	half_width := float32(0)
+	if wall.Components.Collider != nil {
+		half_width = wall.Components.Collider.HalfSize[0]
+	}
+	arena := boss_arena(entity)
+	y := arena.center().y
+	walls := []int{}
+	for _, x := range []float32{arena.min_corner().x - half_width, arena.max_corner().x + half_width} {
+		wall_index := len(g_Map.dynamic_entities)
+		if spawn_prefab(boss.Wall, Vector2DF{x, y}) {
+			walls = append(walls, wall_index)
+		}
+	}
+	g_Map.dynamic_entities[index].boss.walls = walls
+}
+
+// Walls are hidden and let through like taken pickups while the arena is open
+func set_boss_walls(state *BossState, closed bool) {
+	for _, wall := range state.walls {
+		g_Map.dynamic_entities[wall].picked = !closed
+	}
+}
+
+func reset_boss_fight(entity *DynamicEntity) {
+	*entity.boss = BossState{walls: entity.boss.walls}
+	set_boss_walls(entity.boss, false)
+	entity.health = max(entity.prefab.Components.Enemy.Health, 1)
+	entity.pos = entity.origin
+
+	for i := range g_Map.dynamic_entities {
+		if g_Map.dynamic_entities[i].prefab.Components.Projectile != nil {
+			g_Map.dynamic_entities[i].picked = true
+		}
+	}
+}
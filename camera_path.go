@@ -0,0 +1,130 @@
+package main
+
+type EasingFunction func(t float32) float32
+
+func ease_linear(t float32) float32 {
+	return t
+}
+
+func ease_in(t float32) float32 {
+	return t * t
+}
+
+func ease_out(t float32) float32 {
+	return t * (2 - t)
+}
+
+func ease_in_out(t float32) float32 {
+	return t * t * (3 - 2*t)
+}
+
+type CameraKeyframe struct {
+	pos     Vector2DF
+	z_value float32
+
+	// Time to travel from the previous keyframe (or the camera's position for the first one)
+	duration float32
+	easing   EasingFunction
+}
+
+type CameraPath struct {
+	keyframes []CameraKeyframe
+	loop      bool
+
+	on_finish func()
+}
+
+// While a path plays it overrides the follow behavior of step_camera
+type CameraPathPlayer struct {
+	path    *CameraPath
+	index   int
+	elapsed float32
+
+	from_pos Vector2DF
+	from_z   float32
+}
+
+var g_CameraPathPlayer = CameraPathPlayer{}
+
+func start_camera_path(path *CameraPath) {
+	if len(path.keyframes) == 0 {
+		return
+	}
+
+	g_CameraPathPlayer = CameraPathPlayer{
+		path:     path,
+		from_pos: g_Camera.pos2D,
+		from_z:   g_Camera.z_value,
+	}
+}
+
+func stop_camera_path() {
+	g_CameraPathPlayer.path = nil
+}
+
+func camera_path_playing() bool {
+	return g_CameraPathPlayer.path != nil
+}
+
+func step_camera_path(dt float32) {
+	player := &g_CameraPathPlayer
+	if player.path == nil {
+		return
+	}
+
+	player.elapsed += dt
+
+	for {
+		keyframe := player.path.keyframes[player.index]
+
+		if player.elapsed < keyframe.duration {
+			t := player.elapsed / keyframe.duration
+			if keyframe.easing != nil {
+				t = keyframe.easing(t)
+			}
+
+			g_Camera.pos2D = player.from_pos.lerp(keyframe.pos, t)
+			g_Camera.z_value = player.from_z + (keyframe.z_value-player.from_z)*t
+			return
+		}
+
+		player.elapsed -= keyframe.duration
+		player.from_pos = keyframe.pos
+		player.from_z = keyframe.z_value
+		g_Camera.pos2D = keyframe.pos
+		g_Camera.z_value = keyframe.z_value
+
+		player.index++
+		if player.index < len(player.path.keyframes) {
+			continue
+		}
+
+		if player.path.loop {
+			player.index = 0
+			continue
+		}
+
+		on_finish := player.path.on_finish
+		stop_camera_path()
+		if on_finish != nil {
+			on_finish()
+		}
+		return
+	}
+}
+
+func init_camera_paths() {
+	// camera_pan x y z seconds: pans to the given point, holds it and returns to the player
+	register_command("camera_pan", "pan the camera to x y at height z, hold and come back, each leg taking seconds", func(x float32, y float32, z float32, seconds float32) {
+		pos := Vector2DF{x, y}
+		start_camera_path(&CameraPath{keyframes: []CameraKeyframe{
+			{pos: pos, z_value: z, duration: seconds, easing: ease_in_out},
+			{pos: pos, z_value: z, duration: 1.0},
+			{pos: g_Player.pos, z_value: g_Camera.base_z_value, duration: seconds, easing: ease_in_out},
+		}})
+	})
+
+	register_command("camera_stop", "stop a camera pan and follow the player again", func() {
+		stop_camera_path()
+	})
+}
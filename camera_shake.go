@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/noise"
+)
+
+// Trauma based screen shake: hits add trauma, it decays over time and the shake grows with its
+// square so small hits barely move the camera. The offset follows smooth noise instead of random
+// jitter, each axis reading the same noise at a different place.
+type CameraShake struct {
+	trauma float32
+	// Real seconds, so the shake keeps going through a hit-stop
+	time float32
+
+	offset Vector2DF
+	roll   float32
+
+	source *noise.Simplex
+}
+
+var g_CameraShake = CameraShake{source: noise.NewSimplex(0)}
+
+const cameraShakeDecay = 1.5
+const cameraShakeFrequency = 18.0
+const cameraShakeMaxOffset = 0.6
+const cameraShakeMaxRoll = 0.04
+
+// Trauma added per point of damage taken
+const cameraShakeDamageTrauma = 0.03
+
+func init_camera_shake() {
+	register_command("shake", "[trauma]: shakes the camera, trauma goes from 0 to 1", func(args []string) {
+		trauma := 0.5
+		if len(args) == 1 {
+			value, err := strconv.ParseFloat(args[0], 32)
+			if err != nil {
+				console_print("invalid trauma %q", args[0])
+				return
+			}
+			trauma = value
+		}
+		add_camera_trauma(float32(trauma))
+	})
+
+	subscribe_event(EVENT_PLAYER_DAMAGED, func(event GameEvent) {
+		add_camera_trauma(event.magnitude * cameraShakeDamageTrauma)
+	})
+}
+
+func add_camera_trauma(amount float32) {
+	if g_Settings.reduced_motion {
+		return
+	}
+	g_CameraShake.trauma = min(max(g_CameraShake.trauma+amount, 0), 1)
+}
+
+func step_camera_shake(elapsed float32) {
+	shake := &g_CameraShake
+	shake.trauma = max(shake.trauma-cameraShakeDecay*elapsed, 0)
+	shake.time += elapsed
+
+	if shake.trauma == 0 || g_Settings.reduced_motion || photo_mode_active() || free_camera_active() {
+		shake.trauma = 0
+		shake.offset, shake.roll = Vector2DF{0, 0}, 0
+		return
+	}
+
+	amount := shake.trauma * shake.trauma
+	t := float64(shake.time * cameraShakeFrequency)
+	shake.offset = Vector2DF{
+		amount * cameraShakeMaxOffset * float32(shake.source.Noise1(t)),
+		amount * cameraShakeMaxOffset * float32(shake.source.Noise1(t+100)),
+	}
+	shake.roll = amount * cameraShakeMaxRoll * float32(shake.source.Noise1(t+200))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+type ChatLine struct {
+	sender   string
+	text     string
+	received time.Time
+}
+
+type Chat struct {
+	open  bool
+	input TextInput
+
+	lines []ChatLine
+
+	muted   bool
+	ignored map[string]bool
+
+	background_texture uint32
+}
+
+var g_Chat = Chat{ignored: map[string]bool{}}
+
+const chatMaxLines = 50
+const chatVisibleLines = 6
+const chatLineVisibleTime = 8 * time.Second
+const chatLineFadeTime = 2 * time.Second
+const chatMaxMessageLength = 200
+
+func init_chat() {
+	g_Chat.background_texture = render.SolidTexture(color.RGBA{20, 20, 20, 160})
+
+	add_key_input_handler(INPUT_CONTEXT_GAMEPLAY, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+		if key == glfw.KeyT && action == glfw.Press {
+			open_chat()
+		}
+	})
+	add_key_input_handler(INPUT_CONTEXT_CHAT, chat_key_input)
+	set_text_input_handler(INPUT_CONTEXT_CHAT, g_Chat.input.handle_char)
+
+	set_packet_handler(PACKET_CHAT, func(packet NetPacket) {
+		sender, text, ok := decode_chat_message(packet.payload)
+		if !ok {
+			return
+		}
+
+		// The host relays messages between clients, trusting its own view of the sender's name
+		if g_Net.hosting {
+			peer, ok := g_Net.peers[packet.from.String()]
+			if !ok {
+				return
+			}
+			sender = peer.name
+			for key, other := range g_Net.peers {
+				if key != packet.from.String() {
+					net_send_reliable(other.addr, PACKET_CHAT, encode_chat_message(sender, text))
+				}
+			}
+		}
+
+		receive_chat_message(sender, text)
+	})
+
+	register_command("ignore", "hide chat messages from the given players", func(names ...string) {
+		for _, name := range names {
+			g_Chat.ignored[name] = true
+		}
+	})
+	register_command("unignore", "show chat messages from the given players again", func(names ...string) {
+		for _, name := range names {
+			delete(g_Chat.ignored, name)
+		}
+	})
+	register_command("chat_mute", "toggle hiding all chat messages", func() {
+		g_Chat.muted = !g_Chat.muted
+		console_print("chat muted: %v", g_Chat.muted)
+	})
+}
+
+func open_chat() {
+	if g_Chat.open {
+		return
+	}
+	g_Chat.open = true
+	g_Chat.input.ignored_chars = "tT"
+	push_input_context(INPUT_CONTEXT_CHAT)
+}
+
+func close_chat() {
+	g_Chat.open = false
+	g_Chat.input.clear()
+	pop_input_context(INPUT_CONTEXT_CHAT)
+}
+
+func chat_key_input(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key == glfw.KeyEscape && action == glfw.Press {
+		close_chat()
+		return
+	}
+
+	line, submitted := g_Chat.input.handle_key(key, action, mods)
+	if !submitted {
+		return
+	}
+	close_chat()
+
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "/") {
+		// Chat accepts the mute/ignore console commands, e.g. "/ignore name"
+		console_execute(line[1:])
+		return
+	}
+	send_chat_message(line)
+}
+
+func encode_chat_message(sender string, text string) []byte {
+	sender = sender[:min(len(sender), 255)]
+
+	payload := []byte{byte(len(sender))}
+	payload = append(payload, sender...)
+	return append(payload, text...)
+}
+
+func decode_chat_message(payload []byte) (string, string, bool) {
+	if len(payload) < 1 || len(payload) < 1+int(payload[0]) {
+		return "", "", false
+	}
+	sender_end := 1 + int(payload[0])
+	return string(payload[1:sender_end]), string(payload[sender_end:]), true
+}
+
+func send_chat_message(text string) {
+	if text == "" {
+		return
+	}
+	if len(text) > chatMaxMessageLength {
+		text = text[:chatMaxMessageLength]
+	}
+
+	payload := encode_chat_message(g_Settings.player_name, text)
+	if g_Net.hosting {
+		for _, peer := range g_Net.peers {
+			net_send_reliable(peer.addr, PACKET_CHAT, payload)
+		}
+	} else if g_Net.connected {
+		net_send_reliable(g_Net.remote, PACKET_CHAT, payload)
+	}
+
+	receive_chat_message(g_Settings.player_name, text)
+}
+
+func receive_chat_message(sender string, text string) {
+	if g_Chat.muted || g_Chat.ignored[sender] {
+		return
+	}
+
+	g_Chat.lines = append(g_Chat.lines, ChatLine{sender, text, time.Now()})
+	if len(g_Chat.lines) > chatMaxLines {
+		g_Chat.lines = g_Chat.lines[len(g_Chat.lines)-chatMaxLines:]
+	}
+}
+
+func render_chat() {
+	line_height := text_line_height(1)
+	bottom := ui_size().y - 160
+	now := time.Now()
+
+	if g_Chat.open {
+		draw_overlay_quad(g_Chat.background_texture, 4, bottom-line_height*chatVisibleLines-4, 420, line_height*(chatVisibleLines+1)+8)
+		g_Chat.input.draw("say: ", 8, bottom, 1, color.RGBA{255, 255, 255, 255})
+	}
+
+	first_line := max(len(g_Chat.lines)-chatVisibleLines, 0)
+	y := bottom - line_height*float32(len(g_Chat.lines)-first_line)
+
+	for _, line := range g_Chat.lines[first_line:] {
+		alpha := float32(1)
+		if !g_Chat.open {
+			age := now.Sub(line.received)
+			if age > chatLineVisibleTime+chatLineFadeTime {
+				y += line_height
+				continue
+			}
+			if age > chatLineVisibleTime {
+				alpha = 1 - float32(age-chatLineVisibleTime)/float32(chatLineFadeTime)
+			}
+		}
+
+		// Quantized so the text cache isn't flooded with one texture per alpha value, colors are premultiplied
+		alpha_byte := uint8(float32(int(alpha*8+0.5)) * 31.875)
+		draw_text(line.sender+": "+line.text, 8, y, 1, color.RGBA{alpha_byte, alpha_byte, alpha_byte, alpha_byte})
+		y += line_height
+	}
+}
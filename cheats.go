@@ -0,0 +1,116 @@
+package main
+
+import "log"
+
+// Debug commands for testing levels, only registered in developer mode. Once one is used the
+// session no longer writes the save, so records and achievements can't come from cheating.
+type Cheats struct {
+	noclip bool
+	god    bool
+
+	used bool
+}
+
+var g_Cheats = Cheats{}
+
+// Units per second per unit of the movement acceleration
+const noclipSpeed = 0.15
+
+func init_cheats() {
+	if !config_bool("developer") {
+		return
+	}
+
+	register_command("noclip", "toggle flying through blocks without gravity", func() {
+		use_cheat()
+		g_Cheats.noclip = !g_Cheats.noclip
+		g_Player.vel = Vector2DF{0, 0}
+		console_print("noclip = %t", g_Cheats.noclip)
+	})
+	register_command("god", "toggle ignoring damage", func() {
+		use_cheat()
+		g_Cheats.god = !g_Cheats.god
+		console_print("god = %t", g_Cheats.god)
+	})
+	register_command("teleport", "move the player to x y", func(x float32, y float32) {
+		use_cheat()
+		g_Player.pos = Vector2DF{x, y}
+		g_Player.vel = Vector2DF{0, 0}
+		g_Player.state = FALLING
+		// Don't interpolate across the jump
+		g_Player.has_previous_transform = false
+		update_player_bounding_box(&g_Player)
+	})
+	register_command("give", "give health, stamina or collectible, all of it or the given amount", func(item string, amounts ...float32) {
+		amount := float32(-1)
+		if len(amounts) > 0 {
+			amount = amounts[0]
+		}
+
+		switch item {
+		case "health":
+			give_resource(&g_Player.health, amount)
+		case "stamina":
+			give_resource(&g_Player.stamina, amount)
+		case "collectible":
+			give_collectibles(int(amount))
+		default:
+			console_print("unknown item %q, expected health, stamina or collectible", item)
+			return
+		}
+		use_cheat()
+	})
+	register_command("fog_reveal", "explore all of the level's fog of war", func() {
+		if g_Fog.key == "" {
+			console_print("fog: the level has no fog of war")
+			return
+		}
+		use_cheat()
+		fill_fog(255)
+	})
+}
+
+func use_cheat() {
+	if !g_Cheats.used {
+		log.Println("cheats: used, progress won't be saved this session")
+		console_print("cheats used, progress won't be saved this session")
+	}
+	g_Cheats.used = true
+}
+
+// A negative amount refills it
+func give_resource(resource *Resource, amount float32) {
+	if amount < 0 {
+		resource.refill()
+		return
+	}
+	resource.current = min(resource.current+amount, resource.max)
+}
+
+// Collects the next count uncollected collectibles in level order, every one when count is negative
+func give_collectibles(count int) {
+	for i := range g_Map.collectibles {
+		collectible := &g_Map.collectibles[i]
+		if count == 0 {
+			return
+		}
+		if collectible.collected {
+			continue
+		}
+		collectible.collected = true
+		emit_event(GameEvent{kind: EVENT_COLLECTIBLE_PICKED, pos: collectible.pos})
+		count--
+	}
+}
+
+// Replaces step_player while noclip is on, moving straight with the input
+func step_noclip_player(player *Player, dt float32) {
+	// Running so the left and right input accelerate instead of steering a fall
+	player.state = RUNNING
+	player.vel = player.accel.mul_scalar(noclipSpeed)
+	player.pos = player.pos.add(player.vel.mul_scalar(dt))
+	player.angle_z = 0
+	player.accel = Vector2DF{0, 0}
+
+	update_player_bounding_box(player)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Keeps the progression save in step with a copy on an HTTP endpoint, so it follows the player
+// between machines. The last sync is remembered per machine: when only one side changed since
+// then it wins, when both did the newest is offered and the player picks with cloud_resolve.
+// Requests run in the background, their results are applied on the main thread by step_cloud_sync.
+type CloudSync struct {
+	http    *http.Client
+	results chan CloudResult
+
+	state CloudSyncState
+	// Set while both sides changed and the player hasn't picked one yet
+	conflict *CloudSave
+}
+
+// Applied on the main thread, only if the profile that made the request is still the current one
+type CloudResult struct {
+	profile string
+	apply   func()
+}
+
+// What the endpoint stores, GET and PUT on <cloud_url>/saves/<player>/<profile>
+type CloudSave struct {
+	Modified time.Time `json:"modified"`
+	// Remote modification time the upload was based on, the endpoint answers 409 if it has moved on
+	Base time.Time       `json:"base"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Both times as of the last sync, stored next to the save
+type CloudSyncState struct {
+	Local  time.Time `json:"local"`
+	Remote time.Time `json:"remote"`
+}
+
+var g_CloudSync = CloudSync{
+	http:    &http.Client{Timeout: 5 * time.Second},
+	results: make(chan CloudResult, 4),
+}
+
+var errCloudConflict = errors.New("the cloud save changed since the last sync")
+
+func cloud_sync_enabled() bool {
+	return g_Config.String("cloud_url") != ""
+}
+
+func cloud_sync_state_path() string {
+	return filepath.Join(save_directory(), "cloud_sync.json")
+}
+
+func load_cloud_sync_state() {
+	g_CloudSync.state = CloudSyncState{}
+	g_CloudSync.conflict = nil
+	if data, err := os.ReadFile(cloud_sync_state_path()); err == nil {
+		if err := json.Unmarshal(data, &g_CloudSync.state); err != nil {
+			log.Println("cloud:", err)
+		}
+	}
+}
+
+func init_cloud_sync() {
+	load_cloud_sync_state()
+
+	register_command("cloud_sync", "upload or download the save, whichever side changed", func() {
+		if !cloud_sync_enabled() {
+			console_print("cloud: set cloud_url in the config first")
+			return
+		}
+		start_cloud_sync()
+	})
+	register_command("cloud_resolve", "[local|cloud]: settle a sync conflict, no argument keeps the newest", func(args []string) {
+		conflict := g_CloudSync.conflict
+		if conflict == nil {
+			console_print("cloud: no conflict to resolve")
+			return
+		}
+		keep_cloud := conflict.Modified.After(local_save_modified())
+		if len(args) == 1 {
+			keep_cloud = args[0] == "cloud"
+		}
+		g_CloudSync.conflict = nil
+		if keep_cloud {
+			apply_cloud_save(*conflict)
+		} else {
+			start_cloud_upload(conflict.Modified)
+		}
+	})
+
+	if cloud_sync_enabled() {
+		start_cloud_sync()
+	}
+}
+
+// The player name can come from the environment or command line for every profile at once, the
+// profile keeps their saves apart
+func cloud_save_url() string {
+	return g_Config.String("cloud_url") + "/saves/" + url.PathEscape(g_Settings.player_name) + "/" + url.PathEscape(g_Profiles.current)
+}
+
+// Takes the url from the main thread, the profile can switch while the request runs
+func cloud_request(address string, method string, body any, result any) error {
+	data := []byte{}
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		data = encoded
+	}
+
+	request, err := http.NewRequest(method, address, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if token := g_Config.String("cloud_token"); token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := g_CloudSync.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound && method == http.MethodGet:
+		return fs.ErrNotExist
+	case response.StatusCode == http.StatusConflict:
+		return errCloudConflict
+	case response.StatusCode < 200 || response.StatusCode >= 300:
+		return fmt.Errorf("%s %s: %s", method, address, response.Status)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func local_save_modified() time.Time {
+	info, err := os.Stat(save_path())
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func start_cloud_sync() {
+	profile, address := g_Profiles.current, cloud_save_url()
+	go func() {
+		remote := CloudSave{}
+		err := cloud_request(address, http.MethodGet, nil, &remote)
+
+		g_CloudSync.results <- CloudResult{profile, func() {
+			if errors.Is(err, fs.ErrNotExist) {
+				start_cloud_upload(time.Time{})
+				return
+			}
+			if err != nil {
+				console_print("cloud: %v", err)
+				return
+			}
+
+			local_changed := local_save_modified().After(g_CloudSync.state.Local)
+			remote_changed := remote.Modified.After(g_CloudSync.state.Remote)
+			switch {
+			case local_changed && remote_changed:
+				g_CloudSync.conflict = &remote
+				newest := "this machine's"
+				if remote.Modified.After(local_save_modified()) {
+					newest = "the cloud"
+				}
+				console_print("cloud: the save changed here and in the cloud since the last sync, %s is newest", newest)
+				console_print("cloud: cloud_resolve keeps the newest, or pick with cloud_resolve local|cloud")
+				if !g_Console.open {
+					toggle_console()
+				}
+			case remote_changed:
+				apply_cloud_save(remote)
+			case local_changed:
+				start_cloud_upload(remote.Modified)
+			}
+		}}
+	}()
+}
+
+// Replaces the progression in memory and on disk with the downloaded one
+func apply_cloud_save(remote CloudSave) {
+	loaded := new_save_data()
+	if err := json.Unmarshal(remote.Data, &loaded); err != nil {
+		console_print("cloud: the cloud save is corrupt: %v", err)
+		return
+	}
+	g_SaveData = loaded
+	write_save_data()
+
+	record_cloud_sync(local_save_modified(), remote.Modified)
+	console_print("cloud: downloaded the save from %s", remote.Modified.Format(time.DateTime))
+}
+
+func cloud_upload_body(base time.Time) (CloudSave, error) {
+	data, err := os.ReadFile(save_path())
+	if err != nil {
+		return CloudSave{}, err
+	}
+	return CloudSave{Modified: local_save_modified(), Base: base, Data: data}, nil
+}
+
+func start_cloud_upload(base time.Time) {
+	body, err := cloud_upload_body(base)
+	if err != nil {
+		console_print("cloud: %v", err)
+		return
+	}
+
+	profile, address := g_Profiles.current, cloud_save_url()
+	go func() {
+		err := cloud_request(address, http.MethodPut, body, nil)
+
+		g_CloudSync.results <- CloudResult{profile, func() {
+			if err != nil {
+				console_print("cloud: %v", err)
+				return
+			}
+			record_cloud_sync(body.Modified, body.Modified)
+			console_print("cloud: uploaded the save")
+		}}
+	}()
+}
+
+func record_cloud_sync(local time.Time, remote time.Time) {
+	g_CloudSync.state = CloudSyncState{Local: local, Remote: remote}
+	if err := write_save_file(cloud_sync_state_path(), g_CloudSync.state); err != nil {
+		log.Println("cloud:", err)
+	}
+}
+
+func step_cloud_sync() {
+	for {
+		select {
+		case result := <-g_CloudSync.results:
+			// Finished after a profile switch, it would land in the wrong profile's save
+			if result.profile == g_Profiles.current {
+				result.apply()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// On the way out, after the final save is written. Blocks for at most the client timeout, and
+// leaves an unresolved conflict for the next session.
+func finish_cloud_sync() {
+	if !cloud_sync_enabled() || g_CloudSync.conflict != nil {
+		return
+	}
+	if !local_save_modified().After(g_CloudSync.state.Local) {
+		return
+	}
+
+	body, err := cloud_upload_body(g_CloudSync.state.Remote)
+	if err != nil {
+		log.Println("cloud:", err)
+		return
+	}
+	if err := cloud_request(cloud_save_url(), http.MethodPut, body, nil); err != nil {
+		log.Println("cloud:", err)
+		return
+	}
+	record_cloud_sync(body.Modified, body.Modified)
+}
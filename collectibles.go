@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math/rand"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type Collectible struct {
+	pos       Vector2DF
+	bb        BoundingBox2D
+	collected bool
+}
+
+const collectibleHalfSize = 0.4
+const collectibleHeight = 2.5
+
+// Places the level's collectibles above randomly picked blocks of the generated platforms
+func place_collectibles(level_map *Map, count int, rng *rand.Rand, first_block int) {
+	blocks := []Vector2DF{}
+	for _, block := range level_map.entities[first_block:] {
+		blocks = append(blocks, block.pos)
+	}
+	place_collectibles_above(level_map, count, rng, blocks)
+}
+
+func place_collectibles_above(level_map *Map, count int, rng *rand.Rand, blocks []Vector2DF) {
+	level_map.collectibles = nil
+
+	candidates := rng.Perm(len(blocks))
+	for _, candidate := range candidates[:min(count, len(candidates))] {
+		pos := blocks[candidate].add(Vector2DF{0, collectibleHeight})
+		bb := make_bounding_box_2d_centered(pos, Vector2DF{collectibleHalfSize, collectibleHalfSize})
+
+		level_map.collectibles = append(level_map.collectibles, Collectible{pos: pos, bb: bb})
+	}
+}
+
+func collect_collectibles(player *Player) {
+	for i := range g_Map.collectibles {
+		collectible := &g_Map.collectibles[i]
+		if collectible.collected || !collectible.bb.intersects(player.bb) {
+			continue
+		}
+
+		collectible.collected = true
+		emit_event(GameEvent{kind: EVENT_COLLECTIBLE_PICKED, pos: collectible.pos})
+	}
+}
+
+func collected_count() int {
+	count := 0
+	for _, collectible := range g_Map.collectibles {
+		if collectible.collected {
+			count++
+		}
+	}
+	return count
+}
+
+func render_collectibles(model_uniform_location int32) {
+	texture, err := load_texture("square.png")
+	if err != nil {
+		return
+	}
+
+	g_CubeMesh.bind(texture)
+
+	for _, collectible := range g_Map.collectibles {
+		if collectible.collected {
+			continue
+		}
+
+		transform := make_transform(collectible.pos)
+		transform.rotation[1] = g_Map.angle * 2
+		transform.scale = mgl32.Vec3{collectibleHalfSize, collectibleHalfSize, collectibleHalfSize}
+		model := transform.matrix()
+		gl.UniformMatrix4fv(model_uniform_location, 1, false, &model[0])
+
+		g_CubeMesh.draw_elements()
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Per level color grading through a 3D lookup table, authored in an external tool and exported
+// as a .cube file or a strip PNG
+type ColorGrading struct {
+	// Uploaded LUTs by path, a failed load is kept as 0 so it isn't retried every level
+	luts map[string]GradingLut
+	// Set from the console to preview a LUT on any level, "off" disables grading
+	override string
+
+	// Resolved by update_grading when the map is built or the override changes
+	current GradingLut
+}
+
+type GradingLut struct {
+	texture uint32
+	size    int32
+}
+
+var g_ColorGrading = ColorGrading{luts: map[string]GradingLut{}}
+
+var gradingFragmentShader = `
+#version 330
+
+uniform sampler2D source;
+uniform sampler3D lut;
+uniform float lut_size;
+
+in vec2 fragTexCoord;
+
+out vec4 outputColor;
+
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    // Sample between the texel centers of the outermost entries
+    vec3 coord = color.rgb * ((lut_size - 1.0) / lut_size) + 0.5 / lut_size;
+    outputColor = vec4(texture(lut, coord).rgb, color.a);
+}
+` + "\x00"
+
+// The LUT is bound here, the frame is on unit 0
+const gradingTextureUnit = 1
+
+func init_color_grading() {
+	add_post_pass("grading", gradingFragmentShader,
+		func() bool { return g_Settings.color_grading_enabled && g_ColorGrading.current.texture != 0 },
+		func(pass *PostPass) {
+			gl.ActiveTexture(gl.TEXTURE0 + gradingTextureUnit)
+			gl.BindTexture(gl.TEXTURE_3D, g_ColorGrading.current.texture)
+			gl.ActiveTexture(gl.TEXTURE0)
+
+			gl.Uniform1i(pass.uniform("lut"), gradingTextureUnit)
+			gl.Uniform1f(pass.uniform("lut_size"), float32(g_ColorGrading.current.size))
+		})
+
+	register_command("grading", "[file|off]: preview a LUT on any level, no argument goes back to the level's", func(args []string) {
+		g_ColorGrading.override = strings.Join(args, " ")
+		update_grading()
+		if g_ColorGrading.override != "" && g_ColorGrading.override != "off" && g_ColorGrading.current.texture == 0 {
+			console_print("grading: couldn't load %s", g_ColorGrading.override)
+		}
+	})
+}
+
+func current_grading() string {
+	switch g_ColorGrading.override {
+	case "":
+		return level_grading(g_Level)
+	case "off":
+		return ""
+	}
+	return asset_path(g_ColorGrading.override)
+}
+
+// Path of the level's LUT, falling back to its pack's. Relative paths are resolved against the
+// pack directory, or the assets for levels outside a pack.
+func level_grading(level LevelInfo) string {
+	pack := level_pack(level)
+	grading := level.Grading
+	if grading == "" && pack != nil {
+		grading = pack.Grading
+	}
+	if grading == "" {
+		return ""
+	}
+	if pack == nil {
+		return asset_path(grading)
+	}
+	return asset_path(filepath.Join(pack.directory, grading))
+}
+
+// Resolves the LUT of the current level or override, call after the level or override changes
+func update_grading() {
+	g_ColorGrading.current = GradingLut{}
+	if g_Headless {
+		return
+	}
+
+	file := current_grading()
+	if file == "" {
+		return
+	}
+	lut, ok := g_ColorGrading.luts[file]
+	if !ok {
+		var err error
+		lut, err = load_lut(file)
+		if err != nil {
+			console_print("grading: %v", err)
+		}
+		g_ColorGrading.luts[file] = lut
+	}
+	g_ColorGrading.current = lut
+}
+
+func load_lut(file string) (GradingLut, error) {
+	size, data, err := read_lut(file)
+	if err != nil {
+		return GradingLut{}, err
+	}
+
+	var lut uint32
+	gl.GenTextures(1, &lut)
+	gl.BindTexture(gl.TEXTURE_3D, lut)
+	gl.TexImage3D(gl.TEXTURE_3D, 0, gl.RGB16F, int32(size), int32(size), int32(size), 0, gl.RGB, gl.FLOAT, gl.Ptr(data))
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_3D, 0)
+	return GradingLut{lut, int32(size)}, nil
+}
+
+// Size of each side and RGB entries with red changing fastest
+func read_lut(file string) (int, []float32, error) {
+	if filepath.Ext(file) == ".cube" {
+		return read_cube_lut(file)
+	}
+	return read_strip_lut(file)
+}
+
+// Adobe .cube format, entries are listed with red changing fastest then green then blue, which is
+// also the texel order of a 3D texture
+func read_cube_lut(file string) (int, []float32, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	size := 0
+	domain_min := [3]float32{0, 0, 0}
+	domain_max := [3]float32{1, 1, 1}
+	data := []float32{}
+
+	scanner := bufio.NewScanner(f)
+	for line_number := 1; scanner.Scan(); line_number++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+		case "TITLE":
+		case "LUT_1D_SIZE":
+			return 0, nil, fmt.Errorf("%s: 1D LUTs aren't supported", file)
+		case "LUT_3D_SIZE":
+			if len(fields) != 2 {
+				return 0, nil, fmt.Errorf("%s:%d: expected LUT_3D_SIZE <n>", file, line_number)
+			}
+			size, err = strconv.Atoi(fields[1])
+			if err != nil || size < 2 {
+				return 0, nil, fmt.Errorf("%s:%d: bad LUT size %q", file, line_number, fields[1])
+			}
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			values, err := parse_cube_floats(fields[1:])
+			if err != nil {
+				return 0, nil, fmt.Errorf("%s:%d: %v", file, line_number, err)
+			}
+			if fields[0] == "DOMAIN_MIN" {
+				domain_min = values
+			} else {
+				domain_max = values
+			}
+		default:
+			values, err := parse_cube_floats(fields)
+			if err != nil {
+				return 0, nil, fmt.Errorf("%s:%d: %v", file, line_number, err)
+			}
+			for i, value := range values {
+				data = append(data, (value-domain_min[i])/(domain_max[i]-domain_min[i]))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	if size == 0 {
+		return 0, nil, fmt.Errorf("%s: missing LUT_3D_SIZE", file)
+	}
+	if len(data) != size*size*size*3 {
+		return 0, nil, fmt.Errorf("%s: expected %d entries, got %d", file, size*size*size, len(data)/3)
+	}
+	return size, data, nil
+}
+
+func parse_cube_floats(fields []string) ([3]float32, error) {
+	values := [3]float32{}
+	if len(fields) != 3 {
+		return values, fmt.Errorf("expected 3 values, got %d", len(fields))
+	}
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(field, 32)
+		if err != nil {
+			return values, fmt.Errorf("bad value %q", field)
+		}
+		values[i] = float32(value)
+	}
+	return values, nil
+}
+
+// Strip PNGs are size slices of size x size laid side by side, one per blue step. Inside a slice
+// red grows to the right and green downwards.
+func read_strip_lut(file string) (int, []float32, error) {
+	img, err := render.DecodeImage(file)
+	if err != nil {
+		return 0, nil, err
+	}
+	bounds := img.Bounds()
+	size := bounds.Dy()
+	if size < 2 || bounds.Dx() != size*size {
+		return 0, nil, fmt.Errorf("%s: a strip LUT must be n*n by n pixels, got %dx%d", file, bounds.Dx(), bounds.Dy())
+	}
+
+	data := make([]float32, 0, size*size*size*3)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				data = append_lut_color(data, img, bounds.Min.X+b*size+r, bounds.Min.Y+g)
+			}
+		}
+	}
+	return size, data, nil
+}
+
+func append_lut_color(data []float32, img image.Image, x int, y int) []float32 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return append(data, float32(r)/0xffff, float32(g)/0xffff, float32(b)/0xffff)
+}
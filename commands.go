@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Console command. Handlers are plain functions, arguments typed in the console are parsed to
+// their parameter types before calling. A handler taking args []string gets the words as typed.
+type ConsoleCommand struct {
+	name    string
+	help    string
+	handler reflect.Value
+	// Handler takes the raw words and does its own parsing
+	raw bool
+}
+
+var g_ConsoleCommands = map[string]*ConsoleCommand{}
+
+var commandArgumentNames = map[reflect.Kind]string{
+	reflect.String:  "text",
+	reflect.Int:     "int",
+	reflect.Float32: "number",
+	reflect.Bool:    "on|off",
+}
+
+// Handler parameters can be string, int, float32 or bool, the last one may be variadic
+func register_command(name string, help string, handler any) {
+	value := reflect.ValueOf(handler)
+	handler_type := value.Type()
+	if handler_type.Kind() != reflect.Func || handler_type.NumOut() != 0 {
+		log.Fatalf("console: command %q: handler must be a function without results", name)
+	}
+
+	command := &ConsoleCommand{name: name, help: help, handler: value}
+	if _, ok := handler.(func(args []string)); ok {
+		command.raw = true
+	} else {
+		for i := 0; i < handler_type.NumIn(); i++ {
+			kind := handler_type.In(i).Kind()
+			if handler_type.IsVariadic() && i == handler_type.NumIn()-1 {
+				kind = handler_type.In(i).Elem().Kind()
+			}
+			if _, ok := commandArgumentNames[kind]; !ok {
+				log.Fatalf("console: command %q: unsupported parameter type %s", name, handler_type.In(i))
+			}
+		}
+	}
+
+	g_ConsoleCommands[name] = command
+}
+
+func command_usage(command *ConsoleCommand) string {
+	if command.raw {
+		return command.name
+	}
+
+	handler_type := command.handler.Type()
+	parts := []string{command.name}
+	for i := 0; i < handler_type.NumIn(); i++ {
+		if handler_type.IsVariadic() && i == handler_type.NumIn()-1 {
+			parts = append(parts, "["+commandArgumentNames[handler_type.In(i).Elem().Kind()]+"...]")
+		} else {
+			parts = append(parts, "<"+commandArgumentNames[handler_type.In(i).Kind()]+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Usage and help on one line, raw commands spell their arguments out in the help
+func describe_command(command *ConsoleCommand) string {
+	if command.raw {
+		return command.name + " " + command.help
+	}
+	return command_usage(command) + ": " + command.help
+}
+
+func parse_command_argument(arg string, argument_type reflect.Type) (reflect.Value, error) {
+	value := reflect.New(argument_type).Elem()
+	switch argument_type.Kind() {
+	case reflect.String:
+		value.SetString(arg)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(arg)
+		if err != nil {
+			return value, fmt.Errorf("%q is not an integer", arg)
+		}
+		value.SetInt(int64(parsed))
+	case reflect.Float32:
+		parsed, err := strconv.ParseFloat(arg, 32)
+		if err != nil {
+			return value, fmt.Errorf("%q is not a number", arg)
+		}
+		value.SetFloat(parsed)
+	case reflect.Bool:
+		switch strings.ToLower(arg) {
+		case "1", "true", "on", "yes":
+			value.SetBool(true)
+		case "0", "false", "off", "no":
+			value.SetBool(false)
+		default:
+			return value, fmt.Errorf("%q is not on or off", arg)
+		}
+	}
+	return value, nil
+}
+
+func run_command(command *ConsoleCommand, args []string) error {
+	if command.raw {
+		command.handler.Call([]reflect.Value{reflect.ValueOf(args)})
+		return nil
+	}
+
+	handler_type := command.handler.Type()
+	fixed := handler_type.NumIn()
+	if handler_type.IsVariadic() {
+		fixed--
+	}
+	if len(args) < fixed || (!handler_type.IsVariadic() && len(args) > fixed) {
+		return fmt.Errorf("usage: %s", command_usage(command))
+	}
+
+	values := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		argument_type := handler_type.In(min(i, handler_type.NumIn()-1))
+		if i >= fixed {
+			argument_type = argument_type.Elem()
+		}
+		value, err := parse_command_argument(arg, argument_type)
+		if err != nil {
+			return fmt.Errorf("%s, usage: %s", err, command_usage(command))
+		}
+		values[i] = value
+	}
+	command.handler.Call(values)
+	return nil
+}
+
+// Sorted names of the commands starting with prefix
+func complete_command(prefix string) []string {
+	names := []string{}
+	for name := range g_ConsoleCommands {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func common_prefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,36 @@
+package main
+
+import "github.com/go-gl/gl/v4.1-core/gl"
+
+// Last post pass, the player's display adjustments. It only runs while one of them is off its default.
+var compositeFragmentShader = `
+#version 330
+
+uniform sampler2D source;
+uniform float brightness;
+uniform float contrast;
+uniform float gamma;
+
+in vec2 fragTexCoord;
+
+out vec4 outputColor;
+
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    vec3 adjusted = (color.rgb - 0.5) * contrast + 0.5 + brightness;
+    adjusted = pow(clamp(adjusted, 0.0, 1.0), vec3(1.0 / gamma));
+    outputColor = vec4(adjusted, color.a);
+}
+` + "\x00"
+
+func init_composite() {
+	add_post_pass("composite", compositeFragmentShader,
+		func() bool {
+			return g_Settings.brightness != 0 || g_Settings.contrast != 1 || g_Settings.gamma != 1
+		},
+		func(pass *PostPass) {
+			gl.Uniform1f(pass.uniform("brightness"), g_Settings.brightness)
+			gl.Uniform1f(pass.uniform("contrast"), g_Settings.contrast)
+			gl.Uniform1f(pass.uniform("gamma"), g_Settings.gamma)
+		})
+}
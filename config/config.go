@@ -0,0 +1,283 @@
+// Package config merges settings from several layers. A value from a higher
+// layer always wins, regardless of the order the layers are loaded in:
+//
+//	defaults < settings file < environment variables < command-line flags < runtime
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Source int
+
+const (
+	SourceDefault Source = iota
+	SourceFile
+	SourceEnv
+	SourceFlag
+	// Changed while the game runs, from a settings menu or the console
+	SourceRuntime
+)
+
+func (source Source) String() string {
+	switch source {
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	case SourceRuntime:
+		return "runtime"
+	}
+	return "default"
+}
+
+type entry struct {
+	value       string
+	source      Source
+	description string
+	fallback    string
+}
+
+// Only keys given a default are accepted from the other layers
+type Config struct {
+	entries map[string]*entry
+}
+
+func New() *Config {
+	return &Config{entries: map[string]*entry{}}
+}
+
+func (c *Config) Default(key string, value string, description string) {
+	c.entries[key] = &entry{value: value, source: SourceDefault, description: description, fallback: value}
+}
+
+// Forgets what the given layers set, those keys go back to their defaults. A key a dropped
+// layer took over from a lower one doesn't get the lower value back.
+func (c *Config) Reset(sources ...Source) {
+	for _, e := range c.entries {
+		for _, source := range sources {
+			if e.source == source {
+				e.value = e.fallback
+				e.source = SourceDefault
+			}
+		}
+	}
+}
+
+func (c *Config) set(key string, value string, source Source) error {
+	e, ok := c.entries[key]
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if source >= e.source {
+		e.value = value
+		e.source = source
+	}
+	return nil
+}
+
+// Overrides every other layer, for settings changed in game
+func (c *Config) Set(key string, value string) error {
+	return c.set(key, value, SourceRuntime)
+}
+
+// Reads "key = value" lines, # starts a comment. A missing file is not an error.
+func (c *Config) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line_number := 1; scanner.Scan(); line_number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected key = value", path, line_number)
+		}
+		if err := c.set(strings.TrimSpace(key), strings.TrimSpace(value), SourceFile); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line_number, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Maps PREFIX_SOME_KEY=value to some_key, variables for unknown keys are ignored
+func (c *Config) LoadEnv(prefix string, environ []string) {
+	for _, variable := range environ {
+		name, value, ok := strings.Cut(variable, "=")
+		if !ok || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimPrefix(name, prefix))
+		if _, known := c.entries[key]; known {
+			c.set(key, value, SourceEnv)
+		}
+	}
+}
+
+// Accepts -key=value, --key=value, -key value and a bare -key meaning true
+func (c *Config) ParseArgs(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("unexpected argument %q", arg)
+		}
+
+		key, value, has_value := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !has_value {
+			value = "true"
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+				value = args[i]
+			}
+		}
+
+		if err := c.set(key, value, SourceFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) String(key string) string {
+	if e, ok := c.entries[key]; ok {
+		return e.value
+	}
+	return ""
+}
+
+func (c *Config) Source(key string) Source {
+	if e, ok := c.entries[key]; ok {
+		return e.source
+	}
+	return SourceDefault
+}
+
+// True when the value came from anywhere but the defaults
+func (c *Config) IsSet(key string) bool {
+	return c.Source(key) != SourceDefault
+}
+
+func (c *Config) Int(key string) (int, error) {
+	value, err := strconv.Atoi(c.String(key))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return value, nil
+}
+
+func (c *Config) Int64(key string) (int64, error) {
+	value, err := strconv.ParseInt(c.String(key), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return value, nil
+}
+
+func (c *Config) Float(key string) (float32, error) {
+	value, err := strconv.ParseFloat(c.String(key), 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return float32(value), nil
+}
+
+// Also understands on/off and yes/no
+func (c *Config) Bool(key string) (bool, error) {
+	switch strings.ToLower(c.String(key)) {
+	case "1", "true", "on", "yes":
+		return true, nil
+	case "0", "false", "off", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("%s: %q is not a boolean", key, c.String(key))
+}
+
+// Parses sizes written as WIDTHxHEIGHT
+func (c *Config) Size(key string) (int, int, error) {
+	width, height, ok := strings.Cut(strings.ToLower(c.String(key)), "x")
+	if ok {
+		w, err_w := strconv.Atoi(width)
+		h, err_h := strconv.Atoi(height)
+		if err_w == nil && err_h == nil && w > 0 && h > 0 {
+			return w, h, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("%s: %q is not a WIDTHxHEIGHT size", key, c.String(key))
+}
+
+// One line per key with its value, source and description, sorted by key
+func (c *Config) Describe() []string {
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		e := c.entries[key]
+		lines = append(lines, fmt.Sprintf("%s = %s (%s) - %s", key, e.value, e.source, e.description))
+	}
+	return lines
+}
+
+// Writes the current values of keys to a settings file. Lines for other keys and
+// comments are kept, keys not in the file yet are appended.
+func (c *Config) WriteFile(path string, keys []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	pending := map[string]bool{}
+	for _, key := range keys {
+		if _, ok := c.entries[key]; !ok {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		pending[key] = true
+	}
+
+	lines := []string{}
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	}
+	for i, line := range lines {
+		key, _, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if ok && pending[key] && !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines[i] = key + " = " + c.String(key)
+			delete(pending, key)
+		}
+	}
+	for _, key := range keys {
+		if pending[key] {
+			lines = append(lines, key+" = "+c.String(key))
+		}
+	}
+
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
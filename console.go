@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+type Console struct {
+	open bool
+
+	input   TextInput
+	history []string
+	output  []string
+
+	background_texture uint32
+}
+
+var g_Console = Console{}
+
+const consoleMaxOutputLines = 200
+const consoleVisibleLines = 12
+const consoleTextScale = float32(1.0)
+
+func init_console() {
+	g_Console.background_texture = render.SolidTexture(color.RGBA{20, 20, 20, 200})
+
+	add_key_input_handler(INPUT_CONTEXT_CONSOLE, console_key_input)
+	set_text_input_handler(INPUT_CONTEXT_CONSOLE, console_text_input)
+
+	register_command("help", "list commands, or describe the given ones", func(names ...string) {
+		if len(names) == 0 {
+			console_print("commands: %s", strings.Join(complete_command(""), ", "))
+			console_print("help <command> describes one, tab completes names")
+		}
+		for _, name := range names {
+			command, ok := g_ConsoleCommands[name]
+			if !ok {
+				console_print("unknown command %q", name)
+				continue
+			}
+			console_print("%s", describe_command(command))
+		}
+	})
+	register_command("clear", "clear the console output", func() {
+		g_Console.output = nil
+	})
+}
+
+func toggle_console() {
+	g_Console.open = !g_Console.open
+
+	if g_Console.open {
+		push_input_context(INPUT_CONTEXT_CONSOLE)
+	} else {
+		pop_input_context(INPUT_CONTEXT_CONSOLE)
+	}
+}
+
+func console_print(format string, args ...any) {
+	g_Console.output = append(g_Console.output, fmt.Sprintf(format, args...))
+
+	if len(g_Console.output) > consoleMaxOutputLines {
+		g_Console.output = g_Console.output[len(g_Console.output)-consoleMaxOutputLines:]
+	}
+}
+
+func console_execute(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+
+	command, ok := g_ConsoleCommands[fields[0]]
+	if !ok {
+		console_print("unknown command %q", fields[0])
+		return
+	}
+	if err := run_command(command, fields[1:]); err != nil {
+		console_print("%s", err)
+	}
+}
+
+// Completes the command name being typed, or shows the usage once it's complete
+func console_complete() {
+	text := g_Console.input.String()
+	if strings.Contains(text, " ") {
+		if command, ok := g_ConsoleCommands[strings.Fields(text)[0]]; ok {
+			console_print("%s", describe_command(command))
+		}
+		return
+	}
+
+	names := complete_command(text)
+	switch len(names) {
+	case 0:
+		return
+	case 1:
+		g_Console.input.set(names[0] + " ")
+	default:
+		g_Console.input.set(common_prefix(names))
+		console_print("%s", strings.Join(names, "  "))
+	}
+}
+
+func console_key_input(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key == glfw.KeyEscape && action == glfw.Press {
+		toggle_console()
+		return
+	}
+
+	if key == glfw.KeyTab && action != glfw.Release {
+		console_complete()
+		return
+	}
+
+	line, submitted := g_Console.input.handle_key(key, action, mods)
+	if !submitted {
+		return
+	}
+
+	console_print("> %s", line)
+	if len(strings.TrimSpace(line)) > 0 {
+		g_Console.history = append(g_Console.history, line)
+	}
+	console_execute(line)
+}
+
+func console_text_input(char rune) {
+	// The toggle key also produces a character
+	if char == '`' || char == '~' {
+		return
+	}
+	g_Console.input.handle_char(char)
+}
+
+func render_console() {
+	if !g_Console.open {
+		return
+	}
+
+	line_height := text_line_height(consoleTextScale)
+	height := line_height * (consoleVisibleLines + 1.5)
+
+	draw_overlay_quad(g_Console.background_texture, 0, 0, ui_size().x, height)
+
+	text_color := color.RGBA{220, 220, 220, 255}
+
+	first_line := max(len(g_Console.output)-consoleVisibleLines, 0)
+	y := float32(4)
+	for _, line := range g_Console.output[first_line:] {
+		draw_text(line, 6, y, consoleTextScale, text_color)
+		y += line_height
+	}
+
+	g_Console.input.draw("> ", 6, height-line_height-4, consoleTextScale, color.RGBA{255, 255, 255, 255})
+}
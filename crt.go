@@ -0,0 +1,59 @@
+package main
+
+import "github.com/go-gl/gl/v4.1-core/gl"
+
+// Retro monitor look: scanlines, a bulging screen and color fringes towards the edges
+const crtScanlineIntensity = 0.35
+const crtCurvature = 0.08
+const crtAberration = 0.0025
+
+var crtFragmentShader = `
+#version 330
+
+uniform sampler2D source;
+uniform vec2 resolution;
+uniform float scanline_intensity;
+uniform float curvature;
+uniform float aberration;
+
+in vec2 fragTexCoord;
+
+out vec4 outputColor;
+
+vec2 barrel(vec2 uv) {
+    vec2 centered = uv * 2.0 - 1.0;
+    centered *= 1.0 + curvature * dot(centered, centered);
+    return centered * 0.5 + 0.5;
+}
+
+void main() {
+    vec2 uv = barrel(fragTexCoord);
+    if (uv.x < 0.0 || uv.x > 1.0 || uv.y < 0.0 || uv.y > 1.0) {
+        outputColor = vec4(0, 0, 0, 1);
+        return;
+    }
+
+    // Channels split further apart the further from the center
+    vec2 offset = (uv - 0.5) * aberration * 2.0;
+    vec3 color = vec3(
+        texture(source, uv + offset).r,
+        texture(source, uv).g,
+        texture(source, uv - offset).b);
+
+    // One dark line every other pixel row
+    float scanline = 0.5 + 0.5 * cos(uv.y * resolution.y * 3.14159265);
+    color *= mix(1.0, scanline, scanline_intensity);
+
+    outputColor = vec4(color, 1);
+}
+` + "\x00"
+
+func init_crt() {
+	add_post_pass("crt", crtFragmentShader,
+		func() bool { return g_Settings.crt_enabled },
+		func(pass *PostPass) {
+			gl.Uniform1f(pass.uniform("scanline_intensity"), crtScanlineIntensity)
+			gl.Uniform1f(pass.uniform("curvature"), crtCurvature)
+			gl.Uniform1f(pass.uniform("aberration"), crtAberration)
+		})
+}
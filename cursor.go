@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+type CursorKind int32
+
+const (
+	CURSOR_HIDDEN = iota
+	CURSOR_POINTER
+	CURSOR_CROSSHAIR
+	CURSOR_TEXT
+)
+
+// Picks the OS cursor from what the player is doing: none over gameplay, a pointer in menus and
+// a crosshair in the editor
+type Cursors struct {
+	window  *glfw.Window
+	current CursorKind
+	shapes  map[CursorKind]*glfw.Cursor
+}
+
+var g_Cursors = Cursors{current: -1, shapes: map[CursorKind]*glfw.Cursor{}}
+
+type cursorImage struct {
+	file     string
+	hotspot  [2]int
+	fallback glfw.StandardCursor
+}
+
+var cursorImages = map[CursorKind]cursorImage{
+	CURSOR_POINTER:   {"ui/pointer.png", [2]int{0, 0}, glfw.ArrowCursor},
+	CURSOR_CROSSHAIR: {"ui/crosshair.png", [2]int{12, 12}, glfw.CrosshairCursor},
+}
+
+func init_cursors(window *glfw.Window) {
+	g_Cursors.window = window
+
+	for kind, cursor_image := range cursorImages {
+		img, err := render.DecodeImage(texture_path(asset_path(cursor_image.file)))
+		if err != nil {
+			log.Println("cursor:", err)
+			g_Cursors.shapes[kind] = glfw.CreateStandardCursor(cursor_image.fallback)
+			continue
+		}
+		g_Cursors.shapes[kind] = glfw.CreateCursor(img, cursor_image.hotspot[0], cursor_image.hotspot[1])
+	}
+	g_Cursors.shapes[CURSOR_TEXT] = glfw.CreateStandardCursor(glfw.IBeamCursor)
+
+	step_cursor()
+}
+
+// Puts the default cursor back, the window may outlive the game when embedded or debugging
+func close_cursors() {
+	g_Cursors.window.SetCursor(nil)
+	g_Cursors.window.SetInputMode(glfw.CursorMode, glfw.CursorNormal)
+	for _, shape := range g_Cursors.shapes {
+		shape.Destroy()
+	}
+	g_Cursors.shapes = map[CursorKind]*glfw.Cursor{}
+}
+
+func wanted_cursor() CursorKind {
+	switch active_input_context() {
+	case INPUT_CONTEXT_GAMEPLAY:
+		// The on screen controls are pressed with the mouse
+		if g_Settings.touch_controls_enabled {
+			return CURSOR_POINTER
+		}
+		return CURSOR_HIDDEN
+	case INPUT_CONTEXT_EDITOR:
+		return CURSOR_CROSSHAIR
+	case INPUT_CONTEXT_CONSOLE, INPUT_CONTEXT_CHAT:
+		return CURSOR_TEXT
+	}
+	return CURSOR_POINTER
+}
+
+// Called once per frame after polling input, only touches the window when the cursor changes
+func step_cursor() {
+	kind := wanted_cursor()
+	if kind == g_Cursors.current {
+		return
+	}
+	g_Cursors.current = kind
+
+	if kind == CURSOR_HIDDEN {
+		g_Cursors.window.SetInputMode(glfw.CursorMode, glfw.CursorHidden)
+		return
+	}
+	g_Cursors.window.SetInputMode(glfw.CursorMode, glfw.CursorNormal)
+	g_Cursors.window.SetCursor(g_Cursors.shapes[kind])
+}
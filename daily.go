@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type DailyResult struct {
+	Score  int     `json:"score"`
+	Time   float32 `json:"time"`
+	Deaths int     `json:"deaths"`
+	// mapGeneratorVersion of the map it was on, 0 before it was recorded
+	Generator int `json:"generator"`
+}
+
+type DailyChallenge struct {
+	active   bool
+	date     string
+	finished bool
+
+	run_time float32
+	deaths   int
+}
+
+var g_DailyChallenge DailyChallenge
+
+// Dates are in UTC so every player is on the same challenge at the same time
+func daily_challenge_date() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+func daily_seed(date string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(date))
+	return int64(hash.Sum64())
+}
+
+// Must be called before the map is built
+func start_daily_challenge() {
+	g_DailyChallenge = DailyChallenge{active: true, date: daily_challenge_date()}
+	set_level(custom_level("daily-"+g_DailyChallenge.date, daily_seed(g_DailyChallenge.date)))
+}
+
+func init_daily_challenge() {
+	subscribe_event(EVENT_PLAYER_DIED, func(event GameEvent) {
+		if g_DailyChallenge.active && !g_DailyChallenge.finished {
+			g_DailyChallenge.deaths++
+		}
+	})
+	subscribe_event(EVENT_LEVEL_COMPLETED, func(event GameEvent) {
+		if g_DailyChallenge.active && !g_DailyChallenge.finished {
+			finish_daily_challenge()
+		}
+	})
+
+	register_command("map_seed", "[seed]: show the map seed, or generate a new map from one", func(args []string) {
+		if len(args) != 1 {
+			console_print("map seed is %d", g_MapSeed)
+			return
+		}
+		if g_DailyChallenge.active {
+			console_print("the map seed can't be changed during the daily challenge")
+			return
+		}
+
+		seed, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			console_print("usage: map_seed [seed]")
+			return
+		}
+		set_level(custom_level(fmt.Sprintf("seed-%d", seed), seed))
+		build_map()
+		load_ghost()
+		reset_speedrun()
+	})
+
+	register_command("daily", "list finished daily challenges", func() {
+		dates := make([]string, 0, len(g_SaveData.DailyResults))
+		for date := range g_SaveData.DailyResults {
+			dates = append(dates, date)
+		}
+		sort.Strings(dates)
+
+		if len(dates) == 0 {
+			console_print("no daily challenges finished yet")
+		}
+		for _, date := range dates {
+			result := g_SaveData.DailyResults[date]
+			console_print("%s: score %d, %.2fs, %d deaths", date, result.Score, result.Time, result.Deaths)
+		}
+	})
+}
+
+func daily_score(run_time float32, deaths int) int {
+	return max(10000-int(run_time*20)-deaths*500, 0)
+}
+
+func step_daily_challenge(dt float32) {
+	if !g_DailyChallenge.active || g_DailyChallenge.finished {
+		return
+	}
+
+	g_DailyChallenge.run_time += dt
+}
+
+// Only the best result of each day is kept
+func finish_daily_challenge() {
+	g_DailyChallenge.finished = true
+
+	result := DailyResult{
+		Score:  daily_score(g_DailyChallenge.run_time, g_DailyChallenge.deaths),
+		Time:   g_DailyChallenge.run_time,
+		Deaths: g_DailyChallenge.deaths,
+
+		Generator: mapGeneratorVersion,
+	}
+	console_print("daily challenge finished: score %d", result.Score)
+
+	// The day's map changes with the generator, a result on the old one doesn't count against it
+	best, ok := g_SaveData.DailyResults[g_DailyChallenge.date]
+	if ok && best.Generator == mapGeneratorVersion && best.Score >= result.Score {
+		return
+	}
+	g_SaveData.DailyResults[g_DailyChallenge.date] = result
+	write_save_data()
+}
+
+func render_daily_challenge() {
+	if !g_DailyChallenge.active {
+		return
+	}
+
+	text := fmt.Sprintf("Daily %s  %.1fs", g_DailyChallenge.date, g_DailyChallenge.run_time)
+	if g_DailyChallenge.finished {
+		text = fmt.Sprintf("Daily %s  finished, score %d", g_DailyChallenge.date,
+			daily_score(g_DailyChallenge.run_time, g_DailyChallenge.deaths))
+	}
+	draw_text(text, 8, 8, 1, color.RGBA{255, 255, 255, 255})
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+const debugWindowWidth = 480
+const debugWindowHeight = 600
+const debugFrameHistory = 240
+
+// Frame times above this fill the whole graph height
+const debugGraphMaxFrameTime = float32(1) / 20
+
+// Text is rebuilt a few times a second rather than every frame to keep it readable
+const debugTextRefreshInterval = float32(0.25)
+
+// A second window sharing the main GL context's objects, so textures and the
+// shader program are reused, but with its own vertex array since those aren't shared
+type DebugWindow struct {
+	window *glfw.Window
+	main   *glfw.Window
+
+	// The current program is per context, this one has to bind it too
+	program uint32
+
+	quad_vao uint32
+	quad_vbo uint32
+
+	projection_uniform int32
+	camera_uniform     int32
+
+	frame_times [debugFrameHistory]float32
+	frame_index int
+
+	lines        []string
+	since_update float32
+
+	bar_texture        uint32
+	budget_texture     uint32
+	background_texture uint32
+}
+
+var g_DebugWindow = DebugWindow{}
+
+func init_debug_window(main *glfw.Window, program uint32, projection_uniform int32, camera_uniform int32) {
+	g_DebugWindow.main = main
+	g_DebugWindow.program = program
+	g_DebugWindow.projection_uniform = projection_uniform
+	g_DebugWindow.camera_uniform = camera_uniform
+	g_DebugWindow.bar_texture = render.SolidTexture(color.RGBA{90, 200, 120, 255})
+	g_DebugWindow.budget_texture = render.SolidTexture(color.RGBA{220, 80, 80, 255})
+	g_DebugWindow.background_texture = render.SolidTexture(color.RGBA{25, 25, 30, 255})
+
+	register_command("debug_window", "toggle the debug window", func() {
+		if g_DebugWindow.window != nil {
+			close_debug_window()
+			return
+		}
+		open_debug_window()
+	})
+
+	if config_bool("debug_window") {
+		open_debug_window()
+	}
+}
+
+func open_debug_window() {
+	window, err := glfw.CreateWindow(debugWindowWidth, debugWindowHeight, "Game debug", nil, g_DebugWindow.main)
+	if err != nil {
+		log.Println("debug window:", err)
+		return
+	}
+	g_DebugWindow.window = window
+
+	window.MakeContextCurrent()
+	// The main window already waits for vsync, waiting twice would halve the frame rate
+	glfw.SwapInterval(0)
+
+	gl.GenVertexArrays(1, &g_DebugWindow.quad_vao)
+	gl.BindVertexArray(g_DebugWindow.quad_vao)
+	gl.GenBuffers(1, &g_DebugWindow.quad_vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, g_DebugWindow.quad_vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(quadVerticesOverlay)*4, gl.Ptr(quadVerticesOverlay), gl.STATIC_DRAW)
+	config_vertex_data(g_DebugWindow.program)
+
+	g_DebugWindow.main.MakeContextCurrent()
+}
+
+func close_debug_window() {
+	if g_DebugWindow.window == nil {
+		return
+	}
+
+	g_DebugWindow.window.MakeContextCurrent()
+	gl.DeleteVertexArrays(1, &g_DebugWindow.quad_vao)
+	gl.DeleteBuffers(1, &g_DebugWindow.quad_vbo)
+	g_DebugWindow.main.MakeContextCurrent()
+
+	g_DebugWindow.window.Destroy()
+	g_DebugWindow.window = nil
+}
+
+func record_debug_frame(elapsed float32) {
+	g_DebugWindow.frame_times[g_DebugWindow.frame_index] = elapsed
+	g_DebugWindow.frame_index = (g_DebugWindow.frame_index + 1) % debugFrameHistory
+	g_DebugWindow.since_update += elapsed
+}
+
+func debug_window_lines() []string {
+	total, worst := float32(0), float32(0)
+	for _, frame_time := range g_DebugWindow.frame_times {
+		total += frame_time
+		worst = max(worst, frame_time)
+	}
+	average := total / debugFrameHistory
+
+	g_Jobs.mutex.Lock()
+	queued_jobs := len(g_Jobs.queue)
+	g_Jobs.mutex.Unlock()
+
+	lines := []string{
+		fmt.Sprintf("frame %.2f ms avg, %.2f ms worst, %.0f fps", average*1000, worst*1000, 1/max(average, 0.0001)),
+		fmt.Sprintf("jobs queued %d, textures cached %d, text cached %d", queued_jobs, len(g_TextureCache), len(g_TextCache)),
+		"",
+		fmt.Sprintf("player pos (%.2f, %.2f) vel (%.2f, %.2f)", g_Player.pos.x, g_Player.pos.y, g_Player.vel.x, g_Player.vel.y),
+		fmt.Sprintf("player state %d, angle %.2f", g_Player.state, g_Player.angle_z),
+		fmt.Sprintf("camera (%.2f, %.2f) z %.2f", g_Camera.pos2D.x, g_Camera.pos2D.y, g_Camera.z_value),
+		fmt.Sprintf("blocks %d, collectibles %d/%d, scene nodes %d", len(g_Map.entities), collected_count(), len(g_Map.collectibles), len(g_Scene.nodes)),
+		"",
+		fmt.Sprintf("entities (%d)", len(g_Map.dynamic_entities)),
+	}
+	for i, entity := range g_Map.dynamic_entities {
+		lines = append(lines, fmt.Sprintf("  %d %s (%.2f, %.2f) node %d", i, entity.prefab.Id, entity.world_pos.x, entity.world_pos.y, entity.node))
+	}
+	return lines
+}
+
+// Leaves the main window's context current
+func render_debug_window() {
+	if g_DebugWindow.window == nil {
+		return
+	}
+	if g_DebugWindow.window.ShouldClose() {
+		close_debug_window()
+		return
+	}
+
+	if g_DebugWindow.lines == nil || g_DebugWindow.since_update >= debugTextRefreshInterval {
+		g_DebugWindow.lines = debug_window_lines()
+		g_DebugWindow.since_update = 0
+	}
+
+	g_DebugWindow.window.MakeContextCurrent()
+	defer g_DebugWindow.main.MakeContextCurrent()
+	gl.UseProgram(g_DebugWindow.program)
+
+	gl.Viewport(0, 0, debugWindowWidth, debugWindowHeight)
+	gl.ClearColor(0, 0, 0, 1)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+
+	projection := mgl32.Ortho(0, debugWindowWidth, debugWindowHeight, 0, -1, 1)
+	gl.UniformMatrix4fv(g_DebugWindow.projection_uniform, 1, false, &projection[0])
+	camera := mgl32.Ident4()
+	gl.UniformMatrix4fv(g_DebugWindow.camera_uniform, 1, false, &camera[0])
+
+	// draw_overlay_quad uses the overlay's vertex array, swap in the one valid in this context
+	main_vao := g_Overlay.quad_vao
+	g_Overlay.quad_vao = g_DebugWindow.quad_vao
+	defer func() { g_Overlay.quad_vao = main_vao }()
+
+	render_debug_frame_graph(8, 8, debugWindowWidth-16, 120)
+
+	white := color.RGBA{255, 255, 255, 255}
+	line_height := text_line_height(1)
+	for i, line := range g_DebugWindow.lines {
+		draw_text(line, 8, 140+float32(i)*line_height, 1, white)
+	}
+
+	g_DebugWindow.window.SwapBuffers()
+}
+
+// Oldest frame on the left, the red line marks the simulation step
+func render_debug_frame_graph(x float32, y float32, width float32, height float32) {
+	draw_overlay_quad(g_DebugWindow.background_texture, x, y, width, height)
+
+	bar_width := width / debugFrameHistory
+	for i := 0; i < debugFrameHistory; i++ {
+		frame_time := g_DebugWindow.frame_times[(g_DebugWindow.frame_index+i)%debugFrameHistory]
+		bar_height := min(frame_time/debugGraphMaxFrameTime, 1) * height
+		draw_overlay_quad(g_DebugWindow.bar_texture, x+float32(i)*bar_width, y+height-bar_height, bar_width, bar_height)
+	}
+
+	budget_y := y + height - simulationStep/debugGraphMaxFrameTime*height
+	draw_overlay_quad(g_DebugWindow.budget_texture, x, budget_y, width, 1)
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+)
+
+// Rooms and corridors carved out of a solid field of blocks. The field is split in two over and
+// over (binary space partitioning), each leaf gets a room and the two halves of every split are
+// joined by a corridor. Corridors climb in steps of one block so the player can jump up them.
+//
+// Coordinates are in cells of one block, x to the right and y up, cell (0, 0) is at the world origin.
+type DungeonGrid struct {
+	width, height int
+	solid         []bool
+}
+
+type DungeonCell struct {
+	x, y int
+}
+
+type DungeonRect struct {
+	x, y          int
+	width, height int
+}
+
+const dungeonWidth = 56
+const dungeonHeight = 28
+
+// Leaves aren't split below this size, it leaves room for the smallest room and its walls
+const dungeonMinLeafWidth = 12
+const dungeonMinLeafHeight = 8
+
+const dungeonMinRoomWidth = 6
+const dungeonMinRoomHeight = 4
+
+// Taller rooms would leave corridors entering high up, out of jumping reach from the floor
+const dungeonMaxRoomHeight = 5
+
+// Cells of headroom above the corridor floor
+const dungeonCorridorHeight = 3
+
+// Rows of the key and door palette
+const dungeonMaxKeys = 3
+
+// Size of a cell in world units, the same as a block
+const dungeonCellSize = 2
+
+func new_dungeon_grid(width int, height int) DungeonGrid {
+	grid := DungeonGrid{width: width, height: height, solid: make([]bool, width*height)}
+	for i := range grid.solid {
+		grid.solid[i] = true
+	}
+	return grid
+}
+
+// Cells outside of the grid count as solid
+func (grid *DungeonGrid) is_solid(cell DungeonCell) bool {
+	if cell.x < 0 || cell.y < 0 || cell.x >= grid.width || cell.y >= grid.height {
+		return true
+	}
+	return grid.solid[cell.y*grid.width+cell.x]
+}
+
+// The outer ring always stays solid so nothing can leave the field
+func (grid *DungeonGrid) carve(cell DungeonCell) {
+	if cell.x < 1 || cell.y < 1 || cell.x >= grid.width-1 || cell.y >= grid.height-1 {
+		return
+	}
+	grid.solid[cell.y*grid.width+cell.x] = false
+}
+
+func (grid *DungeonGrid) carve_rect(rect DungeonRect) {
+	for y := rect.y; y < rect.y+rect.height; y++ {
+		for x := rect.x; x < rect.x+rect.width; x++ {
+			grid.carve(DungeonCell{x, y})
+		}
+	}
+}
+
+func (rect DungeonRect) floor_center() DungeonCell {
+	return DungeonCell{rect.x + rect.width/2, rect.y}
+}
+
+func (rect DungeonRect) contains(cell DungeonCell) bool {
+	return cell.x >= rect.x && cell.y >= rect.y && cell.x < rect.x+rect.width && cell.y < rect.y+rect.height
+}
+
+func (cell DungeonCell) world() Vector2DF {
+	return Vector2DF{float32(cell.x * dungeonCellSize), float32(cell.y * dungeonCellSize)}
+}
+
+func sign(value int) int {
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	}
+	return 0
+}
+
+// Splits area until the leaves are too small, carving a room in each. Returns the rooms of the subtree.
+func split_dungeon(rng *rand.Rand, grid *DungeonGrid, area DungeonRect) []DungeonRect {
+	split_x := area.width >= 2*dungeonMinLeafWidth
+	split_y := area.height >= 2*dungeonMinLeafHeight
+
+	if !split_x && !split_y {
+		room := DungeonRect{width: dungeonMinRoomWidth + rng.Intn(area.width-dungeonMinRoomWidth-1)}
+		room.height = dungeonMinRoomHeight + rng.Intn(min(area.height-2, dungeonMaxRoomHeight)-dungeonMinRoomHeight+1)
+		room.x = area.x + 1 + rng.Intn(area.width-room.width-1)
+		room.y = area.y + 1 + rng.Intn(area.height-room.height-1)
+		grid.carve_rect(room)
+		return []DungeonRect{room}
+	}
+
+	// Splitting across the longer side keeps leaves from getting thin
+	vertical := split_x && (!split_y || area.width > area.height || rng.Intn(2) == 0)
+	first, second := area, area
+	if vertical {
+		at := dungeonMinLeafWidth + rng.Intn(area.width-2*dungeonMinLeafWidth+1)
+		first.width = at
+		second.x, second.width = area.x+at, area.width-at
+	} else {
+		at := dungeonMinLeafHeight + rng.Intn(area.height-2*dungeonMinLeafHeight+1)
+		first.height = at
+		second.y, second.height = area.y+at, area.height-at
+	}
+
+	first_rooms := split_dungeon(rng, grid, first)
+	second_rooms := split_dungeon(rng, grid, second)
+
+	// Joining the closest pair keeps corridors short and out of the way of the other rooms
+	from, to := first_rooms[0], second_rooms[0]
+	closest := -1
+	for _, a := range first_rooms {
+		for _, b := range second_rooms {
+			ca, cb := a.floor_center(), b.floor_center()
+			dx, dy := ca.x-cb.x, ca.y-cb.y
+			if distance := dx*dx + dy*dy; closest < 0 || distance < closest {
+				from, to, closest = a, b, distance
+			}
+		}
+	}
+	carve_corridor(grid, from.floor_center(), to.floor_center())
+
+	return append(first_rooms, second_rooms...)
+}
+
+// Climbs or drops one cell per cell of run until level with to, then runs straight to it. When the
+// two are right above each other the stairs head off to the side and the corridor walks back.
+func carve_corridor(grid *DungeonGrid, from DungeonCell, to DungeonCell) {
+	carve_headroom := func(cell DungeonCell) {
+		for i := 0; i < dungeonCorridorHeight; i++ {
+			grid.carve(DungeonCell{cell.x, cell.y + i})
+		}
+	}
+
+	cell := from
+	direction := sign(to.x - from.x)
+	if direction == 0 {
+		direction = 1
+	}
+	carve_headroom(cell)
+
+	for cell.y != to.y {
+		if cell.x != to.x {
+			direction = sign(to.x - cell.x)
+		}
+		if next := cell.x + direction; next < 1 || next >= grid.width-1 {
+			direction = -direction
+		}
+		cell.x += direction
+		cell.y += sign(to.y - cell.y)
+		carve_headroom(cell)
+	}
+	for cell.x != to.x {
+		cell.x += sign(to.x - cell.x)
+		carve_headroom(cell)
+	}
+}
+
+// Steps to every open cell reachable from start without passing blocked ones, -1 for the rest
+func (grid *DungeonGrid) flood(start DungeonCell, blocked map[DungeonCell]bool) []int {
+	distances := make([]int, len(grid.solid))
+	for i := range distances {
+		distances[i] = -1
+	}
+
+	distances[start.y*grid.width+start.x] = 0
+	queue := []DungeonCell{start}
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		for _, offset := range []DungeonCell{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			next := DungeonCell{cell.x + offset.x, cell.y + offset.y}
+			if grid.is_solid(next) || blocked[next] || distances[next.y*grid.width+next.x] >= 0 {
+				continue
+			}
+			distances[next.y*grid.width+next.x] = distances[cell.y*grid.width+cell.x] + 1
+			queue = append(queue, next)
+		}
+	}
+	return distances
+}
+
+// Open cell with a block right under it, somewhere to stand
+func (grid *DungeonGrid) is_floor(cell DungeonCell) bool {
+	return !grid.is_solid(cell) && grid.is_solid(DungeonCell{cell.x, cell.y - 1})
+}
+
+// Like flood, but only over floors and the way the player moves between them: walking across,
+// jumping up a step of at most climb cells with headroom over the start, jumping over a gap of at
+// most leap cells onto a floor level with the start, and dropping off a ledge straight down. Longer
+// arcs aren't followed, so it errs on the unreachable side.
+func (grid *DungeonGrid) flood_floors(start DungeonCell, climb int, leap int) []int {
+	distances := make([]int, len(grid.solid))
+	for i := range distances {
+		distances[i] = -1
+	}
+
+	distances[start.y*grid.width+start.x] = 0
+	queue := []DungeonCell{start}
+	visit := func(from DungeonCell, to DungeonCell) {
+		if distances[to.y*grid.width+to.x] >= 0 {
+			return
+		}
+		distances[to.y*grid.width+to.x] = distances[from.y*grid.width+from.x] + 1
+		queue = append(queue, to)
+	}
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		for _, dx := range []int{-1, 1} {
+			// Over the gap, with headroom for the arc all the way
+			for gap := 1; gap <= leap; gap++ {
+				over := DungeonCell{cell.x + gap*dx, cell.y}
+				if grid.is_solid(over) || grid.is_solid(DungeonCell{over.x, over.y + 1}) {
+					break
+				}
+				if landing := (DungeonCell{over.x + dx, over.y}); grid.is_floor(landing) && !grid.is_floor(over) {
+					visit(cell, landing)
+					break
+				}
+			}
+
+			next := DungeonCell{cell.x + dx, cell.y}
+			if grid.is_solid(next) {
+				// Up onto the step, the column above the player has to be open all the way
+				for rise := 1; rise <= climb; rise++ {
+					if grid.is_solid(DungeonCell{cell.x, cell.y + rise}) {
+						break
+					}
+					if step := (DungeonCell{next.x, next.y + rise}); grid.is_floor(step) {
+						next = step
+						break
+					}
+				}
+				if grid.is_solid(next) {
+					continue
+				}
+			}
+			// Off the ledge down to whatever is under it
+			for !grid.is_floor(next) {
+				next.y--
+			}
+			visit(cell, next)
+		}
+	}
+	return distances
+}
+
+// Open cells around the room, every way in or out of it
+func (grid *DungeonGrid) openings(room DungeonRect) []DungeonCell {
+	openings := []DungeonCell{}
+	for y := room.y - 1; y <= room.y+room.height; y++ {
+		for x := room.x - 1; x <= room.x+room.width; x++ {
+			cell := DungeonCell{x, y}
+			if !room.contains(cell) && !grid.is_solid(cell) {
+				openings = append(openings, cell)
+			}
+		}
+	}
+	return openings
+}
+
+// Fills the map with a dungeon: the player starts in the leftmost room and finishes in the one
+// farthest from it, which is locked behind the level's key and door pairs
+func generate_dungeon(level_map *Map, level LevelInfo, rng *rand.Rand, tileset string) {
+	grid := new_dungeon_grid(dungeonWidth, dungeonHeight)
+	rooms := split_dungeon(rng, &grid, DungeonRect{0, 0, dungeonWidth, dungeonHeight})
+
+	spawn := rooms[0]
+	for _, room := range rooms {
+		if room.x < spawn.x {
+			spawn = room
+		}
+	}
+	room_distance := func(distances []int, room DungeonRect) int {
+		cell := room.floor_center()
+		return distances[cell.y*grid.width+cell.x]
+	}
+
+	// Walking distance from the spawn, the exit is the room farthest away
+	distances := grid.flood(spawn.floor_center(), nil)
+	exit := spawn
+	for _, room := range rooms {
+		if room_distance(distances, room) > room_distance(distances, exit) {
+			exit = room
+		}
+	}
+
+	// Each lock guards the room holding the previous lock's key, so the keys are found in order.
+	// A key only goes where it can be reached with every door so far still closed.
+	doors := map[DungeonCell]bool{}
+	locked := []DungeonRect{spawn}
+	locking := exit
+	for variant := 0; variant < min(level.Keys, dungeonMaxKeys) && locking != spawn; variant++ {
+		openings := grid.openings(locking)
+		for _, cell := range openings {
+			doors[cell] = true
+		}
+		locked = append(locked, locking)
+
+		distances := grid.flood(spawn.floor_center(), doors)
+		key_room, key_distance := spawn, -1
+		for _, room := range rooms {
+			if distance := room_distance(distances, room); distance > key_distance && !slices.Contains(locked, room) {
+				key_room, key_distance = room, distance
+			}
+		}
+		if key_distance < 0 {
+			for _, cell := range openings {
+				delete(doors, cell)
+			}
+			break
+		}
+
+		for _, cell := range openings {
+			pos := cell.world()
+			level_map.generated_entities = append(level_map.generated_entities, LevelEntity{Prefab: "door", X: pos.x, Y: pos.y, Variant: &variant})
+		}
+		key := key_room.floor_center().world()
+		level_map.generated_entities = append(level_map.generated_entities, LevelEntity{Prefab: "key", X: key.x, Y: key.y, Variant: &variant})
+		locking = key_room
+	}
+
+	grid.add_blocks(level_map, tileset)
+
+	floors := []Vector2DF{}
+	for _, room := range rooms {
+		for x := room.x; x < room.x+room.width; x++ {
+			if below := (DungeonCell{x, room.y - 1}); grid.is_solid(below) {
+				floors = append(floors, below.world())
+			}
+		}
+	}
+
+	level_map.spawn = spawn.floor_center().world()
+	exit_bb := make_bounding_box_2d_xy(
+		float32(exit.x*dungeonCellSize)-1, float32((exit.x+exit.width)*dungeonCellSize)-1,
+		float32(exit.y*dungeonCellSize)-1, float32((exit.y+exit.height)*dungeonCellSize)-1)
+	level_map.exit = &exit_bb
+	level_map.goal_x = exit.floor_center().world().x
+
+	marker := exit.floor_center().world()
+	level_map.generated_entities = append(level_map.generated_entities, LevelEntity{Prefab: "marker", X: marker.x, Y: marker.y + 1})
+
+	place_collectibles_above(level_map, level.Collectibles, rng, floors)
+}
+
+// Only blocks next to open cells, the rest could never be seen or touched
+func (grid *DungeonGrid) add_blocks(level_map *Map, tileset string) {
+	for y := 0; y < grid.height; y++ {
+		for x := 0; x < grid.width; x++ {
+			cell := DungeonCell{x, y}
+			if !grid.is_solid(cell) || !grid.exposed(cell) {
+				continue
+			}
+			pos := cell.world()
+			bb := make_bounding_box_2d_centered(pos, Vector2DF{1, 1})
+			level_map.entities = append(level_map.entities, make_map_block(pos, bb, tileset))
+		}
+	}
+}
+
+func (grid *DungeonGrid) exposed(cell DungeonCell) bool {
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if !grid.is_solid(DungeonCell{cell.x + dx, cell.y + dy}) {
+				return true
+			}
+		}
+	}
+	return false
+}
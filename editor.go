@@ -0,0 +1,301 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type EditorSelection int32
+
+const (
+	EDITOR_SELECTION_NONE = iota
+	EDITOR_SELECTION_BLOCK
+	EDITOR_SELECTION_ENTITY
+)
+
+type GizmoHandle int32
+
+const (
+	GIZMO_HANDLE_NONE = iota
+	GIZMO_HANDLE_MOVE_X
+	GIZMO_HANDLE_MOVE_Y
+	GIZMO_HANDLE_MOVE_FREE
+	GIZMO_HANDLE_SCALE
+)
+
+// Moves and resizes blocks and entities of the current map with the mouse. The simulation
+// is held while editing, changes last until the level is rebuilt.
+type Editor struct {
+	enabled bool
+
+	selection       EditorSelection
+	selection_index int
+
+	hovered  GizmoHandle
+	dragging GizmoHandle
+	// Pointer and selection when the drag started, drags are applied from these so snapping doesn't drift
+	drag_pointer Vector2DF
+	drag_pos     Vector2DF
+	drag_half    Vector2DF
+
+	// The right button pans the camera
+	panning     bool
+	pan_pointer Vector2DF
+}
+
+var g_Editor = Editor{}
+
+var gizmoHandleTooltips = map[GizmoHandle]string{
+	GIZMO_HANDLE_MOVE_X:    "Drag to move along X",
+	GIZMO_HANDLE_MOVE_Y:    "Drag to move along Y",
+	GIZMO_HANDLE_MOVE_FREE: "Drag to move, snapped to the grid",
+	GIZMO_HANDLE_SCALE:     "Drag to resize",
+}
+
+// Handle sizes are a fraction of the visible height so they look the same at any zoom
+const gizmoScale = 0.15
+const gizmoPickRadius = 0.15
+
+func init_editor() {
+	register_command("editor", "toggle the level editor", func() {
+		set_editor_enabled(!g_Editor.enabled)
+		console_print("editor = %t", g_Editor.enabled)
+	})
+
+	add_tooltip_source(func(pointer Vector2DF) string {
+		if !g_Editor.enabled || g_Editor.panning {
+			return ""
+		}
+		return gizmoHandleTooltips[gizmo_handle_at(screen_to_world(pointer))]
+	})
+}
+
+func set_editor_enabled(enabled bool) {
+	if enabled == g_Editor.enabled {
+		return
+	}
+	g_Editor = Editor{enabled: enabled}
+	if enabled {
+		push_input_context(INPUT_CONTEXT_EDITOR)
+	} else {
+		pop_input_context(INPUT_CONTEXT_EDITOR)
+	}
+}
+
+func editor_active() bool {
+	return g_Editor.enabled
+}
+
+func gizmo_size() float32 {
+	return camera_visible_half_extents().y * gizmoScale
+}
+
+func editor_selection_valid() bool {
+	switch g_Editor.selection {
+	case EDITOR_SELECTION_BLOCK:
+		return g_Editor.selection_index < len(g_Map.entities)
+	case EDITOR_SELECTION_ENTITY:
+		return g_Editor.selection_index < len(g_Map.dynamic_entities)
+	}
+	return false
+}
+
+// Center and half size of the selection as drawn
+func editor_selection_box() (Vector2DF, Vector2DF) {
+	switch g_Editor.selection {
+	case EDITOR_SELECTION_BLOCK:
+		block := &g_Map.entities[g_Editor.selection_index]
+		return block.pos, block.bb.size().mul_scalar(0.5)
+	case EDITOR_SELECTION_ENTITY:
+		entity := &g_Map.dynamic_entities[g_Editor.selection_index]
+		return entity.world_pos, Vector2DF{entity.transform.scale[0], entity.transform.scale[1]}
+	}
+	return Vector2DF{}, Vector2DF{}
+}
+
+func editor_move_selection(pos Vector2DF) {
+	switch g_Editor.selection {
+	case EDITOR_SELECTION_BLOCK:
+		block := &g_Map.entities[g_Editor.selection_index]
+		half := block.bb.size().mul_scalar(0.5)
+		block.pos = pos
+		block.bb = make_bounding_box_2d_centered(pos, half)
+		block.transform.position = pos
+		g_Map.bounds = compute_map_bounds(g_Map.entities)
+		g_Map.nav = nil
+		g_Map.navmesh = nil
+	case EDITOR_SELECTION_ENTITY:
+		// pos and origin are relative to the parent, moving by the world delta keeps attached entities right
+		entity := &g_Map.dynamic_entities[g_Editor.selection_index]
+		delta := pos.subtract(entity.world_pos)
+		entity.pos = entity.pos.add(delta)
+		entity.origin = entity.origin.add(delta)
+		entity.transform.position = entity.pos
+		set_scene_node_local(entity.node, entity.transform.without_scale())
+		update_scene_graph()
+		for i := range g_Map.dynamic_entities {
+			g_Map.dynamic_entities[i].world_pos = scene_node_world_position(g_Map.dynamic_entities[i].node)
+		}
+	}
+}
+
+func editor_resize_selection(half Vector2DF) {
+	switch g_Editor.selection {
+	case EDITOR_SELECTION_BLOCK:
+		block := &g_Map.entities[g_Editor.selection_index]
+		block.bb = make_bounding_box_2d_centered(block.pos, half)
+		block.transform.scale = mgl32.Vec3{half.x, half.y, block.transform.scale[2]}
+		g_Map.bounds = compute_map_bounds(g_Map.entities)
+		g_Map.nav = nil
+		g_Map.navmesh = nil
+	case EDITOR_SELECTION_ENTITY:
+		entity := &g_Map.dynamic_entities[g_Editor.selection_index]
+		entity.transform.scale = mgl32.Vec3{half.x, half.y, entity.transform.scale[2]}
+	}
+}
+
+// Entities are drawn in front of blocks, so they're picked first
+func editor_pick(pointer Vector2DF) (EditorSelection, int) {
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		half := Vector2DF{entity.transform.scale[0], entity.transform.scale[1]}
+		if make_bounding_box_2d_centered(entity.world_pos, half).contains(pointer) {
+			return EDITOR_SELECTION_ENTITY, i
+		}
+	}
+	for i, block := range g_Map.entities {
+		if block.bb.contains(pointer) {
+			return EDITOR_SELECTION_BLOCK, i
+		}
+	}
+	return EDITOR_SELECTION_NONE, 0
+}
+
+func distance_to_segment(point Vector2DF, from Vector2DF, to Vector2DF) float32 {
+	segment := to.subtract(from)
+	length_squared := segment.length_squared()
+	if length_squared == 0 {
+		return point.distance(from)
+	}
+	t := min(max(point.subtract(from).dot(segment)/length_squared, 0), 1)
+	return point.distance(from.lerp(to, t))
+}
+
+func gizmo_handle_at(pointer Vector2DF) GizmoHandle {
+	if !editor_selection_valid() {
+		return GIZMO_HANDLE_NONE
+	}
+	center, half := editor_selection_box()
+	size := gizmo_size()
+	radius := size * gizmoPickRadius * 2
+
+	if pointer.distance(center.add(half)) <= radius {
+		return GIZMO_HANDLE_SCALE
+	}
+	if math.Abs(float64(pointer.x-center.x)) <= float64(size*0.2) && math.Abs(float64(pointer.y-center.y)) <= float64(size*0.2) {
+		return GIZMO_HANDLE_MOVE_FREE
+	}
+	if distance_to_segment(pointer, center, center.add(Vector2DF{size, 0})) <= radius {
+		return GIZMO_HANDLE_MOVE_X
+	}
+	if distance_to_segment(pointer, center, center.add(Vector2DF{0, size})) <= radius {
+		return GIZMO_HANDLE_MOVE_Y
+	}
+	return GIZMO_HANDLE_NONE
+}
+
+// Sizes snap to half the grid so blocks stay aligned to it from their center
+func snap_half_size(half Vector2DF) Vector2DF {
+	step := g_Grid.size / 2
+	snap := func(value float32) float32 {
+		return max(float32(math.Round(float64(value/step)))*step, step)
+	}
+	return Vector2DF{snap(half.x), snap(half.y)}
+}
+
+// Called once per frame after polling input
+func step_editor() {
+	if !g_Editor.enabled || active_input_context() != INPUT_CONTEXT_EDITOR {
+		return
+	}
+	if !editor_selection_valid() {
+		g_Editor.selection = EDITOR_SELECTION_NONE
+	}
+
+	screen_pointer := Vector2DF{g_Mouse.x, g_Mouse.y}
+	pointer := screen_to_world(screen_pointer)
+
+	if action_just_pressed(ACTION_POINTER_SECONDARY) {
+		g_Editor.panning = true
+		g_Editor.pan_pointer = pointer
+	}
+	if !action_held(ACTION_POINTER_SECONDARY) {
+		g_Editor.panning = false
+	}
+	if g_Editor.panning {
+		// The point grabbed stays under the cursor
+		g_Camera.pos2D = g_Camera.pos2D.add(g_Editor.pan_pointer.subtract(pointer))
+		g_Camera.previous_pos2D = g_Camera.pos2D
+		pointer = g_Editor.pan_pointer
+	}
+
+	if g_Editor.dragging == GIZMO_HANDLE_NONE {
+		g_Editor.hovered = gizmo_handle_at(pointer)
+	}
+
+	if action_just_pressed(ACTION_POINTER_PRIMARY) {
+		if g_Editor.hovered != GIZMO_HANDLE_NONE {
+			g_Editor.dragging = g_Editor.hovered
+			g_Editor.drag_pointer = pointer
+			g_Editor.drag_pos, g_Editor.drag_half = editor_selection_box()
+		} else {
+			g_Editor.selection, g_Editor.selection_index = editor_pick(pointer)
+		}
+	}
+	if !action_held(ACTION_POINTER_PRIMARY) {
+		g_Editor.dragging = GIZMO_HANDLE_NONE
+	}
+
+	delta := pointer.subtract(g_Editor.drag_pointer)
+	switch g_Editor.dragging {
+	case GIZMO_HANDLE_MOVE_X:
+		target := snap_to_grid(g_Editor.drag_pos.add(delta))
+		editor_move_selection(Vector2DF{target.x, g_Editor.drag_pos.y})
+	case GIZMO_HANDLE_MOVE_Y:
+		target := snap_to_grid(g_Editor.drag_pos.add(delta))
+		editor_move_selection(Vector2DF{g_Editor.drag_pos.x, target.y})
+	case GIZMO_HANDLE_MOVE_FREE:
+		editor_move_selection(snap_to_grid(g_Editor.drag_pos.add(delta)))
+	case GIZMO_HANDLE_SCALE:
+		editor_resize_selection(snap_half_size(g_Editor.drag_half.add(delta)))
+	}
+}
+
+func gizmo_handle_color(handle GizmoHandle, c color.RGBA) color.RGBA {
+	if g_Editor.dragging == handle || (g_Editor.dragging == GIZMO_HANDLE_NONE && g_Editor.hovered == handle) {
+		return color.RGBA{255, 255, 255, 255}
+	}
+	return c
+}
+
+// Queues the selection outline and its handles on the shapes batch
+func draw_editor_gizmos() {
+	if !g_Editor.enabled || !editor_selection_valid() {
+		return
+	}
+
+	center, half := editor_selection_box()
+	size := gizmo_size()
+	draw_rect(make_bounding_box_2d_centered(center, half), color.RGBA{255, 200, 0, 255})
+
+	draw_arrow(center, center.add(Vector2DF{size, 0}), gizmo_handle_color(GIZMO_HANDLE_MOVE_X, color.RGBA{230, 40, 40, 255}))
+	draw_arrow(center, center.add(Vector2DF{0, size}), gizmo_handle_color(GIZMO_HANDLE_MOVE_Y, color.RGBA{40, 200, 40, 255}))
+
+	free := size * 0.2
+	fill_rect(make_bounding_box_2d_centered(center, Vector2DF{free, free}), gizmo_handle_color(GIZMO_HANDLE_MOVE_FREE, color.RGBA{230, 200, 40, 200}))
+
+	corner := size * gizmoPickRadius
+	fill_rect(make_bounding_box_2d_centered(center.add(half), Vector2DF{corner, corner}), gizmo_handle_color(GIZMO_HANDLE_SCALE, color.RGBA{40, 120, 230, 255}))
+}
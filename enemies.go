@@ -0,0 +1,34 @@
+package main
+
+// Hurts the player through its hazard like any other, but landing on it from above stomps it instead
+type EnemyComponent struct {
+	// Stomps it takes, 0 counts as 1
+	Health float32 `json:"health"`
+	// Upward speed the player bounces off with
+	Bounce float32 `json:"bounce"`
+}
+
+// Returns true when the player came down on the enemy, which then doesn't hurt the player this time
+func stomp_enemy(entity *DynamicEntity, player *Player) bool {
+	enemy := entity.prefab.Components.Enemy
+	if enemy == nil || entity.picked || entity.prefab.Components.Collider == nil {
+		return false
+	}
+	if player.vel.y >= 0 || player.pos.y <= entity.world_pos.y {
+		return false
+	}
+	if !dynamic_entity_bounding_box(entity).intersects(player.bb) {
+		return false
+	}
+
+	player.vel.y = enemy.Bounce
+	player.state = FALLING
+	entity.hazard_cooldown = hazardCooldown
+
+	entity.health--
+	if entity.health <= 0 {
+		entity.picked = true
+		emit_event(GameEvent{kind: EVENT_ENEMY_DEFEATED, pos: entity.world_pos})
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+// Package assets resolves asset names through a stack of mounted directories,
+// so content in later mounts (mods, patches) shadows or extends earlier ones.
+package assets
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+type Mount struct {
+	Name string
+	Root string
+}
+
+type VFS struct {
+	mounts []Mount
+}
+
+func NewVFS() *VFS {
+	return &VFS{}
+}
+
+// Later mounts take priority over earlier ones
+func (vfs *VFS) Mount(name string, root string) {
+	vfs.mounts = append(vfs.mounts, Mount{name, root})
+}
+
+func (vfs *VFS) Unmount(name string) {
+	for i, mount := range vfs.mounts {
+		if mount.Name == name {
+			vfs.mounts = append(vfs.mounts[:i], vfs.mounts[i+1:]...)
+			return
+		}
+	}
+}
+
+func (vfs *VFS) Mounts() []Mount {
+	return vfs.mounts
+}
+
+// Returns the on disk path of the highest priority file with this name
+func (vfs *VFS) Find(name string) (string, bool) {
+	for i := len(vfs.mounts) - 1; i >= 0; i-- {
+		path := filepath.Join(vfs.mounts[i].Root, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// Lists a directory across all mounts sorted by name, entries in later mounts replace same named ones
+func (vfs *VFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	merged := map[string]fs.DirEntry{}
+	var first_err error
+	found := false
+
+	for _, mount := range vfs.mounts {
+		entries, err := os.ReadDir(filepath.Join(mount.Root, name))
+		if err != nil {
+			if first_err == nil {
+				first_err = err
+			}
+			continue
+		}
+
+		found = true
+		for _, entry := range entries {
+			merged[entry.Name()] = entry
+		}
+	}
+
+	if !found {
+		return nil, first_err
+	}
+
+	entries := make([]fs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	return entries, nil
+}
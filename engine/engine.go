@@ -0,0 +1,180 @@
+// Package engine is the stable, importable part of small-game-go: a window with
+// a fixed timestep game loop, scenes, a 2D quad renderer and input state.
+//
+// The API follows semantic versioning through the engine/vX.Y.Z tags of the
+// repository, anything under the root module is internal to the game.
+package engine
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+const Version = "0.2.0"
+
+type Config struct {
+	Title  string
+	Width  int
+	Height int
+	VSync  bool
+	// Seconds per Update call, defaults to 1/60
+	FixedStep float32
+	// Frames longer than this are clamped so a stall doesn't cause a burst of updates, defaults to 0.25
+	MaxFrameTime float32
+}
+
+// A scene owns the game while it is active, Game.SwitchScene moves to another one
+type Scene interface {
+	Enter(game *Game)
+	Update(game *Game, dt float32)
+	// alpha is how far the frame is between the last update and the next one, in [0, 1),
+	// for interpolating the drawn state
+	Render(game *Game, renderer *Renderer, alpha float32)
+	Exit(game *Game)
+}
+
+type Game struct {
+	config   Config
+	window   *glfw.Window
+	renderer *Renderer
+	input    *Input
+
+	scene      Scene
+	next_scene Scene
+	quit       bool
+	time       float64
+}
+
+// GLFW has to be driven from the main OS thread
+func init() {
+	runtime.LockOSThread()
+}
+
+// Opens the window and runs scene until the window is closed or Quit is called.
+// Must be called from the main goroutine.
+func Run(config Config, scene Scene) error {
+	if scene == nil {
+		return errors.New("engine: Run needs a scene")
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		config.Width, config.Height = 800, 600
+	}
+	if config.FixedStep <= 0 {
+		config.FixedStep = 1.0 / 60.0
+	}
+	if config.MaxFrameTime <= 0 {
+		config.MaxFrameTime = 0.25
+	}
+
+	if err := glfw.Init(); err != nil {
+		return err
+	}
+	defer glfw.Terminate()
+
+	glfw.WindowHint(glfw.Resizable, glfw.False)
+	glfw.WindowHint(glfw.ContextVersionMajor, 4)
+	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	window, err := glfw.CreateWindow(config.Width, config.Height, config.Title, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer window.Destroy()
+	window.MakeContextCurrent()
+
+	if config.VSync {
+		glfw.SwapInterval(1)
+	} else {
+		glfw.SwapInterval(0)
+	}
+
+	if err := gl.Init(); err != nil {
+		return err
+	}
+
+	renderer, err := new_renderer(config.Width, config.Height)
+	if err != nil {
+		return err
+	}
+	defer renderer.destroy()
+
+	game := &Game{
+		config:   config,
+		window:   window,
+		renderer: renderer,
+		input:    new_input(window),
+		scene:    scene,
+	}
+	game.loop()
+	return nil
+}
+
+func (game *Game) loop() {
+	game.scene.Enter(game)
+
+	previous := glfw.GetTime()
+	accumulator := float32(0)
+
+	for !game.quit && !game.window.ShouldClose() {
+		now := glfw.GetTime()
+		accumulator += min(float32(now-previous), game.config.MaxFrameTime)
+		previous = now
+
+		glfw.PollEvents()
+
+		updated := false
+		for accumulator >= game.config.FixedStep {
+			game.scene.Update(game, game.config.FixedStep)
+			// Edges are cleared once an update has seen them, a frame without updates keeps
+			// them for the next one
+			if !updated {
+				game.input.begin_frame()
+				updated = true
+			}
+			game.time += float64(game.config.FixedStep)
+			accumulator -= game.config.FixedStep
+
+			if game.next_scene != nil {
+				game.scene.Exit(game)
+				game.scene, game.next_scene = game.next_scene, nil
+				game.scene.Enter(game)
+			}
+		}
+
+		game.renderer.begin_frame()
+		game.scene.Render(game, game.renderer, accumulator/game.config.FixedStep)
+		game.window.SwapBuffers()
+	}
+
+	game.scene.Exit(game)
+}
+
+// Takes effect after the current update
+func (game *Game) SwitchScene(scene Scene) {
+	game.next_scene = scene
+}
+
+func (game *Game) Quit() {
+	game.quit = true
+}
+
+func (game *Game) Input() *Input {
+	return game.input
+}
+
+func (game *Game) Renderer() *Renderer {
+	return game.renderer
+}
+
+// Simulated seconds since Run started, advances in FixedStep increments
+func (game *Game) Time() float64 {
+	return game.time
+}
+
+func (game *Game) Size() (int, int) {
+	return game.config.Width, game.config.Height
+}
@@ -0,0 +1,69 @@
+// A minimal game built only on the public engine API: a box bouncing around
+// the window that can be nudged with the arrow keys.
+package main
+
+import (
+	"image/color"
+	"log"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine"
+)
+
+type BounceScene struct {
+	x, y   float32
+	vx, vy float32
+	// Position before the last update, drawing lerps from it by the frame's alpha
+	previous_x, previous_y float32
+}
+
+const boxSize = 40
+
+func (scene *BounceScene) Enter(game *engine.Game) {
+	scene.x, scene.y = 100, 100
+	scene.previous_x, scene.previous_y = scene.x, scene.y
+	scene.vx, scene.vy = 180, 140
+}
+
+func (scene *BounceScene) Update(game *engine.Game, dt float32) {
+	input := game.Input()
+	if input.KeyDown(glfw.KeyLeft) {
+		scene.vx -= 400 * dt
+	}
+	if input.KeyDown(glfw.KeyRight) {
+		scene.vx += 400 * dt
+	}
+	if input.KeyPressed(glfw.KeyEscape) {
+		game.Quit()
+	}
+
+	width, height := game.Size()
+	scene.previous_x, scene.previous_y = scene.x, scene.y
+	scene.x += scene.vx * dt
+	scene.y += scene.vy * dt
+	if scene.x < 0 || scene.x+boxSize > float32(width) {
+		scene.vx = -scene.vx
+		scene.x = min(max(scene.x, 0), float32(width)-boxSize)
+	}
+	if scene.y < 0 || scene.y+boxSize > float32(height) {
+		scene.vy = -scene.vy
+		scene.y = min(max(scene.y, 0), float32(height)-boxSize)
+	}
+}
+
+func (scene *BounceScene) Render(game *engine.Game, renderer *engine.Renderer, alpha float32) {
+	x := scene.previous_x + (scene.x-scene.previous_x)*alpha
+	y := scene.previous_y + (scene.y-scene.previous_y)*alpha
+	renderer.Clear(color.RGBA{20, 20, 30, 255})
+	renderer.DrawQuad(0, x, y, boxSize, boxSize, color.RGBA{230, 180, 60, 255})
+}
+
+func (scene *BounceScene) Exit(game *engine.Game) {}
+
+func main() {
+	config := engine.Config{Title: "bounce", Width: 640, Height: 480, VSync: true}
+	if err := engine.Run(config, &BounceScene{}); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,9 @@
+module github.com/guiteixeirapimentel/small-game-go/engine
+
+go 1.22.1
+
+require (
+	github.com/go-gl/gl v0.0.0-20210426225639-a3bfa832c8aa
+	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20210410170116-ea3d685f79fb
+	github.com/go-gl/mathgl v1.0.0
+)
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/input"
+)
+
+// Keyboard and mouse state, edges (pressed/released) last for one frame
+type Input struct {
+	keys    *input.Actions[glfw.Key]
+	buttons *input.Actions[glfw.MouseButton]
+
+	mouse_x float32
+	mouse_y float32
+}
+
+func new_input(window *glfw.Window) *Input {
+	state := &Input{
+		keys:    input.NewActions[glfw.Key](int(glfw.KeyLast) + 1),
+		buttons: input.NewActions[glfw.MouseButton](int(glfw.MouseButtonLast) + 1),
+	}
+
+	window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+		if key >= 0 && action != glfw.Repeat {
+			state.keys.Set(key, action == glfw.Press)
+		}
+	})
+	window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		state.buttons.Set(button, action == glfw.Press)
+	})
+	window.SetCursorPosCallback(func(w *glfw.Window, x float64, y float64) {
+		state.mouse_x, state.mouse_y = float32(x), float32(y)
+	})
+
+	return state
+}
+
+func (state *Input) begin_frame() {
+	state.keys.BeginFrame()
+	state.buttons.BeginFrame()
+}
+
+func (state *Input) KeyDown(key glfw.Key) bool {
+	return state.keys.Held(key)
+}
+
+func (state *Input) KeyPressed(key glfw.Key) bool {
+	return state.keys.JustPressed(key)
+}
+
+func (state *Input) KeyReleased(key glfw.Key) bool {
+	return state.keys.JustReleased(key)
+}
+
+func (state *Input) MouseDown(button glfw.MouseButton) bool {
+	return state.buttons.Held(button)
+}
+
+func (state *Input) MousePressed(button glfw.MouseButton) bool {
+	return state.buttons.JustPressed(button)
+}
+
+// In window pixels, origin at the top left
+func (state *Input) MousePosition() (float32, float32) {
+	return state.mouse_x, state.mouse_y
+}
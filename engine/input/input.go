@@ -0,0 +1,90 @@
+// Package input tracks action state and the stack of input contexts, independent of the windowing library.
+package input
+
+type ActionState struct {
+	Held         bool
+	JustPressed  bool
+	JustReleased bool
+}
+
+// Actions are dense indices, usually an enum of the game's actions
+type Actions[A ~int | ~int32] struct {
+	states []ActionState
+}
+
+func NewActions[A ~int | ~int32](count int) *Actions[A] {
+	return &Actions[A]{states: make([]ActionState, count)}
+}
+
+func (actions *Actions[A]) State(action A) ActionState {
+	return actions.states[action]
+}
+
+func (actions *Actions[A]) Held(action A) bool {
+	return actions.states[action].Held
+}
+
+func (actions *Actions[A]) JustPressed(action A) bool {
+	return actions.states[action].JustPressed
+}
+
+func (actions *Actions[A]) JustReleased(action A) bool {
+	return actions.states[action].JustReleased
+}
+
+func (actions *Actions[A]) Set(action A, pressed bool) {
+	state := &actions.states[action]
+
+	if pressed && !state.Held {
+		state.JustPressed = true
+	} else if !pressed && state.Held {
+		state.JustReleased = true
+	}
+	state.Held = pressed
+}
+
+// Edges only last for a single frame, call before polling the new events
+func (actions *Actions[A]) BeginFrame() {
+	for i := range actions.states {
+		actions.states[i].JustPressed = false
+		actions.states[i].JustReleased = false
+	}
+}
+
+// Releases every held action
+func (actions *Actions[A]) Reset() {
+	for i := range actions.states {
+		if actions.states[i].Held {
+			actions.states[i].JustReleased = true
+		}
+		actions.states[i].Held = false
+	}
+}
+
+// Only the context on top receives input, the base context can't be popped
+type ContextStack[C comparable] struct {
+	stack []C
+}
+
+func NewContextStack[C comparable](base C) *ContextStack[C] {
+	return &ContextStack[C]{stack: []C{base}}
+}
+
+func (contexts *ContextStack[C]) Active() C {
+	return contexts.stack[len(contexts.stack)-1]
+}
+
+func (contexts *ContextStack[C]) Push(context C) {
+	contexts.stack = append(contexts.stack, context)
+}
+
+// Removes the topmost instance of the context, returns false if it wasn't on the stack
+func (contexts *ContextStack[C]) Pop(context C) bool {
+	for i := len(contexts.stack) - 1; i > 0; i-- {
+		if contexts.stack[i] == context {
+			contexts.stack = append(contexts.stack[:i], contexts.stack[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package input
+
+import "testing"
+
+func TestActionsEdges(t *testing.T) {
+	type step struct {
+		// Set calls before the frame is checked, true presses and false releases
+		events []bool
+		want   ActionState
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"press then hold", []step{
+			{[]bool{true}, ActionState{Held: true, JustPressed: true}},
+			{nil, ActionState{Held: true}},
+		}},
+		{"release", []step{
+			{[]bool{true}, ActionState{Held: true, JustPressed: true}},
+			{[]bool{false}, ActionState{JustReleased: true}},
+			{nil, ActionState{}},
+		}},
+		{"repeated press isn't a new edge", []step{
+			{[]bool{true}, ActionState{Held: true, JustPressed: true}},
+			{[]bool{true}, ActionState{Held: true}},
+		}},
+		{"release without a press", []step{
+			{[]bool{false}, ActionState{}},
+		}},
+		{"tap within one frame", []step{
+			{[]bool{true, false}, ActionState{JustPressed: true, JustReleased: true}},
+			{nil, ActionState{}},
+		}},
+	}
+	for _, test := range tests {
+		actions := NewActions[int](1)
+		for i, step := range test.steps {
+			actions.BeginFrame()
+			for _, pressed := range step.events {
+				actions.Set(0, pressed)
+			}
+			if got := actions.State(0); got != step.want {
+				t.Errorf("%s, frame %d: State = %+v, want %+v", test.name, i, got, step.want)
+			}
+		}
+	}
+}
+
+func TestActionsReset(t *testing.T) {
+	actions := NewActions[int](2)
+	actions.Set(0, true)
+	actions.BeginFrame()
+	actions.Reset()
+
+	if got, want := actions.State(0), (ActionState{JustReleased: true}); got != want {
+		t.Errorf("held action after Reset = %+v, want %+v", got, want)
+	}
+	if got, want := actions.State(1), (ActionState{}); got != want {
+		t.Errorf("idle action after Reset = %+v, want %+v", got, want)
+	}
+}
+
+func TestContextStack(t *testing.T) {
+	contexts := NewContextStack("game")
+	contexts.Push("menu")
+	contexts.Push("console")
+	contexts.Push("menu")
+
+	if !contexts.Pop("menu") || contexts.Active() != "console" {
+		t.Errorf("Pop removes the topmost instance, active = %q, want %q", contexts.Active(), "console")
+	}
+	if contexts.Pop("game") {
+		t.Errorf("Pop of the base context succeeded")
+	}
+	if contexts.Pop("chat") {
+		t.Errorf("Pop of a context not on the stack succeeded")
+	}
+	contexts.Pop("console")
+	contexts.Pop("menu")
+	if contexts.Active() != "game" {
+		t.Errorf("active = %q after popping everything, want %q", contexts.Active(), "game")
+	}
+}
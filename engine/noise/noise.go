@@ -0,0 +1,221 @@
+// Package noise has seeded gradient noise for procedural content. The same seed
+// always gives the same values, so anything built from it can be reproduced.
+package noise
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Any 1D noise function with values roughly within [-1, 1]
+type Source1 interface {
+	Noise1(x float64) float64
+}
+
+// Any 2D noise function with values roughly within [-1, 1]
+type Source2 interface {
+	Noise2(x float64, y float64) float64
+}
+
+// Shuffled 0..255, repeated so lookups of i + 1 never have to wrap
+type permutation [512]uint8
+
+func new_permutation(seed int64) permutation {
+	var perm permutation
+	for i, value := range rand.New(rand.NewSource(seed)).Perm(256) {
+		perm[i] = uint8(value)
+		perm[i+256] = uint8(value)
+	}
+	return perm
+}
+
+func (perm *permutation) hash1(x int) uint8 {
+	return perm[x&255]
+}
+
+func (perm *permutation) hash2(x int, y int) uint8 {
+	return perm[int(perm[x&255])+y&255]
+}
+
+// One of 16 gradients spread over [-8, 8] in 1D
+func gradient1(hash uint8, x float64) float64 {
+	gradient := float64(1 + hash&7)
+	if hash&8 != 0 {
+		gradient = -gradient
+	}
+	return gradient * x
+}
+
+// The 8 directions of the improved Perlin noise, axis aligned and diagonal
+func gradient2(hash uint8, x float64, y float64) float64 {
+	switch hash & 7 {
+	case 0:
+		return x + y
+	case 1:
+		return -x + y
+	case 2:
+		return x - y
+	case 3:
+		return -x - y
+	case 4:
+		return x
+	case 5:
+		return -x
+	case 6:
+		return y
+	default:
+		return -y
+	}
+}
+
+// 6t^5 - 15t^4 + 10t^3, so the noise has no creases at the lattice points
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(a float64, b float64, t float64) float64 {
+	return a + (b-a)*t
+}
+
+type Perlin struct {
+	perm permutation
+}
+
+func NewPerlin(seed int64) *Perlin {
+	return &Perlin{perm: new_permutation(seed)}
+}
+
+// Zero at every integer
+func (p *Perlin) Noise1(x float64) float64 {
+	floor := math.Floor(x)
+	i := int(floor)
+	x -= floor
+
+	a := gradient1(p.perm.hash1(i), x)
+	b := gradient1(p.perm.hash1(i+1), x-1)
+	// The gradients go up to 8 and the slopes meet at most half way
+	return lerp(a, b, fade(x)) / 4
+}
+
+// Zero at every integer point
+func (p *Perlin) Noise2(x float64, y float64) float64 {
+	floor_x, floor_y := math.Floor(x), math.Floor(y)
+	i, j := int(floor_x), int(floor_y)
+	x -= floor_x
+	y -= floor_y
+
+	u, v := fade(x), fade(y)
+	bottom := lerp(gradient2(p.perm.hash2(i, j), x, y), gradient2(p.perm.hash2(i+1, j), x-1, y), u)
+	top := lerp(gradient2(p.perm.hash2(i, j+1), x, y-1), gradient2(p.perm.hash2(i+1, j+1), x-1, y-1), u)
+	return lerp(bottom, top, v)
+}
+
+// Cheaper than Perlin in 2D and without its axis aligned artifacts
+type Simplex struct {
+	perm permutation
+}
+
+func NewSimplex(seed int64) *Simplex {
+	return &Simplex{perm: new_permutation(seed)}
+}
+
+// Falloff of a corner's contribution, zero once the squared distance reaches radius_sq
+func simplex_corner(radius_sq float64, distance_sq float64, gradient float64) float64 {
+	t := radius_sq - distance_sq
+	if t <= 0 {
+		return 0
+	}
+	t *= t
+	return t * t * gradient
+}
+
+func (s *Simplex) Noise1(x float64) float64 {
+	floor := math.Floor(x)
+	i := int(floor)
+	x0 := x - floor
+	x1 := x0 - 1
+
+	n0 := simplex_corner(1, x0*x0, gradient1(s.perm.hash1(i), x0))
+	n1 := simplex_corner(1, x1*x1, gradient1(s.perm.hash1(i+1), x1))
+	return 0.395 * (n0 + n1)
+}
+
+// Skews the square lattice into triangles and back
+var (
+	simplexSkew2   = (math.Sqrt(3) - 1) / 2
+	simplexUnskew2 = (3 - math.Sqrt(3)) / 6
+)
+
+func (s *Simplex) Noise2(x float64, y float64) float64 {
+	skew := (x + y) * simplexSkew2
+	floor_x, floor_y := math.Floor(x+skew), math.Floor(y+skew)
+	i, j := int(floor_x), int(floor_y)
+
+	unskew := (floor_x + floor_y) * simplexUnskew2
+	x0 := x - (floor_x - unskew)
+	y0 := y - (floor_y - unskew)
+
+	// Which of the two triangles in the cell the point is in
+	i1, j1 := 0, 1
+	if x0 > y0 {
+		i1, j1 = 1, 0
+	}
+
+	x1 := x0 - float64(i1) + simplexUnskew2
+	y1 := y0 - float64(j1) + simplexUnskew2
+	x2 := x0 - 1 + 2*simplexUnskew2
+	y2 := y0 - 1 + 2*simplexUnskew2
+
+	n0 := simplex_corner(0.5, x0*x0+y0*y0, gradient2(s.perm.hash2(i, j), x0, y0))
+	n1 := simplex_corner(0.5, x1*x1+y1*y1, gradient2(s.perm.hash2(i+i1, j+j1), x1, y1))
+	n2 := simplex_corner(0.5, x2*x2+y2*y2, gradient2(s.perm.hash2(i+1, j+1), x2, y2))
+	return 70 * (n0 + n1 + n2)
+}
+
+// Fractal Brownian motion, layers of the same noise at rising frequency and falling amplitude
+type Fractal struct {
+	Octaves int
+
+	// Frequency multiplier between octaves
+	Lacunarity float64
+
+	// Amplitude multiplier between octaves
+	Gain float64
+}
+
+// The usual choice: each octave twice the frequency and half the amplitude of the previous one
+func DefaultFractal(octaves int) Fractal {
+	return Fractal{Octaves: octaves, Lacunarity: 2, Gain: 0.5}
+}
+
+// Divided by the total amplitude, so it stays within the range of the source
+func (f Fractal) FBm1(source Source1, x float64) float64 {
+	sum, total := 0.0, 0.0
+	amplitude := 1.0
+	for octave := 0; octave < f.Octaves; octave++ {
+		sum += amplitude * source.Noise1(x)
+		total += amplitude
+		x *= f.Lacunarity
+		amplitude *= f.Gain
+	}
+	if total == 0 {
+		return 0
+	}
+	return sum / total
+}
+
+func (f Fractal) FBm2(source Source2, x float64, y float64) float64 {
+	sum, total := 0.0, 0.0
+	amplitude := 1.0
+	for octave := 0; octave < f.Octaves; octave++ {
+		sum += amplitude * source.Noise2(x, y)
+		total += amplitude
+		x *= f.Lacunarity
+		y *= f.Lacunarity
+		amplitude *= f.Gain
+	}
+	if total == 0 {
+		return 0
+	}
+	return sum / total
+}
@@ -0,0 +1,39 @@
+// Package physics has the collision math for axis aligned boxes, with y pointing up.
+package physics
+
+type Box struct {
+	MinX, MinY float32
+	MaxX, MaxY float32
+}
+
+// Touching edges don't count as overlapping
+func Overlaps(a Box, b Box) bool {
+	return b.MinX < a.MaxX && b.MaxX > a.MinX && b.MinY < a.MaxY && b.MaxY > a.MinY
+}
+
+// How far a moving box would have to be pushed out of a static one in each direction
+type Penetration struct {
+	Left, Right float32
+	Up, Down    float32
+}
+
+func ComputePenetration(moving Box, static Box) Penetration {
+	return Penetration{
+		Left:  abs(static.MinX - moving.MaxX),
+		Right: abs(static.MaxX - moving.MinX),
+		Up:    abs(static.MaxY - moving.MinY),
+		Down:  abs(static.MinY - moving.MaxY),
+	}
+}
+
+// True when the shallowest way out is horizontal
+func (penetration Penetration) Horizontal() bool {
+	return min(penetration.Left, penetration.Right) < min(penetration.Up, penetration.Down)
+}
+
+func abs(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,58 @@
+package physics
+
+import "testing"
+
+func TestOverlaps(t *testing.T) {
+	unit := Box{0, 0, 1, 1}
+	tests := []struct {
+		name string
+		b    Box
+		want bool
+	}{
+		{"same box", unit, true},
+		{"inside", Box{0.25, 0.25, 0.75, 0.75}, true},
+		{"partial", Box{0.5, 0.5, 1.5, 1.5}, true},
+		{"touching right edge", Box{1, 0, 2, 1}, false},
+		{"touching top edge", Box{0, 1, 1, 2}, false},
+		{"apart", Box{2, 2, 3, 3}, false},
+		{"overlapping x only", Box{0.5, 2, 1.5, 3}, false},
+	}
+	for _, test := range tests {
+		if got := Overlaps(unit, test.b); got != test.want {
+			t.Errorf("%s: Overlaps(%v, %v) = %v, want %v", test.name, unit, test.b, got, test.want)
+		}
+		if got := Overlaps(test.b, unit); got != test.want {
+			t.Errorf("%s: Overlaps(%v, %v) = %v, want %v", test.name, test.b, unit, got, test.want)
+		}
+	}
+}
+
+func TestComputePenetration(t *testing.T) {
+	static := Box{0, 0, 4, 1}
+	tests := []struct {
+		name       string
+		moving     Box
+		want       Penetration
+		horizontal bool
+	}{
+		{"landed on top", Box{1, 0.9, 2, 1.9}, Penetration{Left: 2, Right: 3, Up: 0.1, Down: 1.9}, false},
+		{"hit from below", Box{1, -0.8, 2, 0.2}, Penetration{Left: 2, Right: 3, Up: 1.8, Down: 0.2}, false},
+		{"walked into the left side", Box{-0.75, 0, 0.25, 1}, Penetration{Left: 0.25, Right: 4.75, Up: 1, Down: 1}, true},
+		{"walked into the right side", Box{3.9, 0.1, 4.9, 1.1}, Penetration{Left: 4.9, Right: 0.1, Up: 0.9, Down: 1.1}, true},
+	}
+	for _, test := range tests {
+		got := ComputePenetration(test.moving, static)
+		if !penetrations_close(got, test.want) {
+			t.Errorf("%s: ComputePenetration(%v, %v) = %+v, want %+v", test.name, test.moving, static, got, test.want)
+		}
+		if got.Horizontal() != test.horizontal {
+			t.Errorf("%s: %+v.Horizontal() = %v, want %v", test.name, got, got.Horizontal(), test.horizontal)
+		}
+	}
+}
+
+func penetrations_close(a Penetration, b Penetration) bool {
+	const tolerance = 1e-5
+	return abs(a.Left-b.Left) <= tolerance && abs(a.Right-b.Right) <= tolerance &&
+		abs(a.Up-b.Up) <= tolerance && abs(a.Down-b.Down) <= tolerance
+}
@@ -0,0 +1,58 @@
+package physics
+
+import "math"
+
+// Starts at the origin and goes along the direction, which doesn't need to be normalized
+type Ray struct {
+	OriginX, OriginY float32
+	DirX, DirY       float32
+}
+
+type RayHit struct {
+	// Where along the ray it enters the box, in multiples of the direction
+	Distance float32
+	// Facing out of the side that was hit, zero when the ray starts inside the box
+	NormalX, NormalY float32
+}
+
+// Rays starting inside the box hit it at 0, boxes behind the origin are missed
+func Raycast(ray Ray, box Box) (RayHit, bool) {
+	near, far := float32(0), float32(math.Inf(1))
+	hit := RayHit{}
+
+	for axis, slab := range [2][4]float32{
+		{ray.OriginX, ray.DirX, box.MinX, box.MaxX},
+		{ray.OriginY, ray.DirY, box.MinY, box.MaxY},
+	} {
+		origin, dir, lower, upper := slab[0], slab[1], slab[2], slab[3]
+		if dir == 0 {
+			if origin < lower || origin > upper {
+				return RayHit{}, false
+			}
+			continue
+		}
+
+		enter, exit := (lower-origin)/dir, (upper-origin)/dir
+		normal := float32(-1)
+		if enter > exit {
+			enter, exit = exit, enter
+			normal = 1
+		}
+		if enter > near {
+			near = enter
+			hit.NormalX, hit.NormalY = 0, 0
+			if axis == 0 {
+				hit.NormalX = normal
+			} else {
+				hit.NormalY = normal
+			}
+		}
+		far = min(far, exit)
+		if near > far {
+			return RayHit{}, false
+		}
+	}
+
+	hit.Distance = near
+	return hit, true
+}
@@ -0,0 +1,27 @@
+package physics
+
+import "testing"
+
+func TestRaycast(t *testing.T) {
+	box := Box{2, -1, 4, 1}
+	tests := []struct {
+		name string
+		ray  Ray
+		want RayHit
+		hit  bool
+	}{
+		{"from the left", Ray{0, 0, 1, 0}, RayHit{Distance: 2, NormalX: -1}, true},
+		{"unnormalized direction", Ray{0, 0, 4, 0}, RayHit{Distance: 0.5, NormalX: -1}, true},
+		{"from above", Ray{3, 5, 0, -1}, RayHit{Distance: 4, NormalY: 1}, true},
+		{"from inside", Ray{3, 0, 1, 0}, RayHit{}, true},
+		{"box behind the origin", Ray{6, 0, 1, 0}, RayHit{}, false},
+		{"parallel and outside", Ray{0, 2, 1, 0}, RayHit{}, false},
+		{"diagonal miss", Ray{0, 0, 1, 1}, RayHit{}, false},
+	}
+	for _, test := range tests {
+		got, hit := Raycast(test.ray, box)
+		if hit != test.hit || got != test.want {
+			t.Errorf("%s: Raycast(%+v) = %+v, %v, want %+v, %v", test.name, test.ray, got, hit, test.want, test.hit)
+		}
+	}
+}
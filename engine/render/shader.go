@@ -0,0 +1,69 @@
+// Package render holds the OpenGL helpers shared by games: shader programs and textures.
+// Every function needs a current GL 4.1 core context.
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Sources must be NUL terminated
+func NewProgram(vertex_source string, fragment_source string) (uint32, error) {
+	vertex_shader, err := CompileShader(vertex_source, gl.VERTEX_SHADER)
+	if err != nil {
+		return 0, err
+	}
+
+	fragment_shader, err := CompileShader(fragment_source, gl.FRAGMENT_SHADER)
+	if err != nil {
+		return 0, err
+	}
+
+	program := gl.CreateProgram()
+
+	gl.AttachShader(program, vertex_shader)
+	gl.AttachShader(program, fragment_shader)
+	gl.LinkProgram(program)
+
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var log_length int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &log_length)
+
+		log := strings.Repeat("\x00", int(log_length+1))
+		gl.GetProgramInfoLog(program, log_length, nil, gl.Str(log))
+
+		return 0, fmt.Errorf("failed to link program: %v", log)
+	}
+
+	gl.DeleteShader(vertex_shader)
+	gl.DeleteShader(fragment_shader)
+
+	return program, nil
+}
+
+func CompileShader(source string, shader_type uint32) (uint32, error) {
+	shader := gl.CreateShader(shader_type)
+
+	csources, free := gl.Strs(source)
+	gl.ShaderSource(shader, 1, csources, nil)
+	free()
+	gl.CompileShader(shader)
+
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var log_length int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &log_length)
+
+		log := strings.Repeat("\x00", int(log_length+1))
+		gl.GetShaderInfoLog(shader, log_length, nil, gl.Str(log))
+
+		return 0, fmt.Errorf("failed to compile %v: %v", source, log)
+	}
+
+	return shader, nil
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/png"
+	"os"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+func LoadTexture(file string) (uint32, error) {
+	img, err := DecodeImage(file)
+	if err != nil {
+		return 0, err
+	}
+
+	return TextureFromImage(img)
+}
+
+// Touches no GL state, so it is safe to call off the main thread
+func DecodeImage(file string) (image.Image, error) {
+	img_file, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("texture %q not found on disk: %v", file, err)
+	}
+	defer img_file.Close()
+
+	img, _, err := image.Decode(img_file)
+	return img, err
+}
+
+// The image is converted to RGBA, which holds premultiplied alpha
+func TextureFromImage(img image.Image) (uint32, error) {
+	rgba := image.NewRGBA(img.Bounds())
+	if rgba.Stride != rgba.Rect.Size().X*4 {
+		return 0, fmt.Errorf("unsupported stride")
+	}
+	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
+
+	texture := uint32(0)
+	gl.GenTextures(1, &texture)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexImage2D(
+		gl.TEXTURE_2D,
+		0,
+		gl.RGBA,
+		int32(rgba.Rect.Size().X),
+		int32(rgba.Rect.Size().Y),
+		0,
+		gl.RGBA,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(rgba.Pix))
+
+	return texture, nil
+}
+
+// 1x1 texture, stretched over quads to draw flat colored rectangles
+func SolidTexture(c color.RGBA) uint32 {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, c)
+
+	texture, _ := TextureFromImage(img)
+	return texture
+}
@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"image/color"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Draws textured, tinted quads. Colors and textures use premultiplied alpha.
+type Renderer struct {
+	program            uint32
+	projection_uniform int32
+	view_uniform       int32
+	model_uniform      int32
+	tint_uniform       int32
+
+	quad_vao uint32
+	quad_vbo uint32
+
+	white_texture uint32
+	textures      map[string]uint32
+
+	width  int
+	height int
+}
+
+var quadVertices = []float32{
+	//  X, Y, U, V
+	0, 0, 0, 0,
+	1, 0, 1, 0,
+	0, 1, 0, 1,
+	1, 0, 1, 0,
+	1, 1, 1, 1,
+	0, 1, 0, 1,
+}
+
+var spriteVertexShader = `
+#version 330
+
+uniform mat4 projection;
+uniform mat4 view;
+uniform mat4 model;
+
+in vec2 vert;
+in vec2 vertTexCoord;
+
+out vec2 fragTexCoord;
+
+void main() {
+    fragTexCoord = vertTexCoord;
+    gl_Position = projection * view * model * vec4(vert, 0, 1);
+}
+` + "\x00"
+
+var spriteFragmentShader = `
+#version 330
+
+uniform sampler2D tex;
+uniform vec4 tint;
+
+in vec2 fragTexCoord;
+
+out vec4 outputColor;
+
+void main() {
+    outputColor = texture(tex, fragTexCoord) * tint;
+}
+` + "\x00"
+
+func new_renderer(width int, height int) (*Renderer, error) {
+	program, err := render.NewProgram(spriteVertexShader, spriteFragmentShader)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer := &Renderer{
+		program:            program,
+		projection_uniform: gl.GetUniformLocation(program, gl.Str("projection\x00")),
+		view_uniform:       gl.GetUniformLocation(program, gl.Str("view\x00")),
+		model_uniform:      gl.GetUniformLocation(program, gl.Str("model\x00")),
+		tint_uniform:       gl.GetUniformLocation(program, gl.Str("tint\x00")),
+		white_texture:      render.SolidTexture(color.RGBA{255, 255, 255, 255}),
+		textures:           map[string]uint32{},
+		width:              width,
+		height:             height,
+	}
+
+	gl.GenVertexArrays(1, &renderer.quad_vao)
+	gl.BindVertexArray(renderer.quad_vao)
+	gl.GenBuffers(1, &renderer.quad_vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, renderer.quad_vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(quadVertices)*4, gl.Ptr(quadVertices), gl.STATIC_DRAW)
+
+	vert_attrib := uint32(gl.GetAttribLocation(program, gl.Str("vert\x00")))
+	gl.EnableVertexAttribArray(vert_attrib)
+	gl.VertexAttribPointerWithOffset(vert_attrib, 2, gl.FLOAT, false, 4*4, 0)
+	tex_coord_attrib := uint32(gl.GetAttribLocation(program, gl.Str("vertTexCoord\x00")))
+	gl.EnableVertexAttribArray(tex_coord_attrib)
+	gl.VertexAttribPointerWithOffset(tex_coord_attrib, 2, gl.FLOAT, false, 4*4, 2*4)
+
+	return renderer, nil
+}
+
+func (renderer *Renderer) destroy() {
+	gl.DeleteBuffers(1, &renderer.quad_vbo)
+	gl.DeleteVertexArrays(1, &renderer.quad_vao)
+	gl.DeleteProgram(renderer.program)
+}
+
+func (renderer *Renderer) begin_frame() {
+	gl.UseProgram(renderer.program)
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	renderer.ScreenSpace()
+}
+
+func (renderer *Renderer) Clear(c color.RGBA) {
+	gl.ClearColor(float32(c.R)/255, float32(c.G)/255, float32(c.B)/255, float32(c.A)/255)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+}
+
+// Pixel coordinates with the origin at the top left, the default at the start of every frame
+func (renderer *Renderer) ScreenSpace() {
+	projection := mgl32.Ortho(0, float32(renderer.width), float32(renderer.height), 0, -1, 1)
+	view := mgl32.Ident4()
+	gl.UniformMatrix4fv(renderer.projection_uniform, 1, false, &projection[0])
+	gl.UniformMatrix4fv(renderer.view_uniform, 1, false, &view[0])
+}
+
+// World coordinates with y up, centered on (x, y) with view_height units visible vertically
+func (renderer *Renderer) Camera2D(x float32, y float32, view_height float32) {
+	half_height := view_height / 2
+	half_width := half_height * float32(renderer.width) / float32(renderer.height)
+	projection := mgl32.Ortho(-half_width, half_width, -half_height, half_height, -1, 1)
+	view := mgl32.Translate3D(-x, -y, 0)
+	gl.UniformMatrix4fv(renderer.projection_uniform, 1, false, &projection[0])
+	gl.UniformMatrix4fv(renderer.view_uniform, 1, false, &view[0])
+}
+
+// Cached by path, loading the same file twice returns the same texture
+func (renderer *Renderer) LoadTexture(path string) (uint32, error) {
+	if texture, ok := renderer.textures[path]; ok {
+		return texture, nil
+	}
+
+	texture, err := render.LoadTexture(path)
+	if err != nil {
+		return 0, err
+	}
+	renderer.textures[path] = texture
+	return texture, nil
+}
+
+// A texture of 0 draws a flat colored rectangle
+func (renderer *Renderer) DrawQuad(texture uint32, x float32, y float32, w float32, h float32, tint color.RGBA) {
+	if texture == 0 {
+		texture = renderer.white_texture
+	}
+
+	model := mgl32.Translate3D(x, y, 0).Mul4(mgl32.Scale3D(w, h, 1))
+	gl.UniformMatrix4fv(renderer.model_uniform, 1, false, &model[0])
+	gl.Uniform4f(renderer.tint_uniform, float32(tint.R)/255, float32(tint.G)/255, float32(tint.B)/255, float32(tint.A)/255)
+
+	gl.BindVertexArray(renderer.quad_vao)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+}
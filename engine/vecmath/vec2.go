@@ -0,0 +1,98 @@
+// Package vecmath has 2D vector math generic over the float precision, so a
+// deterministic simulation can run in float64 while rendering stays float32.
+package vecmath
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type Float interface {
+	~float32 | ~float64
+}
+
+type Vec2[T Float] struct {
+	X T
+	Y T
+}
+
+func V2[T Float](x T, y T) Vec2[T] {
+	return Vec2[T]{x, y}
+}
+
+// Converts between precisions, e.g. Convert[float32](v) for a Vec2[float64]
+func Convert[U Float, T Float](v Vec2[T]) Vec2[U] {
+	return Vec2[U]{U(v.X), U(v.Y)}
+}
+
+func FromMgl32[T Float](v mgl32.Vec2) Vec2[T] {
+	return Vec2[T]{T(v[0]), T(v[1])}
+}
+
+func (v Vec2[T]) Mgl32() mgl32.Vec2 {
+	return mgl32.Vec2{float32(v.X), float32(v.Y)}
+}
+
+// Extends to 3D with the given z, as used for model translations
+func (v Vec2[T]) Mgl32Vec3(z float32) mgl32.Vec3 {
+	return mgl32.Vec3{float32(v.X), float32(v.Y), z}
+}
+
+func (v Vec2[T]) Add(rhs Vec2[T]) Vec2[T] {
+	return Vec2[T]{v.X + rhs.X, v.Y + rhs.Y}
+}
+
+func (v Vec2[T]) Sub(rhs Vec2[T]) Vec2[T] {
+	return Vec2[T]{v.X - rhs.X, v.Y - rhs.Y}
+}
+
+func (v Vec2[T]) Scale(scalar T) Vec2[T] {
+	return Vec2[T]{v.X * scalar, v.Y * scalar}
+}
+
+func (v Vec2[T]) Dot(rhs Vec2[T]) T {
+	return v.X*rhs.X + v.Y*rhs.Y
+}
+
+func (v Vec2[T]) LengthSquared() T {
+	return v.Dot(v)
+}
+
+func (v Vec2[T]) Length() T {
+	return T(math.Sqrt(float64(v.LengthSquared())))
+}
+
+func (v Vec2[T]) Distance(rhs Vec2[T]) T {
+	return v.Sub(rhs).Length()
+}
+
+// The zero vector stays zero
+func (v Vec2[T]) Normalized() Vec2[T] {
+	length := v.Length()
+	if length == 0 {
+		return Vec2[T]{}
+	}
+	return v.Scale(1 / length)
+}
+
+func (v Vec2[T]) Lerp(to Vec2[T], t T) Vec2[T] {
+	return v.Add(to.Sub(v).Scale(t))
+}
+
+// Counter clockwise, angle in radians
+func (v Vec2[T]) Rotate(angle T) Vec2[T] {
+	sin, cos := math.Sincos(float64(angle))
+	return Vec2[T]{
+		v.X*T(cos) - v.Y*T(sin),
+		v.X*T(sin) + v.Y*T(cos),
+	}
+}
+
+func (v Vec2[T]) ClampLength(max_length T) Vec2[T] {
+	length_squared := v.LengthSquared()
+	if length_squared <= max_length*max_length {
+		return v
+	}
+	return v.Scale(max_length / T(math.Sqrt(float64(length_squared))))
+}
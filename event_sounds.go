@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"os"
+)
+
+type EventSound struct {
+	Sounds     []string `json:"sounds"`
+	Volume     float32  `json:"volume"`
+	PitchMin   float32  `json:"pitch_min"`
+	PitchMax   float32  `json:"pitch_max"`
+	Positional bool     `json:"positional"`
+	// How far enemies hear it, in world units, 0 for not at all
+	Loudness float32 `json:"loudness"`
+
+	last_variant int
+}
+
+// Loads the table mapping event names to sounds and plays them as the events fire
+func init_event_sounds(table_name string) {
+	data, err := os.ReadFile(asset_path(table_name))
+	if err != nil {
+		log.Println("event sounds:", err)
+		return
+	}
+
+	table := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &table); err != nil {
+		log.Printf("event sounds: %s: %v", table_name, err)
+		return
+	}
+
+	for event_name, entry := range table {
+		kind, ok := gameEventNames[event_name]
+		if !ok {
+			log.Printf("event sounds: %s: unknown event %q", table_name, event_name)
+			continue
+		}
+
+		event_sound := &EventSound{Volume: 1, PitchMin: 1, PitchMax: 1}
+		if err := json.Unmarshal(entry, event_sound); err != nil {
+			log.Printf("event sounds: %s: %s: %v", table_name, event_name, err)
+			continue
+		}
+
+		// Heard even with the audio muted
+		if event_sound.Loudness > 0 {
+			loudness := event_sound.Loudness
+			subscribe_event(kind, func(event GameEvent) { make_noise(event.pos, loudness) })
+		}
+
+		// Drop missing variants up front instead of failing on every play
+		loaded := event_sound.Sounds[:0]
+		for _, sound := range event_sound.Sounds {
+			if _, err := load_sound(sound); err != nil {
+				log.Println("event sounds:", err)
+				continue
+			}
+			loaded = append(loaded, sound)
+		}
+		event_sound.Sounds = loaded
+		event_sound.last_variant = -1
+
+		if len(event_sound.Sounds) == 0 {
+			continue
+		}
+
+		subscribe_event(kind, event_sound.play)
+	}
+}
+
+func (event_sound *EventSound) play(event GameEvent) {
+	// Avoid repeating the same variant twice in a row
+	variant := rand.Intn(len(event_sound.Sounds))
+	if variant == event_sound.last_variant && len(event_sound.Sounds) > 1 {
+		variant = (variant + 1) % len(event_sound.Sounds)
+	}
+	event_sound.last_variant = variant
+
+	name := event_sound.Sounds[variant]
+	var voice *Voice
+	if event_sound.Positional {
+		voice = play_sound_at(name, event_sound.Volume, event.pos)
+	} else {
+		voice = play_sound(name, event_sound.Volume)
+	}
+	if voice == nil {
+		return
+	}
+
+	pitch := event_sound.PitchMin
+	if event_sound.PitchMax > event_sound.PitchMin {
+		pitch += rand.Float32() * (event_sound.PitchMax - event_sound.PitchMin)
+	}
+	if pitch > 0 {
+		g_Mixer.mutex.Lock()
+		voice.pitch = float64(pitch)
+		g_Mixer.mutex.Unlock()
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+type GameEventType int32
+
+const (
+	EVENT_PLAYER_LANDED = iota
+	EVENT_PLAYER_DAMAGED
+	EVENT_PLAYER_JUMPED
+	EVENT_PLAYER_DIED
+	EVENT_COLLECTIBLE_PICKED
+	EVENT_LEVEL_COMPLETED
+	EVENT_CHECKPOINT_REACHED
+	EVENT_ENEMY_DEFEATED
+	EVENT_WAVE_STARTED
+	EVENT_WAVE_CLEARED
+	EVENT_BOSS_PHASE_STARTED
+	EVENT_BOSS_DEFEATED
+)
+
+// Names used to refer to events from data files
+var gameEventNames = map[string]GameEventType{
+	"player_landed":      EVENT_PLAYER_LANDED,
+	"player_damaged":     EVENT_PLAYER_DAMAGED,
+	"player_jumped":      EVENT_PLAYER_JUMPED,
+	"player_died":        EVENT_PLAYER_DIED,
+	"collectible_picked": EVENT_COLLECTIBLE_PICKED,
+	"level_completed":    EVENT_LEVEL_COMPLETED,
+	"checkpoint_reached": EVENT_CHECKPOINT_REACHED,
+	"enemy_defeated":     EVENT_ENEMY_DEFEATED,
+	"wave_started":       EVENT_WAVE_STARTED,
+	"wave_cleared":       EVENT_WAVE_CLEARED,
+	"boss_phase_started": EVENT_BOSS_PHASE_STARTED,
+	"boss_defeated":      EVENT_BOSS_DEFEATED,
+}
+
+type GameEvent struct {
+	kind GameEventType
+	pos  Vector2DF
+
+	// Event specific strength, e.g. impact speed for landings, damage taken, the wave or the boss phase number
+	magnitude float32
+}
+
+var g_EventHandlers = map[GameEventType][]func(GameEvent){}
+
+func subscribe_event(kind GameEventType, handler func(GameEvent)) {
+	g_EventHandlers[kind] = append(g_EventHandlers[kind], handler)
+}
+
+func emit_event(event GameEvent) {
+	for _, handler := range g_EventHandlers[event.kind] {
+		handler(event)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+// Boids: steers along with nearby entities of the same prefab, adding to the steering forces.
+// Separation keeps them apart, alignment matches their headings and cohesion pulls them together,
+// so swarms move as a group without any pathfinding.
+type FlockComponent struct {
+	// How far neighbors are seen
+	Radius float32 `json:"radius"`
+	// Neighbors closer than this are pushed away from
+	SeparationRadius float32 `json:"separation_radius"`
+
+	Separation float32 `json:"separation"`
+	Alignment  float32 `json:"alignment"`
+	Cohesion   float32 `json:"cohesion"`
+}
+
+// Larger than any flock radius would usually be, so queries only touch the cells around
+const flockCellSize = 4
+
+// Flocking entities by where they were at the start of the step
+var g_FlockHash = new_spatial_hash(flockCellSize)
+
+func fill_flock_hash() {
+	g_FlockHash.clear()
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		if entity.prefab.Components.Flock != nil && !entity.picked {
+			g_FlockHash.insert(i, entity.pos)
+		}
+	}
+}
+
+func flock_force(entity *DynamicEntity, steering *SteeringComponent, flock *FlockComponent) Vector2DF {
+	separation, velocity, center := Vector2DF{}, Vector2DF{}, Vector2DF{}
+	neighbors := 0
+
+	g_FlockHash.query(entity.pos, flock.Radius, func(id int) {
+		other := &g_Map.dynamic_entities[id]
+		if other == entity || other.prefab != entity.prefab {
+			return
+		}
+		offset := entity.pos.subtract(other.pos)
+		distance := offset.length()
+		if distance > flock.Radius {
+			return
+		}
+
+		// Harder the closer they are
+		if distance < flock.SeparationRadius && distance > 0 {
+			separation = separation.add(offset.normalized().mul_scalar(1 - distance/flock.SeparationRadius))
+		}
+		velocity = velocity.add(other.velocity)
+		center = center.add(other.pos)
+		neighbors++
+	})
+	if neighbors == 0 {
+		return Vector2DF{}
+	}
+
+	count := 1 / float32(neighbors)
+	force := separation.normalized().mul_scalar(steering.MaxSpeed).mul_scalar(flock.Separation)
+	force = force.add(velocity.mul_scalar(count).subtract(entity.velocity).mul_scalar(flock.Alignment))
+	force = force.add(seek(entity, steering, center.mul_scalar(count)).mul_scalar(flock.Cohesion))
+	return force
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+type WindowFocus struct {
+	focused   bool
+	iconified bool
+	// When the last frame finished, for throttling while unfocused
+	last_frame time.Time
+}
+
+var g_Focus = WindowFocus{focused: true}
+
+func init_focus(window *glfw.Window) {
+	window.SetFocusCallback(func(w *glfw.Window, focused bool) {
+		g_Focus.focused = focused
+		set_audio_focus(focused)
+	})
+	window.SetIconifyCallback(func(w *glfw.Window, iconified bool) {
+		g_Focus.iconified = iconified
+		set_audio_focus(!iconified && g_Focus.focused)
+	})
+
+	register_command("focus_pause", "[on|off]: pause while the window is unfocused", func(args []string) {
+		if len(args) == 1 {
+			g_Settings.pause_on_focus_loss = args[0] == "1" || args[0] == "on"
+		}
+		console_print("focus_pause = %t", g_Settings.pause_on_focus_loss)
+	})
+	register_command("focus_mute", "[on|off]: mute while the window is unfocused", func(args []string) {
+		if len(args) == 1 {
+			g_Settings.audio_mute_on_focus_loss = args[0] == "1" || args[0] == "on"
+		}
+		console_print("focus_mute = %t", g_Settings.audio_mute_on_focus_loss)
+	})
+	register_command("unfocused_fps", "[fps]: frame rate limit while unfocused, 0 for unlimited", func(args []string) {
+		if len(args) == 1 {
+			value, err := strconv.Atoi(args[0])
+			if err != nil || value < 0 {
+				console_print("usage: unfocused_fps <frames per second, 0 for unlimited>")
+				return
+			}
+			g_Settings.unfocused_frame_rate = value
+		}
+		console_print("unfocused_fps = %d", g_Settings.unfocused_frame_rate)
+	})
+}
+
+func window_unfocused() bool {
+	return !g_Focus.focused || g_Focus.iconified
+}
+
+// Online the other players keep going, so the simulation does too
+func simulation_paused_by_focus() bool {
+	return window_unfocused() && g_Settings.pause_on_focus_loss && g_Net.conn == nil
+}
+
+// Sleeps out the rest of the frame while unfocused, called once per frame after presenting
+func throttle_unfocused_frame() {
+	if window_unfocused() && g_Settings.unfocused_frame_rate > 0 {
+		frame_time := time.Second / time.Duration(g_Settings.unfocused_frame_rate)
+		if remaining := frame_time - time.Since(g_Focus.last_frame); remaining > 0 {
+			time.Sleep(remaining)
+		}
+	}
+	g_Focus.last_frame = time.Now()
+}
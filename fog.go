@@ -0,0 +1,236 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Darkens what the player hasn't been near yet, on levels that ask for it. Coverage is kept per
+// cell of the level bounds, drawn stretched over the world and over the minimap, and saved so
+// coming back to a level shows what was already explored.
+type FogOfWar struct {
+	// Save data key of the level being explored, empty while the level has no fog
+	key    string
+	origin Vector2DF
+	width  int
+	height int
+	// 0 unexplored to 255 explored, row 0 is the bottom of the level
+	explored []uint8
+	// Cells with a block, for the minimap
+	solid []bool
+
+	// Reuploaded when exploring changed the coverage
+	dirty           bool
+	texture         uint32
+	minimap_texture uint32
+	texture_size    [2]int
+
+	minimap *Widget
+}
+
+var g_Fog = FogOfWar{}
+
+const fogCellSize = 1.0
+
+// Cells this close to the player are revealed, fading out over the soft edge
+const fogRevealRadius = 7.0
+const fogSoftEdge = 2.0
+
+// Alpha of the black over unexplored parts of the world, the minimap goes fully dark
+const fogDarkness = 0.85
+
+const minimapWidth = 200
+const minimapHeight = 100
+
+var minimapBlockColor = color.RGBA{200, 190, 170, 255}
+var minimapOpenColor = color.RGBA{40, 40, 55, 200}
+
+func init_fog() {
+	g_Fog.minimap = add_widget(nil, new_widget(uiTopRight, uiTopRight, Vector2DF{-16, 32}, Vector2DF{minimapWidth, minimapHeight}))
+	g_Fog.minimap.update = func(widget *Widget) { widget.hidden = !hud_visible() || g_Fog.key == "" }
+	g_Fog.minimap.draw = draw_minimap
+
+	register_command("fog", "show how much of the level is explored, or reset it to unexplored", func(actions ...string) {
+		if g_Fog.key == "" {
+			console_print("fog: the level has no fog of war")
+			return
+		}
+		if len(actions) > 0 {
+			if actions[0] != "reset" {
+				console_print("unknown action %q, expected reset", actions[0])
+				return
+			}
+			fill_fog(0)
+		}
+		console_print("fog: %.0f%% explored", fog_explored_fraction()*100)
+	})
+}
+
+// Every cell at value, explored or not
+func fill_fog(value uint8) {
+	for i := range g_Fog.explored {
+		g_Fog.explored[i] = value
+	}
+	store_fog()
+}
+
+// Called once the map is built, picks up what the save remembers of the level
+func reset_fog() {
+	g_Fog.key = ""
+	if !g_Level.Fog {
+		return
+	}
+
+	bounds := g_Map.bounds
+	g_Fog.origin = bounds.min_corner()
+	g_Fog.width = max(int(bounds.size().x/fogCellSize+0.5), 1)
+	g_Fog.height = max(int(bounds.size().y/fogCellSize+0.5), 1)
+	g_Fog.dirty = true
+
+	// Generated levels differ per seed, and so does what was explored of them
+	g_Fog.key = g_Level.Id + "/" + map_seed_key(g_MapSeed)
+	explored := g_SaveData.Fog[g_Fog.key]
+	if len(explored) != g_Fog.width*g_Fog.height {
+		explored = make([]uint8, g_Fog.width*g_Fog.height)
+	}
+	g_Fog.explored = explored
+
+	g_Fog.solid = make([]bool, len(explored))
+	for _, entity := range g_Map.entities {
+		lower, upper := fog_cell(entity.bb.min_corner()), fog_cell(entity.bb.max_corner().subtract(Vector2DF{0.01, 0.01}))
+		for y := max(lower.y, 0); y <= min(upper.y, g_Fog.height-1); y++ {
+			for x := max(lower.x, 0); x <= min(upper.x, g_Fog.width-1); x++ {
+				g_Fog.solid[y*g_Fog.width+x] = true
+			}
+		}
+	}
+}
+
+func fog_cell(pos Vector2DF) NavCell {
+	offset := pos.subtract(g_Fog.origin).mul_scalar(1 / fogCellSize)
+	return NavCell{int(offset.x), int(offset.y)}
+}
+
+func step_fog() {
+	if g_Fog.key == "" {
+		return
+	}
+	center := fog_cell(g_Player.pos)
+	reach := int(fogRevealRadius/fogCellSize) + 1
+	revealed := false
+	for y := max(center.y-reach, 0); y <= min(center.y+reach, g_Fog.height-1); y++ {
+		for x := max(center.x-reach, 0); x <= min(center.x+reach, g_Fog.width-1); x++ {
+			cell_center := g_Fog.origin.add(Vector2DF{float32(x) + 0.5, float32(y) + 0.5}.mul_scalar(fogCellSize))
+			reveal := min(max((fogRevealRadius-cell_center.distance(g_Player.pos))/fogSoftEdge, 0), 1)
+			if value := uint8(reveal * 255); value > g_Fog.explored[y*g_Fog.width+x] {
+				g_Fog.explored[y*g_Fog.width+x] = value
+				revealed = true
+			}
+		}
+	}
+	if revealed {
+		store_fog()
+	}
+}
+
+// The save data is replaced on profile switches and cloud downloads, so coverage is put back into
+// whichever is current whenever it changes
+func store_fog() {
+	g_Fog.dirty = true
+	if g_SaveData.Fog == nil {
+		g_SaveData.Fog = map[string][]uint8{}
+	}
+	g_SaveData.Fog[g_Fog.key] = g_Fog.explored
+}
+
+// Fraction of the level's cells explored, for the console
+func fog_explored_fraction() float32 {
+	total := 0
+	for _, value := range g_Fog.explored {
+		total += int(value)
+	}
+	return float32(total) / float32(max(len(g_Fog.explored), 1)*255)
+}
+
+func upload_fog_textures() {
+	if !g_Fog.dirty {
+		return
+	}
+	g_Fog.dirty = false
+
+	size := [2]int{g_Fog.width, g_Fog.height}
+	if g_Fog.texture == 0 || g_Fog.texture_size != size {
+		g_Fog.texture = recreate_fog_texture(g_Fog.texture)
+		g_Fog.minimap_texture = recreate_fog_texture(g_Fog.minimap_texture)
+		g_Fog.texture_size = size
+	}
+
+	// Both premultiplied, the world one is black at the fog's alpha. The minimap is flipped to
+	// have its top row first, like the overlay draws it.
+	world := make([]uint8, len(g_Fog.explored)*4)
+	minimap := make([]uint8, len(g_Fog.explored)*4)
+	for y := 0; y < g_Fog.height; y++ {
+		for x := 0; x < g_Fog.width; x++ {
+			explored := float32(g_Fog.explored[y*g_Fog.width+x]) / 255
+			world[(y*g_Fog.width+x)*4+3] = uint8((1 - explored) * fogDarkness * 255)
+
+			c := minimapOpenColor
+			if g_Fog.solid[y*g_Fog.width+x] {
+				c = minimapBlockColor
+			}
+			alpha := float32(c.A) / 255 * explored
+			pixel := minimap[((g_Fog.height-1-y)*g_Fog.width+x)*4:]
+			pixel[0], pixel[1], pixel[2], pixel[3] = uint8(float32(c.R)*alpha), uint8(float32(c.G)*alpha), uint8(float32(c.B)*alpha), uint8(alpha*255)
+		}
+	}
+	gl.BindTexture(gl.TEXTURE_2D, g_Fog.texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(g_Fog.width), int32(g_Fog.height), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(world))
+	gl.BindTexture(gl.TEXTURE_2D, g_Fog.minimap_texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(g_Fog.width), int32(g_Fog.height), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(minimap))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
+// Linear filtering blurs the cells into soft edges
+func recreate_fog_texture(texture uint32) uint32 {
+	if texture != 0 {
+		gl.DeleteTextures(1, &texture)
+	}
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	return texture
+}
+
+// After the world, on the z = 0 plane over everything drawn so far
+func render_fog() {
+	if g_Fog.key == "" {
+		return
+	}
+	upload_fog_textures()
+
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	size := Vector2DF{float32(g_Fog.width), float32(g_Fog.height)}.mul_scalar(fogCellSize)
+	draw_overlay_quad(g_Fog.texture, g_Fog.origin.x, g_Fog.origin.y, size.x, size.y)
+	gl.Disable(gl.BLEND)
+	gl.Enable(gl.DEPTH_TEST)
+}
+
+// The level fitted inside the widget, with the player as a dot
+func draw_minimap(widget *Widget) {
+	rect := widget.rect
+	draw_overlay_quad(g_HUD.back_texture, rect.x, rect.y, rect.w, rect.h)
+
+	scale := min(rect.w/float32(g_Fog.width), rect.h/float32(g_Fog.height))
+	w, h := float32(g_Fog.width)*scale, float32(g_Fog.height)*scale
+	x, y := rect.x+(rect.w-w)/2, rect.y+(rect.h-h)/2
+	draw_overlay_quad(g_Fog.minimap_texture, x, y, w, h)
+
+	player := g_Player.pos.subtract(g_Fog.origin).mul_scalar(scale / fogCellSize)
+	draw_overlay_quad(g_Overlay.white_texture, x+player.x-2, y+h-player.y-2, 4, 4)
+}
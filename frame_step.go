@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Debug mode freezing the simulation, each press of the step key runs exactly one fixed step.
+// Rendering and input carry on, hold a movement key while stepping to watch collisions resolve.
+type FrameStep struct {
+	enabled bool
+	// Steps requested since the last frame
+	pending int
+	// Steps taken since the mode was turned on
+	count int
+}
+
+var g_FrameStep = FrameStep{}
+
+const frameStepToggleKey = glfw.KeyF9
+const frameStepKey = glfw.KeyF10
+
+var playerStateNames = map[PlayerState]string{FALLING: "falling", RUNNING: "running"}
+
+func init_frame_step() {
+	register_command("frame_step", "toggle freezing the simulation, F10 then runs one step", func() {
+		set_frame_step_enabled(!g_FrameStep.enabled)
+		console_print("frame_step = %t", g_FrameStep.enabled)
+	})
+
+	add_key_input_handler(INPUT_CONTEXT_GAMEPLAY, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+		if action == glfw.Release {
+			return
+		}
+		switch key {
+		case frameStepToggleKey:
+			if action == glfw.Press {
+				set_frame_step_enabled(!g_FrameStep.enabled)
+			}
+		case frameStepKey:
+			// Holding the key repeats, for stepping through longer stretches
+			if g_FrameStep.enabled {
+				g_FrameStep.pending++
+			}
+		}
+	})
+}
+
+func set_frame_step_enabled(enabled bool) {
+	g_FrameStep = FrameStep{enabled: enabled}
+}
+
+func frame_step_active() bool {
+	return g_FrameStep.enabled
+}
+
+// Steps to run this frame, the frozen frame renders the latest step without interpolating
+func take_frame_steps() int {
+	steps := g_FrameStep.pending
+	g_FrameStep.pending = 0
+	g_FrameStep.count += steps
+	g_Timestep.alpha = 1
+	return steps
+}
+
+func render_frame_step() {
+	if !g_FrameStep.enabled {
+		return
+	}
+
+	lines := []string{
+		fmt.Sprintf("Frame step %d, F10 steps, F9 resumes", g_FrameStep.count),
+		fmt.Sprintf("pos %.3f %.3f  vel %.3f %.3f  %s", g_Player.pos.x, g_Player.pos.y, g_Player.vel.x, g_Player.vel.y, playerStateNames[g_Player.state]),
+	}
+	y := float32(8)
+	for _, line := range lines {
+		draw_text(line, (ui_size().x-text_width(line, 1))/2, y, 1, color.RGBA{255, 210, 80, 255})
+		y += text_line_height(1)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Debug view detached from the player while the simulation keeps running, for looking around
+// large maps and at entities off screen. Dragging with the right button pans, the wheel zooms.
+type FreeCamera struct {
+	enabled bool
+
+	dragging     bool
+	drag_pointer Vector2DF
+}
+
+var g_FreeCamera = FreeCamera{}
+
+const freeCameraKey = glfw.KeyF8
+const freeCameraZoomStep = 1.15
+const freeCameraMinZ = 5.0
+const freeCameraMaxZ = 400.0
+
+func init_free_camera() {
+	register_command("free_camera", "toggle moving the view freely while the game runs, F8 also toggles", func() {
+		set_free_camera_enabled(!g_FreeCamera.enabled)
+		console_print("free_camera = %t", g_FreeCamera.enabled)
+	})
+
+	add_key_input_handler(INPUT_CONTEXT_GAMEPLAY, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+		if key == freeCameraKey && action == glfw.Press {
+			set_free_camera_enabled(!g_FreeCamera.enabled)
+		}
+	})
+}
+
+// Turning it off lets the camera ease back to the player
+func set_free_camera_enabled(enabled bool) {
+	g_FreeCamera = FreeCamera{enabled: enabled}
+}
+
+func free_camera_active() bool {
+	return g_FreeCamera.enabled
+}
+
+// Runs every frame, gameplay input keeps reaching the player
+func step_free_camera() {
+	if !g_FreeCamera.enabled || !gameplay_input_enabled() {
+		g_FreeCamera.dragging = false
+		return
+	}
+
+	if g_Mouse.scroll != 0 {
+		zoom := float32(math.Pow(freeCameraZoomStep, float64(-g_Mouse.scroll)))
+		g_Camera.z_value = min(max(g_Camera.z_value*zoom, freeCameraMinZ), freeCameraMaxZ)
+	}
+
+	// The point grabbed stays under the cursor
+	pointer := screen_to_world(Vector2DF{g_Mouse.x, g_Mouse.y})
+	if action_just_pressed(ACTION_POINTER_SECONDARY) {
+		g_FreeCamera.dragging = true
+		g_FreeCamera.drag_pointer = pointer
+	}
+	if !action_held(ACTION_POINTER_SECONDARY) {
+		g_FreeCamera.dragging = false
+	}
+	if g_FreeCamera.dragging {
+		g_Camera.pos2D = g_Camera.pos2D.add(g_FreeCamera.drag_pointer.subtract(pointer))
+	}
+
+	g_Camera.previous_pos2D, g_Camera.previous_z_value = g_Camera.pos2D, g_Camera.z_value
+}
+
+func render_free_camera() {
+	if !g_FreeCamera.enabled {
+		return
+	}
+	line := "Free camera: right drag pans, wheel zooms, F8 returns"
+	draw_text(line, (ui_size().x-text_width(line, 1))/2, ui_size().y-text_line_height(1)-8, 1, color.RGBA{255, 210, 80, 255})
+}
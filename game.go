@@ -8,17 +8,22 @@ package main // import "github.com/go-gl/example/gl41core-cube"
 import (
 	"fmt"
 	"go/build"
-	"image"
-	"image/draw"
 	_ "image/png"
 	"log"
+	"math"
+	"math/rand"
 	"os"
+	"path/filepath"
 	"runtime"
-	"strings"
+	"strconv"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/physics"
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+	"github.com/guiteixeirapimentel/small-game-go/engine/vecmath"
 )
 
 type numbers interface {
@@ -32,8 +37,13 @@ func Abs[T numbers](x T) T {
 	return x
 }
 
-const windowWidth = 800
-const windowHeight = 600
+const cameraFovY = 45.0
+const cameraTargetMargin = 4.0
+const mapSkyHeight = 20.0
+const cameraCullMargin = 2.0
+
+// How far below the lowest block the player can fall before dying
+const mapFallDeathDepth = 30.0
 
 type Vector2DF struct {
 	x float32
@@ -52,22 +62,127 @@ func (vec Vector2DF) mul_scalar(scalar float32) Vector2DF {
 	return Vector2DF{vec.x * scalar, vec.y * scalar}
 }
 
+func (vec Vector2DF) dot(rhs Vector2DF) float32 {
+	return vec.x*rhs.x + vec.y*rhs.y
+}
+
+func (vec Vector2DF) length_squared() float32 {
+	return vec.dot(vec)
+}
+
+func (vec Vector2DF) length() float32 {
+	return float32(math.Sqrt(float64(vec.length_squared())))
+}
+
+func (vec Vector2DF) distance(rhs Vector2DF) float32 {
+	return vec.subtract(rhs).length()
+}
+
+// Zero vector stays zero instead of becoming NaN
+func (vec Vector2DF) normalized() Vector2DF {
+	length := vec.length()
+	if length == 0 {
+		return Vector2DF{0, 0}
+	}
+	return vec.mul_scalar(1 / length)
+}
+
+func (vec Vector2DF) lerp(to Vector2DF, t float32) Vector2DF {
+	return vec.add(to.subtract(vec).mul_scalar(t))
+}
+
+// Counter clockwise, angle in radians
+func (vec Vector2DF) rotate(angle float32) Vector2DF {
+	sin, cos := math.Sincos(float64(angle))
+	return Vector2DF{
+		vec.x*float32(cos) - vec.y*float32(sin),
+		vec.x*float32(sin) + vec.y*float32(cos),
+	}
+}
+
+func (vec Vector2DF) clamp_length(max_length float32) Vector2DF {
+	length_squared := vec.length_squared()
+	if length_squared <= max_length*max_length {
+		return vec
+	}
+	return vec.mul_scalar(max_length / float32(math.Sqrt(float64(length_squared))))
+}
+
+func vector_2df_from_vec2[T vecmath.Float](vec vecmath.Vec2[T]) Vector2DF {
+	return Vector2DF{float32(vec.X), float32(vec.Y)}
+}
+
+func (vec Vector2DF) to_vec2_64() vecmath.Vec2[float64] {
+	return vecmath.Vec2[float64]{X: float64(vec.x), Y: float64(vec.y)}
+}
+
+// y points up, so top_left holds (min x, max y) and bottom_right (max x, min y).
+// Use the constructors and min_corner/max_corner rather than building one by hand.
 type BoundingBox2D struct {
 	top_left     Vector2DF
 	bottom_right Vector2DF
 }
 
+// Corners may be given in any order
 func make_bounding_box_2d_vec(top_left Vector2DF, bottom_right Vector2DF) BoundingBox2D {
-	return BoundingBox2D{top_left, bottom_right}
+	return make_bounding_box_2d_xy(top_left.x, bottom_right.x, top_left.y, bottom_right.y)
 }
 
 func make_bounding_box_2d_xy(x_min float32, x_max float32, y_min float32, y_max float32) BoundingBox2D {
+	x_min, x_max = min(x_min, x_max), max(x_min, x_max)
+	y_min, y_max = min(y_min, y_max), max(y_min, y_max)
 	return BoundingBox2D{Vector2DF{x_min, y_max}, Vector2DF{x_max, y_min}}
 }
 
-func (bb BoundingBox2D) intersects_with(other_bb BoundingBox2D) bool {
-	return other_bb.top_left.x < bb.bottom_right.x && other_bb.bottom_right.x > bb.top_left.x &&
-		other_bb.bottom_right.y < bb.top_left.y && other_bb.top_left.y > bb.bottom_right.y
+func make_bounding_box_2d_centered(center Vector2DF, half_size Vector2DF) BoundingBox2D {
+	return make_bounding_box_2d_xy(center.x-half_size.x, center.x+half_size.x, center.y-half_size.y, center.y+half_size.y)
+}
+
+func (bb BoundingBox2D) min_corner() Vector2DF {
+	return Vector2DF{bb.top_left.x, bb.bottom_right.y}
+}
+
+func (bb BoundingBox2D) max_corner() Vector2DF {
+	return Vector2DF{bb.bottom_right.x, bb.top_left.y}
+}
+
+func (bb BoundingBox2D) size() Vector2DF {
+	return bb.max_corner().subtract(bb.min_corner())
+}
+
+func (bb BoundingBox2D) center() Vector2DF {
+	return bb.min_corner().lerp(bb.max_corner(), 0.5)
+}
+
+func (bb BoundingBox2D) to_box() physics.Box {
+	return physics.Box{MinX: bb.top_left.x, MinY: bb.bottom_right.y, MaxX: bb.bottom_right.x, MaxY: bb.top_left.y}
+}
+
+// Touching edges don't count
+func (bb BoundingBox2D) intersects(other_bb BoundingBox2D) bool {
+	return physics.Overlaps(bb.to_box(), other_bb.to_box())
+}
+
+// Points on the edge count as inside
+func (bb BoundingBox2D) contains(point Vector2DF) bool {
+	lower, upper := bb.min_corner(), bb.max_corner()
+	return point.x >= lower.x && point.x <= upper.x && point.y >= lower.y && point.y <= upper.y
+}
+
+func (bb BoundingBox2D) union(other_bb BoundingBox2D) BoundingBox2D {
+	lower, upper := bb.min_corner(), bb.max_corner()
+	other_lower, other_upper := other_bb.min_corner(), other_bb.max_corner()
+	return make_bounding_box_2d_xy(min(lower.x, other_lower.x), max(upper.x, other_upper.x), min(lower.y, other_lower.y), max(upper.y, other_upper.y))
+}
+
+// Grows every side by amount, negative amounts shrink down to a point at the center
+func (bb BoundingBox2D) expand(amount Vector2DF) BoundingBox2D {
+	half_size := bb.size().mul_scalar(0.5).add(amount)
+	return make_bounding_box_2d_centered(bb.center(), Vector2DF{max(half_size.x, 0), max(half_size.y, 0)})
+}
+
+func (bb BoundingBox2D) translated(offset Vector2DF) BoundingBox2D {
+	return BoundingBox2D{bb.top_left.add(offset), bb.bottom_right.add(offset)}
 }
 
 type PlayerState int32
@@ -84,13 +199,18 @@ type Player struct {
 
 	angle_z float32
 
-	vao uint32
-	vbo uint32
-	bb  BoundingBox2D
+	// Transform at the previous simulation step, only kept for the locally simulated player
+	previous_transform     Transform
+	has_previous_transform bool
+
+	bb BoundingBox2D
 
 	texture uint32
 
 	state PlayerState
+
+	health  Resource
+	stamina Resource
 }
 
 type Camera struct {
@@ -99,271 +219,682 @@ type Camera struct {
 	z_value float32
 
 	targetPos Vector2DF
+
+	// Smoothed offset ahead of the player in the direction it is moving
+	look_ahead Vector2DF
+
+	targets        []CameraTarget
+	next_target_id int
+
+	// z_value never zooms in closer than base_z_value when framing targets
+	base_z_value float32
+	max_z_value  float32
+
+	previous_pos2D   Vector2DF
+	previous_z_value float32
+
+	// Radians counterclockwise around the view direction, only photo mode tilts the camera
+	roll float32
+}
+
+type CameraTarget struct {
+	id       int
+	position func() Vector2DF
+	weight   float32
 }
 
 type StaticMapEntity struct {
 	pos     Vector2DF
 	bb      BoundingBox2D
 	texture uint32
+	// Loaded into texture on the main thread, maps are generated without GL
+	texture_filename string
+	transform        Transform
 }
 
 func make_static_map_entity(pos Vector2DF, bb BoundingBox2D, texture_filename string) StaticMapEntity {
-	texture, err := new_texture(texture_filename)
+	block := make_map_block(pos, bb, texture_filename)
+	load_block_texture(&block)
+	return block
+}
+
+// A block without its texture, see load_block_texture
+func make_map_block(pos Vector2DF, bb BoundingBox2D, texture_filename string) StaticMapEntity {
+	return StaticMapEntity{pos: pos, bb: bb, texture_filename: texture_filename, transform: make_transform(pos)}
+}
 
+func load_block_texture(block *StaticMapEntity) {
+	texture, err := load_texture(block.texture_filename)
 	if err != nil {
-		log.Fatalf("Could not load texture %s", texture_filename)
-		return StaticMapEntity{}
+		log.Fatalf("Could not load texture %s", block.texture_filename)
 	}
-
-	return StaticMapEntity{pos, bb, texture}
+	block.texture = texture
 }
 
 type Map struct {
 	angle    float32
 	entities []StaticMapEntity
 
-	cube_vao uint32
-	cube_vbo uint32
+	// Level extents the camera is kept inside of
+	bounds BoundingBox2D
+	// Where the player starts and respawns
+	spawn Vector2DF
+	// Reaching the last platform finishes a run, checkpoints are x positions passed along the way
+	goal_x      float32
+	checkpoints []float32
+	// Generated dungeons finish inside the exit room instead of past goal_x
+	exit *BoundingBox2D
+	// Checkpoints passed so far, in order
+	reached_checkpoints int
+	completed           bool
+
+	collectibles     []Collectible
+	dynamic_entities []DynamicEntity
+	props            []MapProp
+	// Climate of the generated part of the level
+	biomes BiomeMap
+	// Spawned with the level's own entities
+	generated_entities []LevelEntity
+	// Key variants the player picked up
+	keys map[int]bool
+	// Built on first use by path searches, nil whenever the blocks change
+	nav     *NavGrid
+	navmesh *NavMesh
+	water   []WaterRegion
+	// Scene node following the player, entities can be attached to it
+	player_node int
 }
 
 var g_Player = Player{}
 var g_Camera = Camera{}
 var g_Map = Map{}
 
-func init_player(program uint32) {
-	texture, err := new_texture("square.png")
+func init_player() {
+	texture, err := load_texture("square.png")
 	if err != nil {
 		log.Fatalln(err)
 		return
 	}
 
-	gl.GenVertexArrays(1, &g_Player.vao)
-	gl.BindVertexArray(g_Player.vao)
-
-	gl.GenBuffers(1, &g_Player.vbo)
-	gl.BindBuffer(gl.ARRAY_BUFFER, g_Player.vbo)
-	gl.BufferData(gl.ARRAY_BUFFER, len(cubeVerticesPlayer)*4, gl.Ptr(cubeVerticesPlayer), gl.STATIC_DRAW)
-
 	g_Player.texture = texture
 
-	config_vertex_data(program)
-
 	g_Player.state = RUNNING
 
 	g_Player.angle_z = 0
-}
 
-func render_player(model_uniform_location int32) {
-	model := mgl32.Translate3D(g_Player.pos.x, g_Player.pos.y, 0)
+	reset_player_vitals(&g_Player)
+}
 
-	model = model.Mul4(mgl32.HomogRotate3D(g_Player.angle_z, mgl32.Vec3{0, 0, 1}))
+func player_transform(player *Player) Transform {
+	transform := make_transform(player.pos)
+	transform.rotation[2] = player.angle_z
+	return transform
+}
 
-	gl.UniformMatrix4fv(model_uniform_location, 1, false, &model[0])
+// Between the last two simulation steps, players stepped elsewhere (remote, ghost) draw as they are
+func player_render_transform(player *Player) Transform {
+	current := player_transform(player)
+	if !player.has_previous_transform {
+		return current
+	}
+	return lerp_transform(player.previous_transform, current, g_Timestep.alpha)
+}
 
-	gl.BindVertexArray(g_Player.vao)
+func render_player(player *Player, model_uniform_location int32) {
+	model := player_render_transform(player).matrix()
 
-	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, g_Player.texture)
+	gl.UniformMatrix4fv(model_uniform_location, 1, false, &model[0])
 
-	gl.DrawArrays(gl.TRIANGLES, 0, 6*2*3)
+	g_CubeMesh.texture = player.texture
+	g_CubeMesh.draw()
 }
 
-func player_jump() {
-	if g_Player.state != RUNNING {
+func player_jump(player *Player) {
+	if player_swimming(player) {
+		player_swim_stroke(player)
 		return
 	}
+	if player.state != RUNNING || !player.stamina.spend(jumpStaminaCost) {
+		return
+	}
+
+	player.vel.y += float32(20)
 
-	g_Player.vel.y += float32(20)
+	player.state = FALLING
 
-	g_Player.state = FALLING
+	emit_event(GameEvent{kind: EVENT_PLAYER_JUMPED, pos: player.pos})
 }
 
-func player_move_right() {
-	if g_Player.state == RUNNING {
-		g_Player.accel.x += 100
+func player_move_right(player *Player) {
+	if player.state == RUNNING {
+		player.accel.x += 100
+	} else if player_swimming(player) {
+		player.accel.x += waterSwimAccel
 	} else {
-		g_Player.vel.x = +Abs(g_Player.vel.x)
+		player.vel.x = +Abs(player.vel.x)
 	}
 }
 
-func player_move_left() {
-	if g_Player.state == RUNNING {
-		g_Player.accel.x -= 100
+func player_move_left(player *Player) {
+	if player.state == RUNNING {
+		player.accel.x -= 100
+	} else if player_swimming(player) {
+		player.accel.x -= waterSwimAccel
 	} else {
-		g_Player.vel.x = -Abs(g_Player.vel.x)
+		player.vel.x = -Abs(player.vel.x)
 	}
 }
 
-func handle_player_map_colision(map_entity StaticMapEntity) bool {
-	left_insertion := Abs(map_entity.bb.top_left.x - g_Player.bb.bottom_right.x)
-	right_insertion := Abs(map_entity.bb.bottom_right.x - g_Player.bb.top_left.x)
-
-	top_insertion := Abs(map_entity.bb.top_left.y - g_Player.bb.bottom_right.y)
-	bottom_insertion := Abs(map_entity.bb.bottom_right.y - g_Player.bb.top_left.y)
-
-	min_vertical := Abs(min(top_insertion, bottom_insertion))
-	min_horizontal := Abs(min(left_insertion, right_insertion))
+func handle_player_map_colision(player *Player, map_entity StaticMapEntity) bool {
+	penetration := physics.ComputePenetration(player.bb.to_box(), map_entity.bb.to_box())
 
 	should_fall := true
 
-	if min_horizontal < min_vertical {
-		if left_insertion < 0 || right_insertion < 0 || true {
-			g_Player.vel.x = 0
-			if left_insertion < right_insertion {
-				g_Player.pos.x -= left_insertion // Hitting from left
-			} else {
-				g_Player.pos.x += right_insertion
-			}
+	if penetration.Horizontal() {
+		player.vel.x = 0
+		if penetration.Left < penetration.Right {
+			player.pos.x -= penetration.Left // Hitting from left
+		} else {
+			player.pos.x += penetration.Right
 		}
 	} else {
-		if top_insertion < 0 || bottom_insertion < 0 || true {
-			g_Player.vel.y = 0
-
-			if top_insertion < bottom_insertion {
-				g_Player.pos.y += top_insertion // Hitting from above (Feet first)
-				should_fall = false
-				if g_Player.state == FALLING {
-					g_Player.state = RUNNING
-				}
-			} else {
-				g_Player.pos.y -= bottom_insertion // Hitting from below (Head first)
+		impact_speed := -player.vel.y
+		player.vel.y = 0
+
+		if penetration.Up < penetration.Down {
+			player.pos.y += penetration.Up // Hitting from above (Feet first)
+			should_fall = false
+			if player.state == FALLING {
+				player.state = RUNNING
+				emit_event(GameEvent{kind: EVENT_PLAYER_LANDED, pos: player.pos, magnitude: impact_speed})
 			}
+		} else {
+			player.pos.y -= penetration.Down // Hitting from below (Head first)
 		}
 	}
 	return should_fall
 }
 
 func render_map(model_uniform_location int32) {
+	visible := camera_visible_bounds()
 	for _, entity := range g_Map.entities {
-		model := mgl32.Translate3D(entity.pos.x, entity.pos.y, 0)
+		if !entity.bb.intersects(visible) {
+			continue
+		}
 
+		model := entity.transform.matrix()
 		gl.UniformMatrix4fv(model_uniform_location, 1, false, &model[0])
 
-		gl.BindVertexArray(g_Map.cube_vao)
-		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, entity.texture)
-
-		gl.DrawArrays(gl.TRIANGLES, 0, 6*2*3)
+		g_CubeMesh.texture = entity.texture
+		g_CubeMesh.draw()
 	}
 }
 
-func step_player(dt float32) {
-	g_Player.vel = g_Player.vel.add(g_Player.accel.mul_scalar(dt))
-	g_Player.pos = g_Player.pos.add(g_Player.vel.mul_scalar(dt))
+func step_player(player *Player, dt float32) {
+	player.vel = player.vel.add(player.accel.mul_scalar(dt))
+	player.pos = player.pos.add(player.vel.mul_scalar(dt))
 
-	gravity_accel := float32(-50)
+	gravity := Vector2DF{0, -50}
 
-	switch g_Player.state {
+	switch player.state {
 	case FALLING:
-		g_Player.vel.y += gravity_accel * dt
+		player.vel = player.vel.add(gravity.mul_scalar(dt))
 
 		falling_rotation := float32(0)
-		if g_Player.vel.x > 0 {
+		if player.vel.x > 0 {
 			falling_rotation = float32(-1.5)
-		} else if g_Player.vel.x < 0 {
+		} else if player.vel.x < 0 {
 			falling_rotation = float32(1.5)
 		} else {
-			g_Player.angle_z = 0
+			player.angle_z = 0
 		}
-		g_Player.angle_z += dt * falling_rotation
+		player.angle_z += dt * falling_rotation
 	case RUNNING:
-		g_Player.vel = g_Player.vel.mul_scalar(0.85)
-		g_Player.angle_z = 0
+		player.vel = player.vel.mul_scalar(0.85)
+		player.angle_z = 0
+		player.stamina.step(dt)
 	}
 
-	g_Player.accel = Vector2DF{0, 0}
+	player.accel = Vector2DF{0, 0}
 
-	g_Player.bb = make_bounding_box_2d_vec(g_Player.pos.add(Vector2DF{-1, 1}), g_Player.pos.add(Vector2DF{1, -1}))
+	update_player_bounding_box(player)
+}
+
+func update_player_bounding_box(player *Player) {
+	player.bb = make_bounding_box_2d_centered(player.pos, Vector2DF{1, 1})
 }
 
 func init_camera() {
 	g_Camera.pos2D = Vector2DF{0.0, 0.0}
 	g_Camera.z_value = 25.0
+	g_Camera.base_z_value = 25.0
+	g_Camera.max_z_value = 60.0
+	g_Camera.previous_pos2D = g_Camera.pos2D
+	g_Camera.previous_z_value = g_Camera.z_value
+
+	add_camera_target(func() Vector2DF { return g_Player.pos }, 1.0)
+
+	register_command("camera_stiffness", "[stiffness]: how quickly the camera catches up with the player", func(args []string) {
+		if len(args) == 1 {
+			value, err := strconv.ParseFloat(args[0], 32)
+			if err != nil || value <= 0 {
+				console_print("invalid stiffness %q", args[0])
+				return
+			}
+			g_Settings.camera_stiffness = float32(value)
+		}
+		console_print("camera_stiffness = %g", g_Settings.camera_stiffness)
+	})
+}
+
+func add_camera_target(position func() Vector2DF, weight float32) int {
+	g_Camera.next_target_id++
+	g_Camera.targets = append(g_Camera.targets, CameraTarget{g_Camera.next_target_id, position, weight})
+
+	return g_Camera.next_target_id
+}
+
+func remove_camera_target(id int) {
+	for i, target := range g_Camera.targets {
+		if target.id == id {
+			g_Camera.targets = append(g_Camera.targets[:i], g_Camera.targets[i+1:]...)
+			return
+		}
+	}
+}
+
+// Weighted midpoint of the targets and the zoom needed to keep all of them in view
+func camera_frame_targets() (Vector2DF, float32) {
+	if len(g_Camera.targets) == 0 {
+		return g_Player.pos, g_Camera.base_z_value
+	}
+
+	first := g_Camera.targets[0].position()
+	lower, upper := first, first
+	weighted_sum := Vector2DF{0, 0}
+	total_weight := float32(0)
+
+	for _, target := range g_Camera.targets {
+		pos := target.position()
+
+		weighted_sum = weighted_sum.add(pos.mul_scalar(target.weight))
+		total_weight += target.weight
+
+		lower = Vector2DF{min(lower.x, pos.x), min(lower.y, pos.y)}
+		upper = Vector2DF{max(upper.x, pos.x), max(upper.y, pos.y)}
+	}
+
+	midpoint := first
+	if total_weight > 0 {
+		midpoint = weighted_sum.mul_scalar(1 / total_weight)
+	}
+
+	// The midpoint may be off center when weights differ, so frame the farthest target from it
+	half_extents := Vector2DF{
+		max(upper.x-midpoint.x, midpoint.x-lower.x) + cameraTargetMargin,
+		max(upper.y-midpoint.y, midpoint.y-lower.y) + cameraTargetMargin,
+	}
+
+	aspect := g_WindowWidth / g_WindowHeight
+	half_height := max(half_extents.y, half_extents.x/aspect)
+	z_value := half_height / float32(math.Tan(float64(mgl32.DegToRad(cameraFovY)/2)))
+
+	return midpoint, min(max(z_value, g_Camera.base_z_value), g_Camera.max_z_value)
+}
+
+func camera_dead_zone_target(focus Vector2DF) Vector2DF {
+	target := g_Camera.pos2D
+	half_size := g_Settings.camera_dead_zone_half_size
+
+	if focus.x > target.x+half_size.x {
+		target.x = focus.x - half_size.x
+	} else if focus.x < target.x-half_size.x {
+		target.x = focus.x + half_size.x
+	}
+
+	if focus.y > target.y+half_size.y {
+		target.y = focus.y - half_size.y
+	} else if focus.y < target.y-half_size.y {
+		target.y = focus.y + half_size.y
+	}
+
+	return target
+}
+
+// Fraction of the remaining distance to cover this frame, converging at the same rate at any frame rate
+func smoothing_factor(stiffness float32, dt float32) float32 {
+	return 1 - float32(math.Exp(float64(-stiffness*dt)))
+}
+
+func step_camera_look_ahead(dt float32) {
+	desired := Vector2DF{0, 0}
+	if g_Settings.camera_look_ahead_enabled && !g_Settings.reduced_motion {
+		desired = g_Player.vel.mul_scalar(g_Settings.camera_look_ahead_time).clamp_length(g_Settings.camera_look_ahead_max)
+	}
+
+	smoothing := smoothing_factor(g_Settings.camera_look_ahead_smoothing, dt)
+	g_Camera.look_ahead = g_Camera.look_ahead.lerp(desired, smoothing)
 }
 
 func step_camera(dt float32) {
-	g_Camera.targetPos = g_Player.pos
+	if free_camera_active() {
+		return
+	}
+	if camera_path_playing() {
+		step_camera_path(dt)
+		return
+	}
+
+	step_camera_look_ahead(dt)
+
+	midpoint, z_value := camera_frame_targets()
+	focus := midpoint.add(g_Camera.look_ahead)
+
+	g_Camera.z_value += (z_value - g_Camera.z_value) * smoothing_factor(g_Settings.camera_zoom_stiffness, dt)
+
+	g_Camera.targetPos = focus
+	if g_Settings.camera_dead_zone_enabled {
+		g_Camera.targetPos = camera_dead_zone_target(focus)
+	}
+
+	dt_scaled := smoothing_factor(g_Settings.camera_stiffness, dt)
+	g_Camera.pos2D = g_Camera.pos2D.lerp(g_Camera.targetPos, dt_scaled)
+	g_Camera.pos2D = clamp_camera_to_bounds(g_Camera.pos2D, g_Map.bounds)
+}
+
+// Half width and height of the world area visible on the z = 0 plane
+func camera_visible_half_extents() Vector2DF {
+	half_height := g_Camera.z_value * float32(math.Tan(float64(mgl32.DegToRad(cameraFovY)/2)))
+	return Vector2DF{half_height * g_WindowWidth / g_WindowHeight, half_height}
+}
+
+// Blocks stick out of the z = 0 plane towards the camera, so a margin keeps their near faces from popping
+func camera_visible_bounds() BoundingBox2D {
+	half_extents := camera_visible_half_extents()
+	if g_Camera.roll != 0 {
+		// Box around the tilted view
+		sin, cos := math.Sincos(float64(g_Camera.roll))
+		sin, cos = math.Abs(sin), math.Abs(cos)
+		half_extents = Vector2DF{
+			float32(cos)*half_extents.x + float32(sin)*half_extents.y,
+			float32(sin)*half_extents.x + float32(cos)*half_extents.y,
+		}
+	}
+	return make_bounding_box_2d_centered(g_Camera.pos2D, half_extents).expand(Vector2DF{cameraCullMargin, cameraCullMargin})
+}
+
+func clamp_camera_axis(value float32, half_extent float32, lower float32, upper float32) float32 {
+	if upper-lower < 2*half_extent {
+		return (lower + upper) / 2
+	}
+	return min(max(value, lower+half_extent), upper-half_extent)
+}
 
-	dt_scaled := min(dt*3, 1)
+func clamp_camera_to_bounds(pos Vector2DF, bounds BoundingBox2D) Vector2DF {
+	half_extents := camera_visible_half_extents()
 
-	diff_pos_target := g_Camera.targetPos.subtract(g_Camera.pos2D)
+	return Vector2DF{
+		clamp_camera_axis(pos.x, half_extents.x, bounds.min_corner().x, bounds.max_corner().x),
+		clamp_camera_axis(pos.y, half_extents.y, bounds.min_corner().y, bounds.max_corner().y),
+	}
+}
+
+// Interpolated between the last two simulation steps
+func camera_view_matrix() mgl32.Mat4 {
+	pos := g_Camera.previous_pos2D.lerp(g_Camera.pos2D, g_Timestep.alpha).add(g_CameraShake.offset)
+	z_value := lerp_float32(g_Camera.previous_z_value, g_Camera.z_value, g_Timestep.alpha)
+
+	cam_pos_3D := mgl32.Vec3{pos.x, pos.y, z_value}
+	cam_look_at_pos := mgl32.Vec3{pos.x, pos.y, 0.0}
+	up := Vector2DF{0, 1}.rotate(g_Camera.roll + g_CameraShake.roll)
+	up_direction := mgl32.Vec3{up.x, up.y, 0}
+	return mgl32.LookAtV(cam_pos_3D, cam_look_at_pos, up_direction)
+}
 
-	g_Camera.pos2D = g_Camera.pos2D.add(diff_pos_target.mul_scalar(dt_scaled))
+func camera_projection_matrix() mgl32.Mat4 {
+	return mgl32.Perspective(mgl32.DegToRad(cameraFovY), g_WindowWidth/g_WindowHeight, 0.1, 1000.0)
+}
+
+// Where the ray through a window position (in pixels, origin at the top left) hits the z = 0 plane
+func screen_to_world(screen Vector2DF) Vector2DF {
+	camera, projection := camera_view_matrix(), camera_projection_matrix()
+	window := mgl32.Vec3{screen.x, g_WindowHeight - screen.y, 0}
+	width, height := int(g_WindowWidth), int(g_WindowHeight)
+
+	near, err := mgl32.UnProject(window, camera, projection, 0, 0, width, height)
+	if err != nil {
+		return g_Camera.pos2D
+	}
+	window[2] = 1
+	far, err := mgl32.UnProject(window, camera, projection, 0, 0, width, height)
+	if err != nil || far[2] == near[2] {
+		return g_Camera.pos2D
+	}
+
+	t := near[2] / (near[2] - far[2])
+	hit := near.Add(far.Sub(near).Mul(t))
+	return Vector2DF{hit[0], hit[1]}
+}
+
+// Window position (in pixels, origin at the top left) of a point on the z = 0 plane, false when it's behind the camera
+func world_to_screen(pos Vector2DF) (Vector2DF, bool) {
+	width, height := int(g_WindowWidth), int(g_WindowHeight)
+	window := mgl32.Project(mgl32.Vec3{pos.x, pos.y, 0}, camera_view_matrix(), camera_projection_matrix(), 0, 0, width, height)
+	if window[2] < 0 || window[2] > 1 {
+		return Vector2DF{}, false
+	}
+	return Vector2DF{window[0], g_WindowHeight - window[1]}, true
 }
 
 func update_camera_uniforms(cameraUniform int32) {
-	cam_pos_3D := mgl32.Vec3{g_Camera.pos2D.x, g_Camera.pos2D.y, g_Camera.z_value}
-	cam_look_at_pos := mgl32.Vec3{g_Camera.pos2D.x, g_Camera.pos2D.y, 0.0}
-	up_direction := mgl32.Vec3{0, 1, 0}
-	camera := mgl32.LookAtV(cam_pos_3D, cam_look_at_pos, up_direction)
+	camera := camera_view_matrix()
 	gl.UniformMatrix4fv(cameraUniform, 1, false, &camera[0])
+	g_Billboards.camera = camera
+}
+
+func init_map() {
+	build_map()
+}
+
+// Generates the current level's map and puts it in place, also used by headless modes where no GL
+// context exists
+func build_map() {
+	install_map(generate_map(g_Level, g_MapSeed))
 }
 
-func init_map(program uint32) {
-	gl.GenVertexArrays(1, &g_Map.cube_vao)
-	gl.BindVertexArray(g_Map.cube_vao)
+// Blocks, collectibles and the entities the generator places. Works on its own Map without
+// touching any globals or GL, so it can run on a job worker, see load_level.
+func generate_map(level LevelInfo, seed int64) Map {
+	level_map := Map{keys: map[int]bool{}}
+	tileset := pack_tileset(level_pack(level))
+
+	rng := rand.New(rand.NewSource(seed))
+	switch level.Generator {
+	case levelGeneratorDungeon:
+		generate_dungeon(&level_map, level, rng, tileset)
+	case levelGeneratorWFC:
+		generate_wfc(&level_map, level, rng, tileset)
+	default:
+		build_platform_map(&level_map, level, rng, tileset)
+	}
 
-	gl.GenBuffers(1, &g_Map.cube_vbo)
-	gl.BindBuffer(gl.ARRAY_BUFFER, g_Map.cube_vbo)
-	gl.BufferData(gl.ARRAY_BUFFER, len(cubeVerticesMap)*4, gl.Ptr(cubeVerticesMap), gl.STATIC_DRAW)
+	level_map.bounds = compute_map_bounds(level_map.entities)
+	return level_map
+}
 
-	config_vertex_data(program)
+// Replaces the current map with a generated one and spawns the level's entities into it, on the
+// main thread
+func install_map(level_map Map) {
+	reset_time_scale()
+	for i := range level_map.entities {
+		load_block_texture(&level_map.entities[i])
+	}
+	g_Map = level_map
+	spectator_map_changed()
 
-	g_Map.angle = 0
+	spawn_level_entities()
+	spawn_level_props()
+	spawn_level_water()
+
+	reset_fog()
+	reset_weather()
+	update_grading()
+}
 
+// A flat stretch to start on followed by generated platforms
+func build_platform_map(level_map *Map, level LevelInfo, rng *rand.Rand, tileset string) {
 	for i := 0; i < 20; i += 5 {
 		{
 			pos := Vector2DF{float32(i * 3), -6.0}
-			bb := make_bounding_box_2d_vec(pos.add(Vector2DF{-1.0, 1.0}), pos.add(Vector2DF{1.0, -1.0}))
-			block := make_static_map_entity(pos, bb, "square.png")
+			bb := make_bounding_box_2d_centered(pos, Vector2DF{1, 1})
+			block := make_map_block(pos, bb, tileset)
 
-			g_Map.entities = append(g_Map.entities, block)
+			level_map.entities = append(level_map.entities, block)
 		}
 		{
 			pos := Vector2DF{float32(i*3) + 2, -6.0}
-			bb := make_bounding_box_2d_vec(pos.add(Vector2DF{-1.0, 1.0}), pos.add(Vector2DF{1.0, -1.0}))
-			block := make_static_map_entity(pos, bb, "square.png")
+			bb := make_bounding_box_2d_centered(pos, Vector2DF{1, 1})
+			block := make_map_block(pos, bb, tileset)
 
-			g_Map.entities = append(g_Map.entities, block)
+			level_map.entities = append(level_map.entities, block)
 		}
 		{
 			pos := Vector2DF{float32(i*3) + 4, -6.0}
-			bb := make_bounding_box_2d_vec(pos.add(Vector2DF{-1.0, 1.0}), pos.add(Vector2DF{1.0, -1.0}))
-			block := make_static_map_entity(pos, bb, "square.png")
+			bb := make_bounding_box_2d_centered(pos, Vector2DF{1, 1})
+			block := make_map_block(pos, bb, tileset)
 
-			g_Map.entities = append(g_Map.entities, block)
+			level_map.entities = append(level_map.entities, block)
 		}
 		{
 			pos := Vector2DF{float32(i*3) + 6, -6.0}
-			bb := make_bounding_box_2d_vec(pos.add(Vector2DF{-1.0, 1.0}), pos.add(Vector2DF{1.0, -1.0}))
-			block := make_static_map_entity(pos, bb, "square.png")
+			bb := make_bounding_box_2d_centered(pos, Vector2DF{1, 1})
+			block := make_map_block(pos, bb, tileset)
 
-			g_Map.entities = append(g_Map.entities, block)
+			level_map.entities = append(level_map.entities, block)
 		}
 		{
 			pos := Vector2DF{float32(i*3) + 8, -6.0}
-			bb := make_bounding_box_2d_vec(pos.add(Vector2DF{-1.0, 1.0}), pos.add(Vector2DF{1.0, -1.0}))
-			block := make_static_map_entity(pos, bb, "square.png")
+			bb := make_bounding_box_2d_centered(pos, Vector2DF{1, 1})
+			block := make_map_block(pos, bb, tileset)
 
-			g_Map.entities = append(g_Map.entities, block)
+			level_map.entities = append(level_map.entities, block)
 		}
 	}
 
+	last := level_map.entities[len(level_map.entities)-1].pos
+	generate_platforms(level_map, level, rng, last.x, last.y, tileset)
+}
+
+func compute_map_bounds(entities []StaticMapEntity) BoundingBox2D {
+	if len(entities) == 0 {
+		return BoundingBox2D{}
+	}
+
+	bounds := entities[0].bb
+	for _, entity := range entities[1:] {
+		bounds = bounds.union(entity.bb)
+	}
+
+	// Leave room above the blocks for jumping
+	bounds.top_left.y += mapSkyHeight
+
+	return bounds
 }
 
 func step_map(dt float32) {
 	g_Map.angle += dt
 
+	set_scene_node_local(g_Map.player_node, player_transform(&g_Player))
+
+	step_weather(dt)
+	step_dynamic_entities(dt)
+	step_spawners(dt)
+	step_bosses(dt)
+	if !g_Cheats.noclip {
+		collide_player_with_map(&g_Player)
+	}
+	collect_collectibles(&g_Player)
+	touch_dynamic_entities(&g_Player)
+	step_fog()
+
+	if !g_Cheats.noclip && g_Player.pos.y < g_Map.bounds.min_corner().y-mapFallDeathDepth {
+		kill_player(&g_Player)
+	}
+
+	if next := g_Map.reached_checkpoints; next < len(g_Map.checkpoints) && g_Player.pos.x >= g_Map.checkpoints[next] {
+		g_Map.reached_checkpoints++
+		emit_event(GameEvent{kind: EVENT_CHECKPOINT_REACHED, pos: g_Player.pos, magnitude: float32(g_Map.reached_checkpoints)})
+	}
+
+	if !g_Map.completed && g_Player.state == RUNNING && reached_goal(&g_Player) {
+		g_Map.completed = true
+		emit_event(GameEvent{kind: EVENT_LEVEL_COMPLETED, pos: g_Player.pos})
+	}
+}
+
+func reached_goal(player *Player) bool {
+	if g_Map.exit != nil {
+		return g_Map.exit.intersects(player.bb)
+	}
+	return player.pos.x >= g_Map.goal_x-1
+}
+
+// The player entities react to, on a dedicated server the client closest to pos
+func nearest_player(pos Vector2DF) *Player {
+	nearest := &g_Player
+	if len(g_Server.clients) == 0 {
+		return nearest
+	}
+	nearest_distance := float32(math.MaxFloat32)
+	for _, client := range g_Server.clients {
+		if distance := client.player.pos.distance(pos); distance < nearest_distance {
+			nearest, nearest_distance = &client.player, distance
+		}
+	}
+	return nearest
+}
+
+func kill_player(player *Player) {
+	emit_event(GameEvent{kind: EVENT_PLAYER_DIED, pos: player.pos})
+	respawn_player(player)
+}
+
+func respawn_player(player *Player) {
+	player.pos = g_Map.spawn
+	player.vel = Vector2DF{0, 0}
+	player.angle_z = 0
+	player.state = FALLING
+	reset_player_vitals(player)
+	if player == &g_Player {
+		// Teleporting to the spawn isn't distance traveled
+		g_Stats.last_pos = player.pos
+	}
+}
+
+func collide_player_with_map(player *Player) {
 	should_fall := true
 
 	for _, block := range g_Map.entities {
-		if block.bb.intersects_with(g_Player.bb) {
-			should_fall = handle_player_map_colision(block)
+		if block.bb.intersects(player.bb) {
+			should_fall = handle_player_map_colision(player, block)
+		}
+	}
+
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		collider := entity.prefab.Components.Collider
+		if collider == nil || !collider.Solid || entity.picked {
+			continue
+		}
+
+		bb := dynamic_entity_bounding_box(entity)
+		if bb.intersects(player.bb) {
+			should_fall = handle_player_map_colision(player, StaticMapEntity{pos: entity.world_pos, bb: bb})
 		}
 	}
 
 	if should_fall {
-		g_Player.state = FALLING
+		player.state = FALLING
 	}
 }
 
@@ -384,6 +915,23 @@ func config_vertex_data(program uint32) {
 }
 
 func main() {
+	load_config(os.Args[1:])
+
+	init_vfs()
+	load_level_packs("packs")
+	load_behavior_trees("behaviors")
+	load_prefabs("entities")
+	config_level()
+
+	if config_bool("validate") {
+		os.Exit(run_validation())
+	}
+
+	if config_bool("server") {
+		run_server(g_Config.String("name"), config_int("port"))
+		return
+	}
+
 	if err := glfw.Init(); err != nil {
 		log.Fatalln("failed to initialize glfw:", err)
 	}
@@ -394,12 +942,22 @@ func main() {
 	glfw.WindowHint(glfw.ContextVersionMinor, 1)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
-	window, err := glfw.CreateWindow(windowWidth, windowHeight, "Game", nil, nil)
+	window, err := glfw.CreateWindow(int(g_WindowWidth), int(g_WindowHeight), "Game", nil, nil)
 	if err != nil {
 		panic(err)
 	}
 	window.MakeContextCurrent()
 
+	// The resolution setting resizes the window in place
+	window.SetFramebufferSizeCallback(func(w *glfw.Window, width int, height int) {
+		gl.Viewport(0, 0, int32(width), int32(height))
+	})
+
+	set_vsync(g_VSync)
+	if g_Settings.fullscreen {
+		set_fullscreen(window, true)
+	}
+
 	// Initialize Glow
 	if err := gl.Init(); err != nil {
 		panic(err)
@@ -409,14 +967,15 @@ func main() {
 	fmt.Println("OpenGL version", version)
 
 	// Configure the vertex and fragment shaders
-	program, err := newProgram(vertexShader, fragmentShader)
+	program, err := render.NewProgram(vertexShader, fragmentShader)
 	if err != nil {
 		panic(err)
 	}
 
 	gl.UseProgram(program)
+	g_WorldProgram = program
 
-	projection := mgl32.Perspective(mgl32.DegToRad(45.0), float32(windowWidth)/windowHeight, 0.1, 1000.0)
+	projection := camera_projection_matrix()
 	projectionUniform := gl.GetUniformLocation(program, gl.Str("projection\x00"))
 	gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
 
@@ -433,22 +992,123 @@ func main() {
 	textureUniform := gl.GetUniformLocation(program, gl.Str("tex\x00"))
 	gl.Uniform1i(textureUniform, 0)
 
+	alphaUniform := gl.GetUniformLocation(program, gl.Str("alpha\x00"))
+	gl.Uniform1f(alphaUniform, 1)
+
 	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
 
-	init_player(program)
-	init_map(program)
+	init_palettes(program)
+
+	init_cube_mesh()
+	init_player()
+	init_map()
+
+	load_save_data()
+	init_cloud_sync()
+	defer finish_cloud_sync()
+	defer write_save_data()
+	defer write_quit_autosave()
+
+	init_haptics()
+	defer close_haptics()
+
+	init_jobs()
+	defer close_jobs()
+
+	init_audio()
+	defer close_audio()
+
+	init_overlay(program, modelUniform)
+	init_ui()
+	init_widgets()
+	init_hud()
+	init_fog()
+	init_weather(program)
+	init_water()
+	init_popups()
+	init_shapes()
+	init_grid()
+	init_post()
+	init_color_grading()
+	init_accessibility()
+	init_crt()
+	init_composite()
+	init_editor()
+	init_billboards()
+	init_input(window)
+	init_timestep(window)
+	init_focus(window)
+	init_cursors(window)
+	defer close_cursors()
+	init_console()
+	init_cheats()
+	init_time_scale()
+	init_camera_shake()
+	init_bosses()
+	init_pathfinding()
+	init_biomes()
+	init_frame_step()
+	init_screenshots()
+	init_autosave()
+	init_photo_mode(window)
+	init_free_camera()
+	init_key_bindings()
+	init_profiles()
+	init_touch_controls()
+	init_camera_paths()
+	init_achievements()
+	init_stats()
+	init_daily_challenge()
+	init_speedrun()
+	init_ghost()
+	init_start_screen()
+	init_settings_menu(window)
+	init_join_menu()
+	init_level_select()
+	init_plugins()
+	init_scene_graph()
+	init_scripts()
+	init_results_screen()
+	init_validation()
+	init_debug_window(window, program, projectionUniform, cameraUniform)
+	defer close_debug_window()
+
+	init_network()
+	defer net_close()
+	init_lobby()
+	defer close_lobby()
+	init_spectator()
+	init_chat()
+	init_net_game()
+	init_lan_discovery()
+	defer close_lan_discovery()
+	defer stop_spectator_server()
 
 	// Configure global settings
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthFunc(gl.LESS)
 	gl.ClearColor(1.0, 1.0, 1.0, 1.0)
 
-	angle := 0.0
+	if config_bool("bench") {
+		run_bench(window, projection, SceneUniforms{projectionUniform, cameraUniform, modelUniform})
+		// Skips the deferred save, bench runs shouldn't count towards stats
+		os.Exit(0)
+	}
+	if mode := g_Config.String("golden"); mode != "off" {
+		if !run_golden_images(mode == "update", SceneUniforms{projectionUniform, cameraUniform, modelUniform}) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	previousTime := glfw.GetTime()
 
 	for !window.ShouldClose() {
+		begin_post(window)
 		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
+		run_gl_commands()
+
 		// Update
 		time := glfw.GetTime()
 		elapsed := time - previousTime
@@ -459,135 +1119,186 @@ func main() {
 		// Render
 		gl.UseProgram(program)
 
+		projection = camera_projection_matrix()
+		gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
 		update_camera_uniforms(cameraUniform)
+		render_reflection(window, cameraUniform, modelUniform)
 		render_map(modelUniform)
-		render_player(modelUniform)
+		render_props(modelUniform)
+		render_collectibles(modelUniform)
+		render_dynamic_entities(modelUniform)
+		render_plugin_entities(modelUniform)
+		render_player(&g_Player, modelUniform)
+		render_remote_players(modelUniform)
+		render_ghost(modelUniform, alphaUniform)
+		render_water(projection, elapsed_float32)
+		render_grid(projection)
+		gl.UseProgram(program)
+		draw_entity_billboards()
+		render_billboards(modelUniform)
+		render_weather()
+		render_fog()
+		draw_physics_debug()
+		draw_high_contrast_outlines()
+		draw_editor_gizmos()
+		render_shapes(projection)
+		gl.UseProgram(program)
+
+		update_ui_scale(window)
+		begin_overlay(projectionUniform, cameraUniform)
+		if !photo_mode_active() {
+			render_ui()
+			render_popups()
+			render_chat()
+			render_daily_challenge()
+			render_speedrun_timer()
+			render_frame_step()
+			render_free_camera()
+			render_level_select()
+			render_level_load()
+			render_results_screen()
+			render_stats_screen()
+			render_achievement_toasts()
+		}
+		render_photo_mode()
+		render_console()
+		render_tooltip()
+		end_overlay()
+		end_text_frame()
+		end_post()
+		capture_screenshot(window)
 
 		// Maintenance
 		window.SwapBuffers()
+		record_debug_frame(elapsed_float32)
+		render_debug_window()
+		throttle_unfocused_frame()
 
 		// Controls
-		add_accel := float32(100.0)
-		if window.GetKey(glfw.KeyUp) == glfw.Press {
-			angle += 0.5
-			g_Player.accel = g_Player.accel.add(Vector2DF{0.0, +add_accel})
-		}
-		if window.GetKey(glfw.KeyDown) == glfw.Press {
-			g_Player.accel = g_Player.accel.add(Vector2DF{0.0, -add_accel})
-		}
-		if window.GetKey(glfw.KeyLeft) == glfw.Press {
-			player_move_left()
+		begin_input_frame()
+		glfw.PollEvents()
+		step_touch_controls()
+		step_editor()
+		step_photo_mode(elapsed_float32)
+		step_free_camera()
+		step_ui()
+		step_hud(elapsed_float32)
+		step_camera_shake(elapsed_float32)
+		step_tooltips(elapsed_float32)
+		step_cursor()
+		step_audio_ducking()
+
+		net_poll()
+		step_reliable()
+		step_lobby()
+		step_cloud_sync()
+		step_settings_persistence(elapsed_float32)
+		send_net_input()
+		step_lan_discovery(len(g_Net.peers) + 1)
+		step_level_load()
+
+		// Physics/Game steping
+		if simulation_paused_by_focus() || editor_active() || frame_step_active() || photo_mode_active() || level_loading() {
+			hold_timestep()
 		}
-		if window.GetKey(glfw.KeyRight) == glfw.Press {
-			player_move_right()
+		steps := advance_timestep(step_time_scale(elapsed_float32))
+		if frame_step_active() {
+			steps = take_frame_steps()
 		}
-		if window.GetKey(glfw.KeySpace) == glfw.Press {
-			player_jump()
+		for ; steps > 0; steps-- {
+			store_previous_transforms()
+			apply_gameplay_input()
+			step_simulation(simulationStep)
 		}
+		update_audio_listener()
+		step_scripts(elapsed_float32)
 
-		glfw.PollEvents()
-
-		// Physics/Game steping
-		step_player(elapsed_float32)
-		step_camera(elapsed_float32)
-		step_map(elapsed_float32)
+		step_spectator_server(time)
 	}
 }
 
-func newProgram(vertexShaderSource, fragmentShaderSource string) (uint32, error) {
-	vertexShader, err := compileShader(vertexShaderSource, gl.VERTEX_SHADER)
-	if err != nil {
-		return 0, err
+func apply_gameplay_input() {
+	if !gameplay_input_enabled() {
+		return
 	}
 
-	fragmentShader, err := compileShader(fragmentShaderSource, gl.FRAGMENT_SHADER)
-	if err != nil {
-		return 0, err
+	add_accel := float32(100.0)
+	if action_held(ACTION_MOVE_UP) {
+		g_Player.accel = g_Player.accel.add(Vector2DF{0.0, +add_accel})
 	}
-
-	program := gl.CreateProgram()
-
-	gl.AttachShader(program, vertexShader)
-	gl.AttachShader(program, fragmentShader)
-	gl.LinkProgram(program)
-
-	var status int32
-	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
-	if status == gl.FALSE {
-		var logLength int32
-		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
-
-		log := strings.Repeat("\x00", int(logLength+1))
-		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
-
-		return 0, fmt.Errorf("failed to link program: %v", log)
+	if action_held(ACTION_MOVE_DOWN) {
+		g_Player.accel = g_Player.accel.add(Vector2DF{0.0, -add_accel})
+	}
+	if action_held(ACTION_MOVE_LEFT) {
+		player_move_left(&g_Player)
+	}
+	if action_held(ACTION_MOVE_RIGHT) {
+		player_move_right(&g_Player)
+	}
+	if action_held(ACTION_JUMP) {
+		player_jump(&g_Player)
 	}
-
-	gl.DeleteShader(vertexShader)
-	gl.DeleteShader(fragmentShader)
-
-	return program, nil
 }
 
-func compileShader(source string, shaderType uint32) (uint32, error) {
-	shader := gl.CreateShader(shaderType)
-
-	csources, free := gl.Strs(source)
-	gl.ShaderSource(shader, 1, csources, nil)
-	free()
-	gl.CompileShader(shader)
+// One fixed step of gameplay
+func step_simulation(dt float32) {
+	if g_Cheats.noclip {
+		step_noclip_player(&g_Player, dt)
+	} else {
+		step_player(&g_Player, dt)
+		step_player_swimming(&g_Player, dt)
+	}
+	step_camera(dt)
+	step_popups(dt)
+	step_map(dt)
+	step_stats(dt)
+	step_daily_challenge(dt)
+	step_speedrun(dt)
+	step_ghost()
+	step_plugins(dt)
+}
 
-	var status int32
-	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
-	if status == gl.FALSE {
-		var logLength int32
-		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+var g_TextureCache = map[string]uint32{}
 
-		log := strings.Repeat("\x00", int(logLength+1))
-		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+// Headless runs have no GL context, textures resolve to 0 there
+var g_Headless = false
 
-		return 0, fmt.Errorf("failed to compile %v: %v", source, log)
+// Mods can override textures, the rest still load relative to the working directory
+func texture_path(file string) string {
+	if modded, ok := g_VFS.Find(file); ok {
+		return modded
 	}
-
-	return shader, nil
+	return file
 }
 
-func new_texture(file string) (uint32, error) {
-	imgFile, err := os.Open(file)
-	if err != nil {
-		return 0, fmt.Errorf("texture %q not found on disk: %v", file, err)
+func load_texture(file string) (uint32, error) {
+	if g_Headless {
+		return 0, nil
+	}
+
+	if texture, ok := g_TextureCache[file]; ok {
+		return texture, nil
 	}
-	img, _, err := image.Decode(imgFile)
+
+	texture, err := render.LoadTexture(texture_path(file))
 	if err != nil {
 		return 0, err
 	}
+	apply_texture_filter(texture)
+	g_TextureCache[file] = texture
 
-	rgba := image.NewRGBA(img.Bounds())
-	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return 0, fmt.Errorf("unsupported stride")
-	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
+	return texture, nil
+}
 
-	texture := uint32(0)
-	gl.GenTextures(1, &texture)
-	gl.ActiveTexture(gl.TEXTURE0)
+// World textures follow the pixel mode setting, UI and text stay smooth
+func apply_texture_filter(texture uint32) {
+	filter := int32(gl.LINEAR)
+	if g_Settings.pixel_mode {
+		filter = gl.NEAREST
+	}
 	gl.BindTexture(gl.TEXTURE_2D, texture)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
-	gl.TexImage2D(
-		gl.TEXTURE_2D,
-		0,
-		gl.RGBA,
-		int32(rgba.Rect.Size().X),
-		int32(rgba.Rect.Size().Y),
-		0,
-		gl.RGBA,
-		gl.UNSIGNED_BYTE,
-		gl.Ptr(rgba.Pix))
-
-	return texture, nil
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, filter)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, filter)
 }
 
 var vertexShader = `
@@ -612,68 +1323,31 @@ var fragmentShader = `
 #version 330
 
 uniform sampler2D tex;
+uniform float alpha;
+
+// Palette swap, tex holds indices in red and the palette strip one variant per row
+uniform sampler2D palette;
+uniform bool palette_enabled;
+uniform int palette_variant;
 
 in vec2 fragTexCoord;
 
 out vec4 outputColor;
 
 void main() {
-    outputColor = texture(tex, fragTexCoord);
+    vec4 color = texture(tex, fragTexCoord);
+    if (palette_enabled) {
+        ivec2 size = textureSize(palette, 0);
+        int index = int(color.r * 255.0 + 0.5);
+        // Both are premultiplied, the source alpha cuts the sprite out
+        color = texelFetch(palette, ivec2(min(index, size.x - 1), clamp(palette_variant, 0, size.y - 1)), 0) * color.a;
+    }
+    outputColor = color * alpha;
 }
 ` + "\x00"
 
-var cubeVerticesPlayer = []float32{
-	//  X, Y, Z, U, V
-	// Bottom
-	-1.0, -1.0, -1.0, 0.0, 0.0,
-	1.0, -1.0, -1.0, 1.0, 0.0,
-	-1.0, -1.0, 1.0, 0.0, 1.0,
-	1.0, -1.0, -1.0, 1.0, 0.0,
-	1.0, -1.0, 1.0, 1.0, 1.0,
-	-1.0, -1.0, 1.0, 0.0, 1.0,
-
-	// Top
-	-1.0, 1.0, -1.0, 0.0, 0.0,
-	-1.0, 1.0, 1.0, 0.0, 1.0,
-	1.0, 1.0, -1.0, 1.0, 0.0,
-	1.0, 1.0, -1.0, 1.0, 0.0,
-	-1.0, 1.0, 1.0, 0.0, 1.0,
-	1.0, 1.0, 1.0, 1.0, 1.0,
-
-	// Front
-	-1.0, -1.0, 1.0, 1.0, 0.0,
-	1.0, -1.0, 1.0, 0.0, 0.0,
-	-1.0, 1.0, 1.0, 1.0, 1.0,
-	1.0, -1.0, 1.0, 0.0, 0.0,
-	1.0, 1.0, 1.0, 0.0, 1.0,
-	-1.0, 1.0, 1.0, 1.0, 1.0,
-
-	// Back
-	-1.0, -1.0, -1.0, 0.0, 0.0,
-	-1.0, 1.0, -1.0, 0.0, 1.0,
-	1.0, -1.0, -1.0, 1.0, 0.0,
-	1.0, -1.0, -1.0, 1.0, 0.0,
-	-1.0, 1.0, -1.0, 0.0, 1.0,
-	1.0, 1.0, -1.0, 1.0, 1.0,
-
-	// Left
-	-1.0, -1.0, 1.0, 0.0, 1.0,
-	-1.0, 1.0, -1.0, 1.0, 0.0,
-	-1.0, -1.0, -1.0, 0.0, 0.0,
-	-1.0, -1.0, 1.0, 0.0, 1.0,
-	-1.0, 1.0, 1.0, 1.0, 1.0,
-	-1.0, 1.0, -1.0, 1.0, 0.0,
-
-	// Right
-	1.0, -1.0, 1.0, 1.0, 1.0,
-	1.0, -1.0, -1.0, 1.0, 0.0,
-	1.0, 1.0, -1.0, 0.0, 0.0,
-	1.0, -1.0, 1.0, 1.0, 1.0,
-	1.0, 1.0, -1.0, 0.0, 0.0,
-	1.0, 1.0, 1.0, 0.0, 1.0,
-}
-
-var cubeVerticesMap = []float32{
+// Unit cube as separate triangles, init_cube_mesh merges the shared corners
+var cubeVertices = []float32{
 	//  X, Y, Z, U, V
 	// Bottom
 	-1.0, -1.0, -1.0, 0.0, 0.0,
@@ -726,6 +1400,9 @@ var cubeVerticesMap = []float32{
 
 // Set the working directory to the root of Go package, so that its assets can be accessed.
 func init() {
+	// Our own assets live next to the sources, remember where that is before moving away
+	g_GameDir, _ = os.Getwd()
+
 	dir, err := importPathToDir("github.com/go-gl/example/gl41core-cube")
 	if err != nil {
 		log.Fatalln("Unable to find Go package in your GOPATH, it's needed to load assets:", err)
@@ -736,6 +1413,19 @@ func init() {
 	}
 }
 
+var g_GameDir = ""
+
+// Resolves through the mounted mods first, missing files map to the base assets directory
+func asset_path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	if path, ok := g_VFS.Find(name); ok {
+		return path
+	}
+	return filepath.Join(g_GameDir, "assets", name)
+}
+
 // importPathToDir resolves the absolute path from importPath.
 // There doesn't need to be a valid Go package inside that import path,
 // but the directory must exist.
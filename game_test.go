@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+const vectorTolerance = 1e-5
+
+func vectors_close(a Vector2DF, b Vector2DF) bool {
+	return Abs(a.x-b.x) <= vectorTolerance && Abs(a.y-b.y) <= vectorTolerance
+}
+
+func TestVector2DFLength(t *testing.T) {
+	tests := []struct {
+		vec  Vector2DF
+		want float32
+	}{
+		{Vector2DF{0, 0}, 0},
+		{Vector2DF{3, 4}, 5},
+		{Vector2DF{-3, -4}, 5},
+		{Vector2DF{0, -2}, 2},
+	}
+	for _, test := range tests {
+		if got := test.vec.length(); Abs(got-test.want) > vectorTolerance {
+			t.Errorf("%v.length() = %v, want %v", test.vec, got, test.want)
+		}
+		if got := test.vec.length_squared(); Abs(got-test.want*test.want) > vectorTolerance {
+			t.Errorf("%v.length_squared() = %v, want %v", test.vec, got, test.want*test.want)
+		}
+	}
+}
+
+func TestVector2DFNormalized(t *testing.T) {
+	tests := []struct {
+		vec  Vector2DF
+		want Vector2DF
+	}{
+		{Vector2DF{3, 4}, Vector2DF{0.6, 0.8}},
+		{Vector2DF{0, -5}, Vector2DF{0, -1}},
+		// Zero stays zero instead of becoming NaN
+		{Vector2DF{0, 0}, Vector2DF{0, 0}},
+	}
+	for _, test := range tests {
+		if got := test.vec.normalized(); !vectors_close(got, test.want) {
+			t.Errorf("%v.normalized() = %v, want %v", test.vec, got, test.want)
+		}
+	}
+}
+
+func TestVector2DFDotAndDistance(t *testing.T) {
+	a, b := Vector2DF{1, 2}, Vector2DF{4, -2}
+	if got := a.dot(b); got != 0 {
+		t.Errorf("%v.dot(%v) = %v, want 0", a, b, got)
+	}
+	if got := a.dot(a); got != 5 {
+		t.Errorf("%v.dot(%v) = %v, want 5", a, a, got)
+	}
+	if got := a.distance(b); Abs(got-5) > vectorTolerance {
+		t.Errorf("%v.distance(%v) = %v, want 5", a, b, got)
+	}
+	if got, back := a.distance(b), b.distance(a); got != back {
+		t.Errorf("distance isn't symmetric: %v and %v", got, back)
+	}
+}
+
+func TestVector2DFLerp(t *testing.T) {
+	from, to := Vector2DF{-2, 4}, Vector2DF{6, 0}
+	tests := []struct {
+		t    float32
+		want Vector2DF
+	}{
+		{0, from},
+		{1, to},
+		{0.5, Vector2DF{2, 2}},
+		{0.25, Vector2DF{0, 3}},
+	}
+	for _, test := range tests {
+		if got := from.lerp(to, test.t); !vectors_close(got, test.want) {
+			t.Errorf("%v.lerp(%v, %v) = %v, want %v", from, to, test.t, got, test.want)
+		}
+	}
+}
+
+func TestVector2DFRotate(t *testing.T) {
+	tests := []struct {
+		vec   Vector2DF
+		angle float32
+		want  Vector2DF
+	}{
+		{Vector2DF{1, 0}, math.Pi / 2, Vector2DF{0, 1}},
+		{Vector2DF{1, 0}, -math.Pi / 2, Vector2DF{0, -1}},
+		{Vector2DF{0, 1}, math.Pi, Vector2DF{0, -1}},
+		{Vector2DF{2, 3}, 0, Vector2DF{2, 3}},
+		{Vector2DF{1, 1}, math.Pi / 4, Vector2DF{0, float32(math.Sqrt2)}},
+	}
+	for _, test := range tests {
+		if got := test.vec.rotate(test.angle); !vectors_close(got, test.want) {
+			t.Errorf("%v.rotate(%v) = %v, want %v", test.vec, test.angle, got, test.want)
+		}
+	}
+}
+
+func TestVector2DFClampLength(t *testing.T) {
+	tests := []struct {
+		vec        Vector2DF
+		max_length float32
+		want       Vector2DF
+	}{
+		// Shorter ones are left alone
+		{Vector2DF{1, 1}, 2, Vector2DF{1, 1}},
+		{Vector2DF{0, 0}, 1, Vector2DF{0, 0}},
+		// Longer ones keep their direction, unlike a clamp per axis
+		{Vector2DF{6, 8}, 5, Vector2DF{3, 4}},
+		{Vector2DF{-10, 0}, 2, Vector2DF{-2, 0}},
+		{Vector2DF{3, 4}, 0, Vector2DF{0, 0}},
+	}
+	for _, test := range tests {
+		if got := test.vec.clamp_length(test.max_length); !vectors_close(got, test.want) {
+			t.Errorf("%v.clamp_length(%v) = %v, want %v", test.vec, test.max_length, got, test.want)
+		}
+	}
+}
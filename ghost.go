@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Position and rotation of the player at one sample
+type GhostFrame [3]float32
+
+type GhostRun struct {
+	Seed     int64        `json:"seed"`
+	Duration float32      `json:"duration"`
+	Frames   []GhostFrame `json:"frames"`
+}
+
+type Ghost struct {
+	enabled bool
+
+	// Fastest finished run on the current seed, nil if there is none
+	best      *GhostRun
+	recording []GhostFrame
+
+	player Player
+}
+
+var g_Ghost Ghost
+
+const ghostSampleRate = 30
+const ghostAlpha = 0.35
+
+func init_ghost() {
+	g_Ghost.enabled = true
+	g_Ghost.player = g_Player
+	load_ghost()
+
+	register_command("ghost", "toggle racing the best run's ghost", func() {
+		g_Ghost.enabled = !g_Ghost.enabled
+		console_print("ghost: %v", g_Ghost.enabled)
+	})
+}
+
+func ghost_path(seed int64) string {
+	return filepath.Join(save_directory(), "ghosts", map_seed_key(seed)+".json")
+}
+
+func load_ghost() {
+	g_Ghost.best = nil
+
+	data, err := os.ReadFile(ghost_path(g_MapSeed))
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Println("ghost:", err)
+		return
+	}
+
+	run := GhostRun{}
+	if err := json.Unmarshal(data, &run); err != nil {
+		log.Println("ghost:", err)
+		return
+	}
+	g_Ghost.best = &run
+}
+
+// Only replaces the stored ghost when the finished run was faster
+func save_ghost(duration float32) {
+	if g_Ghost.best != nil && g_Ghost.best.Duration <= duration {
+		return
+	}
+
+	run := GhostRun{Seed: g_MapSeed, Duration: duration, Frames: g_Ghost.recording}
+	g_Ghost.best = &run
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		log.Println("ghost:", err)
+		return
+	}
+	path := ghost_path(g_MapSeed)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Println("ghost:", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Println("ghost:", err)
+	}
+}
+
+// Samples are taken on the speedrun's game clock so playback lines up with the live run
+func record_ghost_frame() {
+	if g_Speedrun.finished {
+		return
+	}
+	for len(g_Ghost.recording) <= int(g_Speedrun.game_time*ghostSampleRate) {
+		g_Ghost.recording = append(g_Ghost.recording, GhostFrame{g_Player.pos.x, g_Player.pos.y, g_Player.angle_z})
+	}
+}
+
+func step_ghost() {
+	record_ghost_frame()
+
+	run := g_Ghost.best
+	if run == nil || len(run.Frames) == 0 {
+		return
+	}
+
+	position := g_Speedrun.game_time * ghostSampleRate
+	index := min(int(position), len(run.Frames)-1)
+	next := min(index+1, len(run.Frames)-1)
+	t := min(position-float32(index), 1)
+
+	a, b := run.Frames[index], run.Frames[next]
+	g_Ghost.player.pos = Vector2DF{a[0], a[1]}.lerp(Vector2DF{b[0], b[1]}, t)
+	g_Ghost.player.angle_z = a[2] + (b[2]-a[2])*t
+}
+
+func render_ghost(model_uniform int32, alpha_uniform int32) {
+	if !g_Ghost.enabled || g_Ghost.best == nil {
+		return
+	}
+
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
+	gl.Uniform1f(alpha_uniform, ghostAlpha)
+
+	render_player(&g_Ghost.player, model_uniform)
+
+	gl.Uniform1f(alpha_uniform, 1)
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+}
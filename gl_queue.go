@@ -0,0 +1,92 @@
+package main
+
+import (
+	"image"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// GL calls only work on the main OS thread, other goroutines queue them here
+type GLQueue struct {
+	mutex    sync.Mutex
+	commands []func()
+
+	// Texture files being decoded in the background, main thread only
+	loading map[string][]func(uint32, error)
+}
+
+// Commands left over when the budget runs out wait for the next frame
+const glQueueFrameBudget = 4 * time.Millisecond
+
+var g_GLQueue = GLQueue{loading: map[string][]func(uint32, error){}}
+
+// Safe from any goroutine, command runs on the main thread during a later frame
+func queue_gl(command func()) {
+	g_GLQueue.mutex.Lock()
+	g_GLQueue.commands = append(g_GLQueue.commands, command)
+	g_GLQueue.mutex.Unlock()
+}
+
+// Called once per frame from the main thread
+func run_gl_commands() {
+	started := time.Now()
+
+	for time.Since(started) < glQueueFrameBudget {
+		g_GLQueue.mutex.Lock()
+		if len(g_GLQueue.commands) == 0 {
+			g_GLQueue.mutex.Unlock()
+			return
+		}
+		command := g_GLQueue.commands[0]
+		g_GLQueue.commands = g_GLQueue.commands[1:]
+		g_GLQueue.mutex.Unlock()
+
+		command()
+	}
+}
+
+// Decodes the image on a goroutine and uploads it from the main thread into the
+// texture cache, done (may be nil) runs on the main thread once it's there
+func load_texture_async(file string, done func(uint32, error)) {
+	if done == nil {
+		done = func(uint32, error) {}
+	}
+
+	if texture, ok := g_TextureCache[file]; ok || g_Headless {
+		done(texture, nil)
+		return
+	}
+	if waiting, ok := g_GLQueue.loading[file]; ok {
+		g_GLQueue.loading[file] = append(waiting, done)
+		return
+	}
+	g_GLQueue.loading[file] = []func(uint32, error){done}
+
+	path := texture_path(file)
+	go func() {
+		img, err := render.DecodeImage(path)
+		queue_gl(func() { finish_texture_load(file, img, err) })
+	}()
+}
+
+func finish_texture_load(file string, img image.Image, err error) {
+	texture, cached := g_TextureCache[file]
+	if !cached && err == nil {
+		texture, err = render.TextureFromImage(img)
+		if err == nil {
+			apply_texture_filter(texture)
+			g_TextureCache[file] = texture
+		}
+	}
+	if err != nil {
+		log.Println("textures:", err)
+	}
+
+	for _, done := range g_GLQueue.loading[file] {
+		done(texture, err)
+	}
+	delete(g_GLQueue.loading, file)
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// The subset of glTF 2.0 static props need: triangle meshes with positions,
+// UVs, normals and a base color texture or factor. Animations and skins are ignored.
+type GltfDocument struct {
+	Scene       int              `json:"scene"`
+	Scenes      []GltfScene      `json:"scenes"`
+	Nodes       []GltfNode       `json:"nodes"`
+	Meshes      []GltfMesh       `json:"meshes"`
+	Accessors   []GltfAccessor   `json:"accessors"`
+	BufferViews []GltfBufferView `json:"bufferViews"`
+	Buffers     []GltfBuffer     `json:"buffers"`
+	Materials   []GltfMaterial   `json:"materials"`
+	Textures    []GltfTexture    `json:"textures"`
+	Images      []GltfImage      `json:"images"`
+}
+
+type GltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type GltfNode struct {
+	Mesh        *int      `json:"mesh"`
+	Children    []int     `json:"children"`
+	Matrix      []float32 `json:"matrix"`
+	Translation []float32 `json:"translation"`
+	Rotation    []float32 `json:"rotation"`
+	Scale       []float32 `json:"scale"`
+}
+
+type GltfMesh struct {
+	Primitives []GltfPrimitive `json:"primitives"`
+}
+
+type GltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Material   *int           `json:"material"`
+	Mode       *int           `json:"mode"`
+}
+
+type GltfAccessor struct {
+	BufferView    *int   `json:"bufferView"`
+	ByteOffset    int    `json:"byteOffset"`
+	ComponentType int    `json:"componentType"`
+	Normalized    bool   `json:"normalized"`
+	Count         int    `json:"count"`
+	Type          string `json:"type"`
+}
+
+type GltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type GltfBuffer struct {
+	Uri        string `json:"uri"`
+	ByteLength int    `json:"byteLength"`
+}
+
+type GltfMaterial struct {
+	PbrMetallicRoughness struct {
+		BaseColorFactor  []float32 `json:"baseColorFactor"`
+		BaseColorTexture *struct {
+			Index int `json:"index"`
+		} `json:"baseColorTexture"`
+	} `json:"pbrMetallicRoughness"`
+}
+
+type GltfTexture struct {
+	Source *int `json:"source"`
+}
+
+type GltfImage struct {
+	Uri        string `json:"uri"`
+	BufferView *int   `json:"bufferView"`
+}
+
+const (
+	gltfComponentUnsignedByte  = 5121
+	gltfComponentUnsignedShort = 5123
+	gltfComponentUnsignedInt   = 5125
+	gltfComponentFloat         = 5126
+
+	gltfModeTriangles = 4
+
+	glbMagic     = 0x46546C67
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+)
+
+var gltfComponentCounts = map[string]int{"SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4, "MAT4": 16}
+
+// CPU side mesh data, vertices use the mesh layout and node transforms are already applied
+type ModelPrimitive struct {
+	vertices []float32
+	indices  []uint32
+
+	// Premultiplied, tints base_texture or is the whole color without one
+	base_color color.RGBA
+	// nil when the material has no texture
+	base_texture image.Image
+}
+
+type gltfLoader struct {
+	document  GltfDocument
+	directory string
+	buffers   [][]byte
+}
+
+// Reads a .gltf (with external or data URI buffers) or a binary .glb file
+func read_gltf(path string) ([]ModelPrimitive, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := &gltfLoader{directory: filepath.Dir(path)}
+	json_data, embedded, err := split_glb(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := json.Unmarshal(json_data, &loader.document); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, json_error_context(json_data, err))
+	}
+	if err := loader.load_buffers(embedded); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	primitives := []ModelPrimitive{}
+	roots := []int{}
+	if len(loader.document.Scenes) > 0 {
+		roots = loader.document.Scenes[min(max(loader.document.Scene, 0), len(loader.document.Scenes)-1)].Nodes
+	} else {
+		for i := range loader.document.Nodes {
+			roots = append(roots, i)
+		}
+	}
+	for _, root := range roots {
+		if err := loader.collect_node(root, mgl32.Ident4(), 0, &primitives); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return primitives, nil
+}
+
+// Plain .gltf files are returned as is
+func split_glb(data []byte) ([]byte, []byte, error) {
+	if len(data) < 12 || binary.LittleEndian.Uint32(data) != glbMagic {
+		return data, nil, nil
+	}
+
+	json_data, bin_data := []byte(nil), []byte(nil)
+	for offset := 12; offset+8 <= len(data); {
+		length := int(binary.LittleEndian.Uint32(data[offset:]))
+		kind := binary.LittleEndian.Uint32(data[offset+4:])
+		start := offset + 8
+		if start+length > len(data) {
+			return nil, nil, errors.New("truncated glb chunk")
+		}
+		switch kind {
+		case glbChunkJSON:
+			json_data = data[start : start+length]
+		case glbChunkBIN:
+			bin_data = data[start : start+length]
+		}
+		offset = start + length
+	}
+	if json_data == nil {
+		return nil, nil, errors.New("glb without a JSON chunk")
+	}
+	return json_data, bin_data, nil
+}
+
+func (loader *gltfLoader) load_buffers(embedded []byte) error {
+	for i, buffer := range loader.document.Buffers {
+		data, err := loader.read_uri(buffer.Uri)
+		if buffer.Uri == "" {
+			// The first buffer of a glb without a uri is its BIN chunk
+			data, err = embedded, nil
+		}
+		if err != nil {
+			return fmt.Errorf("buffer %d: %w", i, err)
+		}
+		if len(data) < buffer.ByteLength {
+			return fmt.Errorf("buffer %d is %d bytes, expected %d", i, len(data), buffer.ByteLength)
+		}
+		loader.buffers = append(loader.buffers, data)
+	}
+	return nil
+}
+
+func (loader *gltfLoader) read_uri(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		comma := strings.IndexByte(uri, ',')
+		if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+			return nil, errors.New("only base64 data URIs are supported")
+		}
+		return base64.StdEncoding.DecodeString(uri[comma+1:])
+	}
+	return os.ReadFile(filepath.Join(loader.directory, filepath.FromSlash(uri)))
+}
+
+// Nodes deeper than this are assumed to be a cycle in a broken file
+const gltfMaxNodeDepth = 64
+
+func (loader *gltfLoader) collect_node(index int, parent mgl32.Mat4, depth int, primitives *[]ModelPrimitive) error {
+	if index < 0 || index >= len(loader.document.Nodes) || depth > gltfMaxNodeDepth {
+		return fmt.Errorf("bad node %d", index)
+	}
+	node := loader.document.Nodes[index]
+	world := parent.Mul4(gltf_node_matrix(node))
+
+	if node.Mesh != nil {
+		if *node.Mesh < 0 || *node.Mesh >= len(loader.document.Meshes) {
+			return fmt.Errorf("node %d: bad mesh %d", index, *node.Mesh)
+		}
+		for _, primitive := range loader.document.Meshes[*node.Mesh].Primitives {
+			if primitive.Mode != nil && *primitive.Mode != gltfModeTriangles {
+				continue
+			}
+			model_primitive, err := loader.read_primitive(primitive, world)
+			if err != nil {
+				return fmt.Errorf("mesh %d: %w", *node.Mesh, err)
+			}
+			*primitives = append(*primitives, model_primitive)
+		}
+	}
+
+	for _, child := range node.Children {
+		if err := loader.collect_node(child, world, depth+1, primitives); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gltf_node_matrix(node GltfNode) mgl32.Mat4 {
+	if len(node.Matrix) == 16 {
+		return mgl32.Mat4(node.Matrix)
+	}
+
+	matrix := mgl32.Ident4()
+	if len(node.Translation) == 3 {
+		matrix = matrix.Mul4(mgl32.Translate3D(node.Translation[0], node.Translation[1], node.Translation[2]))
+	}
+	if len(node.Rotation) == 4 {
+		rotation := mgl32.Quat{W: node.Rotation[3], V: mgl32.Vec3{node.Rotation[0], node.Rotation[1], node.Rotation[2]}}
+		matrix = matrix.Mul4(rotation.Normalize().Mat4())
+	}
+	if len(node.Scale) == 3 {
+		matrix = matrix.Mul4(mgl32.Scale3D(node.Scale[0], node.Scale[1], node.Scale[2]))
+	}
+	return matrix
+}
+
+func (loader *gltfLoader) read_primitive(primitive GltfPrimitive, world mgl32.Mat4) (ModelPrimitive, error) {
+	position_accessor, ok := primitive.Attributes["POSITION"]
+	if !ok {
+		return ModelPrimitive{}, errors.New("primitive without positions")
+	}
+	positions, err := loader.read_floats(position_accessor, 3)
+	if err != nil {
+		return ModelPrimitive{}, fmt.Errorf("positions: %w", err)
+	}
+	count := len(positions) / 3
+
+	uvs := make([]float32, count*2)
+	if accessor, ok := primitive.Attributes["TEXCOORD_0"]; ok {
+		if uvs, err = loader.read_floats(accessor, 2); err != nil || len(uvs) != count*2 {
+			return ModelPrimitive{}, fmt.Errorf("uvs: %v", err)
+		}
+	}
+	normals := make([]float32, count*3)
+	if accessor, ok := primitive.Attributes["NORMAL"]; ok {
+		if normals, err = loader.read_floats(accessor, 3); err != nil || len(normals) != count*3 {
+			return ModelPrimitive{}, fmt.Errorf("normals: %v", err)
+		}
+	}
+
+	normal_matrix := world.Mat3().Inv().Transpose()
+	vertices := make([]float32, 0, count*meshVertexFloats)
+	for i := 0; i < count; i++ {
+		position := world.Mul4x1(mgl32.Vec4{positions[i*3], positions[i*3+1], positions[i*3+2], 1})
+		normal := normal_matrix.Mul3x1(mgl32.Vec3{normals[i*3], normals[i*3+1], normals[i*3+2]})
+		if normal.Len() > 0 {
+			normal = normal.Normalize()
+		}
+		vertices = append(vertices, position[0], position[1], position[2], uvs[i*2], uvs[i*2+1], normal[0], normal[1], normal[2])
+	}
+
+	indices := []uint32{}
+	if primitive.Indices != nil {
+		if indices, err = loader.read_indices(*primitive.Indices); err != nil {
+			return ModelPrimitive{}, fmt.Errorf("indices: %w", err)
+		}
+		for _, index := range indices {
+			if int(index) >= count {
+				return ModelPrimitive{}, fmt.Errorf("index %d out of range", index)
+			}
+		}
+	} else {
+		for i := 0; i < count; i++ {
+			indices = append(indices, uint32(i))
+		}
+	}
+
+	model_primitive := ModelPrimitive{vertices: vertices, indices: indices, base_color: color.RGBA{255, 255, 255, 255}}
+	if primitive.Material != nil {
+		if err := loader.read_material(*primitive.Material, &model_primitive); err != nil {
+			return ModelPrimitive{}, err
+		}
+	}
+	return model_primitive, nil
+}
+
+// The raw bytes of an accessor's elements, and the distance between consecutive ones
+func (loader *gltfLoader) accessor_data(index int, element_size int) (GltfAccessor, []byte, int, error) {
+	if index < 0 || index >= len(loader.document.Accessors) {
+		return GltfAccessor{}, nil, 0, fmt.Errorf("bad accessor %d", index)
+	}
+	accessor := loader.document.Accessors[index]
+	if accessor.BufferView == nil {
+		return accessor, nil, 0, errors.New("sparse or empty accessors aren't supported")
+	}
+	if *accessor.BufferView < 0 || *accessor.BufferView >= len(loader.document.BufferViews) {
+		return accessor, nil, 0, fmt.Errorf("bad buffer view %d", *accessor.BufferView)
+	}
+	view := loader.document.BufferViews[*accessor.BufferView]
+	if view.Buffer < 0 || view.Buffer >= len(loader.buffers) {
+		return accessor, nil, 0, fmt.Errorf("bad buffer %d", view.Buffer)
+	}
+
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = element_size
+	}
+	start := view.ByteOffset + accessor.ByteOffset
+	end := start + stride*(accessor.Count-1) + element_size
+	if accessor.Count == 0 {
+		end = start
+	}
+	if start < 0 || end > view.ByteOffset+view.ByteLength || end > len(loader.buffers[view.Buffer]) {
+		return accessor, nil, 0, fmt.Errorf("accessor %d runs past its buffer view", index)
+	}
+	return accessor, loader.buffers[view.Buffer][start:end], stride, nil
+}
+
+func (loader *gltfLoader) read_floats(index int, components int) ([]float32, error) {
+	if index >= 0 && index < len(loader.document.Accessors) {
+		accessor := loader.document.Accessors[index]
+		if accessor.ComponentType != gltfComponentFloat || gltfComponentCounts[accessor.Type] != components {
+			return nil, fmt.Errorf("accessor %d must be %d float components", index, components)
+		}
+	}
+
+	accessor, data, stride, err := loader.accessor_data(index, components*4)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]float32, 0, accessor.Count*components)
+	for i := 0; i < accessor.Count; i++ {
+		for c := 0; c < components; c++ {
+			bits := binary.LittleEndian.Uint32(data[i*stride+c*4:])
+			values = append(values, math.Float32frombits(bits))
+		}
+	}
+	return values, nil
+}
+
+func (loader *gltfLoader) read_indices(index int) ([]uint32, error) {
+	if index < 0 || index >= len(loader.document.Accessors) {
+		return nil, fmt.Errorf("bad accessor %d", index)
+	}
+	size := map[int]int{gltfComponentUnsignedByte: 1, gltfComponentUnsignedShort: 2, gltfComponentUnsignedInt: 4}[loader.document.Accessors[index].ComponentType]
+	if size == 0 {
+		return nil, fmt.Errorf("accessor %d has an unsupported index type", index)
+	}
+
+	accessor, data, stride, err := loader.accessor_data(index, size)
+	if err != nil {
+		return nil, err
+	}
+	indices := make([]uint32, accessor.Count)
+	for i := range indices {
+		switch size {
+		case 1:
+			indices[i] = uint32(data[i*stride])
+		case 2:
+			indices[i] = uint32(binary.LittleEndian.Uint16(data[i*stride:]))
+		case 4:
+			indices[i] = binary.LittleEndian.Uint32(data[i*stride:])
+		}
+	}
+	return indices, nil
+}
+
+func (loader *gltfLoader) read_material(index int, primitive *ModelPrimitive) error {
+	if index < 0 || index >= len(loader.document.Materials) {
+		return fmt.Errorf("bad material %d", index)
+	}
+	pbr := loader.document.Materials[index].PbrMetallicRoughness
+
+	if factor := pbr.BaseColorFactor; len(factor) == 4 {
+		// Premultiplied like every other texture
+		alpha := min(max(factor[3], 0), 1)
+		to_byte := func(value float32) uint8 { return uint8(min(max(value*alpha, 0), 1) * 255) }
+		primitive.base_color = color.RGBA{to_byte(factor[0]), to_byte(factor[1]), to_byte(factor[2]), uint8(alpha * 255)}
+	}
+
+	if pbr.BaseColorTexture == nil {
+		return nil
+	}
+	texture_index := pbr.BaseColorTexture.Index
+	if texture_index < 0 || texture_index >= len(loader.document.Textures) || loader.document.Textures[texture_index].Source == nil {
+		return fmt.Errorf("material %d: bad texture %d", index, texture_index)
+	}
+	image_index := *loader.document.Textures[texture_index].Source
+	if image_index < 0 || image_index >= len(loader.document.Images) {
+		return fmt.Errorf("material %d: bad image %d", index, image_index)
+	}
+
+	img, err := loader.read_image(loader.document.Images[image_index])
+	if err != nil {
+		return fmt.Errorf("image %d: %w", image_index, err)
+	}
+	primitive.base_texture = img
+	return nil
+}
+
+func (loader *gltfLoader) read_image(gltf_image GltfImage) (image.Image, error) {
+	if gltf_image.BufferView != nil {
+		index := *gltf_image.BufferView
+		if index < 0 || index >= len(loader.document.BufferViews) {
+			return nil, fmt.Errorf("bad buffer view %d", index)
+		}
+		view := loader.document.BufferViews[index]
+		if view.Buffer < 0 || view.Buffer >= len(loader.buffers) || view.ByteOffset+view.ByteLength > len(loader.buffers[view.Buffer]) {
+			return nil, fmt.Errorf("buffer view %d runs past its buffer", index)
+		}
+		img, _, err := image.Decode(bytes.NewReader(loader.buffers[view.Buffer][view.ByteOffset : view.ByteOffset+view.ByteLength]))
+		return img, err
+	}
+
+	if strings.HasPrefix(gltf_image.Uri, "data:") {
+		data, err := loader.read_uri(gltf_image.Uri)
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		return img, err
+	}
+	return render.DecodeImage(filepath.Join(loader.directory, filepath.FromSlash(gltf_image.Uri)))
+}
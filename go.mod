@@ -6,9 +6,16 @@ require (
 	github.com/go-gl/gl v0.0.0-20210426225639-a3bfa832c8aa
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20210410170116-ea3d685f79fb
 	github.com/go-gl/mathgl v1.0.0
+	github.com/hajimehoshi/go-mp3 v0.3.4
+	github.com/jfreymuth/oggvorbis v1.0.5
+	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb
 )
 
 require (
 	github.com/go-gl/example v0.0.0-20220216040751-d71b0d9f823d // indirect
-	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb // indirect
+	github.com/jfreymuth/vorbis v1.0.2 // indirect
 )
+
+require github.com/guiteixeirapimentel/small-game-go/engine v0.0.0
+
+replace github.com/guiteixeirapimentel/small-game-go/engine => ./engine
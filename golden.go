@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Fixed so goldens don't depend on the configured window size
+const goldenWidth = 320
+const goldenHeight = 240
+
+type GoldenScene struct {
+	name  string
+	setup func()
+	// Camera center and distance
+	camera Vector2DF
+	z      float32
+}
+
+var g_GoldenScenes = []GoldenScene{
+	{"map_start", func() { golden_map(1) }, Vector2DF{6, -3}, 25},
+	{"map_generated", func() { golden_map(7) }, Vector2DF{50, 0}, 45},
+	{"prefabs", func() {
+		golden_map(1)
+		spawn_prefab("spikes", Vector2DF{2, -4.5})
+		spawn_prefab("moving_platform", Vector2DF{8, -1})
+		spawn_prefab("floater", Vector2DF{12, 0})
+		update_scene_graph()
+	}, Vector2DF{7, -2}, 20},
+}
+
+func golden_map(seed int64) {
+	set_level(custom_level("golden", seed))
+	build_map()
+	respawn_player(&g_Player)
+	update_player_bounding_box(&g_Player)
+}
+
+// Renders every golden scene into an offscreen framebuffer and compares it with
+// the stored PNG, or overwrites the PNGs when update is set. Returns false when a scene differs.
+func run_golden_images(update bool, uniforms SceneUniforms) bool {
+	directory := filepath.Join(g_GameDir, g_Config.String("golden_dir"))
+	tolerance := uint8(config_int("golden_tolerance"))
+	max_fraction := float64(config_float("golden_max_diff"))
+
+	framebuffer, cleanup := create_golden_framebuffer()
+	defer cleanup()
+	gl.BindFramebuffer(gl.FRAMEBUFFER, framebuffer)
+	defer gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	gl.Viewport(0, 0, goldenWidth, goldenHeight)
+	defer gl.Viewport(0, 0, int32(g_WindowWidth), int32(g_WindowHeight))
+
+	passed := true
+	for _, scene := range g_GoldenScenes {
+		img := render_golden_scene(scene, uniforms)
+		path := filepath.Join(directory, scene.name+".png")
+
+		if update {
+			if err := write_png(path, img); err != nil {
+				fmt.Printf("golden %s: %v\n", scene.name, err)
+				passed = false
+				continue
+			}
+			fmt.Printf("golden %s: updated\n", scene.name)
+			continue
+		}
+
+		golden, err := read_png(path)
+		if err != nil {
+			fmt.Printf("golden %s: FAIL %v (run with -golden=update to create it)\n", scene.name, err)
+			passed = false
+			continue
+		}
+
+		differing, diff := compare_images(img, golden, tolerance)
+		fraction := float64(differing) / float64(goldenWidth*goldenHeight)
+		if fraction > max_fraction {
+			fmt.Printf("golden %s: FAIL %d pixels (%.3f%%) differ\n", scene.name, differing, fraction*100)
+			write_png(filepath.Join(directory, scene.name+".actual.png"), img)
+			write_png(filepath.Join(directory, scene.name+".diff.png"), diff)
+			passed = false
+			continue
+		}
+		fmt.Printf("golden %s: ok\n", scene.name)
+	}
+	return passed
+}
+
+func create_golden_framebuffer() (uint32, func()) {
+	framebuffer, color_buffer, depth_buffer := uint32(0), uint32(0), uint32(0)
+
+	gl.GenFramebuffers(1, &framebuffer)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, framebuffer)
+
+	gl.GenRenderbuffers(1, &color_buffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, color_buffer)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.RGBA8, goldenWidth, goldenHeight)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.RENDERBUFFER, color_buffer)
+
+	gl.GenRenderbuffers(1, &depth_buffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, depth_buffer)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, goldenWidth, goldenHeight)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, depth_buffer)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	return framebuffer, func() {
+		gl.DeleteFramebuffers(1, &framebuffer)
+		gl.DeleteRenderbuffers(1, &color_buffer)
+		gl.DeleteRenderbuffers(1, &depth_buffer)
+	}
+}
+
+func render_golden_scene(scene GoldenScene, uniforms SceneUniforms) *image.RGBA {
+	scene.setup()
+
+	g_Map.angle = 0
+	g_Camera.pos2D, g_Camera.previous_pos2D = scene.camera, scene.camera
+	g_Camera.z_value, g_Camera.previous_z_value = scene.z, scene.z
+	g_Player.has_previous_transform = false
+	g_Timestep.alpha = 1
+
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	projection := mgl32.Perspective(mgl32.DegToRad(cameraFovY), float32(goldenWidth)/goldenHeight, 0.1, 1000.0)
+	gl.UniformMatrix4fv(uniforms.projection, 1, false, &projection[0])
+	update_camera_uniforms(uniforms.camera)
+	render_map(uniforms.model)
+	render_collectibles(uniforms.model)
+	render_dynamic_entities(uniforms.model)
+	render_player(&g_Player, uniforms.model)
+
+	img := image.NewRGBA(image.Rect(0, 0, goldenWidth, goldenHeight))
+	gl.ReadPixels(0, 0, goldenWidth, goldenHeight, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+
+	// GL rows start at the bottom
+	row := make([]byte, img.Stride)
+	for y := 0; y < goldenHeight/2; y++ {
+		top := img.Pix[y*img.Stride : (y+1)*img.Stride]
+		bottom := img.Pix[(goldenHeight-1-y)*img.Stride : (goldenHeight-y)*img.Stride]
+		copy(row, top)
+		copy(top, bottom)
+		copy(bottom, row)
+	}
+	return img
+}
+
+// Counts pixels where any channel differs by more than tolerance, marking them red in the diff image
+func compare_images(actual *image.RGBA, golden image.Image, tolerance uint8) (int, *image.RGBA) {
+	diff := image.NewRGBA(actual.Bounds())
+	if golden.Bounds() != actual.Bounds() {
+		return actual.Bounds().Dx() * actual.Bounds().Dy(), diff
+	}
+
+	differing := 0
+	for y := 0; y < goldenHeight; y++ {
+		for x := 0; x < goldenWidth; x++ {
+			a := actual.RGBAAt(x, y)
+			g := color.RGBAModel.Convert(golden.At(x, y)).(color.RGBA)
+			if channel_distance(a.R, g.R) > tolerance || channel_distance(a.G, g.G) > tolerance ||
+				channel_distance(a.B, g.B) > tolerance || channel_distance(a.A, g.A) > tolerance {
+				differing++
+				diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+				continue
+			}
+			// Faded copy of the expected image for context
+			diff.SetRGBA(x, y, color.RGBA{g.R / 4, g.G / 4, g.B / 4, 255})
+		}
+	}
+	return differing, diff
+}
+
+func channel_distance(a uint8, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func read_png(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
+
+func write_png(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, img)
+}
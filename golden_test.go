@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// Set for the copy of the test binary that runs the game, see TestGoldenImages
+const goldenChildEnv = "GOLDEN_TEST_CHILD"
+
+// GLFW has to run on the main thread, which only TestMain is on, so the golden check runs the game
+// in a child process of the test binary rather than in the test itself
+func TestMain(m *testing.M) {
+	if os.Getenv(goldenChildEnv) != "" {
+		os.Args = os.Args[:1]
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Renders every golden scene and fails when one differs from its image in testdata/golden. Needs a
+// display, on CI run it under xvfb-run with Mesa so the rasterization matches the stored images.
+func TestGoldenImages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("renders with OpenGL")
+	}
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		t.Skip("no display to open an OpenGL context on")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Dir = g_GameDir
+	// Default settings and an empty save, not whatever this machine's profile has
+	cmd.Env = append(os.Environ(), goldenChildEnv+"=1", configEnvPrefix+"GOLDEN=check", "XDG_CONFIG_HOME="+t.TempDir())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("golden images differ, see testdata/golden for the .actual.png and .diff.png of each: %v\n%s", err, output)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Lines on the z = 0 plane the blocks sit on, fading out away from the camera. Only the
+// visible part is built each frame, so it looks endless.
+type Grid struct {
+	enabled bool
+	// World units between lines, the editor snaps to it
+	size float32
+
+	vao      uint32
+	vbo      uint32
+	vertices []float32
+}
+
+var g_Grid = Grid{size: 1}
+
+// Every few lines is drawn brighter to make distances easy to count
+const gridMajorEvery = 5
+const gridMaxLines = 400
+
+func init_grid() {
+	g_Grid.enabled = config_bool("grid")
+	g_Grid.size = max(config_float("grid_size"), 0.05)
+	g_Grid.vao, g_Grid.vbo = new_shape_buffer(g_Shapes.program)
+
+	register_command("grid", "[size]: toggle the editor grid, or show it with the given cell size", func(args []string) {
+		if len(args) > 0 {
+			size, err := strconv.ParseFloat(args[0], 32)
+			if err != nil || size < 0.05 {
+				console_print("usage: grid [size]")
+				return
+			}
+			g_Grid.size = float32(size)
+			g_Grid.enabled = true
+		} else {
+			g_Grid.enabled = !g_Grid.enabled
+		}
+		console_print("grid = %t, size %g", g_Grid.enabled, g_Grid.size)
+	})
+}
+
+// Rounds a position to the nearest grid line on both axes
+func snap_to_grid(pos Vector2DF) Vector2DF {
+	size := g_Grid.size
+	return Vector2DF{
+		float32(math.Round(float64(pos.x/size))) * size,
+		float32(math.Round(float64(pos.y/size))) * size,
+	}
+}
+
+func grid_line_color(index int, axis_color color.RGBA, fade float32) color.RGBA {
+	c := color.RGBA{90, 90, 90, 110}
+	if index == 0 {
+		c = axis_color
+	} else if index%gridMajorEvery == 0 {
+		c = color.RGBA{60, 60, 60, 170}
+	}
+	// Premultiplied, every channel fades together
+	scale := func(value uint8) uint8 { return uint8(float32(value) * fade) }
+	return color.RGBA{scale(c.R), scale(c.G), scale(c.B), scale(c.A)}
+}
+
+// Lines are split per cell so the fade can vary along them
+func append_grid_line(vertices []float32, from Vector2DF, to Vector2DF, index int, axis_color color.RGBA, center Vector2DF, fade_distance float32) []float32 {
+	segments := int(math.Ceil(float64(from.distance(to) / g_Grid.size)))
+	for i := 0; i < segments; i++ {
+		a := from.lerp(to, float32(i)/float32(segments))
+		b := from.lerp(to, float32(i+1)/float32(segments))
+		fade_a := max(1-a.distance(center)/fade_distance, 0)
+		fade_b := max(1-b.distance(center)/fade_distance, 0)
+		if fade_a == 0 && fade_b == 0 {
+			continue
+		}
+		vertices = append_shape_vertex(vertices, a, grid_line_color(index, axis_color, fade_a))
+		vertices = append_shape_vertex(vertices, b, grid_line_color(index, axis_color, fade_b))
+	}
+	return vertices
+}
+
+// Drawn after the opaque world with depth testing, so blocks hide the lines behind them
+func render_grid(projection mgl32.Mat4) {
+	if !g_Grid.enabled && !editor_active() {
+		return
+	}
+
+	visible := camera_visible_bounds()
+	lower, upper := visible.min_corner(), visible.max_corner()
+	size := g_Grid.size
+	first_x, last_x := int(math.Floor(float64(lower.x/size))), int(math.Ceil(float64(upper.x/size)))
+	first_y, last_y := int(math.Floor(float64(lower.y/size))), int(math.Ceil(float64(upper.y/size)))
+	if last_x-first_x+last_y-first_y > gridMaxLines {
+		// Zoomed too far out for the cell size, the grid would be a solid smear
+		return
+	}
+
+	center := visible.center()
+	fade_distance := visible.size().length() / 2
+	vertices := g_Grid.vertices[:0]
+	for x := first_x; x <= last_x; x++ {
+		from, to := Vector2DF{float32(x) * size, lower.y}, Vector2DF{float32(x) * size, upper.y}
+		vertices = append_grid_line(vertices, from, to, x, color.RGBA{0, 160, 0, 220}, center, fade_distance)
+	}
+	for y := first_y; y <= last_y; y++ {
+		from, to := Vector2DF{lower.x, float32(y) * size}, Vector2DF{upper.x, float32(y) * size}
+		vertices = append_grid_line(vertices, from, to, y, color.RGBA{200, 0, 0, 220}, center, fade_distance)
+	}
+	g_Grid.vertices = vertices
+
+	camera := camera_view_matrix()
+	gl.UseProgram(g_Shapes.program)
+	gl.UniformMatrix4fv(g_Shapes.projection_uniform, 1, false, &projection[0])
+	gl.UniformMatrix4fv(g_Shapes.camera_uniform, 1, false, &camera[0])
+
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
+
+	flush_shape_buffer(g_Grid.vao, g_Grid.vbo, vertices, gl.LINES)
+
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+}
@@ -0,0 +1,62 @@
+package main
+
+import "log"
+
+type HapticsBackend interface {
+	// Strengths are in [0, 1], duration in seconds
+	rumble(strong float32, weak float32, duration float32)
+	stop()
+	close()
+}
+
+type NullHapticsBackend struct{}
+
+func (NullHapticsBackend) rumble(strong float32, weak float32, duration float32) {}
+func (NullHapticsBackend) stop()                                                 {}
+func (NullHapticsBackend) close()                                                {}
+
+type Haptics struct {
+	backend HapticsBackend
+}
+
+var g_Haptics = Haptics{NullHapticsBackend{}}
+
+const hardLandingSpeed = float32(15)
+const damageForFullRumble = float32(50)
+
+func init_haptics() {
+	backend, err := new_platform_haptics_backend()
+	if err != nil {
+		log.Println("haptics disabled:", err)
+		backend = NullHapticsBackend{}
+	}
+	g_Haptics.backend = backend
+
+	subscribe_event(EVENT_PLAYER_LANDED, func(event GameEvent) {
+		if event.magnitude < hardLandingSpeed {
+			return
+		}
+		strength := min((event.magnitude-hardLandingSpeed)/hardLandingSpeed, 1)
+		haptics_rumble(max(strength, 0.2), 0.5*strength, 0.15)
+	})
+
+	subscribe_event(EVENT_PLAYER_DAMAGED, func(event GameEvent) {
+		strength := min(event.magnitude/damageForFullRumble, 1)
+		haptics_rumble(strength, strength, 0.3)
+	})
+}
+
+func haptics_rumble(strong float32, weak float32, duration float32) {
+	if !g_Settings.haptics_enabled || g_Settings.haptics_intensity <= 0 {
+		return
+	}
+
+	intensity := min(g_Settings.haptics_intensity, 1)
+	g_Haptics.backend.rumble(strong*intensity, weak*intensity, duration)
+}
+
+func close_haptics() {
+	g_Haptics.backend.stop()
+	g_Haptics.backend.close()
+	g_Haptics.backend = NullHapticsBackend{}
+}
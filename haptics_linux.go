@@ -0,0 +1,112 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Mirrors struct ff_effect from linux/input.h with the rumble member of the union.
+// The union is 8 byte aligned because ff_periodic_effect holds a pointer.
+type evdevFFEffect struct {
+	kind      uint16
+	id        int16
+	direction uint16
+	trigger   [2]uint16
+	replay    [2]uint16 // length (ms), delay (ms)
+	_         uint16
+
+	strong_magnitude uint16
+	weak_magnitude   uint16
+	_                [28]byte
+}
+
+type evdevInputEvent struct {
+	time  syscall.Timeval
+	kind  uint16
+	code  uint16
+	value int32
+}
+
+const (
+	evdevEV_FF     = 0x15
+	evdevFF_RUMBLE = 0x50
+
+	iocWrite = 1
+)
+
+func evdev_iow(nr uintptr, size uintptr) uintptr {
+	return (iocWrite << 30) | (size << 16) | ('E' << 8) | nr
+}
+
+var evdevEVIOCSFF = evdev_iow(0x80, unsafe.Sizeof(evdevFFEffect{}))
+var evdevEVIOCRMFF = evdev_iow(0x81, unsafe.Sizeof(int32(0)))
+
+type EvdevHapticsBackend struct {
+	file      *os.File
+	effect_id int16
+}
+
+func new_platform_haptics_backend() (HapticsBackend, error) {
+	devices, _ := filepath.Glob("/sys/class/input/event*/device/capabilities/ff")
+
+	for _, caps_path := range devices {
+		caps, err := os.ReadFile(caps_path)
+		if err != nil || strings.TrimSpace(string(caps)) == "0" {
+			continue
+		}
+
+		event_name := filepath.Base(filepath.Dir(filepath.Dir(filepath.Dir(caps_path))))
+		file, err := os.OpenFile(filepath.Join("/dev/input", event_name), os.O_RDWR, 0)
+		if err != nil {
+			continue
+		}
+
+		return &EvdevHapticsBackend{file, -1}, nil
+	}
+
+	return nil, fmt.Errorf("no force feedback capable input device found")
+}
+
+func (backend *EvdevHapticsBackend) rumble(strong float32, weak float32, duration float32) {
+	effect := evdevFFEffect{
+		kind:             evdevFF_RUMBLE,
+		id:               backend.effect_id,
+		replay:           [2]uint16{uint16(min(duration*1000, 0xffff)), 0},
+		strong_magnitude: uint16(min(max(strong, 0), 1) * 0xffff),
+		weak_magnitude:   uint16(min(max(weak, 0), 1) * 0xffff),
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, backend.file.Fd(), evdevEVIOCSFF, uintptr(unsafe.Pointer(&effect)))
+	if errno != 0 {
+		return
+	}
+	backend.effect_id = effect.id
+
+	backend.write_ff_event(1)
+}
+
+func (backend *EvdevHapticsBackend) stop() {
+	if backend.effect_id < 0 {
+		return
+	}
+	backend.write_ff_event(0)
+}
+
+func (backend *EvdevHapticsBackend) close() {
+	if backend.effect_id >= 0 {
+		syscall.Syscall(syscall.SYS_IOCTL, backend.file.Fd(), evdevEVIOCRMFF, uintptr(backend.effect_id))
+	}
+	backend.file.Close()
+}
+
+func (backend *EvdevHapticsBackend) write_ff_event(value int32) {
+	event := evdevInputEvent{kind: evdevEV_FF, code: uint16(backend.effect_id), value: value}
+	buffer := unsafe.Slice((*byte)(unsafe.Pointer(&event)), unsafe.Sizeof(event))
+	backend.file.Write(buffer)
+}
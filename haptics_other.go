@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+func new_platform_haptics_backend() (HapticsBackend, error) {
+	// GLFW 3.3 has no rumble API, only Linux evdev is supported for now
+	return NullHapticsBackend{}, nil
+}
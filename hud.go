@@ -0,0 +1,141 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Bar following a player resource. The fill eases towards the real value and the part just lost
+// lingers behind it for a moment before draining, so hits read as a chunk being knocked off.
+type ResourceBar struct {
+	widget   *Widget
+	resource func() Resource
+
+	fill_texture uint32
+	// Pulses behind the bar while the resource is at or below low, 0 disables it
+	low float32
+
+	shown       float32
+	trail       float32
+	trail_delay float32
+	pulse       float32
+}
+
+type HUD struct {
+	panel   *Widget
+	health  *ResourceBar
+	stamina *ResourceBar
+
+	boss_panel *Widget
+	boss_name  *Label
+	boss       *ResourceBar
+
+	back_texture  uint32
+	trail_texture uint32
+	pulse_texture uint32
+}
+
+var g_HUD = HUD{}
+
+const hudBarWidth = 200
+const hudBarHeight = 14
+const hudBossBarWidth = 480
+const hudTrailDelay = 0.4
+const hudTrailSpeed = 0.5
+const hudLowPulseRate = 6.0
+
+func init_hud() {
+	g_HUD.back_texture = render.SolidTexture(color.RGBA{20, 20, 30, 180})
+	g_HUD.trail_texture = render.SolidTexture(color.RGBA{240, 220, 200, 255})
+	g_HUD.pulse_texture = render.SolidTexture(color.RGBA{200, 30, 30, 160})
+
+	// Below the daily challenge line in the top left corner
+	g_HUD.panel = add_widget(nil, new_widget(uiTopLeft, uiTopLeft, Vector2DF{16, 32}, Vector2DF{hudBarWidth, hudBarHeight*2 + 6}))
+	g_HUD.panel.update = func(widget *Widget) { widget.hidden = !hud_visible() }
+
+	g_HUD.health = new_resource_bar(func() Resource { return g_Player.health }, color.RGBA{200, 50, 50, 255}, 0.25)
+	add_widget(g_HUD.panel, g_HUD.health.widget)
+
+	g_HUD.stamina = new_resource_bar(func() Resource { return g_Player.stamina }, color.RGBA{60, 170, 80, 255}, 0)
+	g_HUD.stamina.widget.anchor = uiBottomLeft
+	g_HUD.stamina.widget.pivot = uiBottomLeft
+	add_widget(g_HUD.panel, g_HUD.stamina.widget)
+
+	// Bottom center during boss fights, filling up as the fight starts
+	g_HUD.boss_panel = add_widget(nil, new_widget(uiBottom, uiBottom, Vector2DF{0, -48}, Vector2DF{hudBossBarWidth, hudBarHeight + text_line_height(1) + 4}))
+	g_HUD.boss_panel.update = func(widget *Widget) {
+		boss := active_boss()
+		widget.hidden = !hud_visible() || boss == nil
+		if boss != nil {
+			g_HUD.boss_name.text = boss.prefab.Components.Boss.Name
+		}
+	}
+
+	g_HUD.boss_name = new_label("", 1, color.RGBA{240, 230, 220, 255})
+	g_HUD.boss_name.widget.anchor = uiTop
+	g_HUD.boss_name.widget.pivot = uiTop
+	add_widget(g_HUD.boss_panel, g_HUD.boss_name.widget)
+
+	g_HUD.boss = new_resource_bar(boss_health, color.RGBA{170, 60, 200, 255}, 0)
+	g_HUD.boss.widget.anchor = uiBottomLeft
+	g_HUD.boss.widget.pivot = uiBottomLeft
+	add_widget(g_HUD.boss_panel, g_HUD.boss.widget)
+}
+
+func new_resource_bar(resource func() Resource, fill color.RGBA, low float32) *ResourceBar {
+	bar := &ResourceBar{resource: resource, low: low}
+	bar.fill_texture = render.SolidTexture(fill)
+	bar.shown = resource().fraction()
+	bar.trail = bar.shown
+	bar.widget = &Widget{size: Vector2DF{0, hudBarHeight}, stretch: Vector2DF{1, 0}}
+	bar.widget.draw = func(widget *Widget) { draw_resource_bar(bar) }
+	return bar
+}
+
+// Gameplay screens only, menus and results cover the world anyway
+func hud_visible() bool {
+	return !g_StartScreen.open && !g_LevelSelect.open && !g_SettingsMenu.open && !g_ResultsScreen.open
+}
+
+// Real time, so the bars keep animating while the simulation is held
+func step_hud(dt float32) {
+	step_resource_bar(g_HUD.health, dt)
+	step_resource_bar(g_HUD.stamina, dt)
+	step_resource_bar(g_HUD.boss, dt)
+}
+
+func step_resource_bar(bar *ResourceBar, dt float32) {
+	target := bar.resource().fraction()
+	bar.shown += (target - bar.shown) * min(dt*12, 1)
+
+	if bar.shown >= bar.trail {
+		bar.trail = bar.shown
+		bar.trail_delay = hudTrailDelay
+	} else if bar.trail_delay > 0 {
+		bar.trail_delay -= dt
+	} else {
+		bar.trail = max(bar.trail-hudTrailSpeed*dt, bar.shown)
+	}
+
+	if target <= bar.low {
+		bar.pulse += dt
+	} else {
+		bar.pulse = 0
+	}
+}
+
+func draw_resource_bar(bar *ResourceBar) {
+	rect := bar.widget.rect
+
+	if bar.pulse > 0 && g_Settings.reduced_motion {
+		draw_overlay_quad(g_HUD.pulse_texture, rect.x-3, rect.y-3, rect.w+6, rect.h+6)
+	} else if bar.pulse > 0 {
+		grow := 2 + 3*float32(math.Abs(math.Sin(float64(bar.pulse*hudLowPulseRate))))
+		draw_overlay_quad(g_HUD.pulse_texture, rect.x-grow, rect.y-grow, rect.w+grow*2, rect.h+grow*2)
+	}
+	draw_overlay_quad(g_HUD.back_texture, rect.x, rect.y, rect.w, rect.h)
+	draw_overlay_quad(g_HUD.trail_texture, rect.x, rect.y, rect.w*bar.trail, rect.h)
+	draw_overlay_quad(bar.fill_texture, rect.x, rect.y, rect.w*bar.shown, rect.h)
+}
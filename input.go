@@ -0,0 +1,153 @@
+package main
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/input"
+)
+
+type InputContext int32
+
+const (
+	INPUT_CONTEXT_GAMEPLAY = iota
+	INPUT_CONTEXT_UI
+	INPUT_CONTEXT_CONSOLE
+	INPUT_CONTEXT_CHAT
+	INPUT_CONTEXT_EDITOR
+	INPUT_CONTEXT_PHOTO
+)
+
+type Action int32
+
+const (
+	ACTION_MOVE_LEFT = iota
+	ACTION_MOVE_RIGHT
+	ACTION_MOVE_UP
+	ACTION_MOVE_DOWN
+	ACTION_JUMP
+	ACTION_POINTER_PRIMARY
+	ACTION_POINTER_SECONDARY
+	ACTION_COUNT
+)
+
+type MouseState struct {
+	x float32
+	y float32
+	// Wheel steps since the last frame, positive away from the user
+	scroll float32
+}
+
+var g_KeyBindings = map[glfw.Key]Action{
+	glfw.KeyLeft:  ACTION_MOVE_LEFT,
+	glfw.KeyA:     ACTION_MOVE_LEFT,
+	glfw.KeyRight: ACTION_MOVE_RIGHT,
+	glfw.KeyD:     ACTION_MOVE_RIGHT,
+	glfw.KeyUp:    ACTION_MOVE_UP,
+	glfw.KeyDown:  ACTION_MOVE_DOWN,
+	glfw.KeySpace: ACTION_JUMP,
+}
+
+var g_MouseBindings = map[glfw.MouseButton]Action{
+	glfw.MouseButtonLeft:  ACTION_POINTER_PRIMARY,
+	glfw.MouseButtonRight: ACTION_POINTER_SECONDARY,
+}
+
+var g_Actions = input.NewActions[Action](ACTION_COUNT)
+var g_Mouse = MouseState{}
+
+type KeyInputHandler func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey)
+type TextInputHandler func(char rune)
+
+// Only the context on top of the stack receives input, gameplay is always at the bottom
+var g_InputContexts = input.NewContextStack[InputContext](INPUT_CONTEXT_GAMEPLAY)
+
+var g_KeyInputHandlers = map[InputContext][]KeyInputHandler{}
+var g_TextInputHandlers = map[InputContext]TextInputHandler{}
+
+func active_input_context() InputContext {
+	return g_InputContexts.Active()
+}
+
+// Held actions are released when the context changes so nothing keeps moving behind a menu
+func push_input_context(context InputContext) {
+	g_InputContexts.Push(context)
+	g_Actions.Reset()
+}
+
+func pop_input_context(context InputContext) {
+	if g_InputContexts.Pop(context) {
+		g_Actions.Reset()
+	}
+}
+
+func gameplay_input_enabled() bool {
+	return active_input_context() == INPUT_CONTEXT_GAMEPLAY
+}
+
+func add_key_input_handler(context InputContext, handler KeyInputHandler) {
+	g_KeyInputHandlers[context] = append(g_KeyInputHandlers[context], handler)
+}
+
+func set_text_input_handler(context InputContext, handler TextInputHandler) {
+	g_TextInputHandlers[context] = handler
+}
+
+func action_held(action Action) bool {
+	return g_Actions.Held(action)
+}
+
+func action_just_pressed(action Action) bool {
+	return g_Actions.JustPressed(action)
+}
+
+func action_just_released(action Action) bool {
+	return g_Actions.JustReleased(action)
+}
+
+func set_action_state(action Action, pressed bool) {
+	g_Actions.Set(action, pressed)
+}
+
+// Edges only last for a single frame, call before polling the new events
+func begin_input_frame() {
+	g_Actions.BeginFrame()
+	g_Mouse.scroll = 0
+}
+
+func init_input(window *glfw.Window) {
+	window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+		if key == glfw.KeyGraveAccent && action == glfw.Press {
+			toggle_console()
+			return
+		}
+
+		if bound_action, ok := g_KeyBindings[key]; ok && gameplay_input_enabled() && action != glfw.Repeat {
+			set_action_state(bound_action, action == glfw.Press)
+		}
+
+		for _, handler := range g_KeyInputHandlers[active_input_context()] {
+			handler(key, action, mods)
+		}
+	})
+
+	window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		if bound_action, ok := g_MouseBindings[button]; ok {
+			set_action_state(bound_action, action == glfw.Press)
+		}
+	})
+
+	window.SetCursorPosCallback(func(w *glfw.Window, x float64, y float64) {
+		g_Mouse.x = float32(x)
+		g_Mouse.y = float32(y)
+	})
+
+	window.SetScrollCallback(func(w *glfw.Window, x float64, y float64) {
+		g_Mouse.scroll += float32(y)
+	})
+
+	window.SetCharCallback(func(w *glfw.Window, char rune) {
+		if handler, ok := g_TextInputHandlers[active_input_context()]; ok {
+			handler(char)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"container/heap"
+	"runtime"
+	"sync"
+)
+
+// Lower priorities run first, e.g. the distance of a chunk from the camera
+type Job struct {
+	priority float32
+	sequence uint64
+	run      func()
+}
+
+type JobQueue []*Job
+
+func (queue JobQueue) Len() int { return len(queue) }
+func (queue JobQueue) Less(i, j int) bool {
+	if queue[i].priority != queue[j].priority {
+		return queue[i].priority < queue[j].priority
+	}
+	return queue[i].sequence < queue[j].sequence
+}
+func (queue JobQueue) Swap(i, j int) { queue[i], queue[j] = queue[j], queue[i] }
+func (queue *JobQueue) Push(job any) { *queue = append(*queue, job.(*Job)) }
+func (queue *JobQueue) Pop() any {
+	old := *queue
+	job := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return job
+}
+
+// A fixed number of worker goroutines pulling CPU heavy work off the frame loop
+type JobSystem struct {
+	mutex    sync.Mutex
+	wake     *sync.Cond
+	queue    JobQueue
+	sequence uint64
+	workers  int
+	closed   bool
+	running  sync.WaitGroup
+}
+
+var g_Jobs = JobSystem{}
+
+// Result of a job, ready to read once done is closed
+type Future[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func init_jobs() {
+	g_Jobs.wake = sync.NewCond(&g_Jobs.mutex)
+	// Leaves a core for the main thread
+	g_Jobs.workers = max(runtime.NumCPU()-1, 1)
+
+	for i := 0; i < g_Jobs.workers; i++ {
+		g_Jobs.running.Add(1)
+		go job_worker()
+	}
+}
+
+// Queued jobs that haven't started are dropped
+func close_jobs() {
+	g_Jobs.mutex.Lock()
+	g_Jobs.closed = true
+	g_Jobs.queue = nil
+	g_Jobs.mutex.Unlock()
+	if g_Jobs.wake != nil {
+		g_Jobs.wake.Broadcast()
+	}
+	g_Jobs.running.Wait()
+}
+
+func job_worker() {
+	defer g_Jobs.running.Done()
+
+	for {
+		g_Jobs.mutex.Lock()
+		for len(g_Jobs.queue) == 0 && !g_Jobs.closed {
+			g_Jobs.wake.Wait()
+		}
+		if g_Jobs.closed {
+			g_Jobs.mutex.Unlock()
+			return
+		}
+		job := heap.Pop(&g_Jobs.queue).(*Job)
+		g_Jobs.mutex.Unlock()
+
+		job.run()
+	}
+}
+
+// Without workers (headless tools, before init_jobs) the work runs right away on the caller
+func submit_job[T any](priority float32, work func() (T, error)) *Future[T] {
+	future := &Future[T]{done: make(chan struct{})}
+	run := func() {
+		defer close(future.done)
+		future.value, future.err = work()
+	}
+
+	g_Jobs.mutex.Lock()
+	if g_Jobs.workers == 0 || g_Jobs.closed {
+		g_Jobs.mutex.Unlock()
+		run()
+		return future
+	}
+	g_Jobs.sequence++
+	heap.Push(&g_Jobs.queue, &Job{priority: priority, sequence: g_Jobs.sequence, run: run})
+	g_Jobs.mutex.Unlock()
+	g_Jobs.wake.Signal()
+
+	return future
+}
+
+func (future *Future[T]) ready() bool {
+	select {
+	case <-future.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Blocks until the job has run
+func (future *Future[T]) wait() (T, error) {
+	<-future.done
+	return future.value, future.err
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Opened from the start screen, lists the games found on the local network and the
+// sessions of the lobby server
+type JoinMenu struct {
+	open bool
+
+	panel  *Widget
+	menu   *Menu
+	status *Label
+	// One per listed game, the extra ones are hidden
+	entries []*Button
+}
+
+var g_JoinMenu = JoinMenu{}
+
+const joinMenuWidth = 360
+const joinMenuMaxEntries = 8
+
+// A listed game and how to connect to it
+type JoinEntry struct {
+	text string
+	join func()
+}
+
+func init_join_menu() {
+	panel := add_widget(nil, new_widget(uiCenter, uiCenter, Vector2DF{}, Vector2DF{joinMenuWidth, 0}))
+	panel.draw = draw_widget_nine_slice(g_WidgetTheme.panel)
+	panel.update = update_join_menu
+	g_JoinMenu.panel = panel
+
+	title := new_label("Join game", 1, color.RGBA{255, 255, 255, 255})
+	title.widget.offset = Vector2DF{16, 16}
+	add_widget(panel, title.widget)
+
+	menu := new_menu(INPUT_CONTEXT_UI, 6)
+	menu.widget.offset = Vector2DF{16, 16 + text_line_height(1) + 8}
+	menu.on_cancel = close_join_menu
+	add_widget(panel, menu.widget)
+	g_JoinMenu.menu = menu
+
+	width := float32(joinMenuWidth - 32)
+	for i := 0; i < joinMenuMaxEntries; i++ {
+		g_JoinMenu.entries = append(g_JoinMenu.entries, add_menu_button(menu, new_button("", width, func() {
+			// The list may have changed since the last layout
+			if entries := join_menu_entries(); i < len(entries) {
+				entries[i].join()
+				close_join_menu()
+				close_start_screen()
+			}
+		})))
+	}
+	refresh := add_menu_button(menu, new_button("Refresh lobby", width, lobby_list_sessions))
+	refresh.widget.tooltip = "LAN games show up on their own, lobby sessions are fetched from the lobby server"
+	add_menu_button(menu, new_button("Back", width, close_join_menu))
+
+	g_JoinMenu.status = new_label("", 1, color.RGBA{140, 140, 140, 255})
+	add_widget(panel, g_JoinMenu.status.widget)
+}
+
+// LAN games first, they don't need the lobby server to connect
+func join_menu_entries() []JoinEntry {
+	entries := []JoinEntry{}
+	for _, game := range lan_games() {
+		entries = append(entries, JoinEntry{
+			fmt.Sprintf("LAN: %s (%d/%d)", game.name, game.players, game.max_players),
+			func() { lan_join(game) },
+		})
+	}
+	for _, session := range g_Lobby.sessions {
+		entries = append(entries, JoinEntry{
+			fmt.Sprintf("Lobby: %s (%d/%d)", session.Name, session.Players, session.MaxPlayers),
+			func() { lobby_join(session) },
+		})
+	}
+	return entries[:min(len(entries), joinMenuMaxEntries)]
+}
+
+func open_join_menu() {
+	g_StartScreen.open = false
+	g_JoinMenu.open = true
+	g_JoinMenu.menu.focused = 0
+	lobby_list_sessions()
+}
+
+func close_join_menu() {
+	if !g_JoinMenu.open {
+		return
+	}
+	g_JoinMenu.open = false
+	g_StartScreen.open = true
+}
+
+// Follows the discovered games, the panel grows to fit the menu
+func update_join_menu(panel *Widget) {
+	panel.hidden = !g_JoinMenu.open
+
+	entries := join_menu_entries()
+	for i, button := range g_JoinMenu.entries {
+		button.widget.hidden = i >= len(entries)
+		if i < len(entries) {
+			button.text = entries[i].text
+		}
+	}
+
+	g_JoinMenu.status.text = "Network: " + net_status()
+	if len(entries) == 0 {
+		g_JoinMenu.status.text = "No games found"
+	}
+
+	menu_bottom := g_JoinMenu.menu.widget.offset.y + g_JoinMenu.menu.widget.size.y
+	g_JoinMenu.status.widget.offset = Vector2DF{16, menu_bottom + 8}
+	panel.size.y = menu_bottom + 8 + text_line_height(1) + 16
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Keyboard actions the player can rebind, each stored as a bind_<name> setting listing its keys
+var bindableActionNames = map[string]Action{
+	"move_left":  ACTION_MOVE_LEFT,
+	"move_right": ACTION_MOVE_RIGHT,
+	"move_up":    ACTION_MOVE_UP,
+	"move_down":  ACTION_MOVE_DOWN,
+	"jump":       ACTION_JUMP,
+}
+
+var keyNames = map[string]glfw.Key{
+	"space": glfw.KeySpace, "enter": glfw.KeyEnter, "tab": glfw.KeyTab, "backspace": glfw.KeyBackspace,
+	"left": glfw.KeyLeft, "right": glfw.KeyRight, "up": glfw.KeyUp, "down": glfw.KeyDown,
+	"left_shift": glfw.KeyLeftShift, "right_shift": glfw.KeyRightShift,
+	"left_control": glfw.KeyLeftControl, "right_control": glfw.KeyRightControl,
+	"left_alt": glfw.KeyLeftAlt, "right_alt": glfw.KeyRightAlt,
+}
+
+func init() {
+	for key := glfw.KeyA; key <= glfw.KeyZ; key++ {
+		keyNames[string(rune('a'+key-glfw.KeyA))] = key
+	}
+	for key := glfw.Key0; key <= glfw.Key9; key++ {
+		keyNames[string(rune('0'+key-glfw.Key0))] = key
+	}
+}
+
+func key_name(key glfw.Key) string {
+	for name, named := range keyNames {
+		if named == key {
+			return name
+		}
+	}
+	return fmt.Sprint(int(key))
+}
+
+func bindable_action_names() []string {
+	names := []string{}
+	for name := range bindableActionNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keys bound to the action, comma separated in name order so settings files stay stable
+func action_key_list(action Action) string {
+	names := []string{}
+	for key, bound := range g_KeyBindings {
+		if bound == action {
+			names = append(names, key_name(key))
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func parse_key_list(list string) ([]glfw.Key, error) {
+	keys := []glfw.Key{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+		key, ok := keyNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", name)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Replaces the keys of the action, a key taken from another action stops triggering that one
+func bind_action_keys(action Action, keys []glfw.Key) {
+	for key, bound := range g_KeyBindings {
+		if bound == action {
+			delete(g_KeyBindings, key)
+		}
+	}
+	for _, key := range keys {
+		g_KeyBindings[key] = action
+	}
+	g_Actions.Reset()
+}
+
+func default_key_bindings() {
+	for _, name := range bindable_action_names() {
+		g_Config.Default("bind_"+name, action_key_list(bindableActionNames[name]), "keys for "+strings.ReplaceAll(name, "_", " ")+", comma separated")
+	}
+}
+
+func load_key_bindings() {
+	for _, name := range bindable_action_names() {
+		keys, err := parse_key_list(g_Config.String("bind_" + name))
+		if err != nil {
+			log.Printf("config: bind_%s: %v", name, err)
+			continue
+		}
+		bind_action_keys(bindableActionNames[name], keys)
+	}
+}
+
+func init_key_bindings() {
+	register_command("bind", "[action] [keys]: list the bindings, or set an action's comma separated keys", func(args []string) {
+		if len(args) == 0 {
+			for _, name := range bindable_action_names() {
+				console_print("%s = %s", name, action_key_list(bindableActionNames[name]))
+			}
+			return
+		}
+
+		action, ok := bindableActionNames[args[0]]
+		if !ok {
+			console_print("bind: unknown action %q, one of %s", args[0], strings.Join(bindable_action_names(), ", "))
+			return
+		}
+		if len(args) > 1 {
+			keys, err := parse_key_list(strings.Join(args[1:], ","))
+			if err != nil {
+				console_print("bind: %v", err)
+				return
+			}
+			bind_action_keys(action, keys)
+		}
+		console_print("%s = %s", args[0], action_key_list(action))
+	})
+}
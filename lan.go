@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const lanDiscoveryPort = 27962
+const lanBeaconInterval = time.Second
+const lanGameExpiry = 5 * time.Second
+
+var lanBeaconMagic = []byte("SGLAN1")
+
+type LanGame struct {
+	name        string
+	address     string
+	players     int
+	max_players int
+	last_seen   time.Time
+}
+
+type lanBeacon struct {
+	from    *net.UDPAddr
+	payload []byte
+}
+
+// Hosts broadcast beacons on the local network, everyone else listens for them
+type LanDiscovery struct {
+	listener *net.UDPConn
+	beacons  chan lanBeacon
+	games    map[string]*LanGame
+
+	// Set while hosting
+	beacon_conn *net.UDPConn
+	host_name   string
+	host_port   int
+	host_max    int
+	last_beacon time.Time
+}
+
+var g_LanDiscovery = LanDiscovery{games: map[string]*LanGame{}}
+
+func init_lan_discovery() {
+	listener, err := net.ListenUDP("udp4", &net.UDPAddr{Port: lanDiscoveryPort})
+	if err != nil {
+		log.Println("lan discovery unavailable:", err)
+	} else {
+		g_LanDiscovery.listener = listener
+		g_LanDiscovery.beacons = make(chan lanBeacon, 32)
+		go lan_read_loop(listener, g_LanDiscovery.beacons)
+	}
+
+	register_command("lan_list", "list games found on the local network", func() {
+		games := lan_games()
+		for i, game := range games {
+			console_print("%d: %s at %s (%d/%d)", i, game.name, game.address, game.players, game.max_players)
+		}
+		if len(games) == 0 {
+			console_print("lan: no games found")
+		}
+	})
+
+	register_command("lan_join", "join a game by its index in lan_list", func(index int) {
+		games := lan_games()
+		if index < 0 || index >= len(games) {
+			console_print("no game %d, run lan_list first", index)
+			return
+		}
+		lan_join(games[index])
+	})
+}
+
+func lan_join(game *LanGame) {
+	if err := net_connect(game.address, g_Settings.player_name); err != nil {
+		console_print("net: %v", err)
+		return
+	}
+	console_print("net: connecting to %s", game.address)
+}
+
+func lan_read_loop(conn *net.UDPConn, beacons chan<- lanBeacon) {
+	buffer := make([]byte, 512)
+	for {
+		n, from, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		if !bytes.HasPrefix(buffer[:n], lanBeaconMagic) {
+			continue
+		}
+
+		select {
+		case beacons <- lanBeacon{from, bytes.Clone(buffer[len(lanBeaconMagic):n])}:
+		default:
+		}
+	}
+}
+
+func start_lan_beacon(name string, port int, max_players int) {
+	if g_LanDiscovery.beacon_conn == nil {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+		if err != nil {
+			log.Println("lan beacon unavailable:", err)
+			return
+		}
+		g_LanDiscovery.beacon_conn = conn
+	}
+
+	g_LanDiscovery.host_name = name
+	g_LanDiscovery.host_port = port
+	g_LanDiscovery.host_max = max_players
+}
+
+func close_lan_discovery() {
+	if g_LanDiscovery.listener != nil {
+		g_LanDiscovery.listener.Close()
+		g_LanDiscovery.listener = nil
+	}
+	if g_LanDiscovery.beacon_conn != nil {
+		g_LanDiscovery.beacon_conn.Close()
+		g_LanDiscovery.beacon_conn = nil
+	}
+}
+
+// Sends our beacon and collects others', call regularly from the main thread
+func step_lan_discovery(players int) {
+	now := time.Now()
+
+	if g_LanDiscovery.beacon_conn != nil && now.Sub(g_LanDiscovery.last_beacon) >= lanBeaconInterval {
+		g_LanDiscovery.last_beacon = now
+
+		name := g_LanDiscovery.host_name[:min(len(g_LanDiscovery.host_name), 64)]
+		payload := bytes.Clone(lanBeaconMagic)
+		payload = binary.LittleEndian.AppendUint16(payload, uint16(g_LanDiscovery.host_port))
+		payload = append(payload, byte(players), byte(g_LanDiscovery.host_max))
+		payload = append(payload, name...)
+
+		broadcast := &net.UDPAddr{IP: net.IPv4bcast, Port: lanDiscoveryPort}
+		g_LanDiscovery.beacon_conn.WriteToUDP(payload, broadcast)
+	}
+
+	for pending := g_LanDiscovery.beacons != nil; pending; {
+		select {
+		case beacon := <-g_LanDiscovery.beacons:
+			if len(beacon.payload) < 4 {
+				continue
+			}
+			port := int(binary.LittleEndian.Uint16(beacon.payload))
+			address := net.JoinHostPort(beacon.from.IP.String(), strconv.Itoa(port))
+
+			g_LanDiscovery.games[address] = &LanGame{
+				name:        string(beacon.payload[4:]),
+				address:     address,
+				players:     int(beacon.payload[2]),
+				max_players: int(beacon.payload[3]),
+				last_seen:   now,
+			}
+		default:
+			pending = false
+		}
+	}
+
+	for address, game := range g_LanDiscovery.games {
+		if now.Sub(game.last_seen) > lanGameExpiry {
+			delete(g_LanDiscovery.games, address)
+		}
+	}
+}
+
+func lan_games() []*LanGame {
+	games := make([]*LanGame, 0, len(g_LanDiscovery.games))
+	for _, game := range g_LanDiscovery.games {
+		games = append(games, game)
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].address < games[j].address })
+	return games
+}
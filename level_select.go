@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+type LevelSelect struct {
+	open bool
+	// Index into g_Levels and g_LevelPacks, only the selected pack's levels are listed
+	selected int
+	pack     int
+
+	// Keyed by level id, generated the first time the screen is shown
+	thumbnails map[string]uint32
+	// Thumbnails still being generated and rasterized on the job workers
+	pending_thumbnails map[string]*Future[*image.RGBA]
+
+	background_texture uint32
+	highlight_texture  uint32
+	locked_texture     uint32
+}
+
+var g_LevelSelect = LevelSelect{thumbnails: map[string]uint32{}, pending_thumbnails: map[string]*Future[*image.RGBA]{}}
+
+const levelThumbnailWidth = 160
+const levelThumbnailHeight = 48
+const levelSelectRowHeight = 56
+
+func init_level_select() {
+	g_LevelSelect.background_texture = render.SolidTexture(color.RGBA{10, 10, 20, 220})
+	g_LevelSelect.highlight_texture = render.SolidTexture(color.RGBA{60, 60, 90, 255})
+	g_LevelSelect.locked_texture = render.SolidTexture(color.RGBA{0, 0, 0, 180})
+
+	// Catches up on levels finished before their unlock condition changed
+	update_level_unlocks()
+
+	// Warms the texture cache so switching packs doesn't stall on decoding
+	for i := range g_LevelPacks {
+		load_texture_async(pack_tileset(&g_LevelPacks[i]), nil)
+	}
+
+	// Shares the start screen's input context, which is still on the stack while this screen is open
+	add_key_input_handler(INPUT_CONTEXT_UI, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+		if !g_LevelSelect.open || action == glfw.Release {
+			return
+		}
+
+		levels := level_select_visible_levels()
+		row := 0
+		for i, index := range levels {
+			if index == g_LevelSelect.selected {
+				row = i
+			}
+		}
+
+		switch key {
+		case glfw.KeyUp:
+			if row > 0 {
+				g_LevelSelect.selected = levels[row-1]
+			}
+		case glfw.KeyDown:
+			if row+1 < len(levels) {
+				g_LevelSelect.selected = levels[row+1]
+			}
+		case glfw.KeyLeft:
+			select_level_pack(g_LevelSelect.pack - 1)
+		case glfw.KeyRight:
+			select_level_pack(g_LevelSelect.pack + 1)
+		case glfw.KeyEnter:
+			if action == glfw.Press && len(g_Levels) > 0 && level_unlocked(g_LevelSelect.selected) {
+				load_level(g_Levels[g_LevelSelect.selected], nil)
+				close_level_select()
+			}
+		case glfw.KeyEscape:
+			close_level_select()
+		}
+	})
+}
+
+func open_level_select() {
+	g_StartScreen.open = false
+	g_LevelSelect.open = true
+
+	for i, level := range g_Levels {
+		if level.Id == g_Level.Id {
+			g_LevelSelect.selected = i
+		}
+	}
+	// Levels closest to the selection in the list are built first
+	for _, level := range g_Levels {
+		_, done := g_LevelSelect.thumbnails[level.Id]
+		_, pending := g_LevelSelect.pending_thumbnails[level.Id]
+		if !done && !pending {
+			g_LevelSelect.pending_thumbnails[level.Id] = queue_level_thumbnail(level, float32(level_select_distance(level)))
+		}
+	}
+
+	for i, pack := range g_LevelPacks {
+		if len(g_Levels) > 0 && pack.Id == g_Levels[g_LevelSelect.selected].pack {
+			g_LevelSelect.pack = i
+		}
+	}
+}
+
+func select_level_pack(pack int) {
+	if pack < 0 || pack >= len(g_LevelPacks) {
+		return
+	}
+
+	g_LevelSelect.pack = pack
+	if levels := level_select_visible_levels(); len(levels) > 0 {
+		g_LevelSelect.selected = levels[0]
+	}
+}
+
+func level_select_visible_levels() []int {
+	levels := []int{}
+	if len(g_LevelPacks) == 0 {
+		return levels
+	}
+
+	for i, level := range g_Levels {
+		if level.pack == g_LevelPacks[g_LevelSelect.pack].Id {
+			levels = append(levels, i)
+		}
+	}
+	return levels
+}
+
+func close_level_select() {
+	g_LevelSelect.open = false
+	g_StartScreen.open = true
+}
+
+// A level whose map is being generated on a job worker, the current map keeps running until
+// step_level_load swaps it in
+type LevelLoad struct {
+	level  LevelInfo
+	future *Future[Map]
+	// Runs once the level is in place, may be nil
+	done func()
+}
+
+var g_LevelLoad *LevelLoad
+
+// Ahead of every thumbnail, the player is waiting on it
+const levelLoadPriority = -1
+
+// Replaces any load still in progress
+func load_level(level LevelInfo, done func()) {
+	future := submit_job(levelLoadPriority, func() (Map, error) {
+		return generate_map(level, level.Seed), nil
+	})
+	g_LevelLoad = &LevelLoad{level: level, future: future, done: done}
+}
+
+// Call once per frame, before the simulation steps
+func step_level_load() {
+	if g_LevelLoad == nil || !g_LevelLoad.future.ready() {
+		return
+	}
+	load := g_LevelLoad
+	g_LevelLoad = nil
+
+	level_map, _ := load.future.wait()
+	set_level(load.level)
+	play_level_music()
+	install_map(level_map)
+	respawn_player(&g_Player)
+	load_ghost()
+	reset_speedrun()
+	if load.done != nil {
+		load.done()
+	}
+}
+
+// The old map is held still while the new one generates
+func level_loading() bool {
+	return g_LevelLoad != nil
+}
+
+func render_level_load() {
+	if g_LevelLoad == nil {
+		return
+	}
+	text := "Loading " + g_LevelLoad.level.Name + "..."
+	size := ui_size()
+	draw_text(text, (size.x-text_width(text, 1))/2, size.y/2, 1, color.RGBA{255, 255, 255, 255})
+}
+
+// Rows between the level and the selection in the list
+func level_select_distance(level LevelInfo) int {
+	for i := range g_Levels {
+		if g_Levels[i].Id == level.Id {
+			return max(i-g_LevelSelect.selected, g_LevelSelect.selected-i)
+		}
+	}
+	return len(g_Levels)
+}
+
+// Uploads the thumbnails whose job has finished
+func collect_level_thumbnails() {
+	for id, future := range g_LevelSelect.pending_thumbnails {
+		if !future.ready() {
+			continue
+		}
+		delete(g_LevelSelect.pending_thumbnails, id)
+
+		img, _ := future.wait()
+		texture, err := render.TextureFromImage(img)
+		if err == nil {
+			g_LevelSelect.thumbnails[id] = texture
+		}
+	}
+}
+
+// Generates the level's map and rasterizes its blocks fitted to the thumbnail, all on a worker.
+// Most maps generate in well under a millisecond, wave function collapse ones take a few hundred.
+func queue_level_thumbnail(level LevelInfo, priority float32) *Future[*image.RGBA] {
+	return submit_job(priority, func() (*image.RGBA, error) {
+		return rasterize_level_thumbnail(generate_map(level, level.Seed)), nil
+	})
+}
+
+func rasterize_level_thumbnail(level_map Map) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, levelThumbnailWidth, levelThumbnailHeight))
+	sky := color.RGBA{40, 60, 90, 255}
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = sky.R, sky.G, sky.B, sky.A
+	}
+
+	bounds := level_map.bounds
+	size := bounds.size()
+	scale := min(levelThumbnailWidth/size.x, levelThumbnailHeight/size.y)
+
+	to_pixel := func(pos Vector2DF) (int, int) {
+		return int((pos.x - bounds.top_left.x) * scale), int((bounds.top_left.y - pos.y) * scale)
+	}
+	fill := func(bb BoundingBox2D, c color.RGBA) {
+		x_min, y_min := to_pixel(bb.top_left)
+		x_max, y_max := to_pixel(bb.bottom_right)
+		for y := y_min; y <= y_max; y++ {
+			for x := x_min; x <= x_max; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+
+	for _, entity := range level_map.entities {
+		fill(entity.bb, color.RGBA{170, 150, 110, 255})
+	}
+	for _, collectible := range level_map.collectibles {
+		fill(collectible.bb, color.RGBA{255, 210, 80, 255})
+	}
+
+	return img
+}
+
+func render_level_select() {
+	if !g_LevelSelect.open {
+		return
+	}
+
+	collect_level_thumbnails()
+
+	levels := level_select_visible_levels()
+	const width = float32(460)
+	height := float32(len(levels)*levelSelectRowHeight + 76)
+	size := ui_size()
+	x := (size.x - width) / 2
+	y := (size.y - height) / 2
+	white := color.RGBA{255, 255, 255, 255}
+	grey := color.RGBA{140, 140, 140, 255}
+	line_height := text_line_height(1)
+
+	draw_nine_slice(g_WidgetTheme.panel, x, y, width, height)
+	draw_text("Select a level (Enter to load, Esc to go back)", x+16, y+12, 1, white)
+	if len(levels) > 0 {
+		pack_text := g_LevelPacks[g_LevelSelect.pack].Name
+		if len(g_LevelPacks) > 1 {
+			pack_text = fmt.Sprintf("< %s > (%d/%d)", pack_text, g_LevelSelect.pack+1, len(g_LevelPacks))
+		}
+		draw_text(pack_text, x+16, y+12+line_height, 1, color.RGBA{255, 210, 80, 255})
+	}
+
+	for row, i := range levels {
+		level := g_Levels[i]
+		row_y := y + 56 + float32(row*levelSelectRowHeight)
+		if i == g_LevelSelect.selected {
+			draw_overlay_quad(g_LevelSelect.highlight_texture, x+8, row_y-4, width-16, levelSelectRowHeight)
+		}
+
+		thumbnail, ok := g_LevelSelect.thumbnails[level.Id]
+		if !ok {
+			thumbnail = g_LevelSelect.background_texture
+		}
+		draw_overlay_quad(thumbnail, x+16, row_y, levelThumbnailWidth, levelThumbnailHeight)
+
+		text_x := x + 32 + levelThumbnailWidth
+		draw_text(level.Name, text_x, row_y+4, 1, white)
+
+		if !level_unlocked(i) {
+			draw_overlay_quad(g_LevelSelect.locked_texture, x+16, row_y, levelThumbnailWidth, levelThumbnailHeight)
+			draw_text("Locked: "+level_unlock_condition(i), text_x, row_y+4+line_height, 1, grey)
+			continue
+		}
+
+		result, completed := g_SaveData.LevelResults[level.Id]
+		if !completed {
+			draw_text("Not completed", text_x, row_y+4+line_height, 1, grey)
+			continue
+		}
+		draw_text(fmt.Sprintf("%d/3 stars", result.Stars), text_x, row_y+4+line_height, 1, color.RGBA{255, 210, 80, 255})
+		draw_text("Best "+format_speedrun_time(result.BestTime), text_x, row_y+4+line_height*2, 1, grey)
+	}
+}
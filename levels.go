@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type LevelInfo struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Seed int64  `json:"seed"`
+
+	// Finishing at or under par and picking up every collectible each award a star
+	ParTime      float32 `json:"par_time"`
+	Collectibles int     `json:"collectibles"`
+
+	// Besides finishing the previous level, a total number of stars across all levels can be required
+	RequiredStars int `json:"required_stars"`
+
+	Entities []LevelEntity `json:"entities"`
+	Props    []LevelProp   `json:"props"`
+
+	// Color grading LUT, a .cube or strip PNG relative to the pack directory, overrides the pack's
+	Grading string `json:"grading"`
+
+	// How the level's blocks are made, empty for platforms to the right of a starting stretch
+	Generator string `json:"generator"`
+	// Key and door pairs in a generated dungeon, at most dungeonMaxKeys
+	Keys int `json:"keys"`
+	// Map the wfc generator learns from, relative to the assets, empty for levelDefaultSample
+	Sample string `json:"sample"`
+	// Darkens the level until explored, with a minimap of what was
+	Fog bool `json:"fog"`
+	// Overrides the pack's
+	Weather []WeatherPhase `json:"weather"`
+	// Pools as min x, min y, max x, max y
+	Water [][4]float32 `json:"water"`
+
+	pack string
+	// File the level was read from, empty for generated levels
+	path string
+}
+
+type PackMusic struct {
+	Intro string `json:"intro"`
+	Loop  string `json:"loop"`
+}
+
+// A group of levels shipped together, described by the pack.json manifest in the pack's directory
+type LevelPack struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+
+	// Paths are relative to the pack directory, an empty tileset uses the default block texture
+	Tileset string    `json:"tileset"`
+	Music   PackMusic `json:"music"`
+	Grading string    `json:"grading"`
+	// Phases looping while a level of the pack is played, levels can have their own
+	Weather []WeatherPhase `json:"weather"`
+
+	directory string
+}
+
+// Levels of every pack, in pack order, progression runs through them across pack boundaries
+var g_Levels = []LevelInfo{}
+var g_LevelPacks = []LevelPack{}
+var g_Level LevelInfo
+
+const levelDefaultParTime = 45
+const levelDefaultCollectibles = 5
+const levelDefaultTileset = "square.png"
+const levelDefaultSample = "samples/caves.txt"
+
+const levelGeneratorDungeon = "dungeon"
+const levelGeneratorWFC = "wfc"
+
+// Every subdirectory with a pack.json is a pack, packs are listed in directory name order
+func load_level_packs(directory string) {
+	g_Levels = nil
+	g_LevelPacks = nil
+
+	entries, err := g_VFS.ReadDir(directory)
+	if err != nil {
+		log.Println("levels:", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		pack_directory := filepath.Join(directory, entry.Name())
+		data, err := os.ReadFile(asset_path(filepath.Join(pack_directory, "pack.json")))
+		if err != nil {
+			log.Println("levels:", err)
+			continue
+		}
+
+		pack := LevelPack{}
+		if err := json.Unmarshal(data, &pack); err != nil {
+			log.Printf("levels: %s: %v", pack_directory, err)
+			continue
+		}
+		if pack.Id == "" {
+			pack.Id = entry.Name()
+		}
+		if pack.Name == "" {
+			pack.Name = pack.Id
+		}
+		pack.directory = pack_directory
+
+		g_LevelPacks = append(g_LevelPacks, pack)
+		load_levels(pack.Id, filepath.Join(pack_directory, "levels"))
+	}
+}
+
+// Every .json file in the directory describes one level, they are listed in file name order
+func load_levels(pack_id string, directory string) {
+	entries, err := g_VFS.ReadDir(directory)
+	if err != nil {
+		log.Println("levels:", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		level, err := read_level(asset_path(filepath.Join(directory, entry.Name())))
+		if err != nil {
+			log.Println("levels:", err)
+			continue
+		}
+		level.pack = pack_id
+		g_Levels = append(g_Levels, level)
+	}
+}
+
+func read_level(path string) (LevelInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return LevelInfo{}, err
+	}
+
+	level := LevelInfo{ParTime: levelDefaultParTime, Collectibles: levelDefaultCollectibles}
+	if err := json.Unmarshal(data, &level); err != nil {
+		return LevelInfo{}, fmt.Errorf("%s: %w", path, json_error_context(data, err))
+	}
+	if level.Id == "" {
+		level.Id = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+	if level.Name == "" {
+		level.Name = level.Id
+	}
+	level.path = path
+	return level, nil
+}
+
+// Looks the name up as a level id first, then as a level file relative to the game directory
+func find_level(name string) (LevelInfo, error) {
+	for _, level := range g_Levels {
+		if level.Id == name {
+			return level, nil
+		}
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(g_GameDir, path)
+	}
+	return read_level(path)
+}
+
+func level_pack(level LevelInfo) *LevelPack {
+	for i := range g_LevelPacks {
+		if g_LevelPacks[i].Id == level.pack {
+			return &g_LevelPacks[i]
+		}
+	}
+	return nil
+}
+
+func level_tileset() string {
+	return pack_tileset(level_pack(g_Level))
+}
+
+func pack_tileset(pack *LevelPack) string {
+	if pack == nil || pack.Tileset == "" {
+		return levelDefaultTileset
+	}
+	return asset_path(filepath.Join(pack.directory, pack.Tileset))
+}
+
+// Levels outside of a pack keep whatever music is playing
+func play_level_music() {
+	pack := level_pack(g_Level)
+	if pack == nil || pack.Music.Loop == "" {
+		return
+	}
+
+	track := MusicTrack{loop: filepath.Join(pack.directory, pack.Music.Loop)}
+	if pack.Music.Intro != "" {
+		track.intro = filepath.Join(pack.directory, pack.Music.Intro)
+	}
+	play_music(track, defaultMusicCrossfade)
+}
+
+// Metadata for levels that aren't part of a pack, like manually seeded or daily ones
+func custom_level(id string, seed int64) LevelInfo {
+	return LevelInfo{
+		Id:           id,
+		Name:         id,
+		Seed:         seed,
+		ParTime:      levelDefaultParTime,
+		Collectibles: levelDefaultCollectibles,
+	}
+}
+
+func default_level() LevelInfo {
+	if len(g_Levels) == 0 {
+		return custom_level("default", 0)
+	}
+	return g_Levels[0]
+}
+
+// Must be followed by build_map for the change to take effect
+func set_level(level LevelInfo) {
+	g_Level = level
+	g_MapSeed = level.Seed
+}
+
+func total_stars() int {
+	stars := 0
+	for _, result := range g_SaveData.LevelResults {
+		stars += result.Stars
+	}
+	return stars
+}
+
+// The first level is always open, the rest are unlocked once and stay unlocked
+func level_unlocked(index int) bool {
+	return index == 0 || g_SaveData.UnlockedLevels[g_Levels[index].Id]
+}
+
+func level_unlock_condition(index int) string {
+	condition := "Finish " + g_Levels[index-1].Name
+	if g_Levels[index].RequiredStars > 0 {
+		condition += fmt.Sprintf(" and earn %d stars", g_Levels[index].RequiredStars)
+	}
+	return condition
+}
+
+// Returns the names of newly unlocked levels
+func update_level_unlocks() []string {
+	unlocked := []string{}
+
+	for i := 1; i < len(g_Levels); i++ {
+		if level_unlocked(i) {
+			continue
+		}
+		if _, finished := g_SaveData.LevelResults[g_Levels[i-1].Id]; !finished {
+			continue
+		}
+		if total_stars() < g_Levels[i].RequiredStars {
+			continue
+		}
+
+		g_SaveData.UnlockedLevels[g_Levels[i].Id] = true
+		unlocked = append(unlocked, g_Levels[i].Name)
+	}
+
+	return unlocked
+}
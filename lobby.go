@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type LobbySession struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"max_players"`
+}
+
+type lobbyHostRequest struct {
+	Name       string `json:"name"`
+	Port       int    `json:"port"`
+	MaxPlayers int    `json:"max_players"`
+}
+
+type lobbyJoinResponse struct {
+	Address string `json:"address"`
+}
+
+// Talks to the master server over HTTP, requests run in the background and
+// their results are applied on the main thread by step_lobby
+type LobbyClient struct {
+	http *http.Client
+
+	sessions  []LobbySession
+	hosted_id string
+
+	last_heartbeat time.Time
+
+	results chan func()
+}
+
+var g_Lobby = LobbyClient{
+	http:    &http.Client{Timeout: 5 * time.Second},
+	results: make(chan func(), 16),
+}
+
+const lobbyHeartbeatInterval = 30 * time.Second
+const lobbyMaxPlayers = 4
+
+func init_lobby() {
+	register_command("lobby_list", "fetch the sessions listed by the lobby server", func() {
+		lobby_list_sessions()
+	})
+
+	register_command("lobby_host", "[name]: host a session listed on the lobby server", func(args []string) {
+		name := "game"
+		if len(args) > 0 {
+			name = args[0]
+		}
+		lobby_host(name)
+	})
+
+	register_command("lobby_join", "join a session by its index in lobby_list", func(index int) {
+		if index < 0 || index >= len(g_Lobby.sessions) {
+			console_print("no session %d, run lobby_list first", index)
+			return
+		}
+		lobby_join(g_Lobby.sessions[index])
+	})
+
+	register_command("net_status", "show the connection state", func() {
+		console_print("%s", net_status())
+	})
+}
+
+func lobby_request(method string, path string, body any, result any) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	request, err := http.NewRequest(method, g_Settings.lobby_url+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := g_Lobby.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("lobby: %s %s: %s", method, path, response.Status)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func lobby_list_sessions() {
+	go func() {
+		sessions := []LobbySession{}
+		err := lobby_request(http.MethodGet, "/sessions", nil, &sessions)
+
+		g_Lobby.results <- func() {
+			if err != nil {
+				console_print("lobby: %v", err)
+				return
+			}
+
+			g_Lobby.sessions = sessions
+			for i, session := range sessions {
+				console_print("%d: %s (%d/%d)", i, session.Name, session.Players, session.MaxPlayers)
+			}
+			if len(sessions) == 0 {
+				console_print("lobby: no open games")
+			}
+		}
+	}()
+}
+
+func lobby_host(name string) {
+	if err := net_host(netDefaultPort); err != nil {
+		console_print("net: %v", err)
+		return
+	}
+	start_lan_beacon(name, netDefaultPort, lobbyMaxPlayers)
+
+	go func() {
+		session := LobbySession{}
+		err := lobby_request(http.MethodPost, "/sessions", lobbyHostRequest{name, netDefaultPort, lobbyMaxPlayers}, &session)
+
+		g_Lobby.results <- func() {
+			if err != nil {
+				console_print("lobby: %v", err)
+				return
+			}
+			g_Lobby.hosted_id = session.ID
+			g_Lobby.last_heartbeat = time.Now()
+			console_print("lobby: hosting %q", session.Name)
+		}
+	}()
+}
+
+// Asks the master server for the host's address and hands it to the UDP layer
+func lobby_join(session LobbySession) {
+	go func() {
+		joined := lobbyJoinResponse{}
+		err := lobby_request(http.MethodPost, "/sessions/"+session.ID+"/join", nil, &joined)
+
+		g_Lobby.results <- func() {
+			if err != nil {
+				console_print("lobby: %v", err)
+				return
+			}
+			if err := net_connect(joined.Address, g_Settings.player_name); err != nil {
+				console_print("net: %v", err)
+				return
+			}
+			console_print("net: connecting to %s", joined.Address)
+		}
+	}()
+}
+
+func step_lobby() {
+	for pending := true; pending; {
+		select {
+		case result := <-g_Lobby.results:
+			result()
+		default:
+			pending = false
+		}
+	}
+
+	if g_Lobby.hosted_id != "" && time.Since(g_Lobby.last_heartbeat) > lobbyHeartbeatInterval {
+		g_Lobby.last_heartbeat = time.Now()
+
+		id := g_Lobby.hosted_id
+		players := len(g_Net.peers) + 1
+		go lobby_request(http.MethodPost, "/sessions/"+id+"/heartbeat", map[string]int{"players": players}, nil)
+	}
+}
+
+// Removes our hosted session from the master server, blocking since it runs on shutdown
+func close_lobby() {
+	if g_Lobby.hosted_id == "" {
+		return
+	}
+
+	lobby_request(http.MethodDelete, "/sessions/"+g_Lobby.hosted_id, nil, nil)
+	g_Lobby.hosted_id = ""
+}
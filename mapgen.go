@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/noise"
+)
+
+// Seed for the procedurally generated part of the map, every player using the same seed gets the same level
+var g_MapSeed = int64(0)
+
+// Bumped whenever a seed stops giving the same map as before, records kept per seed include it so
+// ghosts and best times of the old map don't show up on the new one. 2 is the terrain noise and biomes.
+const mapGeneratorVersion = 2
+
+// Key of records for the map generated from seed
+func map_seed_key(seed int64) string {
+	return strconv.FormatInt(seed, 10) + "v" + strconv.Itoa(mapGeneratorVersion)
+}
+
+const mapGeneratedPlatforms = 12
+const mapPlatformsPerCheckpoint = 4
+
+// Platform heights follow rolling fractal noise instead of a random walk, so the level has hills
+// and valleys rather than jitter. Frequency is in cycles per world unit.
+const mapTerrainFrequency = 0.04
+const mapTerrainAmplitude = 8.0
+
+var mapTerrainFractal = noise.DefaultFractal(3)
+
+// Height of the terrain at x, snapped to the block grid
+func terrain_height(terrain noise.Source1, x float32, start_y float32) float32 {
+	height := mapTerrainAmplitude * mapTerrainFractal.FBm1(terrain, float64(x)*mapTerrainFrequency)
+	return start_y + 1 + float32(math.Round(height/2))*2
+}
+
+// Appends platforms to the right of start_x, gaps and height changes are kept within jumping range
+func generate_platforms(level_map *Map, level LevelInfo, rng *rand.Rand, start_x float32, start_y float32, tileset string) {
+	x := start_x
+	y := start_y
+	first_block := len(level_map.entities)
+	terrain := noise.NewSimplex(rng.Int63())
+	biomes := new_biome_map(rng)
+	level_map.biomes = biomes
+
+	for i := 0; i < mapGeneratedPlatforms; i++ {
+		biome := biomes.at(Vector2DF{x, y})
+		x += biome.gap_min + float32(rng.Intn(biome.gap_steps))*2
+		// At most two blocks up or down from the previous platform
+		y = min(max(terrain_height(terrain, x, start_y), y-4, start_y-4), y+4, start_y+6)
+
+		if i > 0 && i%mapPlatformsPerCheckpoint == 0 {
+			level_map.checkpoints = append(level_map.checkpoints, x)
+		}
+
+		// The platform takes the biome where it lands, which may differ from where the gap started
+		biome = biomes.at(Vector2DF{x, y})
+		texture := biome.block_texture(tileset)
+		blocks := biome.blocks_min + rng.Intn(biome.blocks_variety)
+		decorated := rng.Intn(blocks)
+		for j := 0; j < blocks; j++ {
+			pos := Vector2DF{x, y}
+			bb := make_bounding_box_2d_centered(pos, Vector2DF{1, 1})
+			level_map.entities = append(level_map.entities, make_map_block(pos, bb, texture))
+			if j == decorated {
+				biome.decorate(level_map, rng, pos)
+			}
+
+			x += 2
+		}
+		x -= 2
+	}
+
+	level_map.goal_x = x
+
+	place_collectibles(level_map, level.Collectibles, rng, first_block)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Vertex layout of meshes: X, Y, Z, U, V, NX, NY, NZ
+const meshVertexFloats = 8
+
+// Indexed triangles in GPU buffers, drawn with a single texture
+type Mesh struct {
+	vao uint32
+	vbo uint32
+	ebo uint32
+
+	index_count int32
+	texture     uint32
+}
+
+// Program the world is drawn with, meshes bind their attributes to it
+var g_WorldProgram = uint32(0)
+
+func new_mesh(vertices []float32, indices []uint32, texture uint32) *Mesh {
+	mesh := &Mesh{index_count: int32(len(indices)), texture: texture}
+
+	gl.GenVertexArrays(1, &mesh.vao)
+	gl.BindVertexArray(mesh.vao)
+
+	gl.GenBuffers(1, &mesh.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, mesh.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, gl.Ptr(vertices), gl.STATIC_DRAW)
+
+	gl.GenBuffers(1, &mesh.ebo)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, mesh.ebo)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*4, gl.Ptr(indices), gl.STATIC_DRAW)
+
+	stride := int32(meshVertexFloats * 4)
+	vert_attrib := uint32(gl.GetAttribLocation(g_WorldProgram, gl.Str("vert\x00")))
+	gl.EnableVertexAttribArray(vert_attrib)
+	gl.VertexAttribPointerWithOffset(vert_attrib, 3, gl.FLOAT, false, stride, 0)
+
+	tex_coord_attrib := uint32(gl.GetAttribLocation(g_WorldProgram, gl.Str("vertTexCoord\x00")))
+	gl.EnableVertexAttribArray(tex_coord_attrib)
+	gl.VertexAttribPointerWithOffset(tex_coord_attrib, 2, gl.FLOAT, false, stride, 3*4)
+
+	// The world shader is unlit for now, normals are only bound once a shader asks for them
+	if normal_attrib := gl.GetAttribLocation(g_WorldProgram, gl.Str("vertNormal\x00")); normal_attrib >= 0 {
+		gl.EnableVertexAttribArray(uint32(normal_attrib))
+		gl.VertexAttribPointerWithOffset(uint32(normal_attrib), 3, gl.FLOAT, false, stride, 5*4)
+	}
+
+	return mesh
+}
+
+func (mesh *Mesh) draw() {
+	mesh.bind(mesh.texture)
+	mesh.draw_elements()
+}
+
+// Binds the vertex array and texture once, draw_elements can then be called per instance
+func (mesh *Mesh) bind(texture uint32) {
+	gl.BindVertexArray(mesh.vao)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+}
+
+func (mesh *Mesh) draw_elements() {
+	gl.DrawElements(gl.TRIANGLES, mesh.index_count, gl.UNSIGNED_INT, nil)
+}
+
+func (mesh *Mesh) destroy() {
+	gl.DeleteBuffers(1, &mesh.vbo)
+	gl.DeleteBuffers(1, &mesh.ebo)
+	gl.DeleteVertexArrays(1, &mesh.vao)
+}
+
+// Merges vertices with the same position, UV and normal, returning the distinct ones and
+// an index per input vertex
+func index_vertices(vertices []float32) ([]float32, []uint32) {
+	unique := []float32{}
+	indices := []uint32{}
+	lookup := map[[meshVertexFloats]float32]uint32{}
+
+	for i := 0; i+meshVertexFloats <= len(vertices); i += meshVertexFloats {
+		key := [meshVertexFloats]float32(vertices[i : i+meshVertexFloats])
+		index, ok := lookup[key]
+		if !ok {
+			index = uint32(len(unique) / meshVertexFloats)
+			lookup[key] = index
+			unique = append(unique, key[:]...)
+		}
+		indices = append(indices, index)
+	}
+	return unique, indices
+}
+
+// Shared by the player, map blocks and every other cube, renderers set the texture before drawing
+var g_CubeMesh *Mesh
+
+func init_cube_mesh() {
+	// cubeVertices is X, Y, Z, U, V per corner of each triangle, normals come from the faces
+	vertices := []float32{}
+	for i := 0; i+15 <= len(cubeVertices); i += 15 {
+		corner := func(j int) mgl32.Vec3 {
+			return mgl32.Vec3{cubeVertices[i+j*5], cubeVertices[i+j*5+1], cubeVertices[i+j*5+2]}
+		}
+		normal := corner(1).Sub(corner(0)).Cross(corner(2).Sub(corner(0))).Normalize()
+		// Winding isn't consistent across faces, normals point away from the center
+		if normal.Dot(corner(0)) < 0 {
+			normal = normal.Mul(-1)
+		}
+		for j := 0; j < 3; j++ {
+			vertices = append(vertices, cubeVertices[i+j*5:i+j*5+5]...)
+			vertices = append(vertices, normal[0], normal[1], normal[2])
+		}
+	}
+
+	unique, indices := index_vertices(vertices)
+	g_CubeMesh = new_mesh(unique, indices, 0)
+}
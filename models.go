@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Static, decorative geometry, props don't collide with anything
+type Model struct {
+	meshes []*Mesh
+}
+
+// Placement of a model in a level file, rotation is in degrees
+type LevelProp struct {
+	Model    string     `json:"model"`
+	X        float32    `json:"x"`
+	Y        float32    `json:"y"`
+	Z        float32    `json:"z"`
+	Scale    float32    `json:"scale"`
+	Rotation [3]float32 `json:"rotation"`
+}
+
+type MapProp struct {
+	model     string
+	transform Transform
+}
+
+// Models by asset path, a nil entry is a model that failed to load and isn't retried
+var g_Models = map[string]*Model{}
+
+// Shared by every untextured material of the same color
+var g_SolidTextures = map[color.RGBA]uint32{}
+
+// Reads the CPU side of a model, the loader is picked by file extension
+func read_model(file string) ([]ModelPrimitive, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".gltf", ".glb":
+		return read_gltf(asset_path(file))
+	case ".obj":
+		return read_obj(asset_path(file))
+	}
+	return nil, fmt.Errorf("%s: unsupported model format", file)
+}
+
+func load_model(file string) *Model {
+	if g_Headless {
+		return nil
+	}
+	if model, ok := g_Models[file]; ok {
+		return model
+	}
+
+	primitives, err := read_model(file)
+	if err != nil {
+		log.Println("models:", err)
+		g_Models[file] = nil
+		return nil
+	}
+
+	model := &Model{}
+	for _, primitive := range primitives {
+		texture := uint32(0)
+		if primitive.base_texture != nil {
+			if texture, err = render.TextureFromImage(tint_image(primitive.base_texture, primitive.base_color)); err != nil {
+				log.Println("models:", file, err)
+			}
+		}
+		if texture == 0 {
+			texture = solid_texture(primitive.base_color)
+		}
+		model.meshes = append(model.meshes, new_mesh(primitive.vertices, primitive.indices, texture))
+	}
+	g_Models[file] = model
+	return model
+}
+
+func solid_texture(c color.RGBA) uint32 {
+	texture, ok := g_SolidTextures[c]
+	if !ok {
+		texture = render.SolidTexture(c)
+		g_SolidTextures[c] = texture
+	}
+	return texture
+}
+
+// The world shader has no tint, so a material's color is multiplied into its texture on load
+func tint_image(img image.Image, tint color.RGBA) image.Image {
+	if tint == (color.RGBA{255, 255, 255, 255}) {
+		return img
+	}
+
+	tinted := image.NewRGBA(img.Bounds())
+	draw.Draw(tinted, tinted.Bounds(), img, img.Bounds().Min, draw.Src)
+	factors := [4]uint32{uint32(tint.R), uint32(tint.G), uint32(tint.B), uint32(tint.A)}
+	for i := range tinted.Pix {
+		// Both are premultiplied, so the product is too
+		tinted.Pix[i] = uint8(uint32(tinted.Pix[i]) * factors[i%4] / 255)
+	}
+	return tinted
+}
+
+func make_map_prop(placement LevelProp) MapProp {
+	transform := make_transform(Vector2DF{placement.X, placement.Y})
+	transform.depth = placement.Z
+	transform.rotation = mgl32.Vec3{
+		mgl32.DegToRad(placement.Rotation[0]),
+		mgl32.DegToRad(placement.Rotation[1]),
+		mgl32.DegToRad(placement.Rotation[2]),
+	}
+	if placement.Scale > 0 {
+		transform.scale = mgl32.Vec3{placement.Scale, placement.Scale, placement.Scale}
+	}
+	return MapProp{model: placement.Model, transform: transform}
+}
+
+// Added to the decorations the generator placed
+func spawn_level_props() {
+	for _, placement := range g_Level.Props {
+		g_Map.props = append(g_Map.props, make_map_prop(placement))
+	}
+}
+
+func render_props(model_uniform_location int32) {
+	visible := camera_visible_bounds()
+	for _, prop := range g_Map.props {
+		// Props are culled by their origin, the margin covers models up to a couple of units wide
+		if !visible.contains(prop.transform.position) {
+			continue
+		}
+		model := load_model(prop.model)
+		if model == nil {
+			continue
+		}
+
+		matrix := prop.transform.matrix()
+		gl.UniformMatrix4fv(model_uniform_location, 1, false, &matrix[0])
+		for _, mesh := range model.meshes {
+			mesh.draw()
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import "log"
+
+type MusicTrack struct {
+	// Optional, played once before the loop section starts
+	intro string
+	loop  string
+}
+
+type MusicPlayer struct {
+	current       *Voice
+	current_track MusicTrack
+}
+
+var g_MusicPlayer = MusicPlayer{}
+
+const defaultMusicCrossfade = float32(1.5)
+
+func init_music() {
+	register_command("music", "[intro] [loop]: play a track, or stop the music without arguments", func(args []string) {
+		switch len(args) {
+		case 0:
+			stop_music(defaultMusicCrossfade)
+		case 1:
+			play_music(MusicTrack{loop: args[0]}, defaultMusicCrossfade)
+		default:
+			play_music(MusicTrack{intro: args[0], loop: args[1]}, defaultMusicCrossfade)
+		}
+	})
+}
+
+// Starts streaming the track, fading out whatever is playing over the crossfade duration
+func play_music(track MusicTrack, crossfade float32) {
+	if track == g_MusicPlayer.current_track && g_MusicPlayer.current != nil {
+		return
+	}
+
+	first := track.loop
+	if track.intro != "" {
+		first = track.intro
+	}
+
+	stream, err := open_audio_stream(asset_path(first))
+	if err != nil {
+		log.Println("music:", err)
+		return
+	}
+
+	voice := new_voice(AUDIO_BUS_MUSIC, stream, 1, track.intro == "")
+	if track.intro != "" {
+		loop, err := open_audio_stream(asset_path(track.loop))
+		if err != nil {
+			log.Println("music:", err)
+			stream.close()
+			return
+		}
+		voice.next = loop
+	}
+
+	g_Mixer.mutex.Lock()
+	if g_MusicPlayer.current != nil {
+		g_MusicPlayer.current.fade(0, crossfade, true)
+	}
+	voice.fade_to = 0
+	voice.fade(1, crossfade, false)
+	g_Mixer.mutex.Unlock()
+
+	mixer_add_voice(voice)
+
+	g_MusicPlayer.current = voice
+	g_MusicPlayer.current_track = track
+}
+
+func stop_music(fade_out float32) {
+	if g_MusicPlayer.current == nil {
+		return
+	}
+
+	g_Mixer.mutex.Lock()
+	g_MusicPlayer.current.fade(0, fade_out, true)
+	g_Mixer.mutex.Unlock()
+
+	g_MusicPlayer.current = nil
+	g_MusicPlayer.current_track = MusicTrack{}
+}
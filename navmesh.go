@@ -0,0 +1,264 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Open space of the map as a few large convex polygons instead of many cells. The nav grid's open
+// cells are merged into rectangles, neighbors are joined through the edge they share (a portal)
+// and paths are pulled tight through the portals, so they cut straight across open areas.
+type NavMesh struct {
+	polygons []NavPolygon
+}
+
+// Axis aligned rectangle, the shape merging grid cells gives
+type NavPolygon struct {
+	bounds BoundingBox2D
+	links  []NavLink
+}
+
+type NavLink struct {
+	polygon int
+	// Ends of the shared edge
+	a, b Vector2DF
+}
+
+// Portal ends are pulled in by this much, so paths round corners instead of grazing them
+const navmeshCornerMargin = 0.5
+
+func map_navmesh() *NavMesh {
+	if g_Map.navmesh == nil {
+		g_Map.navmesh = build_navmesh(map_nav_grid())
+	}
+	return g_Map.navmesh
+}
+
+// Greedily grows each rectangle right, then up, from the lowest leftmost open cell not taken yet
+func build_navmesh(grid *NavGrid) *NavMesh {
+	owner := make([]int, len(grid.blocked))
+	for i := range owner {
+		owner[i] = -1
+	}
+	free := func(x int, y int) bool {
+		return grid.open(NavCell{x, y}) && owner[y*grid.width+x] < 0
+	}
+
+	type cell_rect struct {
+		x, y          int
+		width, height int
+	}
+	mesh := &NavMesh{}
+	rects := []cell_rect{}
+	for y := 0; y < grid.height; y++ {
+		for x := 0; x < grid.width; x++ {
+			if !free(x, y) {
+				continue
+			}
+			rect := cell_rect{x: x, y: y, width: 1, height: 1}
+			for free(rect.x+rect.width, y) {
+				rect.width++
+			}
+			for row_free := true; row_free; {
+				for column := rect.x; column < rect.x+rect.width && row_free; column++ {
+					row_free = free(column, rect.y+rect.height)
+				}
+				if row_free {
+					rect.height++
+				}
+			}
+
+			for cy := rect.y; cy < rect.y+rect.height; cy++ {
+				for cx := rect.x; cx < rect.x+rect.width; cx++ {
+					owner[cy*grid.width+cx] = len(rects)
+				}
+			}
+			rects = append(rects, rect)
+			lower := grid.origin.add(Vector2DF{float32(rect.x), float32(rect.y)}.mul_scalar(navCellSize))
+			upper := lower.add(Vector2DF{float32(rect.width), float32(rect.height)}.mul_scalar(navCellSize))
+			mesh.polygons = append(mesh.polygons, NavPolygon{bounds: make_bounding_box_2d_xy(lower.x, upper.x, lower.y, upper.y)})
+		}
+	}
+
+	// Walks the cells just past the right and top sides, every run of one owner is a shared edge
+	link := func(from int, to int, a Vector2DF, b Vector2DF) {
+		mesh.polygons[from].links = append(mesh.polygons[from].links, NavLink{to, a, b})
+		mesh.polygons[to].links = append(mesh.polygons[to].links, NavLink{from, a, b})
+	}
+	owner_at := func(x int, y int) int {
+		if x < 0 || y < 0 || x >= grid.width || y >= grid.height {
+			return -1
+		}
+		return owner[y*grid.width+x]
+	}
+	for id, rect := range rects {
+		bounds := mesh.polygons[id].bounds
+		for start := rect.y; start < rect.y+rect.height; {
+			neighbor, end := owner_at(rect.x+rect.width, start), start+1
+			for end < rect.y+rect.height && owner_at(rect.x+rect.width, end) == neighbor {
+				end++
+			}
+			if neighbor >= 0 {
+				x := bounds.max_corner().x
+				link(id, neighbor, Vector2DF{x, grid.origin.y + float32(start)*navCellSize}, Vector2DF{x, grid.origin.y + float32(end)*navCellSize})
+			}
+			start = end
+		}
+		for start := rect.x; start < rect.x+rect.width; {
+			neighbor, end := owner_at(start, rect.y+rect.height), start+1
+			for end < rect.x+rect.width && owner_at(end, rect.y+rect.height) == neighbor {
+				end++
+			}
+			if neighbor >= 0 {
+				y := bounds.max_corner().y
+				link(id, neighbor, Vector2DF{grid.origin.x + float32(start)*navCellSize, y}, Vector2DF{grid.origin.x + float32(end)*navCellSize, y})
+			}
+			start = end
+		}
+	}
+	return mesh
+}
+
+// Polygon holding pos, or the one closest to it when it's in a wall, -1 on an empty mesh
+func (mesh *NavMesh) polygon_at(pos Vector2DF) int {
+	closest, closest_distance := -1, float32(math.Inf(1))
+	for i, polygon := range mesh.polygons {
+		if polygon.bounds.contains(pos) {
+			return i
+		}
+		if distance := clamp_to_box(pos, polygon.bounds).distance(pos); distance < closest_distance {
+			closest, closest_distance = i, distance
+		}
+	}
+	return closest
+}
+
+// Closest point of open space, pos itself when it's already open. Points are kept half a cell
+// inside the polygon, on its edge they'd be in the blocked cell next to it as far as the grid goes.
+func (mesh *NavMesh) nearest_point(pos Vector2DF) (Vector2DF, bool) {
+	polygon := mesh.polygon_at(pos)
+	if polygon < 0 {
+		return Vector2DF{}, false
+	}
+	bounds := mesh.polygons[polygon].bounds
+	if bounds.contains(pos) {
+		return pos, true
+	}
+	inset := Vector2DF{navCellSize * 0.5, navCellSize * 0.5}
+	lower, upper := bounds.min_corner().add(inset), bounds.max_corner().subtract(inset)
+	return clamp_to_box(pos, make_bounding_box_2d_xy(lower.x, upper.x, lower.y, upper.y)), true
+}
+
+func clamp_to_box(pos Vector2DF, bb BoundingBox2D) Vector2DF {
+	lower, upper := bb.min_corner(), bb.max_corner()
+	return Vector2DF{min(max(pos.x, lower.x), upper.x), min(max(pos.y, lower.y), upper.y)}
+}
+
+// A* over the polygons, then the funnel through their portals. Returns the points to pass through
+// after from, ending at the open point nearest to to, or nil when it can't be reached.
+func (mesh *NavMesh) find_path(from Vector2DF, to Vector2DF) []Vector2DF {
+	start, goal := mesh.polygon_at(from), mesh.polygon_at(to)
+	if start < 0 || goal < 0 {
+		return nil
+	}
+	from = clamp_to_box(from, mesh.polygons[start].bounds)
+	to = clamp_to_box(to, mesh.polygons[goal].bounds)
+
+	// Costs go through the closest point of each portal, long thin polygons make centers and
+	// midpoints far off the pulled path
+	entry := map[int]Vector2DF{start: from}
+	costs := map[int]float32{start: 0}
+	came_from := map[int]NavLink{}
+	queue := &navQueue{{start, from.distance(to)}}
+
+	found := start == goal
+	for queue.Len() > 0 && !found {
+		polygon := heap.Pop(queue).(navNode).index
+		if polygon == goal {
+			found = true
+			break
+		}
+		for _, link := range mesh.polygons[polygon].links {
+			crossing := clamp_to_box(entry[polygon], make_bounding_box_2d_xy(link.a.x, link.b.x, link.a.y, link.b.y))
+			cost := costs[polygon] + entry[polygon].distance(crossing)
+			if known, ok := costs[link.polygon]; ok && known <= cost {
+				continue
+			}
+			costs[link.polygon] = cost
+			entry[link.polygon] = crossing
+			came_from[link.polygon] = NavLink{polygon, link.a, link.b}
+			heap.Push(queue, navNode{link.polygon, cost + crossing.distance(to)})
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	// Portals from the goal back, each oriented left and right as seen walking towards the goal
+	portals := [][2]Vector2DF{{to, to}}
+	for polygon := goal; polygon != start; {
+		link := came_from[polygon]
+		travel := mesh.polygons[polygon].bounds.center().subtract(mesh.polygons[link.polygon].bounds.center())
+		left, right := link.a, link.b
+		if cross_2d(travel, right.subtract(left)) > 0 {
+			left, right = right, left
+		}
+		margin := min(navmeshCornerMargin, left.distance(right)/2)
+		across := right.subtract(left).normalized().mul_scalar(margin)
+		left, right = left.add(across), right.subtract(across)
+		portals = append(portals, [2]Vector2DF{left, right})
+		polygon = link.polygon
+	}
+	portals = append(portals, [2]Vector2DF{from, from})
+	for i, j := 0, len(portals)-1; i < j; i, j = i+1, j-1 {
+		portals[i], portals[j] = portals[j], portals[i]
+	}
+	return pull_funnel(portals)
+}
+
+func cross_2d(a Vector2DF, b Vector2DF) float32 {
+	return a.x*b.y - a.y*b.x
+}
+
+// The simple stupid funnel algorithm: the path bends only at portal ends, where the funnel of
+// what can be seen through the portals so far closes. Portals hold the left end, then the right.
+func pull_funnel(portals [][2]Vector2DF) []Vector2DF {
+	path := []Vector2DF{}
+	apex, left, right := portals[0][0], portals[0][0], portals[0][1]
+	apex_index, left_index, right_index := 0, 0, 0
+
+	for i := 1; i < len(portals); i++ {
+		next_left, next_right := portals[i][0], portals[i][1]
+
+		// Narrowing from the right, unless it crosses over the left side
+		if cross_2d(right.subtract(apex), next_right.subtract(apex)) >= 0 {
+			if apex == right || cross_2d(left.subtract(apex), next_right.subtract(apex)) < 0 {
+				right, right_index = next_right, i
+			} else {
+				path = append(path, left)
+				apex, apex_index = left, left_index
+				left, right, left_index, right_index = apex, apex, apex_index, apex_index
+				i = apex_index
+				continue
+			}
+		}
+
+		if cross_2d(left.subtract(apex), next_left.subtract(apex)) <= 0 {
+			if apex == left || cross_2d(right.subtract(apex), next_left.subtract(apex)) > 0 {
+				left, left_index = next_left, i
+			} else {
+				path = append(path, right)
+				apex, apex_index = right, right_index
+				left, right, left_index, right_index = apex, apex, apex_index, apex_index
+				i = apex_index
+				continue
+			}
+		}
+	}
+
+	last := portals[len(portals)-1][0]
+	if len(path) == 0 || path[len(path)-1] != last {
+		path = append(path, last)
+	}
+	return path
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+const netDefaultPort = 27960
+const netMaxPacketSize = 1200
+const netProtocolMagic = uint16(0x5347)
+const netHeaderSize = 3 // magic + packet type
+const netConnectResendInterval = 500 * time.Millisecond
+const netConnectTimeout = 10 * time.Second
+
+type NetPacketType uint8
+
+const (
+	PACKET_CONNECT = iota + 1
+	PACKET_ACCEPT
+	PACKET_DISCONNECT
+	PACKET_RELIABLE
+	PACKET_RELIABLE_ACK
+	PACKET_CHAT
+	PACKET_INPUT
+	PACKET_SNAPSHOT
+)
+
+type NetPacket struct {
+	from    *net.UDPAddr
+	kind    NetPacketType
+	payload []byte
+}
+
+type NetPeer struct {
+	addr       *net.UDPAddr
+	name       string
+	last_heard time.Time
+}
+
+type PacketHandler func(packet NetPacket)
+
+// A single UDP socket, either hosting a game for peers or connected to a host
+type NetConnection struct {
+	conn    *net.UDPConn
+	hosting bool
+
+	// Client side, the host we are connected (or connecting) to
+	remote    *net.UDPAddr
+	connected bool
+	local_id  uint16 // Assigned by dedicated servers in PACKET_ACCEPT
+
+	// PACKET_CONNECT is resent until the host accepts, it may be lost like any other packet
+	player_name     string
+	connect_started time.Time
+	last_connect    time.Time
+
+	// Host side, keyed by address string
+	peers map[string]*NetPeer
+
+	incoming chan NetPacket
+	handlers map[NetPacketType]PacketHandler
+}
+
+var g_Net = NetConnection{
+	peers:    map[string]*NetPeer{},
+	handlers: map[NetPacketType]PacketHandler{},
+}
+
+func init_network() {
+	init_reliable()
+
+	set_packet_handler(PACKET_CONNECT, func(packet NetPacket) {
+		if !g_Net.hosting {
+			return
+		}
+
+		key := packet.from.String()
+		if _, ok := g_Net.peers[key]; !ok {
+			log.Printf("net: %s (%s) connected", string(packet.payload), key)
+		}
+		g_Net.peers[key] = &NetPeer{addr: packet.from, name: string(packet.payload), last_heard: time.Now()}
+
+		net_send(packet.from, PACKET_ACCEPT, nil)
+	})
+
+	set_packet_handler(PACKET_ACCEPT, func(packet NetPacket) {
+		if g_Net.hosting || g_Net.connected {
+			return
+		}
+		g_Net.connected = true
+		if len(packet.payload) >= 2 {
+			g_Net.local_id = binary.LittleEndian.Uint16(packet.payload)
+		}
+		log.Printf("net: connected to %s", packet.from)
+	})
+
+	set_packet_handler(PACKET_DISCONNECT, func(packet NetPacket) {
+		forget_reliable_channel(packet.from)
+		if g_Net.hosting {
+			delete(g_Net.peers, packet.from.String())
+			return
+		}
+		g_Net.connected = false
+		log.Printf("net: disconnected by host")
+	})
+}
+
+func set_packet_handler(kind NetPacketType, handler PacketHandler) {
+	g_Net.handlers[kind] = handler
+}
+
+func net_open(local *net.UDPAddr) error {
+	if g_Net.conn != nil {
+		return errors.New("network session already open")
+	}
+
+	conn, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return err
+	}
+
+	g_Net.conn = conn
+	g_Net.incoming = make(chan NetPacket, 256)
+	go net_read_loop(conn, g_Net.incoming)
+
+	return nil
+}
+
+func net_host(port int) error {
+	if err := net_open(&net.UDPAddr{Port: port}); err != nil {
+		return err
+	}
+	g_Net.hosting = true
+
+	log.Printf("net: hosting on port %d", port)
+	return nil
+}
+
+// Starts connecting to a host, the connection is established once it accepts
+func net_connect(address string, player_name string) error {
+	remote, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return err
+	}
+	if err := net_open(&net.UDPAddr{}); err != nil {
+		return err
+	}
+
+	g_Net.remote = remote
+	g_Net.player_name = player_name
+	g_Net.connect_started = time.Now()
+	g_Net.last_connect = g_Net.connect_started
+	net_send(remote, PACKET_CONNECT, []byte(player_name))
+
+	return nil
+}
+
+func net_close() {
+	if g_Net.conn == nil {
+		return
+	}
+
+	if g_Net.hosting {
+		for _, peer := range g_Net.peers {
+			net_send(peer.addr, PACKET_DISCONNECT, nil)
+		}
+	} else if g_Net.remote != nil {
+		net_send(g_Net.remote, PACKET_DISCONNECT, nil)
+	}
+
+	g_Net.conn.Close()
+	g_Net.conn = nil
+	g_Net.hosting = false
+	g_Net.remote = nil
+	g_Net.connected = false
+	g_Net.peers = map[string]*NetPeer{}
+	g_ReliableChannels = map[string]*ReliableChannel{}
+}
+
+func net_send(to *net.UDPAddr, kind NetPacketType, payload []byte) {
+	if g_Net.conn == nil {
+		return
+	}
+
+	if netHeaderSize+len(payload) > netMaxPacketSize {
+		log.Printf("net: dropping oversized packet of type %d (%d bytes)", kind, len(payload))
+		return
+	}
+
+	buffer := make([]byte, netHeaderSize, netHeaderSize+len(payload))
+	binary.LittleEndian.PutUint16(buffer, netProtocolMagic)
+	buffer[2] = byte(kind)
+	buffer = append(buffer, payload...)
+
+	g_Net.conn.WriteToUDP(buffer, to)
+}
+
+func net_read_loop(conn *net.UDPConn, incoming chan<- NetPacket) {
+	buffer := make([]byte, netMaxPacketSize)
+
+	for {
+		n, from, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		if n < netHeaderSize || binary.LittleEndian.Uint16(buffer) != netProtocolMagic {
+			continue
+		}
+
+		payload := make([]byte, n-netHeaderSize)
+		copy(payload, buffer[netHeaderSize:n])
+
+		select {
+		case incoming <- NetPacket{from, NetPacketType(buffer[2]), payload}:
+		default:
+			// Main thread fell behind, UDP is lossy anyway
+		}
+	}
+}
+
+// Dispatches received packets on the main thread, call once per frame
+func net_poll() {
+	if g_Net.conn == nil {
+		return
+	}
+
+	step_connect()
+	if g_Net.conn == nil {
+		return // Gave up connecting
+	}
+
+	for {
+		select {
+		case packet := <-g_Net.incoming:
+			if peer, ok := g_Net.peers[packet.from.String()]; ok {
+				peer.last_heard = time.Now()
+			}
+
+			if handler, ok := g_Net.handlers[packet.kind]; ok {
+				handler(packet)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Resends PACKET_CONNECT until the host accepts, giving up after netConnectTimeout
+func step_connect() {
+	if g_Net.hosting || g_Net.connected || g_Net.remote == nil {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(g_Net.connect_started) >= netConnectTimeout {
+		log.Printf("net: %s didn't answer, giving up", g_Net.remote)
+		net_close()
+		return
+	}
+	if now.Sub(g_Net.last_connect) >= netConnectResendInterval {
+		g_Net.last_connect = now
+		net_send(g_Net.remote, PACKET_CONNECT, []byte(g_Net.player_name))
+	}
+}
+
+func net_status() string {
+	switch {
+	case g_Net.conn == nil:
+		return "offline"
+	case g_Net.hosting:
+		return fmt.Sprintf("hosting, %d peers", len(g_Net.peers))
+	case g_Net.connected:
+		return fmt.Sprintf("connected to %s", g_Net.remote)
+	default:
+		return fmt.Sprintf("connecting to %s", g_Net.remote)
+	}
+}
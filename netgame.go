@@ -0,0 +1,106 @@
+package main
+
+import "encoding/binary"
+
+// Client side of a dedicated server session: sends inputs, applies snapshots
+type NetGame struct {
+	input_sequence uint32
+	last_tick      uint32
+
+	// Decoded snapshots, bases for the server's deltas
+	history SnapshotHistory
+
+	remote_players map[uint16]*Player
+}
+
+var g_NetGame = NetGame{remote_players: map[uint16]*Player{}}
+
+// Local prediction is kept unless it drifts this far from the server
+const netReconcileDistance = float32(1.5)
+
+func init_net_game() {
+	set_packet_handler(PACKET_SNAPSHOT, func(packet NetPacket) {
+		if g_Net.hosting || !g_Net.connected {
+			return
+		}
+
+		snapshot, err := decode_snapshot(packet.payload, &g_NetGame.history)
+		if err != nil {
+			return
+		}
+		apply_snapshot(snapshot)
+	})
+}
+
+func apply_snapshot(snapshot *QuantizedSnapshot) {
+	g_NetGame.history.store(snapshot)
+
+	if snapshot.tick <= g_NetGame.last_tick {
+		return // Out of order, still useful as a delta base
+	}
+	g_NetGame.last_tick = snapshot.tick
+
+	seen := map[uint16]bool{}
+	for _, state := range snapshot.players {
+		pos := state.position()
+		vel := state.velocity()
+
+		if state.id == g_Net.local_id {
+			if pos.subtract(g_Player.pos).length_squared() > netReconcileDistance*netReconcileDistance {
+				g_Player.pos = pos
+				g_Player.vel = vel
+			}
+			continue
+		}
+
+		seen[state.id] = true
+		remote, ok := g_NetGame.remote_players[state.id]
+		if !ok {
+			remote = &Player{texture: g_Player.texture}
+			g_NetGame.remote_players[state.id] = remote
+		}
+
+		remote.pos = pos
+		remote.vel = vel
+		remote.angle_z = dequantize_angle(state.angle)
+		remote.state = PlayerState(state.state)
+	}
+
+	for id := range g_NetGame.remote_players {
+		if !seen[id] {
+			delete(g_NetGame.remote_players, id)
+		}
+	}
+}
+
+func send_net_input() {
+	if g_Net.hosting || !g_Net.connected {
+		return
+	}
+
+	buttons := uint8(0)
+	if gameplay_input_enabled() {
+		if action_held(ACTION_MOVE_LEFT) {
+			buttons |= INPUT_BUTTON_LEFT
+		}
+		if action_held(ACTION_MOVE_RIGHT) {
+			buttons |= INPUT_BUTTON_RIGHT
+		}
+		if action_held(ACTION_JUMP) {
+			buttons |= INPUT_BUTTON_JUMP
+		}
+	}
+
+	g_NetGame.input_sequence++
+	payload := binary.LittleEndian.AppendUint32(nil, g_NetGame.input_sequence)
+	payload = append(payload, buttons)
+	payload = binary.LittleEndian.AppendUint32(payload, g_NetGame.last_tick)
+
+	net_send(g_Net.remote, PACKET_INPUT, payload)
+}
+
+func render_remote_players(model_uniform_location int32) {
+	for _, remote := range g_NetGame.remote_players {
+		render_player(remote, model_uniform_location)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"image/color"
+	"log"
+	"os"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Texture drawn as a 3x3 grid: the corners keep their size, the edges stretch along one axis
+// and the center along both, so borders stay crisp at any size
+type NineSlice struct {
+	texture uint32
+	// In texels
+	width  float32
+	height float32
+	// Left, top, right, bottom, in texels of the texture and pixels on screen
+	margins [4]float32
+}
+
+type SkinEntry struct {
+	Texture string     `json:"texture"`
+	Margins [4]float32 `json:"margins"`
+}
+
+var g_UISkin = map[string]NineSlice{}
+
+const nineSliceVertexCount = 9 * 6
+
+var g_NineSliceVertices = make([]float32, 0, nineSliceVertexCount*5)
+
+// Loads the skin table, entries that fail to load fall back to flat colors where they're used
+func load_ui_skin(table_name string) {
+	data, err := os.ReadFile(asset_path(table_name))
+	if err != nil {
+		log.Println("ui skin:", err)
+		return
+	}
+
+	table := map[string]SkinEntry{}
+	if err := json.Unmarshal(data, &table); err != nil {
+		log.Printf("ui skin: %s: %v", table_name, err)
+		return
+	}
+
+	for name, entry := range table {
+		img, err := render.DecodeImage(texture_path(asset_path(entry.Texture)))
+		if err != nil {
+			log.Printf("ui skin: %s: %v", name, err)
+			continue
+		}
+		texture, err := render.TextureFromImage(img)
+		if err != nil {
+			log.Printf("ui skin: %s: %v", name, err)
+			continue
+		}
+
+		size := img.Bounds().Size()
+		g_UISkin[name] = NineSlice{texture: texture, width: float32(size.X), height: float32(size.Y), margins: entry.Margins}
+	}
+}
+
+// A flat colored slice without margins when the skin has no such entry
+func ui_skin(name string, fallback color.RGBA) NineSlice {
+	if slice, ok := g_UISkin[name]; ok {
+		return slice
+	}
+	return NineSlice{texture: render.SolidTexture(fallback), width: 1, height: 1}
+}
+
+// Three spans along one axis, margins shrink evenly when the quad is smaller than both together
+func nine_slice_spans(size float32, texels float32, low float32, high float32) ([4]float32, [4]float32) {
+	scale := float32(1)
+	if low+high > size {
+		scale = size / (low + high)
+	}
+	positions := [4]float32{0, low * scale, size - high*scale, size}
+	uvs := [4]float32{0, low / texels, 1 - high/texels, 1}
+	return positions, uvs
+}
+
+// Triangles for the quad, in overlay pixels with the X, Y, Z, U, V layout of config_vertex_data
+func nine_slice_vertices(vertices []float32, slice NineSlice, x float32, y float32, w float32, h float32) []float32 {
+	xs, us := nine_slice_spans(w, slice.width, slice.margins[0], slice.margins[2])
+	ys, vs := nine_slice_spans(h, slice.height, slice.margins[1], slice.margins[3])
+
+	for row := 0; row < 3; row++ {
+		for column := 0; column < 3; column++ {
+			x0, x1 := x+xs[column], x+xs[column+1]
+			y0, y1 := y+ys[row], y+ys[row+1]
+			u0, u1 := us[column], us[column+1]
+			v0, v1 := vs[row], vs[row+1]
+			vertices = append(vertices,
+				x0, y0, 0, u0, v0,
+				x0, y1, 0, u0, v1,
+				x1, y0, 0, u1, v0,
+				x1, y0, 0, u1, v0,
+				x0, y1, 0, u0, v1,
+				x1, y1, 0, u1, v1,
+			)
+		}
+	}
+	return vertices
+}
+
+func draw_nine_slice(slice NineSlice, x float32, y float32, w float32, h float32) {
+	g_NineSliceVertices = nine_slice_vertices(g_NineSliceVertices[:0], slice, x, y, w, h)
+
+	model := mgl32.Ident4()
+	gl.UniformMatrix4fv(g_Overlay.model_uniform, 1, false, &model[0])
+
+	gl.BindVertexArray(g_Overlay.nine_slice_vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, g_Overlay.nine_slice_vbo)
+	gl.BufferSubData(gl.ARRAY_BUFFER, 0, len(g_NineSliceVertices)*4, gl.Ptr(g_NineSliceVertices))
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, slice.texture)
+
+	gl.DrawArrays(gl.TRIANGLES, 0, nineSliceVertexCount)
+}
+
+// Draw function stretching a nine-slice over the widget
+func draw_widget_nine_slice(slice NineSlice) func(widget *Widget) {
+	return func(widget *Widget) {
+		draw_nine_slice(slice, widget.rect.x, widget.rect.y, widget.rect.w, widget.rect.h)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Material from an .mtl file, only the diffuse color, dissolve and diffuse map are used
+type ObjMaterial struct {
+	diffuse     [3]float32
+	dissolve    float32
+	diffuse_map string
+}
+
+// Reads a Wavefront .obj and the .mtl libraries it references. Faces are triangulated as fans
+// and every material becomes its own primitive.
+func read_obj(path string) ([]ModelPrimitive, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	directory := filepath.Dir(path)
+	positions := [][3]float32{}
+	uvs := [][2]float32{}
+	normals := [][3]float32{}
+	materials := map[string]ObjMaterial{}
+
+	type objGroup struct {
+		material string
+		vertices []float32
+		indices  []uint32
+		// Index of each distinct position/uv/normal triple already emitted
+		lookup map[[3]int]uint32
+	}
+	groups := []*objGroup{}
+	current := (*objGroup)(nil)
+	use_material := func(name string) {
+		for _, group := range groups {
+			if group.material == name {
+				current = group
+				return
+			}
+		}
+		current = &objGroup{material: name, lookup: map[[3]int]uint32{}}
+		groups = append(groups, current)
+	}
+
+	scanner := bufio.NewScanner(file)
+	line_number := 0
+	for scanner.Scan() {
+		line_number++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("%s:%d: %s", path, line_number, fmt.Sprintf(format, args...))
+		}
+
+		switch fields[0] {
+		case "v", "vn":
+			values, err := parse_floats(fields[1:], 3)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			if fields[0] == "v" {
+				positions = append(positions, [3]float32{values[0], values[1], values[2]})
+			} else {
+				normals = append(normals, [3]float32{values[0], values[1], values[2]})
+			}
+		case "vt":
+			values, err := parse_floats(fields[1:], 2)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			// OBJ puts the V origin at the bottom, textures here are uploaded top row first
+			uvs = append(uvs, [2]float32{values[0], 1 - values[1]})
+		case "mtllib":
+			for _, library := range fields[1:] {
+				if err := read_mtl(filepath.Join(directory, library), materials); err != nil {
+					return nil, fail("%v", err)
+				}
+			}
+		case "usemtl":
+			if len(fields) < 2 {
+				return nil, fail("usemtl without a name")
+			}
+			use_material(fields[1])
+		case "f":
+			if len(fields) < 4 {
+				return nil, fail("faces need at least 3 vertices")
+			}
+			if current == nil {
+				use_material("")
+			}
+			face := []uint32{}
+			for _, corner := range fields[1:] {
+				key, err := parse_obj_corner(corner, len(positions), len(uvs), len(normals))
+				if err != nil {
+					return nil, fail("%v", err)
+				}
+				index, ok := current.lookup[key]
+				if !ok {
+					index = uint32(len(current.vertices) / meshVertexFloats)
+					current.lookup[key] = index
+
+					position, uv, normal := positions[key[0]], [2]float32{}, [3]float32{}
+					if key[1] >= 0 {
+						uv = uvs[key[1]]
+					}
+					if key[2] >= 0 {
+						normal = normals[key[2]]
+					}
+					current.vertices = append(current.vertices, position[0], position[1], position[2], uv[0], uv[1], normal[0], normal[1], normal[2])
+				}
+				face = append(face, index)
+			}
+			for i := 1; i+1 < len(face); i++ {
+				current.indices = append(current.indices, face[0], face[i], face[i+1])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	primitives := []ModelPrimitive{}
+	for _, group := range groups {
+		primitive := ModelPrimitive{vertices: group.vertices, indices: group.indices, base_color: color.RGBA{255, 255, 255, 255}}
+		if material, ok := materials[group.material]; ok {
+			to_byte := func(value float32) uint8 { return uint8(min(max(value*material.dissolve, 0), 1) * 255) }
+			primitive.base_color = color.RGBA{to_byte(material.diffuse[0]), to_byte(material.diffuse[1]), to_byte(material.diffuse[2]), uint8(material.dissolve * 255)}
+			if material.diffuse_map != "" {
+				img, err := render.DecodeImage(material.diffuse_map)
+				if err != nil {
+					return nil, fmt.Errorf("%s: material %s: %w", path, group.material, err)
+				}
+				primitive.base_texture = img
+				// The world shader has no tint, the map is used as is
+				primitive.base_color = color.RGBA{255, 255, 255, 255}
+			}
+		}
+		primitives = append(primitives, primitive)
+	}
+	return primitives, nil
+}
+
+// Resolves a v, v/vt, v//vn or v/vt/vn corner to zero based indices, -1 for missing parts.
+// Negative indices count back from the latest element.
+func parse_obj_corner(corner string, position_count int, uv_count int, normal_count int) ([3]int, error) {
+	key := [3]int{-1, -1, -1}
+	counts := [3]int{position_count, uv_count, normal_count}
+	parts := strings.Split(corner, "/")
+	if len(parts) > 3 || parts[0] == "" {
+		return key, fmt.Errorf("malformed face corner %q", corner)
+	}
+
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		index, err := strconv.Atoi(part)
+		if err != nil {
+			return key, fmt.Errorf("malformed face corner %q", corner)
+		}
+		if index < 0 {
+			index += counts[i]
+		} else {
+			index--
+		}
+		if index < 0 || index >= counts[i] {
+			return key, fmt.Errorf("face corner %q out of range", corner)
+		}
+		key[i] = index
+	}
+	return key, nil
+}
+
+func read_mtl(path string, materials map[string]ObjMaterial) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	name := ""
+	for line_number, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] != "newmtl" && name == "" {
+			continue
+		}
+
+		switch fields[0] {
+		case "newmtl":
+			if len(fields) < 2 {
+				return fmt.Errorf("%s:%d: newmtl without a name", path, line_number+1)
+			}
+			name = fields[1]
+			materials[name] = ObjMaterial{diffuse: [3]float32{1, 1, 1}, dissolve: 1}
+		case "Kd", "d":
+			count := map[string]int{"Kd": 3, "d": 1}[fields[0]]
+			values, err := parse_floats(fields[1:], count)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %v", path, line_number+1, err)
+			}
+			material := materials[name]
+			if fields[0] == "Kd" {
+				material.diffuse = [3]float32{values[0], values[1], values[2]}
+			} else {
+				material.dissolve = min(max(values[0], 0), 1)
+			}
+			materials[name] = material
+		case "map_Kd":
+			if len(fields) < 2 {
+				return fmt.Errorf("%s:%d: map_Kd without a file", path, line_number+1)
+			}
+			material := materials[name]
+			// Options like -s come before the file name, which is always last
+			material.diffuse_map = filepath.Join(filepath.Dir(path), filepath.FromSlash(fields[len(fields)-1]))
+			materials[name] = material
+		}
+	}
+	return nil
+}
+
+// Parses the first count fields, extra ones (like the optional w of a vertex) are ignored
+func parse_floats(fields []string, count int) ([]float32, error) {
+	if len(fields) < count {
+		return nil, fmt.Errorf("expected %d values, got %d", count, len(fields))
+	}
+	values := make([]float32, count)
+	for i := range values {
+		value, err := strconv.ParseFloat(fields[i], 32)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = float32(value)
+	}
+	return values, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"opengl_in_go/basic/config"
+)
+
+var g_Config = config.New()
+
+// Window size and vsync, everything else configurable lives in g_Settings
+var g_WindowWidth = float32(800)
+var g_WindowHeight = float32(600)
+var g_VSync = true
+
+const configEnvPrefix = "SMALLGAME_"
+
+func settings_file_path() string {
+	return filepath.Join(save_directory(), "settings.cfg")
+}
+
+// Layers the settings file, SMALLGAME_* environment variables and the command line over the defaults
+func load_config(args []string) {
+	g_Config.Default("window", "800x600", "window size as WIDTHxHEIGHT")
+	g_Config.Default("vsync", "on", "wait for the display refresh before swapping buffers")
+	g_Config.Default("level", "", "level id or level file to start on")
+	g_Config.Default("seed", "0", "seed for the generated part of the map")
+	g_Config.Default("daily", "off", "play today's daily challenge, the seed is derived from the date")
+	g_Config.Default("server", "off", "run a headless dedicated server")
+	g_Config.Default("port", strconv.Itoa(netDefaultPort), "UDP port the dedicated server listens on")
+	g_Config.Default("name", "dedicated server", "name the dedicated server announces on the LAN")
+	g_Config.Default("spectator_port", "0", "port the dedicated server serves the spectator page on, 0 disables it")
+	g_Config.Default("profile", "", "local profile whose saves and settings are used, empty picks the last one")
+	g_Config.Default("player_name", "", "name shown to other players, empty uses the profile name")
+	g_Config.Default("lobby_url", g_Settings.lobby_url, "address of the lobby server")
+	g_Config.Default("cloud_url", "", "endpoint the save is synced with, empty keeps it on this machine")
+	g_Config.Default("cloud_token", "", "sent as a bearer token to the cloud_url endpoint")
+	g_Config.Default("haptics", "on", "controller rumble")
+	g_Config.Default("touch_controls", "off", "on screen joystick and buttons")
+	g_Config.Default("fullscreen", "off", "fill the monitor, keeping the window resolution")
+	g_Config.Default("monitor", "0", "index of the monitor used in fullscreen, a missing one falls back to the primary")
+	g_Config.Default("pixel_mode", "off", "nearest neighbour filtering for world textures")
+	g_Config.Default("color_grading", "on", "apply the color grading LUTs of levels and packs")
+	g_Config.Default("brightness", "0", "added to every color, from -0.5 up to 0.5")
+	g_Config.Default("contrast", "1", "stretches colors away from middle grey, from 0.5 up to 1.5")
+	g_Config.Default("gamma", "1", "above 1 brightens dark colors, from 0.5 up to 2.5")
+	g_Config.Default("colorblind", "off", "color compensation filter: off, protanopia, deuteranopia or tritanopia")
+	g_Config.Default("high_contrast", "off", "outline hazards and pickups with shapes that don't rely on color")
+	g_Config.Default("text_scale", "1", "size of text in menus, HUD and chat, from 1 up to 2")
+	g_Config.Default("reduced_motion", "off", "disable camera look-ahead, screen shake and pulsing effects")
+	g_Config.Default("reflections", "on", "water mirrors the world above it, draws the world a second time")
+	g_Config.Default("crt", "off", "scanlines, curvature and color fringes like an old monitor")
+	g_Config.Default("ui_scale", "0", "size of menus, HUD and text, 0 picks one from the window height and monitor")
+	g_Config.Default("mods_disabled", "", "comma separated mods in mods/ that aren't mounted")
+	g_Config.Default("hit_stop", strconv.FormatFloat(float64(g_Settings.hit_stop_duration), 'g', -1, 32), "seconds the game freezes on heavy hits, 0 disables it")
+	g_Config.Default("focus_pause", strconv.FormatBool(g_Settings.pause_on_focus_loss), "pause while the window is unfocused, online games keep going")
+	g_Config.Default("focus_mute", strconv.FormatBool(g_Settings.audio_mute_on_focus_loss), "mute while the window is unfocused")
+	g_Config.Default("unfocused_fps", strconv.Itoa(g_Settings.unfocused_frame_rate), "frame rate limit while the window is unfocused, 0 for unlimited")
+	g_Config.Default("camera_stiffness", strconv.FormatFloat(float64(g_Settings.camera_stiffness), 'g', -1, 32), "how quickly the camera catches up with the player")
+	for bus, name := range audioBusNames {
+		g_Config.Default("volume_"+name, strconv.FormatFloat(float64(g_Settings.audio_volumes[bus]), 'g', -1, 32), name+" volume, 0 to 1")
+	}
+	default_key_bindings()
+	g_Config.Default("developer", "off", "enable cheat commands, the save isn't written once one is used")
+	g_Config.Default("debug_window", "off", "open a second window with frame times and an entity inspector")
+	g_Config.Default("grid", "off", "draw a grid on the plane the blocks sit on")
+	g_Config.Default("grid_size", "1", "world units between grid lines, the editor snaps to it")
+	g_Config.Default("bench", "off", "time simulation and rendering of a synthetic scene, print the results and exit")
+	g_Config.Default("bench_blocks", "10000", "blocks in the bench scene")
+	g_Config.Default("bench_entities", "1000", "prefab entities in the bench scene")
+	g_Config.Default("bench_prefab", "floater", "prefab spawned for the bench entities")
+	g_Config.Default("bench_frames", "600", "frames the bench runs for")
+	g_Config.Default("bench_format", "text", "text, or go for go test -bench compatible lines")
+	g_Config.Default("golden", "off", "check renders every golden scene against testdata, update rewrites the stored images")
+	g_Config.Default("golden_dir", filepath.Join("testdata", "golden"), "directory of the golden images, relative to the game")
+	g_Config.Default("golden_tolerance", "8", "per channel difference a pixel may have and still match")
+	g_Config.Default("golden_max_diff", "0.001", "fraction of pixels allowed to differ before a scene fails")
+	g_Config.Default("validate", "off", "check every level and prefab for problems, print them and exit")
+	g_Config.Default("help", "off", "list every setting and exit")
+
+	// Layers win by rank, not load order, so the profile can be picked before its settings file is read
+	g_Config.LoadEnv(configEnvPrefix, os.Environ())
+	if err := g_Config.ParseArgs(args); err != nil {
+		log.Fatalln("config:", err)
+	}
+	select_startup_profile()
+	if err := g_Config.LoadFile(settings_file_path()); err != nil {
+		log.Fatalln("config:", err)
+	}
+
+	if config_bool("help") {
+		fmt.Printf("settings can be given as -key=value, %sKEY=value or in %s\n", configEnvPrefix, settings_file_path())
+		for _, line := range g_Config.Describe() {
+			fmt.Println(line)
+		}
+		os.Exit(0)
+	}
+
+	apply_config_settings()
+
+	register_command("config", "list settings with their values and where they came from", func() {
+		for _, line := range g_Config.Describe() {
+			console_print("%s", line)
+		}
+	})
+}
+
+// Copies the config into the window variables and g_Settings, also run when the profile changes
+func apply_config_settings() {
+	width, height, err := g_Config.Size("window")
+	if err != nil {
+		log.Fatalln("config:", err)
+	}
+	g_WindowWidth, g_WindowHeight = float32(width), float32(height)
+	g_VSync = config_bool("vsync")
+
+	g_Settings.player_name = g_Config.String("player_name")
+	if g_Settings.player_name == "" {
+		g_Settings.player_name = g_Profiles.current
+	}
+	g_Settings.lobby_url = g_Config.String("lobby_url")
+	g_Settings.haptics_enabled = config_bool("haptics")
+	g_Settings.touch_controls_enabled = config_bool("touch_controls")
+	g_Settings.fullscreen = config_bool("fullscreen")
+	g_Settings.monitor = config_int("monitor")
+	g_Settings.pixel_mode = config_bool("pixel_mode")
+	g_Settings.camera_stiffness = max(config_float("camera_stiffness"), 0.1)
+	g_Settings.ui_scale = max(config_float("ui_scale"), 0)
+	g_Settings.crt_enabled = config_bool("crt")
+	g_Settings.reflections_enabled = config_bool("reflections")
+	load_colorblind_mode()
+	g_Settings.high_contrast = config_bool("high_contrast")
+	g_Settings.text_scale = min(max(config_float("text_scale"), 1), 2)
+	g_Settings.reduced_motion = config_bool("reduced_motion")
+	g_Settings.brightness = min(max(config_float("brightness"), -0.5), 0.5)
+	g_Settings.contrast = min(max(config_float("contrast"), 0.5), 1.5)
+	g_Settings.gamma = min(max(config_float("gamma"), 0.5), 2.5)
+	g_Settings.color_grading_enabled = config_bool("color_grading")
+	g_Settings.hit_stop_duration = max(config_float("hit_stop"), 0)
+	g_Settings.pause_on_focus_loss = config_bool("focus_pause")
+	g_Settings.audio_mute_on_focus_loss = config_bool("focus_mute")
+	g_Settings.unfocused_frame_rate = max(config_int("unfocused_fps"), 0)
+	load_disabled_mods()
+	for bus, name := range audioBusNames {
+		set_audio_bus_volume(AudioBus(bus), config_float("volume_"+name))
+	}
+	load_key_bindings()
+}
+
+// Invalid values are fatal at startup rather than silently falling back
+func config_bool(key string) bool {
+	value, err := g_Config.Bool(key)
+	if err != nil {
+		log.Fatalln("config:", err)
+	}
+	return value
+}
+
+func config_int(key string) int {
+	value, err := g_Config.Int(key)
+	if err != nil {
+		log.Fatalln("config:", err)
+	}
+	return value
+}
+
+func config_float(key string) float32 {
+	value, err := g_Config.Float(key)
+	if err != nil {
+		log.Fatalln("config:", err)
+	}
+	return value
+}
+
+func config_int64(key string) int64 {
+	value, err := g_Config.Int64(key)
+	if err != nil {
+		log.Fatalln("config:", err)
+	}
+	return value
+}
+
+// Picks the starting level from the daily, level and seed settings
+func config_level() {
+	if config_bool("daily") {
+		if g_Config.IsSet("seed") || g_Config.IsSet("level") {
+			log.Println("config: seed and level are ignored in the daily challenge")
+		}
+		start_daily_challenge()
+		return
+	}
+
+	level := default_level()
+	if g_Config.IsSet("level") {
+		found, err := find_level(g_Config.String("level"))
+		if err != nil {
+			log.Fatalln("config:", err)
+		}
+		level = found
+	}
+
+	if g_Config.IsSet("seed") {
+		seed := config_int64("seed")
+		if g_Config.IsSet("level") {
+			// Reseeded levels are a different course, keep their results apart
+			level.Seed = seed
+			level.Id = fmt.Sprintf("%s-seed-%d", level.Id, seed)
+		} else {
+			level = custom_level(fmt.Sprintf("seed-%d", seed), seed)
+		}
+	}
+
+	set_level(level)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Screen space quads drawn on top of the world, in pixels with the origin at the top left
+type Overlay struct {
+	quad_vao uint32
+	quad_vbo uint32
+	// Rewritten for every nine-slice drawn
+	nine_slice_vao uint32
+	nine_slice_vbo uint32
+
+	model_uniform int32
+
+	white_texture uint32
+}
+
+var g_Overlay = Overlay{}
+
+var quadVerticesOverlay = []float32{
+	//  X, Y, Z, U, V
+	0.0, 0.0, 0.0, 0.0, 0.0,
+	0.0, 1.0, 0.0, 0.0, 1.0,
+	1.0, 0.0, 0.0, 1.0, 0.0,
+	1.0, 0.0, 0.0, 1.0, 0.0,
+	0.0, 1.0, 0.0, 0.0, 1.0,
+	1.0, 1.0, 0.0, 1.0, 1.0,
+}
+
+func init_overlay(program uint32, model_uniform int32) {
+	gl.GenVertexArrays(1, &g_Overlay.quad_vao)
+	gl.BindVertexArray(g_Overlay.quad_vao)
+
+	gl.GenBuffers(1, &g_Overlay.quad_vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, g_Overlay.quad_vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(quadVerticesOverlay)*4, gl.Ptr(quadVerticesOverlay), gl.STATIC_DRAW)
+
+	config_vertex_data(program)
+
+	gl.GenVertexArrays(1, &g_Overlay.nine_slice_vao)
+	gl.BindVertexArray(g_Overlay.nine_slice_vao)
+
+	gl.GenBuffers(1, &g_Overlay.nine_slice_vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, g_Overlay.nine_slice_vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, nineSliceVertexCount*5*4, nil, gl.DYNAMIC_DRAW)
+
+	config_vertex_data(program)
+
+	g_Overlay.model_uniform = model_uniform
+	g_Overlay.white_texture = render.SolidTexture(color.RGBA{255, 255, 255, 255})
+}
+
+func begin_overlay(projection_uniform int32, camera_uniform int32) {
+	size := ui_size()
+	projection := mgl32.Ortho(0, size.x, size.y, 0, -1, 1)
+	gl.UniformMatrix4fv(projection_uniform, 1, false, &projection[0])
+
+	camera := mgl32.Ident4()
+	gl.UniformMatrix4fv(camera_uniform, 1, false, &camera[0])
+
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	// image.RGBA textures hold premultiplied alpha
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+}
+
+func end_overlay() {
+	gl.Disable(gl.BLEND)
+	gl.Enable(gl.DEPTH_TEST)
+}
+
+func draw_overlay_quad(texture uint32, x float32, y float32, w float32, h float32) {
+	model := mgl32.Translate3D(x, y, 0).Mul4(mgl32.Scale3D(w, h, 1))
+	gl.UniformMatrix4fv(g_Overlay.model_uniform, 1, false, &model[0])
+
+	gl.BindVertexArray(g_Overlay.quad_vao)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+
+	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Palette swapped textures store a palette index in the red channel. The palette strip has one
+// color per column and one variant per row, so one texture gives as many color variants as rows.
+type Palettes struct {
+	enabled_uniform int32
+	variant_uniform int32
+
+	// Indexed textures and strips, kept apart from g_TextureCache so pixel mode never smooths them
+	textures map[string]uint32
+}
+
+var g_Palettes = Palettes{textures: map[string]uint32{}}
+
+// The palette strip is bound here, the source texture stays on unit 0
+const paletteTextureUnit = 1
+
+func init_palettes(program uint32) {
+	g_Palettes.enabled_uniform = gl.GetUniformLocation(program, gl.Str("palette_enabled\x00"))
+	g_Palettes.variant_uniform = gl.GetUniformLocation(program, gl.Str("palette_variant\x00"))
+	gl.Uniform1i(gl.GetUniformLocation(program, gl.Str("palette\x00")), paletteTextureUnit)
+	gl.Uniform1i(g_Palettes.enabled_uniform, 0)
+}
+
+// Indices and palette colors must come through unblended, so these are always sampled nearest
+func load_indexed_texture(file string) (uint32, error) {
+	if g_Headless {
+		return 0, nil
+	}
+	if texture, ok := g_Palettes.textures[file]; ok {
+		return texture, nil
+	}
+
+	texture, err := render.LoadTexture(texture_path(file))
+	if err != nil {
+		return 0, err
+	}
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+
+	g_Palettes.textures[file] = texture
+	return texture, nil
+}
+
+// Draws after this look up their colors in row variant of the palette, until end_palette
+func begin_palette(palette uint32, variant int) {
+	gl.ActiveTexture(gl.TEXTURE0 + paletteTextureUnit)
+	gl.BindTexture(gl.TEXTURE_2D, palette)
+	gl.ActiveTexture(gl.TEXTURE0)
+
+	gl.Uniform1i(g_Palettes.enabled_uniform, 1)
+	gl.Uniform1i(g_Palettes.variant_uniform, int32(variant))
+}
+
+func end_palette() {
+	gl.Uniform1i(g_Palettes.enabled_uniform, 0)
+}
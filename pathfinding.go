@@ -0,0 +1,191 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"time"
+)
+
+// Open space of the map on a grid, for entities finding their way around the blocks. Built on
+// first use and dropped whenever the blocks change.
+type NavGrid struct {
+	origin        Vector2DF
+	width, height int
+	blocked       []bool
+}
+
+type NavCell struct {
+	x, y int
+}
+
+const navCellSize = 1
+
+// Cells this close to a block are blocked too, so paths keep clear of the edges
+const navClearance = 0.25
+
+// Open space above the highest block, for flying entities
+const navHeadroom = 10
+
+// Searches give up after expanding this many cells
+const navMaxSearch = 4000
+
+func init_pathfinding() {
+	register_command("path", "compares grid and navmesh paths from the player to x y", func(x float32, y float32) {
+		to := Vector2DF{x, y}
+		// The grid search gives up on blocked targets, so both get the same open one
+		if open, ok := map_navmesh().nearest_point(to); ok && open != to {
+			console_print("%.1f %.1f is blocked, using the nearest open point %.1f %.1f", to.x, to.y, open.x, open.y)
+			to = open
+		}
+		for _, search := range []struct {
+			name string
+			find func(from Vector2DF, to Vector2DF) []Vector2DF
+		}{{"grid", find_path}, {"navmesh", map_navmesh().find_path}} {
+			start := time.Now()
+			path := search.find(g_Player.pos, to)
+			elapsed := time.Since(start)
+
+			length, from := float32(0), g_Player.pos
+			for _, point := range path {
+				length, from = length+from.distance(point), point
+			}
+			if path == nil {
+				console_print("%s: no path (%v)", search.name, elapsed)
+			} else {
+				console_print("%s: %d points, %.1f long (%v)", search.name, len(path), length, elapsed)
+			}
+		}
+	})
+}
+
+func map_nav_grid() *NavGrid {
+	if g_Map.nav == nil {
+		g_Map.nav = build_nav_grid(g_Map.entities, g_Map.bounds)
+	}
+	return g_Map.nav
+}
+
+func build_nav_grid(blocks []StaticMapEntity, bounds BoundingBox2D) *NavGrid {
+	lower := bounds.min_corner().subtract(Vector2DF{navCellSize, navCellSize})
+	upper := bounds.max_corner().add(Vector2DF{navCellSize, navHeadroom})
+	grid := &NavGrid{
+		origin: lower,
+		width:  int(math.Ceil(float64((upper.x - lower.x) / navCellSize))),
+		height: int(math.Ceil(float64((upper.y - lower.y) / navCellSize))),
+	}
+	grid.blocked = make([]bool, grid.width*grid.height)
+
+	for _, block := range blocks {
+		bb := block.bb.expand(Vector2DF{navClearance, navClearance})
+		from, to := grid.cell(bb.min_corner()), grid.cell(bb.max_corner())
+		for y := max(from.y, 0); y <= min(to.y, grid.height-1); y++ {
+			for x := max(from.x, 0); x <= min(to.x, grid.width-1); x++ {
+				if bb.contains(grid.center(NavCell{x, y})) {
+					grid.blocked[y*grid.width+x] = true
+				}
+			}
+		}
+	}
+	return grid
+}
+
+func (grid *NavGrid) cell(pos Vector2DF) NavCell {
+	offset := pos.subtract(grid.origin).mul_scalar(1.0 / navCellSize)
+	return NavCell{int(math.Floor(float64(offset.x))), int(math.Floor(float64(offset.y)))}
+}
+
+func (grid *NavGrid) center(cell NavCell) Vector2DF {
+	return grid.origin.add(Vector2DF{(float32(cell.x) + 0.5) * navCellSize, (float32(cell.y) + 0.5) * navCellSize})
+}
+
+func (grid *NavGrid) index(cell NavCell) int {
+	return cell.y*grid.width + cell.x
+}
+
+func (grid *NavGrid) cell_at(index int) NavCell {
+	return NavCell{index % grid.width, index / grid.width}
+}
+
+// Cells outside of the grid count as blocked
+func (grid *NavGrid) open(cell NavCell) bool {
+	if cell.x < 0 || cell.y < 0 || cell.x >= grid.width || cell.y >= grid.height {
+		return false
+	}
+	return !grid.blocked[cell.y*grid.width+cell.x]
+}
+
+// Cell index for grids, polygon index for meshes
+type navNode struct {
+	index    int
+	priority float32
+}
+
+type navQueue []navNode
+
+func (queue navQueue) Len() int           { return len(queue) }
+func (queue navQueue) Less(i, j int) bool { return queue[i].priority < queue[j].priority }
+func (queue navQueue) Swap(i, j int)      { queue[i], queue[j] = queue[j], queue[i] }
+func (queue *navQueue) Push(node any)     { *queue = append(*queue, node.(navNode)) }
+func (queue *navQueue) Pop() any {
+	old := *queue
+	node := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return node
+}
+
+// Octile distance, the exact cost between two cells with nothing in the way
+func nav_heuristic(a NavCell, b NavCell) float32 {
+	dx, dy := math.Abs(float64(a.x-b.x)), math.Abs(float64(a.y-b.y))
+	return float32(max(dx, dy) + (math.Sqrt2-1)*min(dx, dy))
+}
+
+// A* through the open cells, moving diagonally only when both sides are open too. Returns the
+// points to pass through after from, ending at to, or nil when to can't be reached.
+func find_path(from Vector2DF, to Vector2DF) []Vector2DF {
+	grid := map_nav_grid()
+	start, goal := grid.cell(from), grid.cell(to)
+	if !grid.open(start) || !grid.open(goal) {
+		return nil
+	}
+	if start == goal {
+		return []Vector2DF{to}
+	}
+
+	costs := map[NavCell]float32{start: 0}
+	came_from := map[NavCell]NavCell{}
+	queue := &navQueue{{grid.index(start), nav_heuristic(start, goal)}}
+
+	for expanded := 0; queue.Len() > 0 && expanded < navMaxSearch; expanded++ {
+		cell := grid.cell_at(heap.Pop(queue).(navNode).index)
+		if cell == goal {
+			path := []Vector2DF{to}
+			for cell = came_from[cell]; cell != start; cell = came_from[cell] {
+				path = append(path, grid.center(cell))
+			}
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path
+		}
+
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				next := NavCell{cell.x + dx, cell.y + dy}
+				if next == cell || !grid.open(next) {
+					continue
+				}
+				if dx != 0 && dy != 0 && !(grid.open(NavCell{cell.x + dx, cell.y}) && grid.open(NavCell{cell.x, cell.y + dy})) {
+					continue
+				}
+				cost := costs[cell] + nav_heuristic(cell, next)
+				if known, ok := costs[next]; ok && known <= cost {
+					continue
+				}
+				costs[next] = cost
+				came_from[next] = cell
+				heap.Push(queue, navNode{grid.index(next), cost + nav_heuristic(next, goal)})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import "math"
+
+// Lets an enemy notice the player by seeing them inside its vision cone or hearing the noises they
+// make. Noticing builds up its alertness from 0 (unaware) to 1 (fully alert), which decays again
+// once nothing is noticed. Behavior tree actions act on it.
+type PerceptionComponent struct {
+	// Vision cone around the way the entity last moved, angle in degrees from edge to edge
+	SightRange float32 `json:"sight_range"`
+	SightAngle float32 `json:"sight_angle"`
+	// Multiplies how far noises carry, 0 is deaf
+	Hearing float32 `json:"hearing"`
+
+	// Alertness gained per second of seeing the player, and per noise heard
+	SeeRate   float32 `json:"see_rate"`
+	HearAlert float32 `json:"hear_alert"`
+	// Alertness lost per second of noticing nothing
+	Decay float32 `json:"decay"`
+}
+
+type PerceptionState struct {
+	alertness float32
+	// Where the player was last seen or heard
+	clue   Vector2DF
+	facing Vector2DF
+	last   Vector2DF
+}
+
+// Made by sounds with a loudness, heard up to loudness away
+type Noise struct {
+	pos      Vector2DF
+	loudness float32
+}
+
+// Noises of the last step, heard during the next one
+var g_Noises = []Noise{}
+
+func new_perception_state(pos Vector2DF) *PerceptionState {
+	// Players come in from the left, so entities start out facing that way
+	return &PerceptionState{facing: Vector2DF{-1, 0}, last: pos}
+}
+
+func make_noise(pos Vector2DF, loudness float32) {
+	g_Noises = append(g_Noises, Noise{pos, loudness})
+}
+
+func perceive(entity *DynamicEntity, perception *PerceptionComponent, dt float32) {
+	state := entity.perception
+	if moved := entity.pos.subtract(state.last); moved.length_squared() > 0 {
+		state.facing = moved.normalized()
+	}
+	state.last = entity.pos
+
+	noticed := false
+	if sees_in_cone(entity.pos, state.facing, perception) {
+		state.alertness += perception.SeeRate * dt
+		state.clue = nearest_player(entity.pos).pos
+		noticed = true
+	} else {
+		for _, noise := range g_Noises {
+			if entity.pos.distance(noise.pos) <= noise.loudness*perception.Hearing {
+				state.alertness += perception.HearAlert
+				state.clue = noise.pos
+				noticed = true
+			}
+		}
+	}
+	if !noticed {
+		state.alertness -= perception.Decay * dt
+	}
+	state.alertness = min(max(state.alertness, 0), 1)
+}
+
+func sees_in_cone(pos Vector2DF, facing Vector2DF, perception *PerceptionComponent) bool {
+	target := nearest_player(pos).pos
+	to_player := target.subtract(pos)
+	distance := to_player.length()
+	if distance > perception.SightRange {
+		return false
+	}
+	if distance > 0 && facing.dot(to_player.mul_scalar(1/distance)) < float32(math.Cos(float64(perception.SightAngle)*math.Pi/360)) {
+		return false
+	}
+	return line_of_sight(pos, target)
+}
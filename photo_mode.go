@@ -0,0 +1,134 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Pauses the simulation and hands the camera to the player for framing a shot. WASD or a mouse
+// drag moves it, the wheel zooms, Q and E roll. The HUD is hidden and captures go through the
+// screenshot system without the hint line.
+type PhotoMode struct {
+	enabled bool
+	window  *glfw.Window
+
+	// Restored on leaving, so gameplay picks up where it was
+	saved_pos Vector2DF
+	saved_z   float32
+
+	dragging     bool
+	drag_pointer Vector2DF
+}
+
+var g_PhotoMode = PhotoMode{}
+
+const photoModeKey = glfw.KeyP
+const photoModeCaptureKey = glfw.KeyEnter
+
+// Pan speed in visible heights per second, so it feels the same at any zoom
+const photoModePanSpeed = 0.8
+const photoModeRollSpeed = 1.0
+const photoModeZoomStep = 1.15
+const photoModeMinZ = 5.0
+const photoModeMaxZ = 150.0
+
+func init_photo_mode(window *glfw.Window) {
+	g_PhotoMode.window = window
+
+	register_command("photo_mode", "pause and frame a screenshot with a free camera", func() {
+		set_photo_mode_enabled(!g_PhotoMode.enabled)
+	})
+
+	add_key_input_handler(INPUT_CONTEXT_GAMEPLAY, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+		if key == photoModeKey && action == glfw.Press {
+			set_photo_mode_enabled(true)
+		}
+	})
+	add_key_input_handler(INPUT_CONTEXT_PHOTO, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+		if action != glfw.Press {
+			return
+		}
+		switch key {
+		case photoModeKey, glfw.KeyEscape:
+			set_photo_mode_enabled(false)
+		case photoModeCaptureKey, screenshotKey:
+			request_screenshot()
+		case glfw.KeyR:
+			g_Camera.roll = 0
+		}
+	})
+}
+
+func set_photo_mode_enabled(enabled bool) {
+	if enabled == g_PhotoMode.enabled {
+		return
+	}
+	g_PhotoMode.enabled = enabled
+	g_PhotoMode.dragging = false
+
+	if enabled {
+		g_PhotoMode.saved_pos, g_PhotoMode.saved_z = g_Camera.pos2D, g_Camera.z_value
+		push_input_context(INPUT_CONTEXT_PHOTO)
+		return
+	}
+	g_Camera.pos2D, g_Camera.z_value = g_PhotoMode.saved_pos, g_PhotoMode.saved_z
+	g_Camera.previous_pos2D, g_Camera.previous_z_value = g_Camera.pos2D, g_Camera.z_value
+	g_Camera.roll = 0
+	pop_input_context(INPUT_CONTEXT_PHOTO)
+}
+
+func photo_mode_active() bool {
+	return g_PhotoMode.enabled
+}
+
+func step_photo_mode(dt float32) {
+	if !g_PhotoMode.enabled || active_input_context() != INPUT_CONTEXT_PHOTO {
+		return
+	}
+
+	held := func(key glfw.Key) float32 {
+		if g_PhotoMode.window.GetKey(key) == glfw.Press {
+			return 1
+		}
+		return 0
+	}
+
+	// Movement follows the rolled screen axes
+	input := Vector2DF{held(glfw.KeyD) - held(glfw.KeyA), held(glfw.KeyW) - held(glfw.KeyS)}
+	speed := camera_visible_half_extents().y * 2 * photoModePanSpeed
+	g_Camera.pos2D = g_Camera.pos2D.add(input.rotate(g_Camera.roll).mul_scalar(speed * dt))
+
+	g_Camera.roll += (held(glfw.KeyQ) - held(glfw.KeyE)) * photoModeRollSpeed * dt
+	g_Camera.roll = float32(math.Remainder(float64(g_Camera.roll), 2*math.Pi))
+
+	if g_Mouse.scroll != 0 {
+		zoom := float32(math.Pow(photoModeZoomStep, float64(-g_Mouse.scroll)))
+		g_Camera.z_value = min(max(g_Camera.z_value*zoom, photoModeMinZ), photoModeMaxZ)
+	}
+
+	// The point grabbed stays under the cursor
+	pointer := screen_to_world(Vector2DF{g_Mouse.x, g_Mouse.y})
+	if action_just_pressed(ACTION_POINTER_PRIMARY) {
+		g_PhotoMode.dragging = true
+		g_PhotoMode.drag_pointer = pointer
+	}
+	if !action_held(ACTION_POINTER_PRIMARY) {
+		g_PhotoMode.dragging = false
+	}
+	if g_PhotoMode.dragging {
+		g_Camera.pos2D = g_Camera.pos2D.add(g_PhotoMode.drag_pointer.subtract(pointer))
+	}
+
+	g_Camera.previous_pos2D, g_Camera.previous_z_value = g_Camera.pos2D, g_Camera.z_value
+}
+
+// Left out of captures
+func render_photo_mode() {
+	if !g_PhotoMode.enabled || screenshot_pending() {
+		return
+	}
+	line := "Photo mode: WASD/drag move, wheel zoom, Q/E roll, R level, Enter capture, P exit"
+	draw_text(line, (ui_size().x-text_width(line, 1))/2, ui_size().y-text_line_height(1)-8, 1, color.RGBA{255, 255, 255, 255})
+}
@@ -0,0 +1,34 @@
+// Package pluginapi is the boundary between the game and plugins built with
+// go build -buildmode=plugin. A plugin exports a function
+//
+//	func Register(host pluginapi.Host)
+//
+// which the game calls once at startup. Plugins must be built with the same
+// Go toolchain and the same version of this package as the game.
+package pluginapi
+
+// Entities spawned from plugin entity types, the game renders them as blocks at their position
+type Entity interface {
+	Step(dt float32)
+	Position() (x float32, y float32)
+}
+
+type EntityFactory func(x float32, y float32) Entity
+
+// Services the game exposes to plugins
+type Host interface {
+	// Adds a console command, replacing any existing command with the same name
+	RegisterCommand(name string, handler func(args []string))
+	// Adds a system stepped once per frame after the built-in simulation
+	RegisterSystem(name string, step func(dt float32))
+	// Makes the entity type spawnable with the spawn console command
+	RegisterEntityType(name string, factory EntityFactory)
+	// Called after a level's entities are spawned, plugin entities of the previous level are gone by then
+	RegisterLevelStart(handler func())
+
+	SpawnEntity(type_name string, x float32, y float32) bool
+	PlayerPosition() (x float32, y float32)
+	Print(format string, args ...any)
+}
+
+const RegisterSymbol = "Register"
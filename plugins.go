@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+
+	"opengl_in_go/basic/pluginapi"
+)
+
+type PluginSystem struct {
+	name string
+	step func(dt float32)
+}
+
+type Plugins struct {
+	systems      []PluginSystem
+	entity_types map[string]pluginapi.EntityFactory
+	entities     []pluginapi.Entity
+	level_start  []func()
+}
+
+var g_Plugins = Plugins{entity_types: map[string]pluginapi.EntityFactory{}}
+
+const pluginsDirectory = "plugins"
+
+// Implements pluginapi.Host on top of the game's globals
+type PluginHost struct {
+	plugin string
+}
+
+func (host PluginHost) RegisterCommand(name string, handler func(args []string)) {
+	register_command(name, "[args...]: registered by plugin "+host.plugin, handler)
+}
+
+func (host PluginHost) RegisterSystem(name string, step func(dt float32)) {
+	g_Plugins.systems = append(g_Plugins.systems, PluginSystem{host.plugin + "/" + name, step})
+}
+
+func (host PluginHost) RegisterEntityType(name string, factory pluginapi.EntityFactory) {
+	g_Plugins.entity_types[name] = factory
+}
+
+func (host PluginHost) RegisterLevelStart(handler func()) {
+	g_Plugins.level_start = append(g_Plugins.level_start, handler)
+}
+
+func (host PluginHost) SpawnEntity(type_name string, x float32, y float32) bool {
+	return spawn_plugin_entity(type_name, x, y)
+}
+
+func (host PluginHost) PlayerPosition() (float32, float32) {
+	return g_Player.pos.x, g_Player.pos.y
+}
+
+func (host PluginHost) Print(format string, args ...any) {
+	console_print("[%s] %s", host.plugin, fmt.Sprintf(format, args...))
+}
+
+// Loads every plugin in plugins/, a plugin failing to load is skipped
+func init_plugins() {
+	paths, _ := filepath.Glob(filepath.Join(g_GameDir, pluginsDirectory, "*.so"))
+	for _, path := range paths {
+		name := filepath.Base(path)
+		register, err := open_plugin(path)
+		if err != nil {
+			log.Printf("plugins: %s: %v", name, err)
+			continue
+		}
+
+		register(PluginHost{plugin: name})
+		log.Printf("plugins: loaded %s", name)
+	}
+
+	register_command("spawn", "spawn a prefab or plugin entity at x y", func(type_name string, x float32, y float32) {
+		// Data driven prefabs take precedence over plugin entity types
+		if !spawn_prefab(type_name, Vector2DF{x, y}) && !spawn_plugin_entity(type_name, x, y) {
+			console_print("unknown entity type %q", type_name)
+		}
+	})
+}
+
+func spawn_plugin_entity(type_name string, x float32, y float32) bool {
+	factory, ok := g_Plugins.entity_types[type_name]
+	if !ok {
+		return false
+	}
+	g_Plugins.entities = append(g_Plugins.entities, factory(x, y))
+	return true
+}
+
+// Plugin entities belong to the level like dynamic entities, they are cleared along with them
+func reset_plugin_entities() {
+	g_Plugins.entities = nil
+}
+
+func start_plugin_level() {
+	for _, handler := range g_Plugins.level_start {
+		handler()
+	}
+}
+
+func step_plugins(dt float32) {
+	for _, system := range g_Plugins.systems {
+		system.step(dt)
+	}
+	for _, entity := range g_Plugins.entities {
+		entity.Step(dt)
+	}
+}
+
+func render_plugin_entities(model_uniform_location int32) {
+	texture, err := load_texture(levelDefaultTileset)
+	if err != nil {
+		return
+	}
+
+	g_CubeMesh.bind(texture)
+
+	for _, entity := range g_Plugins.entities {
+		x, y := entity.Position()
+		model := make_transform(Vector2DF{x, y}).matrix()
+		gl.UniformMatrix4fv(model_uniform_location, 1, false, &model[0])
+
+		g_CubeMesh.draw_elements()
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import (
+	"errors"
+
+	"opengl_in_go/basic/pluginapi"
+)
+
+func open_plugin(path string) (func(pluginapi.Host), error) {
+	// The plugin package only supports Linux, macOS and FreeBSD
+	return nil, errors.New("plugins aren't supported on this platform")
+}
@@ -0,0 +1,28 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"fmt"
+	"plugin"
+
+	"opengl_in_go/basic/pluginapi"
+)
+
+func open_plugin(path string) (func(pluginapi.Host), error) {
+	opened, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol, err := opened.Lookup(pluginapi.RegisterSymbol)
+	if err != nil {
+		return nil, err
+	}
+
+	register, ok := symbol.(func(pluginapi.Host))
+	if !ok {
+		return nil, fmt.Errorf("%s has type %T, expected func(pluginapi.Host)", pluginapi.RegisterSymbol, symbol)
+	}
+	return register, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Short lived text hanging off a point in the world, like damage taken or a pickup
+type Popup struct {
+	text  string
+	pos   Vector2DF
+	color color.RGBA
+	age   float32
+}
+
+var g_Popups []Popup
+
+const popupLifetime = 1.0
+const popupFadeTime = 0.4
+
+// World units per second
+const popupRiseSpeed = 1.5
+const popupMaxCount = 32
+
+func init_popups() {
+	subscribe_event(EVENT_PLAYER_DAMAGED, func(event GameEvent) {
+		spawn_popup(fmt.Sprintf("-%.0f", event.magnitude), event.pos.add(Vector2DF{0, 1}), color.RGBA{230, 60, 60, 255})
+	})
+	subscribe_event(EVENT_COLLECTIBLE_PICKED, func(event GameEvent) {
+		spawn_popup("+1", event.pos.add(Vector2DF{0, 0.5}), color.RGBA{255, 210, 80, 255})
+	})
+	subscribe_event(EVENT_WAVE_STARTED, func(event GameEvent) {
+		spawn_popup(fmt.Sprintf("Wave %.0f", event.magnitude), event.pos.add(Vector2DF{0, 2}), color.RGBA{230, 120, 60, 255})
+	})
+	subscribe_event(EVENT_WAVE_CLEARED, func(event GameEvent) {
+		spawn_popup(fmt.Sprintf("Wave %.0f cleared", event.magnitude), event.pos.add(Vector2DF{0, 2}), color.RGBA{120, 220, 120, 255})
+	})
+}
+
+func spawn_popup(text string, pos Vector2DF, c color.RGBA) {
+	// Drops the oldest one rather than growing without bound when many land at once
+	if len(g_Popups) >= popupMaxCount {
+		g_Popups = g_Popups[1:]
+	}
+	g_Popups = append(g_Popups, Popup{text: text, pos: pos, color: c})
+}
+
+func step_popups(dt float32) {
+	alive := g_Popups[:0]
+	for _, popup := range g_Popups {
+		popup.age += dt
+		if popup.age < popupLifetime {
+			alive = append(alive, popup)
+		}
+	}
+	g_Popups = alive
+}
+
+func render_popups() {
+	for _, popup := range g_Popups {
+		pos := popup.pos.add(Vector2DF{0, popup.age * popupRiseSpeed})
+		screen, visible := world_to_screen(pos)
+		if !visible {
+			continue
+		}
+		screen = screen.mul_scalar(1 / g_UIScale)
+
+		alpha := float32(1)
+		if popup.age > popupLifetime-popupFadeTime {
+			alpha = (popupLifetime - popup.age) / popupFadeTime
+		}
+		// Quantized like chat lines so the text cache keeps a handful of textures per popup
+		alpha = float32(int(alpha*8+0.5)) / 8
+		c := color.RGBA{
+			uint8(float32(popup.color.R) * alpha), uint8(float32(popup.color.G) * alpha),
+			uint8(float32(popup.color.B) * alpha), uint8(float32(popup.color.A) * alpha),
+		}
+
+		draw_text(popup.text, screen.x-text_width(popup.text, 1)/2, screen.y-text_line_height(1), 1, c)
+	}
+}
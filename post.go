@@ -0,0 +1,174 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Full screen passes run over the finished frame, world and overlay both. While no pass is
+// enabled the frame is drawn straight to the window and nothing here costs anything.
+type Post struct {
+	// Scene is drawn into targets[0], passes ping-pong between the two and the last one
+	// draws to the window
+	targets [2]RenderTarget
+	width   int32
+	height  int32
+	active  bool
+
+	// Draws a full screen triangle from gl_VertexID, core profile still wants a VAO bound
+	empty_vao uint32
+
+	passes []*PostPass
+}
+
+type RenderTarget struct {
+	framebuffer uint32
+	texture     uint32
+	depth       uint32
+}
+
+type PostPass struct {
+	name    string
+	program uint32
+
+	source_uniform     int32
+	resolution_uniform int32
+
+	enabled func() bool
+	// Sets the pass' own uniforms, its program is in use
+	apply func(pass *PostPass)
+}
+
+var g_Post = Post{}
+
+var postVertexShader = `
+#version 330
+
+out vec2 fragTexCoord;
+
+void main() {
+    vec2 corner = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+    fragTexCoord = corner;
+    gl_Position = vec4(corner * 2.0 - 1.0, 0, 1);
+}
+` + "\x00"
+
+func init_post() {
+	gl.GenVertexArrays(1, &g_Post.empty_vao)
+}
+
+// Passes run in the order they're added
+func add_post_pass(name string, fragment_shader string, enabled func() bool, apply func(pass *PostPass)) *PostPass {
+	program, err := render.NewProgram(postVertexShader, fragment_shader)
+	if err != nil {
+		panic(name + ": " + err.Error())
+	}
+	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+
+	pass := &PostPass{
+		name:               name,
+		program:            program,
+		source_uniform:     gl.GetUniformLocation(program, gl.Str("source\x00")),
+		resolution_uniform: gl.GetUniformLocation(program, gl.Str("resolution\x00")),
+		enabled:            enabled,
+		apply:              apply,
+	}
+	g_Post.passes = append(g_Post.passes, pass)
+	return pass
+}
+
+func (pass *PostPass) uniform(name string) int32 {
+	return gl.GetUniformLocation(pass.program, gl.Str(name+"\x00"))
+}
+
+func enabled_post_passes() []*PostPass {
+	passes := []*PostPass{}
+	for _, pass := range g_Post.passes {
+		if pass.enabled() {
+			passes = append(passes, pass)
+		}
+	}
+	return passes
+}
+
+// Before the frame is cleared, redirects drawing into the scene target when any pass will run
+func begin_post(window *glfw.Window) {
+	g_Post.active = len(enabled_post_passes()) > 0
+	if !g_Post.active {
+		return
+	}
+
+	width, height := window.GetFramebufferSize()
+	if int32(width) != g_Post.width || int32(height) != g_Post.height {
+		resize_post_targets(int32(width), int32(height))
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, g_Post.targets[0].framebuffer)
+}
+
+// After the overlay, runs the enabled passes and leaves the result in the window
+func end_post() {
+	if !g_Post.active {
+		return
+	}
+	passes := enabled_post_passes()
+
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Disable(gl.BLEND)
+	gl.BindVertexArray(g_Post.empty_vao)
+	gl.ActiveTexture(gl.TEXTURE0)
+
+	source := 0
+	for i, pass := range passes {
+		if i == len(passes)-1 {
+			gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+		} else {
+			gl.BindFramebuffer(gl.FRAMEBUFFER, g_Post.targets[1-source].framebuffer)
+		}
+
+		gl.UseProgram(pass.program)
+		gl.BindTexture(gl.TEXTURE_2D, g_Post.targets[source].texture)
+		gl.Uniform1i(pass.source_uniform, 0)
+		gl.Uniform2f(pass.resolution_uniform, float32(g_Post.width), float32(g_Post.height))
+		pass.apply(pass)
+		gl.DrawArrays(gl.TRIANGLES, 0, 3)
+
+		source = 1 - source
+	}
+
+	gl.Enable(gl.DEPTH_TEST)
+}
+
+// Only the scene target needs depth, passes draw without it
+func resize_post_targets(width int32, height int32) {
+	for i := range g_Post.targets {
+		target := &g_Post.targets[i]
+		if target.framebuffer == 0 {
+			gl.GenFramebuffers(1, &target.framebuffer)
+			gl.GenTextures(1, &target.texture)
+			if i == 0 {
+				gl.GenRenderbuffers(1, &target.depth)
+			}
+		}
+
+		gl.BindTexture(gl.TEXTURE_2D, target.texture)
+		gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, width, height, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+
+		gl.BindFramebuffer(gl.FRAMEBUFFER, target.framebuffer)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, target.texture, 0)
+		if target.depth != 0 {
+			gl.BindRenderbuffer(gl.RENDERBUFFER, target.depth)
+			gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, width, height)
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, target.depth)
+		}
+	}
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	g_Post.width, g_Post.height = width, height
+}
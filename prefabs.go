@@ -0,0 +1,471 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type RenderComponent struct {
+	Texture string     `json:"texture"`
+	Scale   [2]float32 `json:"scale"`
+	// Initial rotation around the view axis in radians and how fast it spins, in radians per second
+	Rotation float32 `json:"rotation"`
+	Spin     float32 `json:"spin"`
+	// Palette strip for an indexed texture and the row used unless the level picks another
+	Palette string `json:"palette"`
+	Variant int    `json:"variant"`
+}
+
+type ColliderComponent struct {
+	HalfSize [2]float32 `json:"half_size"`
+	// Solid colliders are stood on like blocks, others only detect overlaps
+	Solid bool `json:"solid"`
+}
+
+// Behaviors are looked up by type in g_Behaviors, params are behavior specific
+type BehaviorComponent struct {
+	Type   string             `json:"type"`
+	Params map[string]float32 `json:"params"`
+	// Function in the gameplay scripts run by the script behavior
+	Script string `json:"script"`
+	// Behavior tree run by the tree behavior
+	Tree string `json:"tree"`
+	// Route followed by patrol actions, relative to the spawn point
+	Waypoints [][2]float32 `json:"waypoints"`
+}
+
+type HazardComponent struct {
+	Damage    float32 `json:"damage"`
+	Knockback float32 `json:"knockback"`
+}
+
+// Taken once by touching it, the entity disappears afterwards
+type PickupComponent struct {
+	// Slows the simulation to time_scale for duration real seconds, 0 duration for none
+	TimeScale float32 `json:"time_scale"`
+	Duration  float32 `json:"duration"`
+	// Keys open the doors of the same variant
+	Key bool `json:"key"`
+}
+
+// Solid until the player touches it holding a key of the same variant, then every door of that
+// variant opens
+type DoorComponent struct{}
+
+// Components missing from a definition are nil
+type PrefabComponents struct {
+	Render     *RenderComponent     `json:"render"`
+	Billboard  *BillboardComponent  `json:"billboard"`
+	Collider   *ColliderComponent   `json:"collider"`
+	Behavior   *BehaviorComponent   `json:"behavior"`
+	Hazard     *HazardComponent     `json:"hazard"`
+	Pickup     *PickupComponent     `json:"pickup"`
+	Door       *DoorComponent       `json:"door"`
+	Enemy      *EnemyComponent      `json:"enemy"`
+	Spawner    *SpawnerComponent    `json:"spawner"`
+	Projectile *ProjectileComponent `json:"projectile"`
+	Boss       *BossComponent       `json:"boss"`
+	Steering   *SteeringComponent   `json:"steering"`
+	Flock      *FlockComponent      `json:"flock"`
+	Perception *PerceptionComponent `json:"perception"`
+	Wind       *WindComponent       `json:"wind"`
+}
+
+// Spawned along with the prefab and attached to it, x and y are relative to the parent
+type PrefabChild struct {
+	Prefab string  `json:"prefab"`
+	X      float32 `json:"x"`
+	Y      float32 `json:"y"`
+}
+
+type Prefab struct {
+	Id         string           `json:"id"`
+	Components PrefabComponents `json:"components"`
+	Children   []PrefabChild    `json:"children"`
+
+	// Relative to the assets, for error messages
+	path string
+}
+
+// pos and origin are relative to the parent scene node, world_pos is where the entity ends up
+type DynamicEntity struct {
+	prefab    *Prefab
+	pos       Vector2DF
+	origin    Vector2DF
+	world_pos Vector2DF
+	age       float32
+	transform Transform
+	node      int
+
+	hazard_cooldown float32
+	// Taken for pickups, open for doors, defeated for enemies
+	picked  bool
+	variant int
+	// Stomps left, for enemies
+	health float32
+	// Waves progress, for spawners
+	spawner *SpawnerState
+	// For projectiles and steering
+	velocity Vector2DF
+	// Fight progress, for bosses
+	boss *BossState
+	// Remembered by behavior tree actions
+	ai         *AIState
+	perception *PerceptionState
+}
+
+// Placement of a prefab in a level file
+type LevelEntity struct {
+	Prefab string  `json:"prefab"`
+	X      float32 `json:"x"`
+	Y      float32 `json:"y"`
+	// Palette row, overriding the prefab's
+	Variant *int `json:"variant"`
+}
+
+var g_Prefabs = map[string]*Prefab{}
+
+var g_Behaviors = map[string]func(entity *DynamicEntity, behavior *BehaviorComponent, dt float32){
+	// Moves back and forth horizontally around the spawn point
+	"patrol": func(entity *DynamicEntity, behavior *BehaviorComponent, dt float32) {
+		params := behavior.Params
+		offset := float32(math.Sin(float64(entity.age*params["speed"]/max(params["range"], 0.01)))) * params["range"]
+		entity.pos.x = entity.origin.x + offset
+	},
+	// Floats up and down around the spawn point
+	"bob": func(entity *DynamicEntity, behavior *BehaviorComponent, dt float32) {
+		params := behavior.Params
+		entity.pos.y = entity.origin.y + float32(math.Sin(float64(entity.age*params["speed"])))*params["range"]
+	},
+	"script": run_script_behavior,
+	"tree":   step_tree_behavior,
+}
+
+// Script behaviors see x, y, origin_x, origin_y and age as variables, changes to x and y move the entity
+func run_script_behavior(entity *DynamicEntity, behavior *BehaviorComponent, dt float32) {
+	globals := map[string]any{
+		"x":        float64(entity.pos.x),
+		"y":        float64(entity.pos.y),
+		"origin_x": float64(entity.origin.x),
+		"origin_y": float64(entity.origin.y),
+		"age":      float64(entity.age),
+	}
+	builtins := map[string]func(args []any) (any, error){
+		"param": func(args []any) (any, error) {
+			if len(args) != 1 {
+				return nil, errors.New("param expects a parameter name")
+			}
+			name, ok := args[0].(string)
+			if !ok {
+				return nil, errors.New("param expects a parameter name")
+			}
+			return float64(behavior.Params[name]), nil
+		},
+	}
+
+	if _, err := call_script(behavior.Script, globals, builtins, float64(dt)); err != nil {
+		return
+	}
+
+	x, x_ok := globals["x"].(float64)
+	y, y_ok := globals["y"].(float64)
+	if x_ok && y_ok {
+		entity.pos = Vector2DF{float32(x), float32(y)}
+	}
+}
+
+const hazardCooldown = 1.0
+
+// Stops prefabs that list each other as children from recursing forever
+const prefabMaxChildDepth = 8
+
+// Every .json file in the directory defines one prefab, mods can add or replace them
+func load_prefabs(directory string) {
+	entries, err := g_VFS.ReadDir(directory)
+	if err != nil {
+		log.Println("prefabs:", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		file_name := filepath.Join(directory, entry.Name())
+		data, err := os.ReadFile(asset_path(file_name))
+		if err != nil {
+			log.Println("prefabs:", err)
+			continue
+		}
+
+		prefab := &Prefab{path: file_name}
+		if err := json.Unmarshal(data, prefab); err != nil {
+			log.Printf("prefabs: %s: %v", file_name, json_error_context(data, err))
+			continue
+		}
+		if prefab.Id == "" {
+			prefab.Id = strings.TrimSuffix(entry.Name(), ".json")
+		}
+		if behavior := prefab.Components.Behavior; behavior != nil && g_Behaviors[behavior.Type] == nil {
+			log.Printf("prefabs: %s: unknown behavior %q", file_name, behavior.Type)
+			prefab.Components.Behavior = nil
+		}
+		if behavior := prefab.Components.Behavior; behavior != nil && behavior.Type == "tree" && g_BehaviorTrees[behavior.Tree] == nil {
+			log.Printf("prefabs: %s: unknown behavior tree %q", file_name, behavior.Tree)
+			prefab.Components.Behavior = nil
+		}
+		if prefab.Components.Flock != nil && prefab.Components.Steering == nil {
+			log.Printf("prefabs: %s: flock without a steering component can't move", file_name)
+			prefab.Components.Flock = nil
+		}
+		if prefab.Components.Wind != nil && prefab.Components.Steering == nil {
+			log.Printf("prefabs: %s: wind without a steering component can't move", file_name)
+			prefab.Components.Wind = nil
+		}
+		if prefab.Components.Boss != nil && prefab.Components.Enemy == nil {
+			log.Printf("prefabs: %s: boss without an enemy component can't be defeated", file_name)
+			prefab.Components.Boss = nil
+		}
+		if render := prefab.Components.Render; render != nil && render.Scale == [2]float32{} {
+			render.Scale = [2]float32{1, 1}
+		}
+
+		g_Prefabs[prefab.Id] = prefab
+	}
+}
+
+func spawn_prefab(id string, pos Vector2DF) bool {
+	_, ok := spawn_prefab_attached(id, pos, sceneNoParent)
+	return ok
+}
+
+// Returns the scene node of the new entity so more can be attached to it
+func spawn_prefab_attached(id string, pos Vector2DF, parent int) (int, bool) {
+	return spawn_prefab_tree(id, pos, parent, 0)
+}
+
+func spawn_prefab_tree(id string, pos Vector2DF, parent int, depth int) (int, bool) {
+	prefab, ok := g_Prefabs[id]
+	if !ok {
+		return sceneNoParent, false
+	}
+
+	entity := DynamicEntity{prefab: prefab, pos: pos, origin: pos, transform: make_transform(pos)}
+	if render := prefab.Components.Render; render != nil {
+		entity.transform.rotation[2] = render.Rotation
+		entity.transform.scale = mgl32.Vec3{render.Scale[0], render.Scale[1], 1}
+		entity.variant = render.Variant
+	}
+	if enemy := prefab.Components.Enemy; enemy != nil {
+		entity.health = max(enemy.Health, 1)
+	}
+	if spawner := prefab.Components.Spawner; spawner != nil {
+		entity.spawner = new_spawner_state(spawner)
+	}
+	if prefab.Components.Boss != nil {
+		entity.boss = &BossState{}
+	}
+	if prefab.Components.Perception != nil {
+		entity.perception = new_perception_state(pos)
+	}
+	entity.node = add_scene_node(parent, entity.transform.without_scale())
+	entity.world_pos = scene_node_world_position(entity.node)
+	g_Map.dynamic_entities = append(g_Map.dynamic_entities, entity)
+
+	if depth >= prefabMaxChildDepth {
+		log.Printf("prefabs: %s: children nested too deep", id)
+		return entity.node, true
+	}
+	for _, child := range prefab.Children {
+		if _, ok := spawn_prefab_tree(child.Prefab, Vector2DF{child.X, child.Y}, entity.node, depth+1); !ok {
+			log.Printf("prefabs: %s: unknown child prefab %q", id, child.Prefab)
+		}
+	}
+	return entity.node, true
+}
+
+func spawn_level_entities() {
+	g_Map.dynamic_entities = nil
+	reset_plugin_entities()
+	clear_scene_graph()
+	g_Map.player_node = add_scene_node(sceneNoParent, player_transform(&g_Player))
+
+	for _, placement := range g_Level.Entities {
+		spawn_level_entity(placement)
+	}
+	for _, placement := range g_Map.generated_entities {
+		spawn_level_entity(placement)
+	}
+	start_plugin_level()
+}
+
+func spawn_level_entity(placement LevelEntity) {
+	first := len(g_Map.dynamic_entities)
+	if !spawn_prefab(placement.Prefab, Vector2DF{placement.X, placement.Y}) {
+		log.Printf("level %s: unknown prefab %q", g_Level.Id, placement.Prefab)
+		return
+	}
+	if placement.Variant != nil {
+		g_Map.dynamic_entities[first].variant = *placement.Variant
+	}
+}
+
+func dynamic_entity_bounding_box(entity *DynamicEntity) BoundingBox2D {
+	half_size := entity.prefab.Components.Collider.HalfSize
+	return make_bounding_box_2d_centered(entity.world_pos, Vector2DF{half_size[0], half_size[1]})
+}
+
+func step_dynamic_entities(dt float32) {
+	fill_flock_hash()
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		entity.age += dt
+		entity.hazard_cooldown = max(entity.hazard_cooldown-dt, 0)
+
+		if perception := entity.prefab.Components.Perception; perception != nil && !entity.picked {
+			perceive(entity, perception, dt)
+		}
+		if behavior := entity.prefab.Components.Behavior; behavior != nil {
+			g_Behaviors[behavior.Type](entity, behavior, dt)
+		}
+		if !entity.picked {
+			step_entity_swimming(entity, dt)
+		}
+		if projectile := entity.prefab.Components.Projectile; projectile != nil && !entity.picked {
+			step_projectile(entity, projectile, dt)
+		}
+		if steering := entity.prefab.Components.Steering; steering != nil && !entity.picked {
+			step_steering(entity, steering, dt)
+		}
+
+		entity.transform.position = entity.pos
+		if render := entity.prefab.Components.Render; render != nil {
+			entity.transform.rotation[2] += render.Spin * dt
+		}
+		set_scene_node_local(entity.node, entity.transform.without_scale())
+	}
+	g_Noises = g_Noises[:0]
+
+	update_scene_graph()
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		entity.world_pos = scene_node_world_position(entity.node)
+	}
+}
+
+// Hazards hurt and knock back the player, with a cooldown so overlapping for several frames counts once
+func touch_dynamic_entities(player *Player) {
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		touch_pickup(entity, player)
+		touch_door(entity, player)
+		if stomp_enemy(entity, player) {
+			continue
+		}
+
+		hazard := entity.prefab.Components.Hazard
+		if hazard == nil || entity.prefab.Components.Collider == nil || entity.hazard_cooldown > 0 || entity.picked {
+			continue
+		}
+		if !dynamic_entity_bounding_box(entity).intersects(player.bb) {
+			continue
+		}
+
+		entity.hazard_cooldown = hazardCooldown
+		player.vel.y = hazard.Knockback
+		player.state = FALLING
+		damage_player(player, hazard.Damage)
+		if entity.prefab.Components.Projectile != nil {
+			entity.picked = true
+		}
+	}
+}
+
+func touch_pickup(entity *DynamicEntity, player *Player) {
+	pickup := entity.prefab.Components.Pickup
+	if pickup == nil || entity.picked || entity.prefab.Components.Collider == nil {
+		return
+	}
+	if !dynamic_entity_bounding_box(entity).intersects(player.bb) {
+		return
+	}
+
+	entity.picked = true
+	if pickup.Duration > 0 {
+		slow_motion(pickup.TimeScale, pickup.Duration)
+	}
+	if pickup.Key {
+		g_Map.keys[entity.variant] = true
+	}
+}
+
+// Doors are solid so the player never overlaps them, being right next to one counts as touching
+const doorTouchMargin = 0.1
+
+func touch_door(entity *DynamicEntity, player *Player) {
+	if entity.prefab.Components.Door == nil || entity.picked || !g_Map.keys[entity.variant] {
+		return
+	}
+	if !dynamic_entity_bounding_box(entity).expand(Vector2DF{doorTouchMargin, doorTouchMargin}).intersects(player.bb) {
+		return
+	}
+
+	for i := range g_Map.dynamic_entities {
+		door := &g_Map.dynamic_entities[i]
+		if door.prefab.Components.Door != nil && door.variant == entity.variant {
+			door.picked = true
+		}
+	}
+}
+
+func render_dynamic_entities(model_uniform_location int32) {
+	for _, entity := range g_Map.dynamic_entities {
+		render := entity.prefab.Components.Render
+		if render == nil || entity.picked {
+			continue
+		}
+		if render.Palette != "" {
+			render_palette_entity(&entity, render, model_uniform_location)
+			continue
+		}
+		texture, err := load_texture(render.Texture)
+		if err != nil {
+			continue
+		}
+
+		draw_dynamic_entity(&entity, texture, model_uniform_location)
+	}
+}
+
+func render_palette_entity(entity *DynamicEntity, render *RenderComponent, model_uniform_location int32) {
+	texture, err := load_indexed_texture(render.Texture)
+	if err != nil {
+		return
+	}
+	palette, err := load_indexed_texture(render.Palette)
+	if err != nil {
+		return
+	}
+
+	begin_palette(palette, entity.variant)
+	draw_dynamic_entity(entity, texture, model_uniform_location)
+	end_palette()
+}
+
+func draw_dynamic_entity(entity *DynamicEntity, texture uint32, model_uniform_location int32) {
+	scale := entity.transform.scale
+	model := scene_node_render_world(entity.node).Mul4(mgl32.Scale3D(scale[0], scale[1], scale[2]))
+	gl.UniformMatrix4fv(model_uniform_location, 1, false, &model[0])
+
+	g_CubeMesh.texture = texture
+	g_CubeMesh.draw()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"opengl_in_go/basic/config"
+)
+
+// Players sharing a machine each get a profile directory with their own progression, stats,
+// achievements, settings, ghosts and speedruns
+type Profiles struct {
+	current string
+}
+
+var g_Profiles = Profiles{}
+
+const profileDefaultName = "player"
+
+// Files that lived directly in the save root before profiles, moved into the first profile
+var legacySaveFiles = []string{"save.json", "settings.cfg", "cloud_sync.json", "autosave_0.json", "autosave_1.json", "autosave_2.json", "ghosts", "speedruns"}
+
+func profile_directory(name string) string {
+	return filepath.Join(save_root(), "profiles", name)
+}
+
+func last_profile_path() string {
+	return filepath.Join(save_root(), "last_profile")
+}
+
+// Names end up as directory names
+func valid_profile_name(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\:`)
+}
+
+// The profile setting, or the one used last, or the default one on a fresh install
+func select_startup_profile() {
+	name := g_Config.String("profile")
+	if name == "" {
+		if data, err := os.ReadFile(last_profile_path()); err == nil {
+			name = strings.TrimSpace(string(data))
+		}
+	}
+	if !valid_profile_name(name) {
+		if name != "" {
+			log.Printf("config: %q can't be a profile name", name)
+		}
+		name = profileDefaultName
+	}
+
+	migrate_legacy_saves(name)
+	g_Profiles.current = name
+	remember_profile()
+}
+
+func remember_profile() {
+	if err := os.MkdirAll(save_root(), 0755); err != nil {
+		log.Println("profiles:", err)
+		return
+	}
+	if err := os.WriteFile(last_profile_path(), []byte(g_Profiles.current+"\n"), 0644); err != nil {
+		log.Println("profiles:", err)
+	}
+}
+
+func migrate_legacy_saves(name string) {
+	if _, err := os.Stat(filepath.Join(save_root(), "profiles")); !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err := os.MkdirAll(profile_directory(name), 0755); err != nil {
+		log.Println("profiles:", err)
+		return
+	}
+	for _, file := range legacySaveFiles {
+		err := os.Rename(filepath.Join(save_root(), file), filepath.Join(profile_directory(name), file))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Println("profiles:", err)
+		}
+	}
+}
+
+// Every profile with a directory, plus the current one even before it has saved anything
+func list_profiles() []string {
+	names := []string{g_Profiles.current}
+	entries, _ := os.ReadDir(filepath.Join(save_root(), "profiles"))
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != g_Profiles.current {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init_profiles() {
+	register_command("profile", "[name]: show the profile, or switch to another, creating it if needed", func(args []string) {
+		if len(args) == 1 {
+			if err := switch_profile(args[0]); err != nil {
+				console_print("profile: %v", err)
+				return
+			}
+		}
+		console_print("profile = %s (%s)", g_Profiles.current, strings.Join(list_profiles(), ", "))
+	})
+}
+
+func cycle_profile(step int) {
+	names := list_profiles()
+	current := sort.SearchStrings(names, g_Profiles.current)
+	switch_profile(names[(current+step+len(names))%len(names)])
+}
+
+// Writes out the current profile, then loads the other one's settings and progression.
+// Settings from the environment and command line still apply on top.
+func switch_profile(name string) error {
+	if !valid_profile_name(name) {
+		return fmt.Errorf("%q can't be a profile name", name)
+	}
+	if name == g_Profiles.current {
+		return nil
+	}
+
+	write_save_data()
+	write_settings()
+
+	g_Profiles.current = name
+	remember_profile()
+
+	g_Config.Reset(config.SourceFile, config.SourceRuntime)
+	if err := g_Config.LoadFile(settings_file_path()); err != nil {
+		log.Println("config:", err)
+	}
+	apply_config_settings()
+	apply_window_settings(g_SettingsMenu.window)
+	g_SettingsMenu.written = settings_values()
+
+	load_save_data()
+	load_ghost()
+	load_cloud_sync_state()
+	init_autosave_slot()
+	return nil
+}
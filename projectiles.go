@@ -0,0 +1,56 @@
+package main
+
+// Flies in a straight line until it hits a block or its time runs out. A hazard on it is used up
+// by hurting the player once.
+type ProjectileComponent struct {
+	// World units per second
+	Speed    float32 `json:"speed"`
+	Lifetime float32 `json:"lifetime"`
+}
+
+// Spent projectiles of the same prefab are reused, so a long fight doesn't keep growing the entities
+func spawn_projectile(id string, pos Vector2DF, direction Vector2DF) bool {
+	prefab, ok := g_Prefabs[id]
+	if !ok || prefab.Components.Projectile == nil {
+		return false
+	}
+	velocity := direction.normalized().mul_scalar(prefab.Components.Projectile.Speed)
+
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		if entity.prefab == prefab && entity.picked {
+			entity.pos, entity.origin, entity.world_pos = pos, pos, pos
+			entity.velocity = velocity
+			entity.age = 0
+			entity.hazard_cooldown = 0
+			entity.picked = false
+			return true
+		}
+	}
+
+	index := len(g_Map.dynamic_entities)
+	if !spawn_prefab(id, pos) {
+		return false
+	}
+	g_Map.dynamic_entities[index].velocity = velocity
+	return true
+}
+
+func step_projectile(entity *DynamicEntity, projectile *ProjectileComponent, dt float32) {
+	entity.pos = entity.pos.add(entity.velocity.mul_scalar(dt))
+	if entity.age >= projectile.Lifetime {
+		entity.picked = true
+		return
+	}
+
+	if entity.prefab.Components.Collider == nil {
+		return
+	}
+	bb := make_bounding_box_2d_centered(entity.pos, Vector2DF{entity.prefab.Components.Collider.HalfSize[0], entity.prefab.Components.Collider.HalfSize[1]})
+	for _, block := range g_Map.entities {
+		if block.bb.intersects(bb) {
+			entity.picked = true
+			return
+		}
+	}
+}
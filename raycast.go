@@ -0,0 +1,45 @@
+package main
+
+import "github.com/guiteixeirapimentel/small-game-go/engine/physics"
+
+type RaycastHit struct {
+	pos      Vector2DF
+	distance float32
+	// Out of the side that was hit, zero when the ray starts inside
+	normal Vector2DF
+}
+
+// Closest block or solid entity along the ray, up to max_distance away. Open doors and other
+// picked entities are let through.
+func raycast(origin Vector2DF, direction Vector2DF, max_distance float32) (RaycastHit, bool) {
+	direction = direction.normalized()
+	ray := physics.Ray{OriginX: origin.x, OriginY: origin.y, DirX: direction.x, DirY: direction.y}
+
+	closest, hit := RaycastHit{distance: max_distance}, false
+	check := func(bb BoundingBox2D) {
+		if result, ok := physics.Raycast(ray, bb.to_box()); ok && result.Distance <= closest.distance {
+			closest = RaycastHit{distance: result.Distance, normal: Vector2DF{result.NormalX, result.NormalY}}
+			hit = true
+		}
+	}
+	for _, block := range g_Map.entities {
+		check(block.bb)
+	}
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		if collider := entity.prefab.Components.Collider; collider != nil && collider.Solid && !entity.picked {
+			check(dynamic_entity_bounding_box(entity))
+		}
+	}
+
+	if !hit {
+		return RaycastHit{}, false
+	}
+	closest.pos = origin.add(direction.mul_scalar(closest.distance))
+	return closest, true
+}
+
+func line_of_sight(from Vector2DF, to Vector2DF) bool {
+	_, hit := raycast(from, to.subtract(from), from.distance(to))
+	return !hit
+}
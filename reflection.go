@@ -0,0 +1,118 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// The scene drawn again upside down around the surface of a water pool, into a texture the water
+// shader samples at its own screen position. A point d above the surface ends up d below it, right
+// where the water shows it. It costs a second pass over the world, so it can be turned off.
+type Reflection struct {
+	target RenderTarget
+	width  int32
+	height int32
+
+	// Rendered this frame, around the surface at plane
+	active bool
+	plane  float32
+	// Size of the framebuffer the scene is drawn to, the water samples at its fraction of it
+	screen Vector2DF
+}
+
+var g_Reflection = Reflection{}
+
+// Fraction of the window's resolution, the water distorts it anyway
+const reflectionScale = 0.5
+
+// The water samples it here, the frame is on unit 0
+const reflectionTextureUnit = 1
+
+// The pool whose surface is nearest the middle of the view, only one is reflected per frame
+func reflection_plane() (float32, bool) {
+	visible := camera_visible_bounds()
+	plane, found := float32(0), false
+	for _, region := range g_Map.water {
+		if !region.bounds.intersects(visible) {
+			continue
+		}
+		surface := region.bounds.max_corner().y
+		if !found || Abs(surface-g_Camera.pos2D.y) < Abs(plane-g_Camera.pos2D.y) {
+			plane, found = surface, true
+		}
+	}
+	return plane, found
+}
+
+// Before the world is drawn, leaves the framebuffer, viewport and camera uniform as they were
+func render_reflection(window *glfw.Window, camera_uniform int32, model_uniform int32) {
+	g_Reflection.active = false
+	if !g_Settings.reflections_enabled {
+		return
+	}
+	plane, ok := reflection_plane()
+	if !ok {
+		return
+	}
+
+	// The scene target while post passes run, the window otherwise
+	framebuffer, viewport := int32(0), [4]int32{}
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &framebuffer)
+	gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
+
+	width, height := window.GetFramebufferSize()
+	g_Reflection.screen = Vector2DF{float32(width), float32(height)}
+	width, height = max(int(float32(width)*reflectionScale), 1), max(int(float32(height)*reflectionScale), 1)
+	if int32(width) != g_Reflection.width || int32(height) != g_Reflection.height {
+		resize_reflection_target(int32(width), int32(height))
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, g_Reflection.target.framebuffer)
+	gl.Viewport(0, 0, int32(width), int32(height))
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	// Flips y around the plane
+	mirror := mgl32.Translate3D(0, plane, 0).Mul4(mgl32.Scale3D(1, -1, 1)).Mul4(mgl32.Translate3D(0, -plane, 0))
+	camera := camera_view_matrix().Mul4(mirror)
+	gl.UniformMatrix4fv(camera_uniform, 1, false, &camera[0])
+
+	render_map(model_uniform)
+	render_props(model_uniform)
+	render_collectibles(model_uniform)
+	render_dynamic_entities(model_uniform)
+	render_player(&g_Player, model_uniform)
+
+	camera = camera_view_matrix()
+	gl.UniformMatrix4fv(camera_uniform, 1, false, &camera[0])
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(framebuffer))
+	gl.Viewport(viewport[0], viewport[1], viewport[2], viewport[3])
+
+	g_Reflection.active = true
+	g_Reflection.plane = plane
+}
+
+func resize_reflection_target(width int32, height int32) {
+	target := &g_Reflection.target
+	if target.framebuffer == 0 {
+		gl.GenFramebuffers(1, &target.framebuffer)
+		gl.GenTextures(1, &target.texture)
+		gl.GenRenderbuffers(1, &target.depth)
+	}
+
+	gl.BindTexture(gl.TEXTURE_2D, target.texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, width, height, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, target.framebuffer)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, target.texture, 0)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, target.depth)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, width, height)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, target.depth)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	g_Reflection.width, g_Reflection.height = width, height
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"net"
+	"time"
+)
+
+const reliableResendInterval = 250 * time.Millisecond
+const reliableHeaderSize = 5 // sequence + inner packet type
+
+// Most unacknowledged messages kept, and how far past the next expected sequence a receiver buffers
+const reliableWindow = 256
+
+// Unacknowledged messages are dropped after this, and receivers stop waiting on a gap after it
+const reliableTimeout = 10 * time.Second
+
+type reliableMessage struct {
+	sequence   uint32
+	kind       NetPacketType
+	payload    []byte
+	first_sent time.Time
+	last_sent  time.Time
+}
+
+// Ordered, acknowledged delivery to one remote on top of the UDP session
+type ReliableChannel struct {
+	next_sequence uint32
+	pending       []*reliableMessage
+
+	expected_sequence uint32
+	out_of_order      map[uint32]NetPacket
+	// When out_of_order started waiting on expected_sequence
+	gap_since time.Time
+}
+
+var g_ReliableChannels = map[string]*ReliableChannel{}
+
+func reliable_channel(addr *net.UDPAddr) *ReliableChannel {
+	key := addr.String()
+	channel, ok := g_ReliableChannels[key]
+	if !ok {
+		channel = &ReliableChannel{next_sequence: 1, expected_sequence: 1, out_of_order: map[uint32]NetPacket{}}
+		g_ReliableChannels[key] = channel
+	}
+	return channel
+}
+
+func init_reliable() {
+	set_packet_handler(PACKET_RELIABLE, func(packet NetPacket) {
+		if len(packet.payload) < reliableHeaderSize {
+			return
+		}
+		sequence := binary.LittleEndian.Uint32(packet.payload)
+
+		channel := reliable_channel(packet.from)
+		if sequence >= channel.expected_sequence+reliableWindow {
+			return // Too far ahead to buffer, left unacknowledged so it's resent later
+		}
+		net_send(packet.from, PACKET_RELIABLE_ACK, packet.payload[:4])
+		if sequence < channel.expected_sequence {
+			return // Duplicate of something already delivered, our ack got lost
+		}
+
+		if len(channel.out_of_order) == 0 {
+			channel.gap_since = time.Now()
+		}
+		channel.out_of_order[sequence] = NetPacket{packet.from, NetPacketType(packet.payload[4]), packet.payload[reliableHeaderSize:]}
+		deliver_reliable(channel)
+	})
+
+	set_packet_handler(PACKET_RELIABLE_ACK, func(packet NetPacket) {
+		if len(packet.payload) < 4 {
+			return
+		}
+		sequence := binary.LittleEndian.Uint32(packet.payload)
+
+		channel := reliable_channel(packet.from)
+		for i, message := range channel.pending {
+			if message.sequence == sequence {
+				channel.pending = append(channel.pending[:i], channel.pending[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+func net_send_reliable(to *net.UDPAddr, kind NetPacketType, payload []byte) {
+	channel := reliable_channel(to)
+
+	if len(channel.pending) >= reliableWindow {
+		log.Printf("net: %s isn't acknowledging, dropping message %d", to, channel.pending[0].sequence)
+		channel.pending = channel.pending[1:]
+	}
+
+	now := time.Now()
+	message := &reliableMessage{sequence: channel.next_sequence, kind: kind, payload: payload, first_sent: now, last_sent: now}
+	channel.next_sequence++
+	channel.pending = append(channel.pending, message)
+
+	send_reliable_message(to, message)
+}
+
+func send_reliable_message(to *net.UDPAddr, message *reliableMessage) {
+	buffer := make([]byte, reliableHeaderSize, reliableHeaderSize+len(message.payload))
+	binary.LittleEndian.PutUint32(buffer, message.sequence)
+	buffer[4] = byte(message.kind)
+	buffer = append(buffer, message.payload...)
+
+	net_send(to, PACKET_RELIABLE, buffer)
+}
+
+// Hands buffered messages to their handlers for as long as they are in sequence
+func deliver_reliable(channel *ReliableChannel) {
+	for {
+		next, ok := channel.out_of_order[channel.expected_sequence]
+		if !ok {
+			break
+		}
+		delete(channel.out_of_order, channel.expected_sequence)
+		channel.expected_sequence++
+		channel.gap_since = time.Now()
+
+		if handler, ok := g_Net.handlers[next.kind]; ok {
+			handler(next)
+		}
+	}
+}
+
+// Resends everything not acknowledged in time and gives up on what took too long, call once per frame
+func step_reliable() {
+	now := time.Now()
+
+	for key, channel := range g_ReliableChannels {
+		// The sender dropped whatever fills the gap, skip to what did arrive
+		if len(channel.out_of_order) > 0 && now.Sub(channel.gap_since) >= reliableTimeout {
+			skip_to := channel.expected_sequence + reliableWindow
+			for sequence := range channel.out_of_order {
+				skip_to = min(skip_to, sequence)
+			}
+			log.Printf("net: %s never sent %d to %d, skipping them", key, channel.expected_sequence, skip_to-1)
+			channel.expected_sequence = skip_to
+			deliver_reliable(channel)
+		}
+
+		to, err := net.ResolveUDPAddr("udp", key)
+		if err != nil {
+			continue
+		}
+
+		kept := channel.pending[:0]
+		for _, message := range channel.pending {
+			if now.Sub(message.first_sent) >= reliableTimeout {
+				log.Printf("net: %s never acknowledged message %d, dropping it", key, message.sequence)
+				continue
+			}
+			if now.Sub(message.last_sent) >= reliableResendInterval {
+				message.last_sent = now
+				send_reliable_message(to, message)
+			}
+			kept = append(kept, message)
+		}
+		channel.pending = kept
+	}
+}
+
+func forget_reliable_channel(addr *net.UDPAddr) {
+	delete(g_ReliableChannels, addr.String())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+type LevelResult struct {
+	Stars     int     `json:"stars"`
+	BestTime  float32 `json:"best_time"`
+	Collected int     `json:"collected"`
+}
+
+type ResultsScreen struct {
+	open bool
+
+	time      float32
+	collected int
+	stars     int
+	new_best  bool
+	unlocked  []string
+
+	star_texture       uint32
+	empty_star_texture uint32
+}
+
+var g_ResultsScreen ResultsScreen
+
+func init_results_screen() {
+	g_ResultsScreen.star_texture = render.SolidTexture(color.RGBA{255, 210, 80, 255})
+	g_ResultsScreen.empty_star_texture = render.SolidTexture(color.RGBA{60, 60, 60, 255})
+
+	// Subscribed after the speedrun so the final game time is already known, the start screen's
+	// key handler has to come first so the Enter closing this screen doesn't also close that one
+	subscribe_event(EVENT_LEVEL_COMPLETED, func(event GameEvent) {
+		open_results_screen(g_Speedrun.game_time, collected_count())
+	})
+
+	add_key_input_handler(INPUT_CONTEXT_UI, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+		if g_ResultsScreen.open && key == glfw.KeyEnter && action == glfw.Press {
+			close_results_screen()
+		}
+	})
+}
+
+// One star for finishing, one for beating par and one for collecting everything
+func level_stars(level LevelInfo, time float32, collected int) int {
+	stars := 1
+	if time <= level.ParTime {
+		stars++
+	}
+	if collected >= level.Collectibles {
+		stars++
+	}
+	return stars
+}
+
+func open_results_screen(time float32, collected int) {
+	g_ResultsScreen.open = true
+	g_ResultsScreen.time = time
+	g_ResultsScreen.collected = collected
+	g_ResultsScreen.stars = level_stars(g_Level, time, collected)
+	push_input_context(INPUT_CONTEXT_UI)
+
+	// Stars, time and collectibles are each kept at their best independently
+	best, ok := g_SaveData.LevelResults[g_Level.Id]
+	g_ResultsScreen.new_best = !ok || time < best.BestTime
+	if g_ResultsScreen.new_best {
+		best.BestTime = time
+	}
+	best.Stars = max(best.Stars, g_ResultsScreen.stars)
+	best.Collected = max(best.Collected, collected)
+	g_SaveData.LevelResults[g_Level.Id] = best
+	g_ResultsScreen.unlocked = update_level_unlocks()
+	write_save_data()
+}
+
+// Restarts the level from the start screen
+func close_results_screen() {
+	g_ResultsScreen.open = false
+	pop_input_context(INPUT_CONTEXT_UI)
+
+	build_map()
+	respawn_player(&g_Player)
+	open_start_screen()
+}
+
+func render_results_screen() {
+	if !g_ResultsScreen.open {
+		return
+	}
+
+	const width = float32(320)
+	height := float32(170 + 13*len(g_ResultsScreen.unlocked))
+	size := ui_size()
+	x := (size.x - width) / 2
+	y := (size.y - height) / 2
+	white := color.RGBA{255, 255, 255, 255}
+	line_height := text_line_height(1)
+
+	draw_nine_slice(g_WidgetTheme.panel, x, y, width, height)
+	draw_text(g_Level.Name+" complete", x+16, y+16, 1, white)
+
+	for i := 0; i < 3; i++ {
+		texture := g_ResultsScreen.empty_star_texture
+		if i < g_ResultsScreen.stars {
+			texture = g_ResultsScreen.star_texture
+		}
+		draw_overlay_quad(texture, x+16+float32(i)*36, y+20+line_height, 28, 28)
+	}
+
+	time_text := fmt.Sprintf("Time %s (par %s)", format_speedrun_time(g_ResultsScreen.time), format_speedrun_time(g_Level.ParTime))
+	if g_ResultsScreen.new_best {
+		time_text += " new best!"
+	}
+	draw_text(time_text, x+16, y+60+line_height, 1, white)
+	draw_text(fmt.Sprintf("Collected %d/%d", g_ResultsScreen.collected, g_Level.Collectibles), x+16, y+60+line_height*2, 1, white)
+	for i, name := range g_ResultsScreen.unlocked {
+		draw_text("Unlocked "+name, x+16, y+60+line_height*float32(3+i), 1, color.RGBA{255, 210, 80, 255})
+	}
+	draw_text("Press Enter to continue", x+16, y+60+line_height*float32(4+len(g_ResultsScreen.unlocked)), 1, color.RGBA{140, 140, 140, 255})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type SaveData struct {
+	Achievements        map[string]time.Time   `json:"achievements"`
+	AchievementProgress map[string]float32     `json:"achievement_progress"`
+	Stats               PlayerStats            `json:"stats"`
+	DailyResults        map[string]DailyResult `json:"daily_results"`
+	BestSplits          map[string][]float32   `json:"best_splits"`
+	LevelResults        map[string]LevelResult `json:"level_results"`
+	UnlockedLevels      map[string]bool        `json:"unlocked_levels"`
+	// Fog of war coverage by level and seed
+	Fog map[string][]uint8 `json:"fog"`
+}
+
+var g_SaveData = new_save_data()
+
+func new_save_data() SaveData {
+	return SaveData{
+		Achievements:        map[string]time.Time{},
+		AchievementProgress: map[string]float32{},
+		DailyResults:        map[string]DailyResult{},
+		BestSplits:          map[string][]float32{},
+		LevelResults:        map[string]LevelResult{},
+		UnlockedLevels:      map[string]bool{},
+		Fog:                 map[string][]uint8{},
+	}
+}
+
+// Shared by every profile
+func save_root() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = g_GameDir
+	}
+	return filepath.Join(dir, "small-game-go")
+}
+
+// Saves, settings, ghosts and speedruns of the current profile
+func save_directory() string {
+	return profile_directory(g_Profiles.current)
+}
+
+func save_path() string {
+	return filepath.Join(save_directory(), "save.json")
+}
+
+func load_save_data() {
+	g_SaveData = new_save_data()
+	data, err := os.ReadFile(save_path())
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Println("save:", err)
+		return
+	}
+
+	loaded := new_save_data()
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		log.Printf("save: %s is corrupt: %v", save_path(), err)
+		return
+	}
+	g_SaveData = loaded
+}
+
+// Writes to a temporary file first so a crash mid-write can't lose the old save. Sessions that
+// used cheats leave the save as it was.
+func write_save_data() {
+	if g_Cheats.used {
+		return
+	}
+	if err := write_save_file(save_path(), g_SaveData); err != nil {
+		log.Println("save:", err)
+	}
+}
+
+func write_save_file(path string, value any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(value, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
@@ -0,0 +1,72 @@
+package main
+
+import "github.com/go-gl/mathgl/mgl32"
+
+const sceneNoParent = -1
+
+// Children are always added after their parent, so one pass in id order sees parents first
+type SceneNode struct {
+	parent int
+	local  Transform
+	world  mgl32.Mat4
+	// World matrix at the previous simulation step, for render interpolation
+	previous_world mgl32.Mat4
+}
+
+type SceneGraph struct {
+	nodes []SceneNode
+}
+
+var g_Scene = SceneGraph{}
+
+func init_scene_graph() {
+	register_command("attach", "spawn a prefab at x y relative to the player, moving with it", func(prefab string, x float32, y float32) {
+		if _, ok := spawn_prefab_attached(prefab, Vector2DF{x, y}, g_Map.player_node); !ok {
+			console_print("unknown prefab %q", prefab)
+		}
+	})
+}
+
+// Removes every node, called when the map is rebuilt
+func clear_scene_graph() {
+	g_Scene.nodes = g_Scene.nodes[:0]
+}
+
+func add_scene_node(parent int, local Transform) int {
+	if parent >= len(g_Scene.nodes) {
+		parent = sceneNoParent
+	}
+
+	world := local.matrix()
+	g_Scene.nodes = append(g_Scene.nodes, SceneNode{parent: parent, local: local, world: world, previous_world: world})
+	return len(g_Scene.nodes) - 1
+}
+
+func set_scene_node_local(id int, local Transform) {
+	g_Scene.nodes[id].local = local
+}
+
+func scene_node_world(id int) mgl32.Mat4 {
+	return g_Scene.nodes[id].world
+}
+
+func scene_node_render_world(id int) mgl32.Mat4 {
+	node := &g_Scene.nodes[id]
+	return lerp_matrix(node.previous_world, node.world, g_Timestep.alpha)
+}
+
+func scene_node_world_position(id int) Vector2DF {
+	world := g_Scene.nodes[id].world
+	return Vector2DF{world[12], world[13]}
+}
+
+// Walks the hierarchy root first, composing each local transform onto its parent's world matrix
+func update_scene_graph() {
+	for id := range g_Scene.nodes {
+		node := &g_Scene.nodes[id]
+		node.world = node.local.matrix()
+		if node.parent != sceneNoParent {
+			node.world = g_Scene.nodes[node.parent].world.Mul4(node.world)
+		}
+	}
+}
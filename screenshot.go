@@ -0,0 +1,81 @@
+package main
+
+import (
+	"image"
+	"path/filepath"
+	"time"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Captures of the finished frame, post effects included, written as PNGs on a worker
+type Screenshots struct {
+	pending bool
+	saving  []*Future[string]
+}
+
+var g_Screenshots = Screenshots{}
+
+const screenshotKey = glfw.KeyF12
+const screenshotDirectory = "screenshots"
+
+func init_screenshots() {
+	register_command("screenshot", "save the next frame to the screenshots directory", request_screenshot)
+
+	add_key_input_handler(INPUT_CONTEXT_GAMEPLAY, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+		if key == screenshotKey && action == glfw.Press {
+			request_screenshot()
+		}
+	})
+}
+
+// Taken at the end of the frame, once everything is drawn
+func request_screenshot() {
+	g_Screenshots.pending = true
+}
+
+func screenshot_pending() bool {
+	return g_Screenshots.pending
+}
+
+// After the post passes and before the buffers are swapped, the back buffer holds the frame
+func capture_screenshot(window *glfw.Window) {
+	for i := 0; i < len(g_Screenshots.saving); i++ {
+		future := g_Screenshots.saving[i]
+		if !future.ready() {
+			continue
+		}
+		if path, err := future.wait(); err != nil {
+			console_print("screenshot: %v", err)
+		} else {
+			console_print("screenshot saved to %s", path)
+		}
+		g_Screenshots.saving = append(g_Screenshots.saving[:i], g_Screenshots.saving[i+1:]...)
+		i--
+	}
+
+	if !g_Screenshots.pending {
+		return
+	}
+	g_Screenshots.pending = false
+
+	width, height := window.GetFramebufferSize()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	gl.ReadPixels(0, 0, int32(width), int32(height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+
+	path := filepath.Join(g_GameDir, screenshotDirectory, time.Now().Format("2006-01-02_15-04-05.000")+".png")
+	g_Screenshots.saving = append(g_Screenshots.saving, submit_job(0, func() (string, error) {
+		// GL rows start at the bottom, and the window has no use for alpha
+		flipped := image.NewRGBA(img.Rect)
+		stride := img.Stride
+		for y := 0; y < height; y++ {
+			copy(flipped.Pix[y*stride:(y+1)*stride], img.Pix[(height-1-y)*stride:(height-y)*stride])
+		}
+		for i := 3; i < len(flipped.Pix); i += 4 {
+			flipped.Pix[i] = 255
+		}
+
+		return path, write_png(path, flipped)
+	}))
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"math"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Gameplay scripts are Go source files interpreted by a small tree walking interpreter, so they can be
+// edited while the game runs. Only float64, bool and string values, basic statements and the builtins
+// below are supported. Script files start with //go:build ignore so the Go toolchain skips them.
+type Script struct {
+	path     string
+	mod_time time.Time
+	funcs    map[string]*ast.FuncDecl
+}
+
+type Scripts struct {
+	scripts map[string]*Script
+	// Keyed by function name, the most recently loaded script wins
+	funcs map[string]*ast.FuncDecl
+
+	since_poll float32
+	// Last runtime error per function, so a failing script doesn't flood the log every frame
+	errors map[string]string
+}
+
+var g_Scripts = Scripts{
+	scripts: map[string]*Script{},
+	funcs:   map[string]*ast.FuncDecl{},
+	errors:  map[string]string{},
+}
+
+const scriptsDirectory = "scripts"
+const scriptsPollInterval = 0.5
+const scriptMaxLoopIterations = 100000
+
+type ScriptScope struct {
+	vars   map[string]any
+	parent *ScriptScope
+}
+
+type ScriptContext struct {
+	builtins map[string]func(args []any) (any, error)
+	depth    int
+}
+
+// Signals a return statement unwinding through nested blocks
+type scriptReturn struct {
+	value any
+}
+
+func init_scripts() {
+	reload_scripts(true)
+
+	register_command("scripts_reload", "reload entity scripts", func() {
+		reload_scripts(true)
+		console_print("%d script functions loaded", len(g_Scripts.funcs))
+	})
+}
+
+func step_scripts(dt float32) {
+	g_Scripts.since_poll += dt
+	if g_Scripts.since_poll < scriptsPollInterval {
+		return
+	}
+	g_Scripts.since_poll = 0
+	reload_scripts(false)
+}
+
+// Parses scripts that are new or changed on disk, a script that fails to parse keeps its old version
+func reload_scripts(force bool) {
+	entries, err := g_VFS.ReadDir(scriptsDirectory)
+	if err != nil {
+		return
+	}
+
+	changed := false
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".go" {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := asset_path(filepath.Join(scriptsDirectory, entry.Name()))
+		if old, ok := g_Scripts.scripts[path]; ok && !force && old.mod_time.Equal(info.ModTime()) {
+			continue
+		}
+
+		script, err := parse_script(path)
+		if err != nil {
+			log.Println("scripts:", err)
+			console_print("script error: %v", err)
+			// Don't retry until the file changes again
+			if old, ok := g_Scripts.scripts[path]; ok {
+				old.mod_time = info.ModTime()
+			} else {
+				g_Scripts.scripts[path] = &Script{path: path, mod_time: info.ModTime(), funcs: map[string]*ast.FuncDecl{}}
+			}
+			continue
+		}
+		script.mod_time = info.ModTime()
+		g_Scripts.scripts[path] = script
+		changed = true
+		log.Printf("scripts: loaded %s", entry.Name())
+	}
+
+	if changed {
+		g_Scripts.funcs = map[string]*ast.FuncDecl{}
+		g_Scripts.errors = map[string]string{}
+		for _, script := range g_Scripts.scripts {
+			for name, decl := range script.funcs {
+				g_Scripts.funcs[name] = decl
+			}
+		}
+	}
+}
+
+func parse_script(path string) (*Script, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	script := &Script{path: path, funcs: map[string]*ast.FuncDecl{}}
+	for _, decl := range file.Decls {
+		if function, ok := decl.(*ast.FuncDecl); ok && function.Recv == nil {
+			script.funcs[function.Name.Name] = function
+		}
+	}
+	return script, nil
+}
+
+// Calls a script function, globals are visible to it as variables and read back after it returns
+func call_script(name string, globals map[string]any, builtins map[string]func(args []any) (any, error), args ...any) (any, error) {
+	global_scope := &ScriptScope{vars: globals}
+	context := &ScriptContext{builtins: builtins}
+
+	value, err := context.call(name, global_scope, args)
+	if err != nil {
+		if g_Scripts.errors[name] != err.Error() {
+			g_Scripts.errors[name] = err.Error()
+			log.Printf("scripts: %s: %v", name, err)
+		}
+	}
+	return value, err
+}
+
+func (scope *ScriptScope) lookup(name string) (*ScriptScope, bool) {
+	for current := scope; current != nil; current = current.parent {
+		if _, ok := current.vars[name]; ok {
+			return current, true
+		}
+	}
+	return nil, false
+}
+
+func (context *ScriptContext) call(name string, global_scope *ScriptScope, args []any) (any, error) {
+	if builtin, ok := context.builtins[name]; ok {
+		return builtin(args)
+	}
+	if builtin, ok := g_ScriptBuiltins[name]; ok {
+		return builtin(args)
+	}
+
+	decl, ok := g_Scripts.funcs[name]
+	if !ok {
+		return nil, fmt.Errorf("undefined function %s", name)
+	}
+
+	context.depth++
+	defer func() { context.depth-- }()
+	if context.depth > 64 {
+		return nil, errors.New("call stack too deep")
+	}
+
+	scope := &ScriptScope{vars: map[string]any{}, parent: global_scope}
+	i := 0
+	for _, field := range decl.Type.Params.List {
+		for _, param := range field.Names {
+			if i >= len(args) {
+				return nil, fmt.Errorf("%s: not enough arguments", name)
+			}
+			scope.vars[param.Name] = args[i]
+			i++
+		}
+	}
+
+	err := context.exec_block(decl.Body.List, scope)
+	if ret, ok := err.(scriptReturn); ok {
+		return ret.value, nil
+	}
+	return nil, err
+}
+
+func (ret scriptReturn) Error() string {
+	return "return outside of a function"
+}
+
+func (context *ScriptContext) exec_block(stmts []ast.Stmt, scope *ScriptScope) error {
+	for _, stmt := range stmts {
+		if err := context.exec(stmt, scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (context *ScriptContext) exec(stmt ast.Stmt, scope *ScriptScope) error {
+	switch stmt := stmt.(type) {
+	case *ast.ExprStmt:
+		_, err := context.eval(stmt.X, scope)
+		return err
+
+	case *ast.AssignStmt:
+		if len(stmt.Lhs) != len(stmt.Rhs) {
+			return errors.New("multiple value assignments are not supported")
+		}
+		values := make([]any, len(stmt.Rhs))
+		for i, expr := range stmt.Rhs {
+			value, err := context.eval(expr, scope)
+			if err != nil {
+				return err
+			}
+			values[i] = value
+		}
+		for i, lhs := range stmt.Lhs {
+			if err := context.assign(lhs, stmt.Tok, values[i], scope); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *ast.IncDecStmt:
+		op := token.ADD_ASSIGN
+		if stmt.Tok == token.DEC {
+			op = token.SUB_ASSIGN
+		}
+		return context.assign(stmt.X, op, float64(1), scope)
+
+	case *ast.DeclStmt:
+		decl, ok := stmt.Decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.VAR {
+			return errors.New("only var declarations are supported")
+		}
+		for _, spec := range decl.Specs {
+			value_spec := spec.(*ast.ValueSpec)
+			for i, name := range value_spec.Names {
+				var value any = float64(0)
+				if i < len(value_spec.Values) {
+					evaluated, err := context.eval(value_spec.Values[i], scope)
+					if err != nil {
+						return err
+					}
+					value = evaluated
+				}
+				scope.vars[name.Name] = value
+			}
+		}
+		return nil
+
+	case *ast.BlockStmt:
+		return context.exec_block(stmt.List, &ScriptScope{vars: map[string]any{}, parent: scope})
+
+	case *ast.IfStmt:
+		inner := &ScriptScope{vars: map[string]any{}, parent: scope}
+		if stmt.Init != nil {
+			if err := context.exec(stmt.Init, inner); err != nil {
+				return err
+			}
+		}
+		condition, err := context.eval_bool(stmt.Cond, inner)
+		if err != nil {
+			return err
+		}
+		if condition {
+			return context.exec(stmt.Body, inner)
+		}
+		if stmt.Else != nil {
+			return context.exec(stmt.Else, inner)
+		}
+		return nil
+
+	case *ast.ForStmt:
+		inner := &ScriptScope{vars: map[string]any{}, parent: scope}
+		if stmt.Init != nil {
+			if err := context.exec(stmt.Init, inner); err != nil {
+				return err
+			}
+		}
+		for iteration := 0; ; iteration++ {
+			if iteration >= scriptMaxLoopIterations {
+				return errors.New("loop ran too long")
+			}
+			if stmt.Cond != nil {
+				condition, err := context.eval_bool(stmt.Cond, inner)
+				if err != nil {
+					return err
+				}
+				if !condition {
+					return nil
+				}
+			}
+			if err := context.exec(stmt.Body, inner); err != nil {
+				return err
+			}
+			if stmt.Post != nil {
+				if err := context.exec(stmt.Post, inner); err != nil {
+					return err
+				}
+			}
+		}
+
+	case *ast.ReturnStmt:
+		if len(stmt.Results) == 0 {
+			return scriptReturn{}
+		}
+		value, err := context.eval(stmt.Results[0], scope)
+		if err != nil {
+			return err
+		}
+		return scriptReturn{value}
+	}
+
+	return fmt.Errorf("unsupported statement %T", stmt)
+}
+
+func (context *ScriptContext) assign(lhs ast.Expr, op token.Token, value any, scope *ScriptScope) error {
+	ident, ok := lhs.(*ast.Ident)
+	if !ok {
+		return errors.New("can only assign to variables")
+	}
+	if ident.Name == "_" {
+		return nil
+	}
+
+	if op == token.DEFINE {
+		scope.vars[ident.Name] = value
+		return nil
+	}
+
+	owner, ok := scope.lookup(ident.Name)
+	if !ok {
+		return fmt.Errorf("undefined variable %s", ident.Name)
+	}
+
+	if op != token.ASSIGN {
+		// x op= y is evaluated as x = x op y
+		binary_op := map[token.Token]token.Token{
+			token.ADD_ASSIGN: token.ADD,
+			token.SUB_ASSIGN: token.SUB,
+			token.MUL_ASSIGN: token.MUL,
+			token.QUO_ASSIGN: token.QUO,
+			token.REM_ASSIGN: token.REM,
+		}[op]
+		combined, err := script_binary(binary_op, owner.vars[ident.Name], value)
+		if err != nil {
+			return err
+		}
+		value = combined
+	}
+
+	owner.vars[ident.Name] = value
+	return nil
+}
+
+func (context *ScriptContext) eval_bool(expr ast.Expr, scope *ScriptScope) (bool, error) {
+	value, err := context.eval(expr, scope)
+	if err != nil {
+		return false, err
+	}
+	condition, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition is %T, not bool", value)
+	}
+	return condition, nil
+}
+
+func (context *ScriptContext) eval(expr ast.Expr, scope *ScriptScope) (any, error) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		switch expr.Kind {
+		case token.INT, token.FLOAT:
+			return strconv.ParseFloat(expr.Value, 64)
+		case token.STRING:
+			return strconv.Unquote(expr.Value)
+		}
+		return nil, fmt.Errorf("unsupported literal %s", expr.Value)
+
+	case *ast.Ident:
+		switch expr.Name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		owner, ok := scope.lookup(expr.Name)
+		if !ok {
+			return nil, fmt.Errorf("undefined variable %s", expr.Name)
+		}
+		return owner.vars[expr.Name], nil
+
+	case *ast.ParenExpr:
+		return context.eval(expr.X, scope)
+
+	case *ast.UnaryExpr:
+		value, err := context.eval(expr.X, scope)
+		if err != nil {
+			return nil, err
+		}
+		switch expr.Op {
+		case token.SUB:
+			if number, ok := value.(float64); ok {
+				return -number, nil
+			}
+		case token.NOT:
+			if condition, ok := value.(bool); ok {
+				return !condition, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid operation %s%T", expr.Op, value)
+
+	case *ast.BinaryExpr:
+		left, err := context.eval(expr.X, scope)
+		if err != nil {
+			return nil, err
+		}
+		// && and || short circuit
+		if condition, ok := left.(bool); ok && (expr.Op == token.LAND || expr.Op == token.LOR) {
+			if condition == (expr.Op == token.LOR) {
+				return condition, nil
+			}
+			return context.eval_bool(expr.Y, scope)
+		}
+		right, err := context.eval(expr.Y, scope)
+		if err != nil {
+			return nil, err
+		}
+		return script_binary(expr.Op, left, right)
+
+	case *ast.CallExpr:
+		ident, ok := expr.Fun.(*ast.Ident)
+		if !ok {
+			return nil, errors.New("only calls to named functions are supported")
+		}
+		args := make([]any, len(expr.Args))
+		for i, arg := range expr.Args {
+			value, err := context.eval(arg, scope)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = value
+		}
+
+		global_scope := scope
+		for global_scope.parent != nil {
+			global_scope = global_scope.parent
+		}
+		return context.call(ident.Name, global_scope, args)
+	}
+
+	return nil, fmt.Errorf("unsupported expression %T", expr)
+}
+
+func script_binary(op token.Token, left any, right any) (any, error) {
+	switch left := left.(type) {
+	case float64:
+		right, ok := right.(float64)
+		if !ok {
+			break
+		}
+		switch op {
+		case token.ADD:
+			return left + right, nil
+		case token.SUB:
+			return left - right, nil
+		case token.MUL:
+			return left * right, nil
+		case token.QUO:
+			return left / right, nil
+		case token.REM:
+			return math.Mod(left, right), nil
+		case token.LSS:
+			return left < right, nil
+		case token.GTR:
+			return left > right, nil
+		case token.LEQ:
+			return left <= right, nil
+		case token.GEQ:
+			return left >= right, nil
+		case token.EQL:
+			return left == right, nil
+		case token.NEQ:
+			return left != right, nil
+		}
+
+	case bool:
+		right, ok := right.(bool)
+		if !ok {
+			break
+		}
+		switch op {
+		case token.EQL:
+			return left == right, nil
+		case token.NEQ:
+			return left != right, nil
+		}
+
+	case string:
+		right, ok := right.(string)
+		if !ok {
+			break
+		}
+		switch op {
+		case token.ADD:
+			return left + right, nil
+		case token.EQL:
+			return left == right, nil
+		case token.NEQ:
+			return left != right, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid operation %T %s %T", left, op, right)
+}
+
+func script_number_args(name string, args []any, count int) ([]float64, error) {
+	if len(args) != count {
+		return nil, fmt.Errorf("%s expects %d arguments", name, count)
+	}
+	numbers := make([]float64, count)
+	for i, arg := range args {
+		number, ok := arg.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s: argument %d is %T, not a number", name, i+1, arg)
+		}
+		numbers[i] = number
+	}
+	return numbers, nil
+}
+
+func script_math_builtin(name string, count int, function func(args []float64) float64) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		numbers, err := script_number_args(name, args, count)
+		if err != nil {
+			return nil, err
+		}
+		return function(numbers), nil
+	}
+}
+
+var g_ScriptBuiltins = map[string]func(args []any) (any, error){
+	"sin":   script_math_builtin("sin", 1, func(a []float64) float64 { return math.Sin(a[0]) }),
+	"cos":   script_math_builtin("cos", 1, func(a []float64) float64 { return math.Cos(a[0]) }),
+	"abs":   script_math_builtin("abs", 1, func(a []float64) float64 { return math.Abs(a[0]) }),
+	"sqrt":  script_math_builtin("sqrt", 1, func(a []float64) float64 { return math.Sqrt(a[0]) }),
+	"floor": script_math_builtin("floor", 1, func(a []float64) float64 { return math.Floor(a[0]) }),
+	"min":   script_math_builtin("min", 2, func(a []float64) float64 { return math.Min(a[0], a[1]) }),
+	"max":   script_math_builtin("max", 2, func(a []float64) float64 { return math.Max(a[0], a[1]) }),
+	// Conversions are accepted so scripts read like regular Go, every number is a float64 anyway
+	"float64": script_math_builtin("float64", 1, func(a []float64) float64 { return a[0] }),
+	"int":     script_math_builtin("int", 1, func(a []float64) float64 { return math.Trunc(a[0]) }),
+	"print": func(args []any) (any, error) {
+		console_print("%s", fmt.Sprint(args...))
+		return nil, nil
+	},
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/vecmath"
+)
+
+const serverTickRate = 30
+const serverMaxClients = 8
+const serverClientTimeout = 10 * time.Second
+
+// Inputs beyond this within one tick are dropped as flooding
+const serverMaxInputsPerTick = 4
+
+const (
+	INPUT_BUTTON_LEFT  = 1 << 0
+	INPUT_BUTTON_RIGHT = 1 << 1
+	INPUT_BUTTON_JUMP  = 1 << 2
+
+	inputButtonsMask = INPUT_BUTTON_LEFT | INPUT_BUTTON_RIGHT | INPUT_BUTTON_JUMP
+)
+
+const inputPacketSize = 9 // sequence + buttons + acknowledged snapshot tick
+
+type ServerClient struct {
+	id     uint16
+	name   string
+	player Player
+
+	// Authoritative position, player.pos is a float32 copy for collision and snapshots
+	position vecmath.Vec2[float64]
+
+	// Checkpoints are passed per client, g_Map's count is the local player's
+	reached_checkpoints int
+
+	last_input_sequence uint32
+	buttons             uint8
+	inputs_this_tick    int
+
+	// Latest snapshot the client confirmed, deltas are encoded against it
+	acked_tick uint32
+}
+
+// The dedicated server owns the simulation, clients only send their inputs
+type GameServer struct {
+	clients map[string]*ServerClient
+	next_id uint16
+	tick    uint32
+
+	history SnapshotHistory
+}
+
+var g_Server = GameServer{clients: map[string]*ServerClient{}}
+
+func run_server(name string, port int) {
+	g_Headless = true
+	build_map()
+
+	init_network()
+	set_packet_handler(PACKET_CONNECT, server_handle_connect)
+	set_packet_handler(PACKET_DISCONNECT, server_handle_disconnect)
+	set_packet_handler(PACKET_INPUT, server_handle_input)
+
+	if err := net_host(port); err != nil {
+		log.Fatalln("failed to start server:", err)
+	}
+	defer net_close()
+
+	start_lan_beacon(name, port, serverMaxClients)
+	defer close_lan_discovery()
+
+	if spectator_port := config_int("spectator_port"); spectator_port > 0 {
+		if err := start_spectator_server(spectator_port); err != nil {
+			log.Fatalln("failed to start spectator server:", err)
+		}
+		defer stop_spectator_server()
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	ticker := time.NewTicker(time.Second / serverTickRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-interrupt:
+			log.Println("server: shutting down")
+			return
+		case <-ticker.C:
+			server_tick(float32(1) / serverTickRate)
+		}
+	}
+}
+
+func server_handle_connect(packet NetPacket) {
+	key := packet.from.String()
+	client, ok := g_Server.clients[key]
+
+	if !ok {
+		if len(g_Server.clients) >= serverMaxClients {
+			net_send(packet.from, PACKET_DISCONNECT, nil)
+			return
+		}
+
+		g_Server.next_id++
+		client = &ServerClient{id: g_Server.next_id, name: string(packet.payload)}
+		respawn_player(&client.player)
+		g_Server.clients[key] = client
+		g_Net.peers[key] = &NetPeer{addr: packet.from, name: client.name, last_heard: time.Now()}
+
+		log.Printf("server: %s (%s) joined as %d", client.name, key, client.id)
+	}
+
+	// Resent on duplicate connects in case our first accept was lost
+	net_send(packet.from, PACKET_ACCEPT, binary.LittleEndian.AppendUint16(nil, client.id))
+}
+
+func server_handle_disconnect(packet NetPacket) {
+	key := packet.from.String()
+	if client, ok := g_Server.clients[key]; ok {
+		log.Printf("server: %s left", client.name)
+	}
+	server_remove_client(key)
+}
+
+func server_remove_client(key string) {
+	delete(g_Server.clients, key)
+	delete(g_Net.peers, key)
+	delete(g_ReliableChannels, key)
+}
+
+func server_handle_input(packet NetPacket) {
+	client, ok := g_Server.clients[packet.from.String()]
+	if !ok || len(packet.payload) != inputPacketSize {
+		return
+	}
+
+	sequence := binary.LittleEndian.Uint32(packet.payload)
+	buttons := packet.payload[4]
+
+	// Stale or reordered inputs, unknown buttons and floods are rejected
+	if sequence <= client.last_input_sequence || buttons&^inputButtonsMask != 0 {
+		return
+	}
+	client.inputs_this_tick++
+	if client.inputs_this_tick > serverMaxInputsPerTick {
+		return
+	}
+
+	client.last_input_sequence = sequence
+	client.buttons = buttons
+
+	acked_tick := binary.LittleEndian.Uint32(packet.payload[5:])
+	if acked_tick <= g_Server.tick && acked_tick > client.acked_tick {
+		client.acked_tick = acked_tick
+	}
+}
+
+func apply_input_buttons(player *Player, buttons uint8) {
+	if buttons&INPUT_BUTTON_LEFT != 0 {
+		player_move_left(player)
+	}
+	if buttons&INPUT_BUTTON_RIGHT != 0 {
+		player_move_right(player)
+	}
+	if buttons&INPUT_BUTTON_JUMP != 0 {
+		player_jump(player)
+	}
+}
+
+func server_tick(dt float32) {
+	net_poll()
+	step_reliable()
+
+	now := time.Now()
+	for key, client := range g_Server.clients {
+		if peer, ok := g_Net.peers[key]; !ok || now.Sub(peer.last_heard) > serverClientTimeout {
+			log.Printf("server: %s timed out", client.name)
+			server_remove_client(key)
+		}
+	}
+
+	server_step_world(dt)
+	for _, client := range g_Server.clients {
+		apply_input_buttons(&client.player, client.buttons)
+		server_step_client(client, dt)
+		server_check_client(client)
+
+		client.inputs_this_tick = 0
+	}
+
+	g_Server.tick++
+	server_broadcast_snapshot()
+	step_spectator_server(float64(g_Server.tick) / serverTickRate)
+
+	step_lan_discovery(len(g_Server.clients))
+}
+
+// Same movement as step_player but the position integrates in float64, so
+// long sessions don't drift from float32 rounding
+func server_step_client(client *ServerClient, dt float32) {
+	player := &client.player
+	// Anything that moved the player directly (spawns, collision) wins over the float64 copy
+	if player.pos != vector_2df_from_vec2(client.position) {
+		client.position = player.pos.to_vec2_64()
+	}
+	velocity := player.vel.add(player.accel.mul_scalar(dt))
+
+	step_player(player, dt)
+	client.position = client.position.Add(velocity.to_vec2_64().Scale(float64(dt)))
+	player.pos = vector_2df_from_vec2(client.position)
+	update_player_bounding_box(player)
+
+	collide_player_with_map(player)
+}
+
+// Moving platforms, enemies, projectiles and bosses, which react to the nearest client
+func server_step_world(dt float32) {
+	g_Map.angle += dt
+	step_dynamic_entities(dt)
+	step_spawners(dt)
+	step_bosses(dt)
+}
+
+// The per player part of step_map: hazards, the kill plane and checkpoints
+func server_check_client(client *ServerClient) {
+	player := &client.player
+	touch_dynamic_entities(player)
+
+	if player.pos.y < g_Map.bounds.min_corner().y-mapFallDeathDepth {
+		kill_player(player)
+	}
+
+	if next := client.reached_checkpoints; next < len(g_Map.checkpoints) && player.pos.x >= g_Map.checkpoints[next] {
+		client.reached_checkpoints++
+		emit_event(GameEvent{kind: EVENT_CHECKPOINT_REACHED, pos: player.pos, magnitude: float32(client.reached_checkpoints)})
+	}
+}
+
+func server_broadcast_snapshot() {
+	snapshot := &QuantizedSnapshot{tick: g_Server.tick}
+	for _, client := range g_Server.clients {
+		snapshot.players = append(snapshot.players, quantize_player(client.id, client.name, &client.player))
+	}
+	g_Server.history.store(snapshot)
+
+	for key, client := range g_Server.clients {
+		peer, ok := g_Net.peers[key]
+		if !ok {
+			continue
+		}
+
+		// Falls back to a full snapshot when the acked one is too old to be in the history
+		base := g_Server.history.find(client.acked_tick)
+		net_send(peer.addr, PACKET_SNAPSHOT, encode_snapshot(snapshot, base))
+	}
+}
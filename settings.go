@@ -0,0 +1,101 @@
+package main
+
+type Settings struct {
+	player_name string
+	lobby_url   string
+
+	haptics_enabled   bool
+	haptics_intensity float32
+
+	touch_controls_enabled bool
+
+	// Directory names under mods/, every mod not listed here is mounted
+	mods_disabled map[string]bool
+
+	speedrun_timer_enabled bool
+
+	fullscreen bool
+	// Index into the connected monitors, for fullscreen
+	monitor int
+	// Nearest filtering on world textures, for crisp pixel art
+	pixel_mode bool
+	// Overlay pixels per UI unit, 0 picks one from the window height and monitor
+	ui_scale float32
+	// Per level LUTs
+	color_grading_enabled bool
+	// Scanlines and screen curvature post effect
+	crt_enabled bool
+	// Water mirrors the scene, at the cost of drawing it twice
+	reflections_enabled bool
+	// Daltonization filter, simulate shows the deficiency instead of compensating it
+	colorblind_mode     ColorblindMode
+	colorblind_simulate bool
+	// Outlines telling hazards and pickups apart by shape
+	high_contrast bool
+	// Multiplies every text size, menus and HUD layouts grow with it
+	text_scale float32
+	// Holds the camera still relative to the player and stops pulsing effects
+	reduced_motion bool
+	// Display adjustments, brightness is added and gamma brightens above 1
+	brightness float32
+	contrast   float32
+	gamma      float32
+
+	// Indexed by AudioBus, the master volume scales every other bus
+	audio_volumes            [AUDIO_BUS_COUNT]float32
+	audio_mute_on_focus_loss bool
+
+	// While the window is unfocused or minimized, 0 frame rate doesn't throttle
+	pause_on_focus_loss  bool
+	unfocused_frame_rate int
+
+	// Rectangle around the screen center, in world units, the player can move in without the camera following
+	camera_dead_zone_enabled   bool
+	camera_dead_zone_half_size Vector2DF
+
+	// Exponential smoothing rates (1/s) of the camera position and zoom
+	camera_stiffness      float32
+	camera_zoom_stiffness float32
+
+	// The camera leads the player by velocity * look_ahead_time, its length clamped to look_ahead_max
+	camera_look_ahead_enabled   bool
+	camera_look_ahead_time      float32
+	camera_look_ahead_max       float32
+	camera_look_ahead_smoothing float32
+
+	// Simulation freeze on heavy hits, in seconds, 0 disables it
+	hit_stop_duration float32
+}
+
+var g_Settings = Settings{
+	player_name: "player",
+	lobby_url:   "http://localhost:27900",
+
+	haptics_enabled:   true,
+	haptics_intensity: 1.0,
+
+	mods_disabled: map[string]bool{},
+
+	audio_volumes:            [AUDIO_BUS_COUNT]float32{1.0, 0.7, 1.0, 1.0},
+	audio_mute_on_focus_loss: true,
+
+	pause_on_focus_loss:  true,
+	unfocused_frame_rate: 10,
+
+	camera_dead_zone_enabled:   true,
+	camera_dead_zone_half_size: Vector2DF{3.0, 2.0},
+
+	camera_stiffness:      3.0,
+	camera_zoom_stiffness: 2.0,
+
+	camera_look_ahead_enabled:   true,
+	camera_look_ahead_time:      0.4,
+	camera_look_ahead_max:       6.0,
+	camera_look_ahead_smoothing: 2.0,
+
+	hit_stop_duration: 0.06,
+
+	text_scale: 1,
+	contrast:   1,
+	gamma:      1,
+}
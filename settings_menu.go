@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Opened from the start screen, changes apply right away and are written to the
+// settings file when the menu is closed
+type SettingsMenu struct {
+	open   bool
+	window *glfw.Window
+	// Where the window was before going fullscreen
+	windowed_x int
+	windowed_y int
+
+	panel *Widget
+	menu  *Menu
+
+	// Values as last written to the settings file
+	written     map[string]string
+	check_timer float32
+}
+
+var g_SettingsMenu = SettingsMenu{}
+
+const settingsMenuWidth = 360
+const settingsCheckInterval = 1.0
+
+func init_settings_menu(window *glfw.Window) {
+	g_SettingsMenu.window = window
+	g_SettingsMenu.written = settings_values()
+
+	panel := add_widget(nil, new_widget(uiCenter, uiCenter, Vector2DF{}, Vector2DF{settingsMenuWidth, 0}))
+	panel.draw = draw_widget_nine_slice(g_WidgetTheme.panel)
+	panel.update = func(widget *Widget) {
+		widget.hidden = !g_SettingsMenu.open
+		widget.size.y = g_SettingsMenu.menu.widget.size.y + 32 + text_line_height(1) + 8
+	}
+	g_SettingsMenu.panel = panel
+
+	title := new_label("Settings", 1, color.RGBA{255, 255, 255, 255})
+	title.widget.offset = Vector2DF{16, 16}
+	add_widget(panel, title.widget)
+
+	menu := new_menu(INPUT_CONTEXT_UI, 6)
+	menu.widget.offset = Vector2DF{16, 16 + text_line_height(1) + 8}
+	menu.on_cancel = close_settings_menu
+	add_widget(panel, menu.widget)
+	g_SettingsMenu.menu = menu
+
+	width := float32(settingsMenuWidth - 32)
+	for bus, name := range audioBusNames[:AUDIO_BUS_UI] {
+		bus := AudioBus(bus)
+		add_menu_button(menu, new_slider("Volume "+name, width, 0, 1, 0.1,
+			func() float32 { return g_Settings.audio_volumes[bus] },
+			func(value float32) { set_audio_bus_volume(bus, value) }))
+	}
+	stiffness := add_menu_button(menu, new_slider("Camera stiffness", width, 0.5, 10, 0.5,
+		func() float32 { return g_Settings.camera_stiffness },
+		func(value float32) { g_Settings.camera_stiffness = value }))
+	stiffness.widget.tooltip = "How quickly the camera catches up with the player"
+	vsync := add_menu_button(menu, new_toggle("VSync", width,
+		func() bool { return g_VSync },
+		set_vsync))
+	vsync.widget.tooltip = "Waits for the display refresh, avoids tearing"
+	resolutions := settings_resolutions()
+	add_menu_button(menu, new_choice("Resolution", width, resolutions,
+		func() int {
+			return slices.Index(resolutions, fmt.Sprintf("%dx%d", int(g_WindowWidth), int(g_WindowHeight)))
+		},
+		func(index int) {
+			width, height := 0, 0
+			fmt.Sscanf(resolutions[index], "%dx%d", &width, &height)
+			set_resolution(g_SettingsMenu.window, width, height)
+		}))
+	if monitors := glfw.GetMonitors(); len(monitors) > 1 {
+		names := []string{}
+		for i, monitor := range monitors {
+			names = append(names, fmt.Sprintf("%d %s", i+1, monitor.GetName()))
+		}
+		display := add_menu_button(menu, new_choice("Display", width, names,
+			func() int { return min(max(g_Settings.monitor, 0), len(names)-1) },
+			func(index int) { set_monitor(g_SettingsMenu.window, index) }))
+		display.widget.tooltip = "Monitor used in fullscreen"
+	}
+	add_menu_button(menu, new_toggle("Fullscreen", width,
+		func() bool { return g_Settings.fullscreen },
+		func(enabled bool) { set_fullscreen(g_SettingsMenu.window, enabled) }))
+	pixel_mode := add_menu_button(menu, new_toggle("Pixel mode", width,
+		func() bool { return g_Settings.pixel_mode },
+		set_pixel_mode))
+	pixel_mode.widget.tooltip = "Nearest neighbour texture filtering"
+	colorblind := add_menu_button(menu, new_choice("Colorblind filter", width, colorblindModeNames[:],
+		func() int { return int(g_Settings.colorblind_mode) },
+		func(mode int) { g_Settings.colorblind_mode = ColorblindMode(mode) }))
+	colorblind.widget.tooltip = "Shifts colors that are hard to tell apart into ones that aren't"
+	high_contrast := add_menu_button(menu, new_toggle("High contrast", width,
+		func() bool { return g_Settings.high_contrast },
+		func(enabled bool) { g_Settings.high_contrast = enabled }))
+	high_contrast.widget.tooltip = "Outlines hazards with a crossed box and pickups with a ring"
+	add_menu_button(menu, new_slider("Text size", width, 1, 2, 0.25,
+		func() float32 { return g_Settings.text_scale },
+		func(value float32) { g_Settings.text_scale = value }))
+	reduced_motion := add_menu_button(menu, new_toggle("Reduced motion", width,
+		func() bool { return g_Settings.reduced_motion },
+		func(enabled bool) { g_Settings.reduced_motion = enabled }))
+	reduced_motion.widget.tooltip = "Keeps the camera on the player and stops screen shake and pulsing effects"
+	crt := add_menu_button(menu, new_toggle("CRT effect", width,
+		func() bool { return g_Settings.crt_enabled },
+		func(enabled bool) { g_Settings.crt_enabled = enabled }))
+	crt.widget.tooltip = "Scanlines and a curved screen, like an old monitor"
+	reflections := add_menu_button(menu, new_toggle("Reflections", width,
+		func() bool { return g_Settings.reflections_enabled },
+		func(enabled bool) { g_Settings.reflections_enabled = enabled }))
+	reflections.widget.tooltip = "Water mirrors the world above it, turn off if the frame rate drops near water"
+	add_menu_button(menu, new_slider("Brightness", width, -0.5, 0.5, 0.05,
+		func() float32 { return g_Settings.brightness },
+		func(value float32) { g_Settings.brightness = value }))
+	add_menu_button(menu, new_slider("Contrast", width, 0.5, 1.5, 0.05,
+		func() float32 { return g_Settings.contrast },
+		func(value float32) { g_Settings.contrast = value }))
+	gamma := add_menu_button(menu, new_slider("Gamma", width, 0.5, 2.5, 0.1,
+		func() float32 { return g_Settings.gamma },
+		func(value float32) { g_Settings.gamma = value }))
+	gamma.widget.tooltip = "Raise it when dark areas are hard to make out"
+	ui_scale := add_menu_button(menu, new_slider("UI scale", width, 0, 3, 0.25,
+		func() float32 { return g_Settings.ui_scale },
+		func(value float32) { g_Settings.ui_scale = value }))
+	ui_scale.widget.tooltip = "0 picks a size from the window and monitor"
+	focus_pause := add_menu_button(menu, new_toggle("Pause when unfocused", width,
+		func() bool { return g_Settings.pause_on_focus_loss },
+		func(enabled bool) { g_Settings.pause_on_focus_loss = enabled }))
+	focus_pause.widget.tooltip = "Online games keep going regardless"
+	add_menu_button(menu, new_toggle("Mute when unfocused", width,
+		func() bool { return g_Settings.audio_mute_on_focus_loss },
+		func(enabled bool) { g_Settings.audio_mute_on_focus_loss = enabled }))
+	unfocused_fps := add_menu_button(menu, new_slider("Unfocused FPS", width, 0, 60, 5,
+		func() float32 { return float32(g_Settings.unfocused_frame_rate) },
+		func(value float32) { g_Settings.unfocused_frame_rate = int(value) }))
+	unfocused_fps.widget.tooltip = "Frame rate limit while the window is in the background, 0 for unlimited"
+	add_menu_button(menu, new_button("Back", width, close_settings_menu))
+}
+
+var settingsResolutions = []string{"800x600", "1280x720", "1600x900", "1920x1080", "2560x1440"}
+
+// The common sizes plus the current one, when it was set to something else in the file
+func settings_resolutions() []string {
+	resolutions := slices.Clone(settingsResolutions)
+	if current := fmt.Sprintf("%dx%d", int(g_WindowWidth), int(g_WindowHeight)); !slices.Contains(resolutions, current) {
+		resolutions = append(resolutions, current)
+	}
+	return resolutions
+}
+
+// Shares the start screen's input context, like the level select
+func open_settings_menu() {
+	g_StartScreen.open = false
+	g_SettingsMenu.open = true
+	g_SettingsMenu.menu.focused = 0
+}
+
+func close_settings_menu() {
+	if !g_SettingsMenu.open {
+		return
+	}
+	g_SettingsMenu.open = false
+	g_StartScreen.open = true
+	write_settings()
+}
+
+func set_vsync(enabled bool) {
+	g_VSync = enabled
+	if enabled {
+		glfw.SwapInterval(1)
+	} else {
+		glfw.SwapInterval(0)
+	}
+}
+
+// Keeps the window resolution, so the projection and UI layout don't change
+func set_fullscreen(window *glfw.Window, enabled bool) {
+	width, height := int(g_WindowWidth), int(g_WindowHeight)
+	if enabled && window.GetMonitor() == nil {
+		g_SettingsMenu.windowed_x, g_SettingsMenu.windowed_y = window.GetPos()
+		window.SetMonitor(selected_monitor(), 0, 0, width, height, glfw.DontCare)
+	} else if !enabled && window.GetMonitor() != nil {
+		window.SetMonitor(nil, g_SettingsMenu.windowed_x, g_SettingsMenu.windowed_y, width, height, glfw.DontCare)
+	}
+	g_Settings.fullscreen = enabled
+}
+
+// The window is resized in place, GL resources survive and the projection follows the new size
+func set_resolution(window *glfw.Window, width int, height int) {
+	g_WindowWidth, g_WindowHeight = float32(width), float32(height)
+	if monitor := window.GetMonitor(); monitor != nil {
+		window.SetMonitor(monitor, 0, 0, width, height, glfw.DontCare)
+	} else {
+		window.SetSize(width, height)
+	}
+}
+
+// After the settings were reloaded, e.g. for another profile
+func apply_window_settings(window *glfw.Window) {
+	set_vsync(g_VSync)
+	set_resolution(window, int(g_WindowWidth), int(g_WindowHeight))
+	set_fullscreen(window, g_Settings.fullscreen)
+	set_monitor(window, g_Settings.monitor)
+	set_pixel_mode(g_Settings.pixel_mode)
+}
+
+// Monitors can be unplugged between sessions, a missing one falls back to the primary
+func selected_monitor() *glfw.Monitor {
+	monitors := glfw.GetMonitors()
+	if g_Settings.monitor < 0 || g_Settings.monitor >= len(monitors) {
+		return glfw.GetPrimaryMonitor()
+	}
+	return monitors[g_Settings.monitor]
+}
+
+func set_monitor(window *glfw.Window, index int) {
+	g_Settings.monitor = index
+	if window.GetMonitor() != nil {
+		window.SetMonitor(selected_monitor(), 0, 0, int(g_WindowWidth), int(g_WindowHeight), glfw.DontCare)
+	}
+}
+
+func set_pixel_mode(enabled bool) {
+	g_Settings.pixel_mode = enabled
+	for _, texture := range g_TextureCache {
+		apply_texture_filter(texture)
+	}
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
+// Every setting that can change while the game runs, as stored in the settings file
+func settings_values() map[string]string {
+	format := func(value float32) string { return strconv.FormatFloat(float64(value), 'g', -1, 32) }
+	values := map[string]string{
+		"window":           fmt.Sprintf("%dx%d", int(g_WindowWidth), int(g_WindowHeight)),
+		"monitor":          strconv.Itoa(g_Settings.monitor),
+		"vsync":            strconv.FormatBool(g_VSync),
+		"fullscreen":       strconv.FormatBool(g_Settings.fullscreen),
+		"pixel_mode":       strconv.FormatBool(g_Settings.pixel_mode),
+		"crt":              strconv.FormatBool(g_Settings.crt_enabled),
+		"reflections":      strconv.FormatBool(g_Settings.reflections_enabled),
+		"colorblind":       colorblindModeNames[g_Settings.colorblind_mode],
+		"high_contrast":    strconv.FormatBool(g_Settings.high_contrast),
+		"text_scale":       format(g_Settings.text_scale),
+		"reduced_motion":   strconv.FormatBool(g_Settings.reduced_motion),
+		"brightness":       format(g_Settings.brightness),
+		"contrast":         format(g_Settings.contrast),
+		"gamma":            format(g_Settings.gamma),
+		"camera_stiffness": format(g_Settings.camera_stiffness),
+		"ui_scale":         format(g_Settings.ui_scale),
+		"focus_pause":      strconv.FormatBool(g_Settings.pause_on_focus_loss),
+		"focus_mute":       strconv.FormatBool(g_Settings.audio_mute_on_focus_loss),
+		"unfocused_fps":    strconv.Itoa(g_Settings.unfocused_frame_rate),
+		"mods_disabled":    disabled_mod_list(),
+	}
+	for bus, name := range audioBusNames {
+		values["volume_"+name] = format(g_Settings.audio_volumes[bus])
+	}
+	for _, name := range bindable_action_names() {
+		values["bind_"+name] = action_key_list(bindableActionNames[name])
+	}
+	return values
+}
+
+// Checked every settingsCheckInterval, so changes from the menu or the console are saved shortly after
+func step_settings_persistence(dt float32) {
+	g_SettingsMenu.check_timer -= dt
+	if g_SettingsMenu.check_timer > 0 {
+		return
+	}
+	g_SettingsMenu.check_timer = settingsCheckInterval
+
+	if !maps.Equal(settings_values(), g_SettingsMenu.written) {
+		write_settings()
+	}
+}
+
+// Other lines of the settings file are left alone
+func write_settings() {
+	values := settings_values()
+	g_SettingsMenu.written = values
+
+	keys := []string{}
+	for key, value := range values {
+		g_Config.Set(key, value)
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if err := os.MkdirAll(save_directory(), 0755); err != nil {
+		log.Println("settings:", err)
+		return
+	}
+	if err := g_Config.WriteFile(settings_file_path(), keys); err != nil {
+		log.Println("settings:", err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Colored lines and triangles in world space, queued during the frame and drawn
+// in one batch each by render_shapes, on top of the world
+type Shapes struct {
+	program            uint32
+	projection_uniform int32
+	camera_uniform     int32
+
+	line_vao uint32
+	line_vbo uint32
+	fill_vao uint32
+	fill_vbo uint32
+
+	// X, Y, Z, R, G, B, A per vertex
+	lines []float32
+	fills []float32
+
+	// Boxes, velocities and pickup radii of the physics world
+	physics_debug bool
+}
+
+const shapeVertexFloats = 7
+const shapeCircleSegments = 24
+
+var g_Shapes = Shapes{}
+
+var shapesVertexShader = `
+#version 330
+
+uniform mat4 projection;
+uniform mat4 camera;
+
+in vec3 vert;
+in vec4 vertColor;
+
+out vec4 fragColor;
+
+void main() {
+    fragColor = vertColor;
+    gl_Position = projection * camera * vec4(vert, 1);
+}
+` + "\x00"
+
+var shapesFragmentShader = `
+#version 330
+
+in vec4 fragColor;
+
+out vec4 outputColor;
+
+void main() {
+    outputColor = fragColor;
+}
+` + "\x00"
+
+func init_shapes() {
+	program, err := render.NewProgram(shapesVertexShader, shapesFragmentShader)
+	if err != nil {
+		panic(err)
+	}
+	g_Shapes.program = program
+	g_Shapes.projection_uniform = gl.GetUniformLocation(program, gl.Str("projection\x00"))
+	g_Shapes.camera_uniform = gl.GetUniformLocation(program, gl.Str("camera\x00"))
+
+	g_Shapes.line_vao, g_Shapes.line_vbo = new_shape_buffer(program)
+	g_Shapes.fill_vao, g_Shapes.fill_vbo = new_shape_buffer(program)
+
+	register_command("debug_physics", "toggle drawing collision boxes", func() {
+		g_Shapes.physics_debug = !g_Shapes.physics_debug
+		console_print("debug_physics = %t", g_Shapes.physics_debug)
+	})
+}
+
+func new_shape_buffer(program uint32) (uint32, uint32) {
+	vao, vbo := uint32(0), uint32(0)
+	gl.GenVertexArrays(1, &vao)
+	gl.BindVertexArray(vao)
+	gl.GenBuffers(1, &vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+
+	vert_attrib := uint32(gl.GetAttribLocation(program, gl.Str("vert\x00")))
+	gl.EnableVertexAttribArray(vert_attrib)
+	gl.VertexAttribPointerWithOffset(vert_attrib, 3, gl.FLOAT, false, shapeVertexFloats*4, 0)
+	color_attrib := uint32(gl.GetAttribLocation(program, gl.Str("vertColor\x00")))
+	gl.EnableVertexAttribArray(color_attrib)
+	gl.VertexAttribPointerWithOffset(color_attrib, 4, gl.FLOAT, false, shapeVertexFloats*4, 3*4)
+
+	return vao, vbo
+}
+
+// Premultiplied, like the rest of the blending
+func append_shape_vertex(vertices []float32, pos Vector2DF, c color.RGBA) []float32 {
+	return append(vertices, pos.x, pos.y, 0, float32(c.R)/255, float32(c.G)/255, float32(c.B)/255, float32(c.A)/255)
+}
+
+func draw_line(from Vector2DF, to Vector2DF, c color.RGBA) {
+	g_Shapes.lines = append_shape_vertex(g_Shapes.lines, from, c)
+	g_Shapes.lines = append_shape_vertex(g_Shapes.lines, to, c)
+}
+
+func draw_rect(bb BoundingBox2D, c color.RGBA) {
+	lower, upper := bb.min_corner(), bb.max_corner()
+	corners := [4]Vector2DF{lower, {upper.x, lower.y}, upper, {lower.x, upper.y}}
+	for i := range corners {
+		draw_line(corners[i], corners[(i+1)%4], c)
+	}
+}
+
+func fill_rect(bb BoundingBox2D, c color.RGBA) {
+	lower, upper := bb.min_corner(), bb.max_corner()
+	for _, corner := range []Vector2DF{lower, {upper.x, lower.y}, upper, lower, upper, {lower.x, upper.y}} {
+		g_Shapes.fills = append_shape_vertex(g_Shapes.fills, corner, c)
+	}
+}
+
+func draw_circle(center Vector2DF, radius float32, c color.RGBA) {
+	previous := center.add(Vector2DF{radius, 0})
+	for i := 1; i <= shapeCircleSegments; i++ {
+		angle := float32(i) / shapeCircleSegments * 2 * math.Pi
+		next := center.add(Vector2DF{radius, 0}.rotate(angle))
+		draw_line(previous, next, c)
+		previous = next
+	}
+}
+
+// Head size scales with the arrow but stays readable for short ones
+func draw_arrow(from Vector2DF, to Vector2DF, c color.RGBA) {
+	draw_line(from, to, c)
+
+	direction := to.subtract(from)
+	length := direction.length()
+	if length == 0 {
+		return
+	}
+	head := direction.normalized().mul_scalar(-min(max(length*0.25, 0.2), 0.6))
+	draw_line(to, to.add(head.rotate(math.Pi/6)), c)
+	draw_line(to, to.add(head.rotate(-math.Pi/6)), c)
+}
+
+// Queues the physics debug view, when enabled
+func draw_physics_debug() {
+	if !g_Shapes.physics_debug {
+		return
+	}
+
+	visible := camera_visible_bounds()
+	for _, block := range g_Map.entities {
+		if block.bb.intersects(visible) {
+			draw_rect(block.bb, color.RGBA{0, 200, 255, 255})
+		}
+	}
+	for i := range g_Map.dynamic_entities {
+		entity := &g_Map.dynamic_entities[i]
+		if entity.prefab.Components.Collider == nil {
+			continue
+		}
+		c := color.RGBA{255, 160, 0, 255}
+		if entity.prefab.Components.Hazard != nil {
+			c = color.RGBA{255, 40, 40, 255}
+		}
+		draw_rect(dynamic_entity_bounding_box(entity), c)
+	}
+	for _, collectible := range g_Map.collectibles {
+		if !collectible.collected {
+			draw_rect(collectible.bb, color.RGBA{255, 220, 0, 255})
+		}
+	}
+
+	draw_rect(g_Player.bb, color.RGBA{0, 255, 0, 255})
+	draw_arrow(g_Player.pos, g_Player.pos.add(g_Player.vel.mul_scalar(0.1)), color.RGBA{255, 0, 255, 255})
+}
+
+// Draws and clears everything queued this frame
+func render_shapes(projection mgl32.Mat4) {
+	if len(g_Shapes.lines) == 0 && len(g_Shapes.fills) == 0 {
+		return
+	}
+
+	camera := camera_view_matrix()
+	gl.UseProgram(g_Shapes.program)
+	gl.UniformMatrix4fv(g_Shapes.projection_uniform, 1, false, &projection[0])
+	gl.UniformMatrix4fv(g_Shapes.camera_uniform, 1, false, &camera[0])
+
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+
+	flush_shape_buffer(g_Shapes.fill_vao, g_Shapes.fill_vbo, g_Shapes.fills, gl.TRIANGLES)
+	flush_shape_buffer(g_Shapes.line_vao, g_Shapes.line_vbo, g_Shapes.lines, gl.LINES)
+
+	gl.Disable(gl.BLEND)
+	gl.Enable(gl.DEPTH_TEST)
+
+	g_Shapes.lines = g_Shapes.lines[:0]
+	g_Shapes.fills = g_Shapes.fills[:0]
+}
+
+func flush_shape_buffer(vao uint32, vbo uint32, vertices []float32, mode uint32) {
+	if len(vertices) == 0 {
+		return
+	}
+	gl.BindVertexArray(vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, gl.Ptr(vertices), gl.STREAM_DRAW)
+	gl.DrawArrays(mode, 0, int32(len(vertices)/shapeVertexFloats))
+}
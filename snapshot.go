@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Wire format, little endian:
+//   tick u32, base_tick u32 (0 = full snapshot), player count u8, removed count u8, removed ids u16...
+//   per player: id u16, field mask u8, then the fields present in the mask in bit order
+// Fields are only written when they differ from the base snapshot the client acknowledged.
+
+const snapshotPositionScale = 32 // 1/32 unit precision, +-1024 units range
+const snapshotVelocityScale = 64 // 1/64 unit/s precision, +-512 units/s range
+const snapshotHistorySize = 32
+
+const (
+	SNAPSHOT_FIELD_POSITION = 1 << iota
+	SNAPSHOT_FIELD_VELOCITY
+	SNAPSHOT_FIELD_ANGLE
+	SNAPSHOT_FIELD_STATE
+	SNAPSHOT_FIELD_NAME
+)
+
+type QuantizedPlayer struct {
+	id    uint16
+	name  string
+	x, y  int16
+	vx    int16
+	vy    int16
+	angle uint16
+	state uint8
+}
+
+type QuantizedSnapshot struct {
+	tick    uint32
+	players []QuantizedPlayer
+}
+
+// Ring buffer of recent snapshots, the base for encoding or decoding deltas
+type SnapshotHistory [snapshotHistorySize]*QuantizedSnapshot
+
+func (history *SnapshotHistory) store(snapshot *QuantizedSnapshot) {
+	history[snapshot.tick%snapshotHistorySize] = snapshot
+}
+
+func (history *SnapshotHistory) find(tick uint32) *QuantizedSnapshot {
+	snapshot := history[tick%snapshotHistorySize]
+	if snapshot == nil || snapshot.tick != tick {
+		return nil
+	}
+	return snapshot
+}
+
+func quantize(value float32, scale float32) int16 {
+	return int16(min(max(value*scale, math.MinInt16), math.MaxInt16))
+}
+
+func quantize_angle(angle float32) uint16 {
+	turns := float64(angle) / (2 * math.Pi)
+	turns -= math.Floor(turns)
+	return uint16(turns * 65536)
+}
+
+func dequantize_angle(angle uint16) float32 {
+	return float32(angle) / 65536 * 2 * math.Pi
+}
+
+func quantize_player(id uint16, name string, player *Player) QuantizedPlayer {
+	return QuantizedPlayer{
+		id:    id,
+		name:  name,
+		x:     quantize(player.pos.x, snapshotPositionScale),
+		y:     quantize(player.pos.y, snapshotPositionScale),
+		vx:    quantize(player.vel.x, snapshotVelocityScale),
+		vy:    quantize(player.vel.y, snapshotVelocityScale),
+		angle: quantize_angle(player.angle_z),
+		state: uint8(player.state),
+	}
+}
+
+func (quantized QuantizedPlayer) position() Vector2DF {
+	return Vector2DF{float32(quantized.x) / snapshotPositionScale, float32(quantized.y) / snapshotPositionScale}
+}
+
+func (quantized QuantizedPlayer) velocity() Vector2DF {
+	return Vector2DF{float32(quantized.vx) / snapshotVelocityScale, float32(quantized.vy) / snapshotVelocityScale}
+}
+
+func find_quantized_player(snapshot *QuantizedSnapshot, id uint16) *QuantizedPlayer {
+	if snapshot == nil {
+		return nil
+	}
+	for i := range snapshot.players {
+		if snapshot.players[i].id == id {
+			return &snapshot.players[i]
+		}
+	}
+	return nil
+}
+
+func encode_snapshot(snapshot *QuantizedSnapshot, base *QuantizedSnapshot) []byte {
+	base_tick := uint32(0)
+	if base != nil {
+		base_tick = base.tick
+	}
+
+	removed := []uint16{}
+	if base != nil {
+		for _, old := range base.players {
+			if find_quantized_player(snapshot, old.id) == nil {
+				removed = append(removed, old.id)
+			}
+		}
+	}
+
+	data := []byte{}
+	written := 0
+
+	for _, player := range snapshot.players {
+		mask := byte(SNAPSHOT_FIELD_POSITION | SNAPSHOT_FIELD_VELOCITY | SNAPSHOT_FIELD_ANGLE | SNAPSHOT_FIELD_STATE | SNAPSHOT_FIELD_NAME)
+		if old := find_quantized_player(base, player.id); old != nil {
+			mask = 0
+			if old.x != player.x || old.y != player.y {
+				mask |= SNAPSHOT_FIELD_POSITION
+			}
+			if old.vx != player.vx || old.vy != player.vy {
+				mask |= SNAPSHOT_FIELD_VELOCITY
+			}
+			if old.angle != player.angle {
+				mask |= SNAPSHOT_FIELD_ANGLE
+			}
+			if old.state != player.state {
+				mask |= SNAPSHOT_FIELD_STATE
+			}
+			if old.name != player.name {
+				mask |= SNAPSHOT_FIELD_NAME
+			}
+			if mask == 0 {
+				continue
+			}
+		}
+		written++
+
+		data = binary.LittleEndian.AppendUint16(data, player.id)
+		data = append(data, mask)
+
+		if mask&SNAPSHOT_FIELD_POSITION != 0 {
+			data = binary.LittleEndian.AppendUint16(data, uint16(player.x))
+			data = binary.LittleEndian.AppendUint16(data, uint16(player.y))
+		}
+		if mask&SNAPSHOT_FIELD_VELOCITY != 0 {
+			data = binary.LittleEndian.AppendUint16(data, uint16(player.vx))
+			data = binary.LittleEndian.AppendUint16(data, uint16(player.vy))
+		}
+		if mask&SNAPSHOT_FIELD_ANGLE != 0 {
+			data = binary.LittleEndian.AppendUint16(data, player.angle)
+		}
+		if mask&SNAPSHOT_FIELD_STATE != 0 {
+			data = append(data, player.state)
+		}
+		if mask&SNAPSHOT_FIELD_NAME != 0 {
+			name := player.name[:min(len(player.name), 255)]
+			data = append(data, byte(len(name)))
+			data = append(data, name...)
+		}
+	}
+
+	header := binary.LittleEndian.AppendUint32(nil, snapshot.tick)
+	header = binary.LittleEndian.AppendUint32(header, base_tick)
+	header = append(header, byte(written), byte(len(removed)))
+	for _, id := range removed {
+		header = binary.LittleEndian.AppendUint16(header, id)
+	}
+
+	return append(header, data...)
+}
+
+var errSnapshotTruncated = errors.New("truncated snapshot")
+var errSnapshotMissingBase = errors.New("snapshot delta base not in history")
+
+type snapshotReader struct {
+	data []byte
+	err  error
+}
+
+func (reader *snapshotReader) take(n int) []byte {
+	if reader.err != nil || len(reader.data) < n {
+		reader.err = errSnapshotTruncated
+		return make([]byte, n)
+	}
+	bytes := reader.data[:n]
+	reader.data = reader.data[n:]
+	return bytes
+}
+
+func (reader *snapshotReader) u8() uint8   { return reader.take(1)[0] }
+func (reader *snapshotReader) u16() uint16 { return binary.LittleEndian.Uint16(reader.take(2)) }
+func (reader *snapshotReader) u32() uint32 { return binary.LittleEndian.Uint32(reader.take(4)) }
+
+func decode_snapshot(data []byte, history *SnapshotHistory) (*QuantizedSnapshot, error) {
+	reader := snapshotReader{data: data}
+
+	snapshot := &QuantizedSnapshot{tick: reader.u32()}
+	base_tick := reader.u32()
+	player_count := int(reader.u8())
+	removed_count := int(reader.u8())
+
+	var base *QuantizedSnapshot
+	if base_tick != 0 {
+		base = history.find(base_tick)
+		if base == nil {
+			return nil, errSnapshotMissingBase
+		}
+	}
+
+	removed := map[uint16]bool{}
+	for i := 0; i < removed_count; i++ {
+		removed[reader.u16()] = true
+	}
+
+	for i := 0; i < player_count; i++ {
+		id := reader.u16()
+		mask := reader.u8()
+
+		player := QuantizedPlayer{id: id}
+		if old := find_quantized_player(base, id); old != nil {
+			player = *old
+		}
+
+		if mask&SNAPSHOT_FIELD_POSITION != 0 {
+			player.x = int16(reader.u16())
+			player.y = int16(reader.u16())
+		}
+		if mask&SNAPSHOT_FIELD_VELOCITY != 0 {
+			player.vx = int16(reader.u16())
+			player.vy = int16(reader.u16())
+		}
+		if mask&SNAPSHOT_FIELD_ANGLE != 0 {
+			player.angle = reader.u16()
+		}
+		if mask&SNAPSHOT_FIELD_STATE != 0 {
+			player.state = reader.u8()
+		}
+		if mask&SNAPSHOT_FIELD_NAME != 0 {
+			player.name = string(reader.take(int(reader.u8())))
+		}
+
+		snapshot.players = append(snapshot.players, player)
+	}
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	// Players only present in the base were unchanged, not left out
+	if base != nil {
+		for _, old := range base.players {
+			if !removed[old.id] && find_quantized_player(snapshot, old.id) == nil {
+				snapshot.players = append(snapshot.players, old)
+			}
+		}
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math"
+)
+
+// Short sounds are decoded fully into memory and shared by every voice playing them
+type SoundBuffer struct {
+	frames []float32
+	rate   int
+}
+
+type MemoryStream struct {
+	buffer   *SoundBuffer
+	position int
+}
+
+func (stream *MemoryStream) read(frames []float32) (int, error) {
+	n := copy(frames, stream.buffer.frames[stream.position*2:]) / 2
+	stream.position += n
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (stream *MemoryStream) sample_rate() int {
+	return stream.buffer.rate
+}
+
+func (stream *MemoryStream) rewind() error {
+	stream.position = 0
+	return nil
+}
+
+func (stream *MemoryStream) close() {}
+
+var g_SoundBuffers = map[string]*SoundBuffer{}
+
+func load_sound(name string) (*SoundBuffer, error) {
+	if buffer, ok := g_SoundBuffers[name]; ok {
+		return buffer, nil
+	}
+
+	stream, err := open_audio_stream(asset_path(name))
+	if err != nil {
+		return nil, err
+	}
+	defer stream.close()
+
+	buffer := &SoundBuffer{rate: stream.sample_rate()}
+	chunk := make([]float32, audioBufferFrames*2)
+	for {
+		n, err := stream.read(chunk)
+		buffer.frames = append(buffer.frames, chunk[:n*2]...)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	g_SoundBuffers[name] = buffer
+	return buffer, nil
+}
+
+func play_sound(name string, gain float32) *Voice {
+	return play_sound_on_bus(AUDIO_BUS_SFX, name, gain)
+}
+
+func play_ui_sound(name string) *Voice {
+	return play_sound_on_bus(AUDIO_BUS_UI, name, 1)
+}
+
+func play_sound_on_bus(bus AudioBus, name string, gain float32) *Voice {
+	buffer, err := load_sound(name)
+	if err != nil {
+		log.Println("sound:", err)
+		return nil
+	}
+
+	voice := new_voice(bus, &MemoryStream{buffer: buffer}, gain, false)
+	mixer_add_voice(voice)
+
+	return voice
+}
+
+// The emitter is only evaluated on the main thread, in update_audio_listener
+func play_sound_following(name string, gain float32, emitter func() Vector2DF) *Voice {
+	buffer, err := load_sound(name)
+	if err != nil {
+		log.Println("sound:", err)
+		return nil
+	}
+
+	voice := new_voice(AUDIO_BUS_SFX, &MemoryStream{buffer: buffer}, gain, false)
+	voice.emitter = emitter
+	voice.left_gain, voice.right_gain = positional_gains(emitter())
+
+	mixer_add_voice(voice)
+
+	return voice
+}
+
+func play_sound_at(name string, gain float32, pos Vector2DF) *Voice {
+	return play_sound_following(name, gain, func() Vector2DF { return pos })
+}
+
+const audioReferenceDistance = float32(8)
+const audioMaxDistance = float32(60)
+
+// Equal power panning by horizontal offset from the listener plus distance attenuation
+func positional_gains(pos Vector2DF) (float32, float32) {
+	offset := pos.subtract(g_Camera.pos2D)
+	distance := offset.length()
+
+	if distance >= audioMaxDistance {
+		return 0, 0
+	}
+
+	attenuation := audioReferenceDistance / max(distance, audioReferenceDistance)
+	// Fade to silence over the last quarter instead of cutting off at the max distance
+	attenuation *= min((audioMaxDistance-distance)/(audioMaxDistance*0.25), 1)
+
+	pan := min(max(offset.x/camera_visible_half_extents().x, -1), 1)
+	angle := float64(pan+1) * math.Pi / 4
+
+	return float32(math.Cos(angle)*math.Sqrt2) * attenuation, float32(math.Sin(angle)*math.Sqrt2) * attenuation
+}
+
+// The listener is the camera, call once per frame after it moved
+func update_audio_listener() {
+	g_Mixer.mutex.Lock()
+	defer g_Mixer.mutex.Unlock()
+
+	for _, voice := range g_Mixer.voices {
+		if voice.emitter != nil {
+			voice.left_gain, voice.right_gain = positional_gains(voice.emitter())
+		}
+	}
+}
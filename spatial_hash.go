@@ -0,0 +1,48 @@
+package main
+
+import "math"
+
+// Buckets points into square cells so finding those near a position only looks at a few cells
+// instead of every point
+type SpatialHash struct {
+	cell_size float32
+	cells     map[SpatialCell][]int
+}
+
+type SpatialCell struct {
+	x, y int
+}
+
+func new_spatial_hash(cell_size float32) SpatialHash {
+	return SpatialHash{cell_size: cell_size, cells: map[SpatialCell][]int{}}
+}
+
+func (hash *SpatialHash) cell(pos Vector2DF) SpatialCell {
+	return SpatialCell{int(math.Floor(float64(pos.x / hash.cell_size))), int(math.Floor(float64(pos.y / hash.cell_size)))}
+}
+
+// Keeps the allocated buckets around for the next fill
+func (hash *SpatialHash) clear() {
+	for cell, ids := range hash.cells {
+		hash.cells[cell] = ids[:0]
+	}
+}
+
+func (hash *SpatialHash) insert(id int, pos Vector2DF) {
+	cell := hash.cell(pos)
+	hash.cells[cell] = append(hash.cells[cell], id)
+}
+
+// Calls visit with every id in the cells overlapping the circle, which can be a bit farther away
+// than radius
+func (hash *SpatialHash) query(pos Vector2DF, radius float32, visit func(id int)) {
+	from := hash.cell(pos.subtract(Vector2DF{radius, radius}))
+	to := hash.cell(pos.add(Vector2DF{radius, radius}))
+	for y := from.y; y <= to.y; y++ {
+		for x := from.x; x <= to.x; x++ {
+			for _, id := range hash.cells[SpatialCell{x, y}] {
+				visit(id)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+// Sends enemies at the player in waves, for arena style fights. Each wave waits for its trigger,
+// then its delay, then spawns its enemies one interval apart. A wave is cleared once all of its
+// enemies have been spawned and defeated.
+type SpawnerComponent struct {
+	// The first wave is triggered once the player comes this close, 0 triggers it right away
+	Radius float32 `json:"radius"`
+	// Enemies appear up to this far to either side of the spawner
+	Spread float32          `json:"spread"`
+	Waves  []WaveDefinition `json:"waves"`
+}
+
+type WaveDefinition struct {
+	Enemies []WaveEnemy `json:"enemies"`
+	// Seconds between two enemies of the wave
+	Interval float32 `json:"interval"`
+	// Seconds between the trigger and the first enemy
+	Delay float32 `json:"delay"`
+	// When the previous wave is cleared (the default) or once it's done spawning, waveTriggerCleared
+	// or waveTriggerTimer
+	Trigger string `json:"trigger"`
+}
+
+// Spawned in order, count of one before the next
+type WaveEnemy struct {
+	Prefab string `json:"prefab"`
+	Count  int    `json:"count"`
+}
+
+const waveTriggerCleared = "cleared"
+const waveTriggerTimer = "timer"
+
+type SpawnerState struct {
+	active bool
+
+	// Wave being spawned, len(waves) once every wave has been
+	wave      int
+	triggered bool
+	// Seconds since the wave was triggered
+	clock   float32
+	spawned int
+
+	// Entity indices of the enemies of every wave started so far
+	enemies [][]int
+	cleared []bool
+}
+
+func new_spawner_state(spawner *SpawnerComponent) *SpawnerState {
+	return &SpawnerState{enemies: make([][]int, len(spawner.Waves)), cleared: make([]bool, len(spawner.Waves))}
+}
+
+func (wave *WaveDefinition) size() int {
+	size := 0
+	for _, enemy := range wave.Enemies {
+		size += max(enemy.Count, 0)
+	}
+	return size
+}
+
+// Prefab of the i-th enemy of the wave
+func (wave *WaveDefinition) enemy(i int) string {
+	for _, enemy := range wave.Enemies {
+		if i < enemy.Count {
+			return enemy.Prefab
+		}
+		i -= max(enemy.Count, 0)
+	}
+	return ""
+}
+
+// Spawned enemies are appended to the entities, so spawners are stepped by index
+func step_spawners(dt float32) {
+	for i := range g_Map.dynamic_entities {
+		if g_Map.dynamic_entities[i].spawner != nil {
+			step_spawner(i, dt)
+		}
+	}
+}
+
+func step_spawner(index int, dt float32) {
+	entity := &g_Map.dynamic_entities[index]
+	spawner, state := entity.prefab.Components.Spawner, entity.spawner
+	origin := entity.world_pos
+
+	if !state.active {
+		if spawner.Radius > 0 && origin.distance(nearest_player(origin).pos) > spawner.Radius {
+			return
+		}
+		state.active = true
+	}
+
+	for wave := 0; wave < state.wave; wave++ {
+		if !state.cleared[wave] && enemies_defeated(state.enemies[wave]) {
+			state.cleared[wave] = true
+			emit_event(GameEvent{kind: EVENT_WAVE_CLEARED, pos: origin, magnitude: float32(wave + 1)})
+		}
+	}
+
+	if state.wave >= len(spawner.Waves) {
+		return
+	}
+	definition := &spawner.Waves[state.wave]
+
+	if !state.triggered {
+		if state.wave > 0 && definition.Trigger != waveTriggerTimer && !state.cleared[state.wave-1] {
+			return
+		}
+		state.triggered = true
+		state.clock = 0
+	}
+	state.clock += dt
+
+	size := definition.size()
+	for state.spawned < size && state.clock >= definition.Delay+float32(state.spawned)*definition.Interval {
+		if state.spawned == 0 {
+			emit_event(GameEvent{kind: EVENT_WAVE_STARTED, pos: origin, magnitude: float32(state.wave + 1)})
+		}
+
+		// Left, right, middle and around again
+		offset := Vector2DF{spawner.Spread * float32(state.spawned%3-1), 0}
+		enemy := len(g_Map.dynamic_entities)
+		if spawn_prefab(definition.enemy(state.spawned), origin.add(offset)) {
+			state.enemies[state.wave] = append(state.enemies[state.wave], enemy)
+		}
+		state.spawned++
+	}
+
+	if state.spawned >= size {
+		state.wave++
+		state.triggered = false
+		state.spawned = 0
+	}
+}
+
+func enemies_defeated(enemies []int) bool {
+	for _, index := range enemies {
+		if !g_Map.dynamic_entities[index].picked {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type SpectatorEntity struct {
+	X     float32 `json:"x"`
+	Y     float32 `json:"y"`
+	Angle float32 `json:"angle,omitempty"`
+}
+
+type SpectatorMessage struct {
+	Kind     string            `json:"kind"`
+	Time     float64           `json:"time"`
+	Players  []SpectatorEntity `json:"players,omitempty"`
+	Entities []SpectatorEntity `json:"entities,omitempty"`
+	Blocks   []SpectatorEntity `json:"blocks,omitempty"`
+}
+
+type spectatorClient struct {
+	ws   *WebSocketConn
+	send chan []byte
+}
+
+// Streams world snapshots to view-only websocket clients (see spectatorPage)
+type SpectatorServer struct {
+	server *http.Server
+
+	mutex   sync.Mutex
+	clients map[*spectatorClient]bool
+
+	map_message    []byte
+	last_broadcast time.Time
+}
+
+var g_Spectators = SpectatorServer{clients: map[*spectatorClient]bool{}}
+
+const spectatorDefaultPort = 27961
+const spectatorSnapshotInterval = 50 * time.Millisecond
+
+func init_spectator() {
+	register_command("spectator_start", "[port]: serve a web page streaming the game", func(args []string) {
+		port := spectatorDefaultPort
+		if len(args) > 0 {
+			parsed, err := strconv.Atoi(args[0])
+			if err != nil {
+				console_print("invalid port %q", args[0])
+				return
+			}
+			port = parsed
+		}
+
+		if err := start_spectator_server(port); err != nil {
+			console_print("spectator: %v", err)
+			return
+		}
+		console_print("spectator: open http://localhost:%d to watch", port)
+	})
+
+	register_command("spectator_stop", "stop the spectator server", func() {
+		stop_spectator_server()
+	})
+}
+
+func start_spectator_server(port int) error {
+	if g_Spectators.server != nil {
+		return errors.New("spectator server already running")
+	}
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return err
+	}
+
+	g_Spectators.map_message = spectator_map_message()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(spectatorPage))
+	})
+	mux.HandleFunc("/ws", spectator_handle_websocket)
+
+	g_Spectators.server = &http.Server{Handler: mux}
+	go g_Spectators.server.Serve(listener)
+
+	return nil
+}
+
+func stop_spectator_server() {
+	if g_Spectators.server == nil {
+		return
+	}
+
+	g_Spectators.server.Close()
+	g_Spectators.server = nil
+
+	g_Spectators.mutex.Lock()
+	for client := range g_Spectators.clients {
+		client.ws.close()
+	}
+	g_Spectators.mutex.Unlock()
+}
+
+func spectator_handle_websocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := websocket_upgrade(w, r)
+	if err != nil {
+		return
+	}
+
+	client := &spectatorClient{ws, make(chan []byte, 8)}
+
+	g_Spectators.mutex.Lock()
+	g_Spectators.clients[client] = true
+	map_message := g_Spectators.map_message
+	g_Spectators.mutex.Unlock()
+
+	client.send <- map_message
+
+	go func() {
+		for message := range client.send {
+			if ws.write_text(message) != nil {
+				ws.close()
+				return
+			}
+		}
+	}()
+
+	// Spectators are view only, anything they send besides control frames is ignored
+	for {
+		if _, _, err := ws.read_frame(); err != nil {
+			break
+		}
+	}
+
+	g_Spectators.mutex.Lock()
+	delete(g_Spectators.clients, client)
+	g_Spectators.mutex.Unlock()
+
+	close(client.send)
+	ws.close()
+}
+
+func spectator_map_message() []byte {
+	message := SpectatorMessage{Kind: "map"}
+	for _, entity := range g_Map.entities {
+		message.Blocks = append(message.Blocks, SpectatorEntity{X: entity.pos.x, Y: entity.pos.y})
+	}
+
+	data, _ := json.Marshal(message)
+	return data
+}
+
+// Called whenever g_Map is replaced, spectators already watching get the new blocks too
+func spectator_map_changed() {
+	if g_Spectators.server == nil {
+		return
+	}
+	map_message := spectator_map_message()
+
+	g_Spectators.mutex.Lock()
+	defer g_Spectators.mutex.Unlock()
+
+	g_Spectators.map_message = map_message
+	for client := range g_Spectators.clients {
+		select {
+		case client.send <- map_message:
+		default:
+			// Unlike a snapshot the map isn't resent, a spectator too slow for it is dropped
+			client.ws.close()
+		}
+	}
+}
+
+// Every player in the session, the dedicated server has no local one
+func spectator_players() []SpectatorEntity {
+	var players []SpectatorEntity
+	if len(g_Server.clients) > 0 {
+		for _, client := range g_Server.clients {
+			players = append(players, SpectatorEntity{client.player.pos.x, client.player.pos.y, client.player.angle_z})
+		}
+		return players
+	}
+
+	players = append(players, SpectatorEntity{g_Player.pos.x, g_Player.pos.y, g_Player.angle_z})
+	for _, remote := range g_NetGame.remote_players {
+		players = append(players, SpectatorEntity{remote.pos.x, remote.pos.y, remote.angle_z})
+	}
+	return players
+}
+
+// Same entities render_dynamic_entities draws
+func spectator_entities() []SpectatorEntity {
+	var entities []SpectatorEntity
+	for _, entity := range g_Map.dynamic_entities {
+		if entity.prefab.Components.Render == nil || entity.picked {
+			continue
+		}
+		entities = append(entities, SpectatorEntity{X: entity.world_pos.x, Y: entity.world_pos.y})
+	}
+	return entities
+}
+
+// Called every frame on the main thread, broadcasts at a fixed rate
+func step_spectator_server(time_seconds float64) {
+	if g_Spectators.server == nil || time.Since(g_Spectators.last_broadcast) < spectatorSnapshotInterval {
+		return
+	}
+	g_Spectators.last_broadcast = time.Now()
+
+	message := SpectatorMessage{Kind: "snapshot", Time: time_seconds, Players: spectator_players(), Entities: spectator_entities()}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Println("spectator:", err)
+		return
+	}
+
+	g_Spectators.mutex.Lock()
+	defer g_Spectators.mutex.Unlock()
+
+	for client := range g_Spectators.clients {
+		select {
+		case client.send <- data:
+		default:
+			// Slow spectator, skip this snapshot for them
+		}
+	}
+}
+
+var spectatorPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>Spectating</title>
+<style>body { margin: 0; background: #fff; } canvas { display: block; }</style>
+</head>
+<body>
+<canvas id="view"></canvas>
+<script>
+const canvas = document.getElementById("view");
+const context = canvas.getContext("2d");
+const scale = 20;
+let blocks = [];
+let entities = [];
+let players = [];
+
+function resize() {
+	canvas.width = window.innerWidth;
+	canvas.height = window.innerHeight;
+}
+
+function draw() {
+	context.clearRect(0, 0, canvas.width, canvas.height);
+	const focus = players.length > 0 ? players[0] : { x: 0, y: 0 };
+
+	context.save();
+	context.translate(canvas.width / 2 - focus.x * scale, canvas.height / 2 + focus.y * scale);
+	context.scale(scale, -scale);
+
+	context.fillStyle = "#555";
+	for (const block of blocks) {
+		context.fillRect(block.x - 1, block.y - 1, 2, 2);
+	}
+
+	context.fillStyle = "#39c";
+	for (const entity of entities) {
+		context.fillRect(entity.x - 0.5, entity.y - 0.5, 1, 1);
+	}
+
+	context.fillStyle = "#c33";
+	for (const player of players) {
+		context.save();
+		context.translate(player.x, player.y);
+		context.rotate(player.angle || 0);
+		context.fillRect(-1, -1, 2, 2);
+		context.restore();
+	}
+
+	context.restore();
+	requestAnimationFrame(draw);
+}
+
+const socket = new WebSocket("ws://" + location.host + "/ws");
+socket.onmessage = function (event) {
+	const message = JSON.parse(event.data);
+	if (message.kind === "map") {
+		blocks = message.blocks || [];
+	} else if (message.kind === "snapshot") {
+		players = message.players || [];
+		entities = message.entities || [];
+	}
+};
+
+window.addEventListener("resize", resize);
+resize();
+requestAnimationFrame(draw);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Split times are cumulative in-game seconds since the run started
+type Speedrun struct {
+	started_at time.Time
+	game_time  float32
+	// Wall clock time of a finished run, includes time lost to stutters or pauses
+	real_time float32
+
+	splits   []float32
+	finished bool
+}
+
+var g_Speedrun Speedrun
+
+func init_speedrun() {
+	reset_speedrun()
+
+	subscribe_event(EVENT_LEVEL_COMPLETED, func(event GameEvent) {
+		if !g_Speedrun.finished {
+			g_Speedrun.splits = append(g_Speedrun.splits, g_Speedrun.game_time)
+			finish_speedrun()
+		}
+	})
+	subscribe_event(EVENT_PLAYER_DIED, func(event GameEvent) {
+		// Dying before the first checkpoint restarts the run instead of leaving a ruined attempt running
+		if len(g_Speedrun.splits) == 0 {
+			reset_speedrun()
+		}
+	})
+
+	register_command("speedrun", "toggle the speedrun timer", func() {
+		g_Settings.speedrun_timer_enabled = !g_Settings.speedrun_timer_enabled
+		console_print("speedrun timer: %v", g_Settings.speedrun_timer_enabled)
+	})
+	register_command("speedrun_reset", "restart the speedrun timer", func() {
+		kill_player(&g_Player)
+		reset_speedrun()
+	})
+	register_command("speedrun_export", "write the splits of the current level to a file", func() {
+		if path, err := export_speedrun(); err != nil {
+			console_print("export failed: %v", err)
+		} else {
+			console_print("exported to %s", path)
+		}
+	})
+}
+
+func reset_speedrun() {
+	g_Speedrun = Speedrun{started_at: time.Now()}
+	g_Ghost.recording = nil
+}
+
+// Best splits are stored per map seed since every seed is a different course
+func speedrun_best_key() string {
+	return map_seed_key(g_MapSeed)
+}
+
+func speedrun_checkpoint_count() int {
+	return len(g_Map.checkpoints) + 1
+}
+
+func step_speedrun(dt float32) {
+	if g_Speedrun.finished {
+		return
+	}
+
+	g_Speedrun.game_time += dt
+
+	next := len(g_Speedrun.splits)
+	if next < len(g_Map.checkpoints) && g_Player.pos.x >= g_Map.checkpoints[next] {
+		g_Speedrun.splits = append(g_Speedrun.splits, g_Speedrun.game_time)
+	}
+}
+
+func speedrun_real_time() float32 {
+	if g_Speedrun.finished {
+		return g_Speedrun.real_time
+	}
+	return float32(time.Since(g_Speedrun.started_at).Seconds())
+}
+
+func finish_speedrun() {
+	g_Speedrun.real_time = speedrun_real_time()
+	g_Speedrun.finished = true
+
+	key := speedrun_best_key()
+	best := g_SaveData.BestSplits[key]
+	if len(best) != len(g_Speedrun.splits) {
+		best = make([]float32, len(g_Speedrun.splits))
+	}
+	for i, split := range g_Speedrun.splits {
+		if best[i] == 0 || split < best[i] {
+			best[i] = split
+		}
+	}
+	g_SaveData.BestSplits[key] = best
+	write_save_data()
+	save_ghost(g_Speedrun.game_time)
+
+	if path, err := export_speedrun(); err != nil {
+		log.Println("speedrun:", err)
+	} else {
+		log.Println("speedrun exported to", path)
+	}
+}
+
+// Writes the splits as CSV so they can be imported into split tracking tools or spreadsheets
+func export_speedrun() (string, error) {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "seed,%d\n", g_MapSeed)
+	fmt.Fprintf(&builder, "real_time,%.3f\n", speedrun_real_time())
+	fmt.Fprintf(&builder, "game_time,%.3f\n", g_Speedrun.game_time)
+	builder.WriteString("split,time\n")
+	for i, split := range g_Speedrun.splits {
+		fmt.Fprintf(&builder, "%d,%.3f\n", i+1, split)
+	}
+
+	directory := filepath.Join(save_directory(), "speedruns")
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(directory, time.Now().Format("2006-01-02_15-04-05")+".csv")
+	return path, os.WriteFile(path, []byte(builder.String()), 0644)
+}
+
+func format_speedrun_time(seconds float32) string {
+	minutes := int(seconds) / 60
+	return fmt.Sprintf("%d:%05.2f", minutes, seconds-float32(minutes*60))
+}
+
+func render_speedrun_timer() {
+	if !g_Settings.speedrun_timer_enabled {
+		return
+	}
+
+	line_height := text_line_height(1)
+	size := ui_size()
+	x := size.x - 180
+	y := size.y - 40
+	white := color.RGBA{255, 255, 255, 255}
+
+	draw_text("IGT "+format_speedrun_time(g_Speedrun.game_time), x, y, 1, white)
+	draw_text("RTA "+format_speedrun_time(speedrun_real_time()), x, y+line_height, 1, white)
+
+	best := g_SaveData.BestSplits[speedrun_best_key()]
+	for i, split := range g_Speedrun.splits {
+		split_y := y - line_height*float32(len(g_Speedrun.splits)-i)
+		text := fmt.Sprintf("%d/%d %s", i+1, speedrun_checkpoint_count(), format_speedrun_time(split))
+		draw_text(text, x, split_y, 1, white)
+
+		if i < len(best) && best[i] > 0 {
+			delta := split - best[i]
+			delta_color := color.RGBA{80, 220, 80, 255}
+			if delta > 0 {
+				delta_color = color.RGBA{230, 70, 70, 255}
+			}
+			draw_text(fmt.Sprintf("%+.2f", delta), x+120, split_y, 1, delta_color)
+		}
+	}
+}
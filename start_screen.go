@@ -0,0 +1,123 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+type StartScreen struct {
+	open bool
+
+	panel        *Widget
+	title        *Label
+	menu         *Menu
+	level_button *Button
+	ghost_button *Button
+	ghost_label  *Label
+}
+
+var g_StartScreen StartScreen
+
+const startScreenWidth = 320
+
+// Shown before every run, gameplay input is blocked until the player starts
+func init_start_screen() {
+
+	panel := add_widget(nil, new_widget(uiCenter, uiCenter, Vector2DF{}, Vector2DF{startScreenWidth, 0}))
+	panel.draw = draw_widget_nine_slice(g_WidgetTheme.panel)
+	panel.update = update_start_screen
+	g_StartScreen.panel = panel
+
+	g_StartScreen.title = new_label("", 1, color.RGBA{255, 255, 255, 255})
+	g_StartScreen.title.widget.offset = Vector2DF{16, 16}
+	add_widget(panel, g_StartScreen.title.widget)
+
+	menu := new_menu(INPUT_CONTEXT_UI, 6)
+	menu.widget.offset = Vector2DF{16, 16 + text_line_height(1) + 8}
+	add_widget(panel, menu.widget)
+	button_width := float32(startScreenWidth - 32)
+	add_menu_button(menu, new_button("Start", button_width, close_start_screen))
+	g_StartScreen.level_button = add_menu_button(menu, new_button("Level select (L)", button_width, func() {
+		// The daily challenge is locked to today's level
+		if !g_DailyChallenge.active {
+			open_level_select()
+		}
+	}))
+	add_menu_button(menu, new_button("Join game", button_width, open_join_menu))
+	g_StartScreen.ghost_button = add_menu_button(menu, new_button("", button_width, func() {
+		g_Ghost.enabled = !g_Ghost.enabled
+	}))
+	g_StartScreen.ghost_button.widget.tooltip = "Race against the recording of your best run"
+	add_menu_button(menu, new_button("Settings", button_width, open_settings_menu))
+	profile := add_menu_button(menu, new_button("", button_width, func() { cycle_profile(1) }))
+	profile.on_adjust = cycle_profile
+	profile.widget.update = func(widget *Widget) { profile.text = "Profile: " + g_Profiles.current }
+	profile.widget.tooltip = "Each profile keeps its own progress and settings, add one with the profile command"
+	g_StartScreen.menu = menu
+
+	g_StartScreen.ghost_label = new_label("", 1, color.RGBA{140, 140, 140, 255})
+	add_widget(panel, g_StartScreen.ghost_label.widget)
+
+	// Shortcuts for the menu entries
+	add_key_input_handler(INPUT_CONTEXT_UI, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+		if !g_StartScreen.open || action != glfw.Press {
+			return
+		}
+
+		switch key {
+		case glfw.KeyG:
+			g_Ghost.enabled = !g_Ghost.enabled
+		case glfw.KeyL:
+			g_StartScreen.menu.pending_click = g_StartScreen.level_button
+		}
+	})
+
+	open_start_screen()
+}
+
+func open_start_screen() {
+	g_StartScreen.open = true
+	play_level_music()
+	push_input_context(INPUT_CONTEXT_UI)
+}
+
+func close_start_screen() {
+	g_StartScreen.open = false
+	pop_input_context(INPUT_CONTEXT_UI)
+
+	// The run starts when the player leaves the start screen
+	reset_speedrun()
+	g_DailyChallenge.run_time = 0
+	g_DailyChallenge.deaths = 0
+	g_DailyChallenge.finished = false
+}
+
+// Follows the level, ghost and daily challenge state, the panel grows to fit the menu
+func update_start_screen(panel *Widget) {
+	panel.hidden = !g_StartScreen.open
+	g_StartScreen.title.text = g_Level.Name
+	g_StartScreen.level_button.widget.hidden = g_DailyChallenge.active
+
+	ghost := g_Ghost.best != nil
+	g_StartScreen.ghost_button.widget.hidden = !ghost
+	ghost_state := "off"
+	if g_Ghost.enabled {
+		ghost_state = "on"
+	}
+	g_StartScreen.ghost_button.text = "Race ghost (G): " + ghost_state
+	g_StartScreen.ghost_label.text = "No ghost recorded for this level"
+	if ghost {
+		g_StartScreen.ghost_label.text = "Ghost time " + format_speedrun_time(g_Ghost.best.Duration)
+	}
+
+	menu_height := float32(0)
+	for _, button := range g_StartScreen.menu.buttons {
+		if !button.widget.hidden {
+			menu_height += button.widget.size.y + g_StartScreen.menu.spacing
+		}
+	}
+	menu_bottom := g_StartScreen.menu.widget.offset.y + menu_height
+	g_StartScreen.ghost_label.widget.offset = Vector2DF{16, menu_bottom + 8}
+	panel.size.y = menu_bottom + 8 + text_line_height(1) + 16
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+type PlayerStats struct {
+	DistanceTraveled float64 `json:"distance_traveled"`
+	Jumps            int     `json:"jumps"`
+	Deaths           int     `json:"deaths"`
+	PlaytimeSeconds  float64 `json:"playtime_seconds"`
+}
+
+type StatsTracker struct {
+	screen_open bool
+
+	last_pos        Vector2DF
+	since_last_save float32
+
+	background_texture uint32
+}
+
+var g_Stats StatsTracker
+
+// Playtime and distance change every frame, so they are flushed to disk periodically instead of on every change
+const statsSaveInterval = 60
+
+func init_stats() {
+	g_Stats.background_texture = render.SolidTexture(color.RGBA{20, 20, 20, 200})
+	g_Stats.last_pos = g_Player.pos
+
+	subscribe_event(EVENT_PLAYER_JUMPED, func(event GameEvent) {
+		g_SaveData.Stats.Jumps++
+	})
+	subscribe_event(EVENT_PLAYER_DIED, func(event GameEvent) {
+		g_SaveData.Stats.Deaths++
+	})
+
+	add_key_input_handler(INPUT_CONTEXT_GAMEPLAY, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+		if key == glfw.KeyTab && action == glfw.Press {
+			g_Stats.screen_open = !g_Stats.screen_open
+		}
+	})
+
+	register_command("stats", "print lifetime statistics, Tab toggles the stats screen", func() {
+		for _, line := range stats_lines() {
+			console_print("%s", line)
+		}
+	})
+}
+
+func step_stats(dt float32) {
+	g_SaveData.Stats.PlaytimeSeconds += float64(dt)
+	g_SaveData.Stats.DistanceTraveled += float64(g_Player.pos.subtract(g_Stats.last_pos).length())
+	g_Stats.last_pos = g_Player.pos
+
+	g_Stats.since_last_save += dt
+	if g_Stats.since_last_save >= statsSaveInterval {
+		g_Stats.since_last_save = 0
+		write_save_data()
+	}
+}
+
+func stats_lines() []string {
+	stats := g_SaveData.Stats
+	playtime := time.Duration(stats.PlaytimeSeconds) * time.Second
+
+	return []string{
+		fmt.Sprintf("Distance traveled: %.0f", stats.DistanceTraveled),
+		fmt.Sprintf("Jumps: %d", stats.Jumps),
+		fmt.Sprintf("Deaths: %d", stats.Deaths),
+		fmt.Sprintf("Playtime: %s", playtime),
+	}
+}
+
+func render_stats_screen() {
+	if !g_Stats.screen_open {
+		return
+	}
+
+	const width = float32(300)
+	lines := stats_lines()
+	line_height := text_line_height(1)
+	height := line_height*float32(len(lines)+2) + 16
+	size := ui_size()
+	x := (size.x - width) / 2
+	y := (size.y - height) / 2
+
+	draw_overlay_quad(g_Stats.background_texture, x, y, width, height)
+	draw_text("Statistics", x+12, y+8, 1, color.RGBA{255, 210, 80, 255})
+
+	for i, line := range lines {
+		draw_text(line, x+12, y+8+line_height*float32(i+2), 1, color.RGBA{255, 255, 255, 255})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/noise"
+)
+
+// Moves the entity by its velocity, accelerated by a weighted sum of steering forces. The sum is
+// capped at max_force and the velocity at max_speed, so forces blend into smooth turns.
+type SteeringComponent struct {
+	// World units per second
+	MaxSpeed float32 `json:"max_speed"`
+	// World units per second squared
+	MaxForce float32         `json:"max_force"`
+	Forces   []SteeringForce `json:"forces"`
+}
+
+type SteeringForce struct {
+	// steerSeek, steerFlee, steerArrive, steerWander or steerAvoid
+	Type   string  `json:"type"`
+	Weight float32 `json:"weight"`
+
+	// For seek, flee and arrive, steerTargetPlayer or steerTargetOrigin (the default), plus an offset
+	Target string     `json:"target"`
+	Offset [2]float32 `json:"offset"`
+	// Only applies while the target is this close, 0 for any distance
+	Range float32 `json:"range"`
+
+	// Slowing down distance for arrive, size of the wander circle, look ahead distance for avoid
+	Radius float32 `json:"radius"`
+}
+
+const steerSeek = "seek"
+const steerFlee = "flee"
+const steerArrive = "arrive"
+const steerWander = "wander"
+const steerAvoid = "avoid"
+
+const steerTargetPlayer = "player"
+const steerTargetOrigin = "origin"
+
+// Turns of the wander target per second, at most
+const steerWanderRate = 0.3
+
+// Wander targets come from noise rather than random numbers so runs replay the same
+var g_SteeringNoise = noise.NewSimplex(1)
+
+func step_steering(entity *DynamicEntity, steering *SteeringComponent, dt float32) {
+	force := Vector2DF{}
+	for i := range steering.Forces {
+		force = force.add(steering_force(entity, steering, &steering.Forces[i]).mul_scalar(steering.Forces[i].Weight))
+	}
+	if flock := entity.prefab.Components.Flock; flock != nil {
+		force = force.add(flock_force(entity, steering, flock))
+	}
+	force = force.clamp_length(steering.MaxForce)
+
+	entity.velocity = entity.velocity.add(force.mul_scalar(dt)).clamp_length(steering.MaxSpeed)
+	entity.pos = entity.pos.add(entity.velocity.mul_scalar(dt))
+	// Outside the velocity so steering can't cancel it out within max_speed
+	if wind := entity.prefab.Components.Wind; wind != nil {
+		entity.pos = entity.pos.add(wind_at(entity.pos).mul_scalar(wind.Drag * dt))
+	}
+}
+
+func steering_force(entity *DynamicEntity, steering *SteeringComponent, force *SteeringForce) Vector2DF {
+	target := entity.origin
+	if force.Target == steerTargetPlayer {
+		target = nearest_player(entity.pos).pos
+	}
+	target = target.add(Vector2DF{force.Offset[0], force.Offset[1]})
+	if force.Range > 0 && entity.pos.distance(target) > force.Range {
+		return Vector2DF{}
+	}
+
+	switch force.Type {
+	case steerSeek:
+		return seek(entity, steering, target)
+	case steerFlee:
+		return seek(entity, steering, target).mul_scalar(-1)
+	case steerArrive:
+		return arrive(entity, steering, target, force.Radius)
+	case steerWander:
+		return wander(entity, steering, force.Radius)
+	case steerAvoid:
+		return avoid(entity, steering, force.Radius)
+	}
+	return Vector2DF{}
+}
+
+// Full speed straight at the target
+func seek(entity *DynamicEntity, steering *SteeringComponent, target Vector2DF) Vector2DF {
+	desired := target.subtract(entity.pos).normalized().mul_scalar(steering.MaxSpeed)
+	return desired.subtract(entity.velocity)
+}
+
+// Like seek, slowing down to a stop inside radius
+func arrive(entity *DynamicEntity, steering *SteeringComponent, target Vector2DF, radius float32) Vector2DF {
+	offset := target.subtract(entity.pos)
+	speed := steering.MaxSpeed
+	if distance := offset.length(); distance < radius {
+		speed *= distance / radius
+	}
+	return offset.normalized().mul_scalar(speed).subtract(entity.velocity)
+}
+
+// Seeks a point drifting around a circle ahead of the entity
+func wander(entity *DynamicEntity, steering *SteeringComponent, radius float32) Vector2DF {
+	heading := entity.velocity.normalized()
+	if heading == (Vector2DF{}) {
+		heading = Vector2DF{1, 0}
+	}
+	// Every entity gets its own row of noise
+	angle := g_SteeringNoise.Noise2(float64(entity.age*steerWanderRate), float64(entity.node)) * 2 * math.Pi
+	ahead := entity.pos.add(heading.mul_scalar(radius * 2))
+	return seek(entity, steering, ahead.add(heading.rotate(float32(angle)).mul_scalar(radius)))
+}
+
+// Pushes off the side of whatever is up to radius ahead, harder the closer it is
+func avoid(entity *DynamicEntity, steering *SteeringComponent, radius float32) Vector2DF {
+	if entity.velocity == (Vector2DF{}) || radius <= 0 {
+		return Vector2DF{}
+	}
+	hit, ok := raycast(entity.pos, entity.velocity, radius)
+	if !ok {
+		return Vector2DF{}
+	}
+	return hit.normal.mul_scalar(steering.MaxForce * (1 - hit.distance/radius))
+}
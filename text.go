@@ -0,0 +1,95 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+type TextTexture struct {
+	texture uint32
+	width   int32
+	height  int32
+
+	last_used_frame uint64
+}
+
+type textCacheKey struct {
+	text  string
+	color color.RGBA
+}
+
+// Rasterized strings are cached and dropped once they go unused for a while
+var g_TextCache = map[textCacheKey]*TextTexture{}
+var g_TextFrame = uint64(0)
+
+const textCacheMaxIdleFrames = 120
+
+var textFace = basicfont.Face7x13
+
+// Scales are multiplied by the text_scale setting, layouts measuring with these grow along
+func text_line_height(scale float32) float32 {
+	return float32(textFace.Metrics().Height.Ceil()) * scale * g_Settings.text_scale
+}
+
+func text_width(text string, scale float32) float32 {
+	return float32(font.MeasureString(textFace, text).Ceil()) * scale * g_Settings.text_scale
+}
+
+func get_text_texture(text string, c color.RGBA) *TextTexture {
+	key := textCacheKey{text, c}
+	if cached, ok := g_TextCache[key]; ok {
+		cached.last_used_frame = g_TextFrame
+		return cached
+	}
+
+	metrics := textFace.Metrics()
+	width := max(font.MeasureString(textFace, text).Ceil(), 1)
+	height := metrics.Height.Ceil()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	drawer := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: textFace,
+		Dot:  fixed.P(0, metrics.Ascent.Ceil()),
+	}
+	drawer.DrawString(text)
+
+	texture, _ := render.TextureFromImage(img)
+	// The font is a bitmap, nearest filtering keeps it sharp when the UI scale enlarges it
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+
+	cached := &TextTexture{texture, int32(width), int32(height), g_TextFrame}
+	g_TextCache[key] = cached
+
+	return cached
+}
+
+// Draws text with its top left corner at (x, y) in overlay coordinates
+func draw_text(text string, x float32, y float32, scale float32, c color.RGBA) {
+	if len(text) == 0 {
+		return
+	}
+
+	scale *= g_Settings.text_scale
+	text_texture := get_text_texture(text, c)
+	draw_overlay_quad(text_texture.texture, x, y, float32(text_texture.width)*scale, float32(text_texture.height)*scale)
+}
+
+func end_text_frame() {
+	for key, cached := range g_TextCache {
+		if g_TextFrame-cached.last_used_frame > textCacheMaxIdleFrames {
+			gl.DeleteTextures(1, &cached.texture)
+			delete(g_TextCache, key)
+		}
+	}
+	g_TextFrame++
+}
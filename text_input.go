@@ -0,0 +1,145 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Single line text editing shared by the console and chat boxes
+type TextInput struct {
+	text []rune
+	// Index in text the next character goes before
+	cursor int
+	// Set by Ctrl+A, the next edit replaces the whole line
+	all_selected bool
+
+	// Characters typed by the key that opened the box arrive right after it, skip them
+	ignored_chars string
+}
+
+func (input *TextInput) handle_char(char rune) {
+	if input.ignored_chars != "" {
+		ignored := input.ignored_chars
+		input.ignored_chars = ""
+		for _, ignored_char := range ignored {
+			if char == ignored_char {
+				return
+			}
+		}
+	}
+	input.insert([]rune{char})
+}
+
+func (input *TextInput) insert(chars []rune) {
+	input.delete_selection()
+	text := make([]rune, 0, len(input.text)+len(chars))
+	text = append(text, input.text[:input.cursor]...)
+	text = append(text, chars...)
+	input.text = append(text, input.text[input.cursor:]...)
+	input.cursor += len(chars)
+}
+
+func (input *TextInput) delete_selection() bool {
+	if !input.all_selected {
+		return false
+	}
+	input.clear()
+	return true
+}
+
+// Command on macOS, Control elsewhere
+func shortcut_modifier(mods glfw.ModifierKey) bool {
+	return mods&(glfw.ModControl|glfw.ModSuper) != 0
+}
+
+// Pasted lines are joined, the box holds a single line
+func paste_text(text string) []rune {
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r", ""), "\n")
+	return []rune(strings.NewReplacer("\n", " ", "\t", " ").Replace(text))
+}
+
+// Returns the line and true when it was submitted with enter
+func (input *TextInput) handle_key(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) (string, bool) {
+	if action == glfw.Release {
+		return "", false
+	}
+
+	if shortcut_modifier(mods) {
+		switch key {
+		case glfw.KeyA:
+			input.all_selected = len(input.text) > 0
+		case glfw.KeyC:
+			glfw.SetClipboardString(string(input.text))
+		case glfw.KeyX:
+			glfw.SetClipboardString(string(input.text))
+			input.clear()
+		case glfw.KeyV:
+			if action == glfw.Press {
+				input.insert(paste_text(glfw.GetClipboardString()))
+			}
+		}
+		return "", false
+	}
+
+	selected := input.all_selected
+	input.all_selected = false
+
+	switch key {
+	case glfw.KeyBackspace:
+		if selected {
+			input.clear()
+		} else if input.cursor > 0 {
+			input.text = append(input.text[:input.cursor-1], input.text[input.cursor:]...)
+			input.cursor--
+		}
+	case glfw.KeyDelete:
+		if selected {
+			input.clear()
+		} else if input.cursor < len(input.text) {
+			input.text = append(input.text[:input.cursor], input.text[input.cursor+1:]...)
+		}
+	case glfw.KeyLeft:
+		input.cursor = max(input.cursor-1, 0)
+	case glfw.KeyRight:
+		input.cursor = min(input.cursor+1, len(input.text))
+	case glfw.KeyHome:
+		input.cursor = 0
+	case glfw.KeyEnd:
+		input.cursor = len(input.text)
+	case glfw.KeyEnter, glfw.KeyKPEnter:
+		line := string(input.text)
+		input.clear()
+		return line, true
+	}
+
+	return "", false
+}
+
+// Replaces the text, with the caret at its end
+func (input *TextInput) set(text string) {
+	input.text = []rune(text)
+	input.cursor = len(input.text)
+	input.all_selected = false
+}
+
+func (input *TextInput) clear() {
+	input.text = input.text[:0]
+	input.cursor = 0
+	input.all_selected = false
+}
+
+func (input *TextInput) String() string {
+	return string(input.text)
+}
+
+// Draws prefix followed by the text, with the caret and the selection
+func (input *TextInput) draw(prefix string, x float32, y float32, scale float32, c color.RGBA) {
+	text_x := x + text_width(prefix, scale)
+	if input.all_selected {
+		draw_nine_slice(g_WidgetTheme.selection, text_x, y, text_width(string(input.text), scale), text_line_height(scale))
+	}
+	draw_text(prefix+string(input.text), x, y, scale, c)
+	draw_text("_", text_x+text_width(string(input.text[:input.cursor]), scale), y, scale, c)
+}
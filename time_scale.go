@@ -0,0 +1,98 @@
+package main
+
+import "strconv"
+
+// Multiplies the time fed to the fixed timestep, UI, audio and input keep running in real time.
+// Gameplay effects ask for a scale for a while, the lowest active one wins and the scale in use
+// eases towards it so slow motion ramps in and out.
+type TimeScale struct {
+	// Set from the console, effects can only slow down further
+	base    float32
+	current float32
+	effects []TimeScaleEffect
+	// Real seconds left of a hit-stop, the simulation gets no time at all until it runs out and
+	// then carries on at the scale it had
+	freeze float32
+}
+
+type TimeScaleEffect struct {
+	scale float32
+	// In real seconds, so slow motion doesn't stretch its own duration
+	remaining float32
+}
+
+var g_TimeScale = TimeScale{base: 1, current: 1}
+
+// Fraction of the way to the target covered per real second, roughly
+const timeScaleRampRate = 8.0
+
+// Damage from a single hit that counts as heavy
+const hitStopDamage = 15.0
+
+func init_time_scale() {
+	register_command("time_scale", "[scale]: simulation speed, 1 is normal and 0 freezes", func(args []string) {
+		if len(args) == 1 {
+			scale, err := strconv.ParseFloat(args[0], 32)
+			if err != nil || scale < 0 {
+				console_print("invalid scale %q", args[0])
+				return
+			}
+			g_TimeScale.base = float32(scale)
+		}
+		console_print("time_scale = %g (%g in use)", g_TimeScale.base, g_TimeScale.current)
+	})
+
+	subscribe_event(EVENT_PLAYER_DAMAGED, func(event GameEvent) {
+		if event.magnitude >= hitStopDamage {
+			hit_stop(g_Settings.hit_stop_duration)
+		}
+	})
+}
+
+// Overlapping hits don't add up, the longest remaining freeze wins
+func hit_stop(duration float32) {
+	g_TimeScale.freeze = max(g_TimeScale.freeze, duration)
+}
+
+func slow_motion(scale float32, duration float32) {
+	g_TimeScale.effects = append(g_TimeScale.effects, TimeScaleEffect{scale: max(scale, 0), remaining: duration})
+}
+
+func time_scale_target() float32 {
+	target := g_TimeScale.base
+	for _, effect := range g_TimeScale.effects {
+		target = min(target, effect.scale)
+	}
+	return target
+}
+
+// Called once per frame with the real frame time, returns the frame time the simulation sees
+func step_time_scale(elapsed float32) float32 {
+	active := g_TimeScale.effects[:0]
+	for _, effect := range g_TimeScale.effects {
+		effect.remaining -= elapsed
+		if effect.remaining > 0 {
+			active = append(active, effect)
+		}
+	}
+	g_TimeScale.effects = active
+
+	if g_TimeScale.freeze > 0 {
+		g_TimeScale.freeze -= elapsed
+		return 0
+	}
+
+	target := time_scale_target()
+	g_TimeScale.current += (target - g_TimeScale.current) * min(elapsed*timeScaleRampRate, 1)
+	if Abs(target-g_TimeScale.current) < 0.01 {
+		g_TimeScale.current = target
+	}
+	return elapsed * g_TimeScale.current
+}
+
+// Restores normal speed, for level changes and restarts
+func reset_time_scale() {
+	g_TimeScale.effects = nil
+	g_TimeScale.freeze = 0
+	g_TimeScale.current = g_TimeScale.base
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Gameplay advances in fixed steps, rendering interpolates between the last two
+const simulationStep = float32(1) / 60
+
+// A stall (debugger, window drag, slow disk) longer than this is treated as this long
+const maxFrameTime = float32(0.25)
+
+// If simulating can't keep up, time beyond this many steps is dropped instead of piling up
+const maxStepsPerFrame = 5
+
+type FixedTimestep struct {
+	accumulator float32
+	// How far the frame being rendered is between the previous and the current step, 0 to 1
+	alpha float32
+	// Set while the window is being moved or resized, the time spent doing that isn't simulated
+	held bool
+}
+
+var g_Timestep = FixedTimestep{}
+
+func init_timestep(window *glfw.Window) {
+	window.SetPosCallback(func(w *glfw.Window, x int, y int) {
+		hold_timestep()
+	})
+	window.SetSizeCallback(func(w *glfw.Window, width int, height int) {
+		hold_timestep()
+	})
+}
+
+// Skips simulating the time of the current frame
+func hold_timestep() {
+	g_Timestep.held = true
+}
+
+// Banks the frame time and returns how many simulation steps are due
+func advance_timestep(elapsed float32) int {
+	if g_Timestep.held {
+		g_Timestep.held = false
+		return 0
+	}
+
+	g_Timestep.accumulator += min(max(elapsed, 0), maxFrameTime)
+
+	steps := 0
+	for g_Timestep.accumulator >= simulationStep {
+		g_Timestep.accumulator -= simulationStep
+		steps++
+	}
+
+	if steps > maxStepsPerFrame {
+		steps = maxStepsPerFrame
+		g_Timestep.accumulator = 0
+	}
+
+	g_Timestep.alpha = g_Timestep.accumulator / simulationStep
+	return steps
+}
+
+// Called before every simulation step so the renderer has the state to interpolate from
+func store_previous_transforms() {
+	g_Player.previous_transform = player_transform(&g_Player)
+	g_Player.has_previous_transform = true
+
+	g_Camera.previous_pos2D = g_Camera.pos2D
+	g_Camera.previous_z_value = g_Camera.z_value
+
+	for id := range g_Scene.nodes {
+		g_Scene.nodes[id].previous_world = g_Scene.nodes[id].world
+	}
+}
+
+func lerp_float32(from float32, to float32, t float32) float32 {
+	return from + (to-from)*t
+}
+
+func lerp_transform(from Transform, to Transform, t float32) Transform {
+	return Transform{
+		position: from.position.lerp(to.position, t),
+		depth:    lerp_float32(from.depth, to.depth, t),
+		rotation: from.rotation.Add(to.rotation.Sub(from.rotation).Mul(t)),
+		scale:    from.scale.Add(to.scale.Sub(from.scale).Mul(t)),
+	}
+}
+
+// Element wise, close enough to a proper decomposition for the small change within one step
+func lerp_matrix(from mgl32.Mat4, to mgl32.Mat4, t float32) mgl32.Mat4 {
+	return from.Mul(1 - t).Add(to.Mul(t))
+}
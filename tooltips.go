@@ -0,0 +1,75 @@
+package main
+
+import (
+	"image/color"
+)
+
+// Text shown next to the cursor once it rests on something that explains itself. Widgets set
+// their tooltip field, anything else drawn without widgets (like the editor gizmos) adds a source.
+type Tooltips struct {
+	// Asked in order when no widget under the pointer has a tooltip, in window pixels
+	sources []func(pointer Vector2DF) string
+
+	text       string
+	hover_time float32
+}
+
+var g_Tooltips = Tooltips{}
+
+const tooltipDelay = 0.5
+const tooltipPadding = 6
+
+// Away from the cursor so it doesn't cover what's being pointed at
+var tooltipCursorOffset = Vector2DF{14, 20}
+
+func add_tooltip_source(source func(pointer Vector2DF) string) {
+	g_Tooltips.sources = append(g_Tooltips.sources, source)
+}
+
+func tooltip_at(pointer Vector2DF) string {
+	if text := widget_tooltip_at(g_UI.root, ui_pointer()); text != "" {
+		return text
+	}
+	for _, source := range g_Tooltips.sources {
+		if text := source(pointer); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// Real time, called once per frame after polling input
+func step_tooltips(dt float32) {
+	text := ""
+	if !action_held(ACTION_POINTER_PRIMARY) {
+		text = tooltip_at(Vector2DF{g_Mouse.x, g_Mouse.y})
+	}
+	if text != g_Tooltips.text {
+		g_Tooltips.text = text
+		g_Tooltips.hover_time = 0
+	}
+	g_Tooltips.hover_time += dt
+}
+
+// Drawn last in the overlay pass so it sits above every other piece of UI
+func render_tooltip() {
+	if g_Tooltips.text == "" || g_Tooltips.hover_time < tooltipDelay {
+		return
+	}
+
+	w := text_width(g_Tooltips.text, 1) + tooltipPadding*2
+	h := text_line_height(1) + tooltipPadding*2
+	pointer, size := ui_pointer(), ui_size()
+	x := pointer.x + tooltipCursorOffset.x
+	y := pointer.y + tooltipCursorOffset.y
+	// Flipped to the other side of the cursor near the right and bottom edges
+	if x+w > size.x {
+		x = max(pointer.x-tooltipCursorOffset.x-w, 0)
+	}
+	if y+h > size.y {
+		y = max(pointer.y-tooltipCursorOffset.y-h, 0)
+	}
+
+	draw_nine_slice(g_WidgetTheme.tooltip, x, y, w, h)
+	draw_text(g_Tooltips.text, x+tooltipPadding, y+tooltipPadding, 1, color.RGBA{255, 255, 255, 255})
+}
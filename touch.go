@@ -0,0 +1,160 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// GLFW 3.3 has no touch events, touch screens reach us as an emulated mouse so only a
+// single pointer is tracked: the joystick and the buttons can't be held at the same time.
+type VirtualJoystick struct {
+	widget *Widget
+	radius float32
+
+	active bool
+	knob   Vector2DF // Offset from center, at most radius long
+}
+
+type VirtualButton struct {
+	widget *Widget
+	radius float32
+	action Action
+
+	pressed bool
+}
+
+type TouchControls struct {
+	joystick VirtualJoystick
+	buttons  []VirtualButton
+
+	// Actions currently driven by the joystick, so releasing it doesn't release the keyboard
+	joystick_actions [ACTION_COUNT]bool
+
+	base_texture   uint32
+	knob_texture   uint32
+	button_texture uint32
+}
+
+var g_TouchControls = TouchControls{}
+
+const touchJoystickDeadZone = float32(0.3)
+
+func new_circle_texture(size int, c color.RGBA) uint32 {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	radius := float32(size) / 2
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			offset := Vector2DF{float32(x) + 0.5 - radius, float32(y) + 0.5 - radius}
+			if offset.length_squared() <= radius*radius {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+
+	texture, _ := render.TextureFromImage(img)
+	return texture
+}
+
+func init_touch_controls() {
+	// Kept in the bottom corners whatever the window size
+	g_TouchControls.joystick = VirtualJoystick{radius: 70}
+	g_TouchControls.joystick.widget = add_widget(nil, new_widget(uiBottomLeft, uiCenter, Vector2DF{110, -110}, Vector2DF{140, 140}))
+	g_TouchControls.joystick.widget.draw = draw_touch_joystick
+	g_TouchControls.buttons = []VirtualButton{
+		{radius: 45, action: ACTION_JUMP},
+	}
+	for i := range g_TouchControls.buttons {
+		button := &g_TouchControls.buttons[i]
+		button.widget = add_widget(nil, new_widget(uiBottomRight, uiCenter, Vector2DF{-90, -90}, Vector2DF{button.radius * 2, button.radius * 2}))
+		button.widget.draw = func(widget *Widget) { draw_touch_button(button) }
+	}
+	update_touch_controls_visibility()
+
+	g_TouchControls.base_texture = new_circle_texture(128, color.RGBA{40, 40, 40, 90})
+	g_TouchControls.knob_texture = new_circle_texture(64, color.RGBA{40, 40, 40, 160})
+	g_TouchControls.button_texture = new_circle_texture(64, color.RGBA{140, 42, 42, 140})
+
+	register_command("touch_controls", "toggle the on screen joystick and buttons", func() {
+		g_Settings.touch_controls_enabled = !g_Settings.touch_controls_enabled
+		console_print("touch controls: %v", g_Settings.touch_controls_enabled)
+	})
+}
+
+func point_in_circle(point Vector2DF, center Vector2DF, radius float32) bool {
+	return point.subtract(center).length_squared() <= radius*radius
+}
+
+func update_touch_controls_visibility() {
+	g_TouchControls.joystick.widget.hidden = !g_Settings.touch_controls_enabled
+	for _, button := range g_TouchControls.buttons {
+		button.widget.hidden = !g_Settings.touch_controls_enabled
+	}
+}
+
+func step_touch_controls() {
+	update_touch_controls_visibility()
+	if !g_Settings.touch_controls_enabled || !gameplay_input_enabled() {
+		return
+	}
+
+	pointer := ui_pointer()
+	joystick := &g_TouchControls.joystick
+	center := joystick.widget.rect.center()
+
+	if action_just_pressed(ACTION_POINTER_PRIMARY) && point_in_circle(pointer, center, joystick.radius*1.5) {
+		joystick.active = true
+	}
+	if !action_held(ACTION_POINTER_PRIMARY) {
+		joystick.active = false
+	}
+
+	joystick.knob = Vector2DF{0, 0}
+	if joystick.active {
+		joystick.knob = pointer.subtract(center).clamp_length(joystick.radius)
+	}
+
+	dead_zone := touchJoystickDeadZone * joystick.radius
+	set_joystick_action(ACTION_MOVE_LEFT, joystick.knob.x < -dead_zone)
+	set_joystick_action(ACTION_MOVE_RIGHT, joystick.knob.x > dead_zone)
+
+	for i := range g_TouchControls.buttons {
+		button := &g_TouchControls.buttons[i]
+		pressed := !joystick.active && action_held(ACTION_POINTER_PRIMARY) && point_in_circle(pointer, button.widget.rect.center(), button.radius)
+
+		if pressed != button.pressed {
+			set_action_state(button.action, pressed)
+			button.pressed = pressed
+		}
+	}
+}
+
+func set_joystick_action(action Action, pressed bool) {
+	if g_TouchControls.joystick_actions[action] == pressed {
+		return
+	}
+	g_TouchControls.joystick_actions[action] = pressed
+	set_action_state(action, pressed)
+}
+
+func draw_touch_joystick(widget *Widget) {
+	joystick := g_TouchControls.joystick
+	center := widget.rect.center()
+	draw_overlay_quad(g_TouchControls.base_texture,
+		center.x-joystick.radius, center.y-joystick.radius, joystick.radius*2, joystick.radius*2)
+
+	knob_radius := joystick.radius * 0.45
+	knob := center.add(joystick.knob)
+	draw_overlay_quad(g_TouchControls.knob_texture, knob.x-knob_radius, knob.y-knob_radius, knob_radius*2, knob_radius*2)
+}
+
+func draw_touch_button(button *VirtualButton) {
+	center := button.widget.rect.center()
+	radius := button.radius
+	if button.pressed {
+		radius *= 0.9
+	}
+	draw_overlay_quad(g_TouchControls.button_texture, center.x-radius, center.y-radius, radius*2, radius*2)
+}
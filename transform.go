@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Placement of a renderable, rotation is euler angles in radians applied z, y then x
+type Transform struct {
+	position Vector2DF
+	depth    float32
+	rotation mgl32.Vec3
+	scale    mgl32.Vec3
+}
+
+func make_transform(position Vector2DF) Transform {
+	return Transform{position: position, scale: mgl32.Vec3{1, 1, 1}}
+}
+
+func (transform Transform) matrix() mgl32.Mat4 {
+	model := mgl32.Translate3D(transform.position.x, transform.position.y, transform.depth)
+	model = model.Mul4(mgl32.HomogRotate3DZ(transform.rotation[2]))
+	model = model.Mul4(mgl32.HomogRotate3DY(transform.rotation[1]))
+	model = model.Mul4(mgl32.HomogRotate3DX(transform.rotation[0]))
+	return model.Mul4(mgl32.Scale3D(transform.scale[0], transform.scale[1], transform.scale[2]))
+}
+
+// Scale stays with the renderable, children of a scene node shouldn't inherit it
+func (transform Transform) without_scale() Transform {
+	transform.scale = mgl32.Vec3{1, 1, 1}
+	return transform
+}
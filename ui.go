@@ -0,0 +1,233 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Rectangle in overlay pixels, origin at the top left
+type UIRect struct {
+	x float32
+	y float32
+	w float32
+	h float32
+}
+
+func (rect UIRect) contains(point Vector2DF) bool {
+	return point.x >= rect.x && point.x < rect.x+rect.w && point.y >= rect.y && point.y < rect.y+rect.h
+}
+
+func (rect UIRect) center() Vector2DF {
+	return Vector2DF{rect.x + rect.w/2, rect.y + rect.h/2}
+}
+
+// Node of the retained UI tree. Widgets are placed relative to their parent every frame, so
+// anything anchored to an edge or corner follows the window size.
+type Widget struct {
+	// Point on the parent the widget hangs from and the point of the widget put there, 0 to 1 on each axis
+	anchor Vector2DF
+	pivot  Vector2DF
+	// In pixels, offset moves the widget away from its anchor
+	offset Vector2DF
+	size   Vector2DF
+	// Fraction of the parent's size added to size, {1, 0} spans the parent's width
+	stretch Vector2DF
+
+	// Siblings draw in increasing order, equal ones in the order they were added
+	order  int
+	hidden bool
+	// Shown near the cursor after hovering for a moment, empty for none
+	tooltip string
+
+	// Called before layout each frame, for widgets that follow game state
+	update func(widget *Widget)
+	// Called with rect laid out, nil for plain containers
+	draw func(widget *Widget)
+
+	rect     UIRect
+	parent   *Widget
+	children []*Widget
+}
+
+type UI struct {
+	root *Widget
+}
+
+var g_UI = UI{root: &Widget{stretch: Vector2DF{1, 1}}}
+
+// Overlay pixels per UI unit. Everything drawn in the overlay pass, text included, is laid out in
+// UI units so it keeps its size relative to the window.
+var g_UIScale = float32(1)
+
+// Window height the UI is designed for, taller windows scale it up in whole steps
+const uiReferenceHeight = 720
+
+// Common anchors and pivots
+var (
+	uiTopLeft     = Vector2DF{0, 0}
+	uiTop         = Vector2DF{0.5, 0}
+	uiTopRight    = Vector2DF{1, 0}
+	uiCenter      = Vector2DF{0.5, 0.5}
+	uiBottomLeft  = Vector2DF{0, 1}
+	uiBottom      = Vector2DF{0.5, 1}
+	uiBottomRight = Vector2DF{1, 1}
+)
+
+func new_widget(anchor Vector2DF, pivot Vector2DF, offset Vector2DF, size Vector2DF) *Widget {
+	return &Widget{anchor: anchor, pivot: pivot, offset: offset, size: size}
+}
+
+// A nil parent adds to the root, which covers the window
+func add_widget(parent *Widget, child *Widget) *Widget {
+	if parent == nil {
+		parent = g_UI.root
+	}
+	remove_widget(child)
+	child.parent = parent
+	parent.children = append(parent.children, child)
+	return child
+}
+
+func remove_widget(widget *Widget) {
+	parent := widget.parent
+	if parent == nil {
+		return
+	}
+	for i, child := range parent.children {
+		if child == widget {
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			break
+		}
+	}
+	widget.parent = nil
+}
+
+// Hidden widgets hide their children too
+func widget_visible(widget *Widget) bool {
+	for ; widget != nil; widget = widget.parent {
+		if widget.hidden {
+			return false
+		}
+	}
+	return true
+}
+
+func update_widget(widget *Widget) {
+	if widget.update != nil {
+		widget.update(widget)
+	}
+	for _, child := range widget.children {
+		update_widget(child)
+	}
+}
+
+func layout_widget(widget *Widget, parent UIRect) {
+	w := widget.size.x + widget.stretch.x*parent.w
+	h := widget.size.y + widget.stretch.y*parent.h
+	widget.rect = UIRect{
+		x: parent.x + parent.w*widget.anchor.x + widget.offset.x - w*widget.pivot.x,
+		y: parent.y + parent.h*widget.anchor.y + widget.offset.y - h*widget.pivot.y,
+		w: w,
+		h: h,
+	}
+
+	for _, child := range widget.children {
+		layout_widget(child, widget.rect)
+	}
+}
+
+func draw_widget(widget *Widget) {
+	if widget.hidden {
+		return
+	}
+	if widget.draw != nil {
+		widget.draw(widget)
+	}
+
+	for _, child := range sorted_children(widget) {
+		draw_widget(child)
+	}
+}
+
+// In draw order
+func sorted_children(widget *Widget) []*Widget {
+	children := make([]*Widget, len(widget.children))
+	copy(children, widget.children)
+	sort.SliceStable(children, func(i, j int) bool { return children[i].order < children[j].order })
+	return children
+}
+
+// Tooltip of the topmost visible widget under the pointer that has one, as laid out last frame
+func widget_tooltip_at(widget *Widget, pointer Vector2DF) string {
+	if widget.hidden {
+		return ""
+	}
+	children := sorted_children(widget)
+	for i := len(children) - 1; i >= 0; i-- {
+		if tooltip := widget_tooltip_at(children[i], pointer); tooltip != "" {
+			return tooltip
+		}
+	}
+	if widget.tooltip != "" && widget.rect.contains(pointer) {
+		return widget.tooltip
+	}
+	return ""
+}
+
+// Updates and lays the tree out against the window, then draws it. Called inside the overlay pass.
+func render_ui() {
+	update_widget(g_UI.root)
+	size := ui_size()
+	layout_widget(g_UI.root, UIRect{0, 0, size.x, size.y})
+	draw_widget(g_UI.root)
+}
+
+func init_ui() {
+	register_command("ui_scale", "[scale]: size of menus, HUD and text, 0 picks one from the window", func(args []string) {
+		if len(args) == 1 {
+			scale, err := strconv.ParseFloat(args[0], 32)
+			if err != nil || scale < 0 {
+				console_print("invalid scale %q", args[0])
+				return
+			}
+			g_Settings.ui_scale = float32(scale)
+		}
+		console_print("ui_scale = %g (%g in use)", g_Settings.ui_scale, g_UIScale)
+	})
+}
+
+// The ui_scale setting, or when it's 0 whole steps of the window height times the monitor's content
+// scale. Content scale only counts where the window isn't already sized in scaled points.
+func update_ui_scale(window *glfw.Window) {
+	if g_Settings.ui_scale > 0 {
+		g_UIScale = g_Settings.ui_scale
+		return
+	}
+
+	scale := max(float32(math.Round(float64(g_WindowHeight/uiReferenceHeight))), 1)
+	if framebuffer_width, _ := window.GetFramebufferSize(); framebuffer_width == int(g_WindowWidth) {
+		_, content_scale := window.GetContentScale()
+		scale *= max(content_scale, 1)
+	}
+	g_UIScale = scale
+}
+
+// Size of the window in UI units
+func ui_size() Vector2DF {
+	return Vector2DF{g_WindowWidth / g_UIScale, g_WindowHeight / g_UIScale}
+}
+
+// Mouse position in UI units
+func ui_pointer() Vector2DF {
+	return Vector2DF{g_Mouse.x / g_UIScale, g_Mouse.y / g_UIScale}
+}
+
+// Draw function stretching a texture over the widget
+func draw_widget_texture(texture uint32) func(widget *Widget) {
+	return func(widget *Widget) {
+		draw_overlay_quad(texture, widget.rect.x, widget.rect.y, widget.rect.w, widget.rect.h)
+	}
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A problem found in a level or prefab, line and column are 1 based and 0 when unknown
+type ValidationIssue struct {
+	file    string
+	line    int
+	column  int
+	message string
+}
+
+func (issue ValidationIssue) String() string {
+	if issue.line == 0 {
+		return fmt.Sprintf("%s: %s", issue.file, issue.message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", issue.file, issue.line, issue.column, issue.message)
+}
+
+// Spawned entities closer than this to each other or the player spawn are reported as overlapping
+const validateSpawnClearance = 1.0
+
+func init_validation() {
+	register_command("validate", "check the given levels and prefabs for mistakes, or all of them", func(paths ...string) {
+		issues := validate_content(paths)
+		for _, issue := range issues {
+			console_print("%s", issue)
+		}
+		console_print("%d issues", len(issues))
+	})
+}
+
+// With no names every level and prefab is checked
+func validate_content(level_names []string) []ValidationIssue {
+	issues := []ValidationIssue{}
+
+	if len(level_names) == 0 {
+		issues = append(issues, validate_prefabs()...)
+		for _, level := range g_Levels {
+			issues = append(issues, validate_level(level)...)
+		}
+		return issues
+	}
+
+	for _, name := range level_names {
+		level, err := find_level(name)
+		if err != nil {
+			issues = append(issues, ValidationIssue{file: name, message: err.Error()})
+			continue
+		}
+		issues = append(issues, validate_level(level)...)
+	}
+	return issues
+}
+
+// Runs without a window, for -validate. Returns the process exit status.
+func run_validation() int {
+	headless := g_Headless
+	g_Headless = true
+	defer func() { g_Headless = headless }()
+
+	issues := validate_content(nil)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	fmt.Printf("%d levels, %d prefabs, %d issues\n", len(g_Levels), len(g_Prefabs), len(issues))
+
+	if len(issues) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func validate_level(level LevelInfo) []ValidationIssue {
+	file := level.path
+	if file == "" {
+		file = "level " + level.Id
+	}
+	issues := []ValidationIssue{}
+	report := func(offset int64, format string, args ...any) {
+		line, column := 0, 0
+		if data, err := os.ReadFile(level.path); err == nil && offset >= 0 {
+			line, column = offset_line_column(data, offset)
+		}
+		issues = append(issues, ValidationIssue{file, line, column, fmt.Sprintf(format, args...)})
+	}
+
+	if pack := level_pack(level); pack != nil && pack.Tileset != "" {
+		if _, err := os.Stat(pack_tileset(pack)); err != nil {
+			issues = append(issues, ValidationIssue{file: filepath.Join(pack.directory, "pack.json"), message: fmt.Sprintf("tileset %q not found", pack.Tileset)})
+		}
+	}
+
+	if grading := level_grading(level); grading != "" {
+		if _, _, err := read_lut(grading); err != nil {
+			report(-1, "grading: %v", err)
+		}
+	}
+
+	switch level.Generator {
+	case "", levelGeneratorDungeon:
+	case levelGeneratorWFC:
+		sample := level.Sample
+		if sample == "" {
+			sample = levelDefaultSample
+		}
+		if _, err := read_wfc_sample(sample); err != nil {
+			report(-1, "sample: %v", err)
+		}
+	default:
+		report(-1, "unknown generator %q", level.Generator)
+	}
+	for _, phase := range level_weather(level) {
+		if _, ok := weatherKinds[phase.Type]; !ok {
+			report(-1, "unknown weather %q", phase.Type)
+		}
+		if phase.Intensity < 0 || phase.Intensity > 1 {
+			report(-1, "weather intensity must be from 0 up to 1, got %g", phase.Intensity)
+		}
+		if phase.Duration < 0 {
+			report(-1, "weather duration can't be negative")
+		}
+	}
+	for _, water := range level.Water {
+		if !(water[0] < water[2] && water[1] < water[3]) {
+			report(-1, "water must be min x, min y, max x, max y, got %v", water)
+		}
+	}
+	if level.Keys < 0 || level.Keys > dungeonMaxKeys {
+		report(-1, "keys must be from 0 up to %d, got %d", dungeonMaxKeys, level.Keys)
+	}
+
+	offsets := []int64{}
+	if data, err := os.ReadFile(level.path); err == nil {
+		offsets, _ = json_array_offsets(data, "entities")
+	}
+	entity_offset := func(i int) int64 {
+		if i < len(offsets) {
+			return offsets[i]
+		}
+		return -1
+	}
+
+	level_map := generate_map(level, level.Seed)
+	bounds := level_map.bounds
+
+	for i, block := range level_map.entities {
+		if !bounding_box_valid(block.bb) {
+			report(-1, "block %d at (%g, %g) has a malformed bounding box", i, block.pos.x, block.pos.y)
+		}
+	}
+
+	spawns := []Vector2DF{level_map.spawn}
+	for i, placement := range level.Entities {
+		pos := Vector2DF{placement.X, placement.Y}
+		prefab, ok := g_Prefabs[placement.Prefab]
+		if !ok {
+			report(entity_offset(i), "unknown prefab %q", placement.Prefab)
+			continue
+		}
+		if !bounds.contains(pos) {
+			report(entity_offset(i), "%s at (%g, %g) is outside the level bounds", prefab.Id, pos.x, pos.y)
+		}
+		for j, other := range spawns {
+			if pos.distance(other) < validateSpawnClearance {
+				if j == 0 {
+					report(entity_offset(i), "%s at (%g, %g) overlaps the player spawn", prefab.Id, pos.x, pos.y)
+				} else {
+					report(entity_offset(i), "%s at (%g, %g) overlaps entity %d", prefab.Id, pos.x, pos.y, j-1)
+				}
+			}
+		}
+		spawns = append(spawns, pos)
+
+		if placement.Variant != nil {
+			render := prefab.Components.Render
+			if render == nil || render.Palette == "" {
+				report(entity_offset(i), "%s has no palette to pick a variant from", prefab.Id)
+			} else if *placement.Variant < 0 {
+				report(entity_offset(i), "variant can't be negative, got %d", *placement.Variant)
+			}
+		}
+
+		if collider := prefab.Components.Collider; collider != nil && collider.Solid {
+			bb := make_bounding_box_2d_centered(pos, Vector2DF{collider.HalfSize[0], collider.HalfSize[1]})
+			for _, block := range level_map.entities {
+				if bb.intersects(block.bb) {
+					report(entity_offset(i), "solid %s at (%g, %g) overlaps a block", prefab.Id, pos.x, pos.y)
+					break
+				}
+			}
+		}
+	}
+
+	prop_offsets := []int64{}
+	if data, err := os.ReadFile(level.path); err == nil {
+		prop_offsets, _ = json_array_offsets(data, "props")
+	}
+	for i, placement := range level.Props {
+		offset := int64(-1)
+		if i < len(prop_offsets) {
+			offset = prop_offsets[i]
+		}
+		if _, err := read_model(placement.Model); err != nil {
+			report(offset, "prop model: %v", err)
+		}
+		if placement.Scale < 0 {
+			report(offset, "prop scale must be positive, got %g", placement.Scale)
+		}
+	}
+
+	return issues
+}
+
+func validate_prefabs() []ValidationIssue {
+	issues := []ValidationIssue{}
+
+	for _, prefab := range g_Prefabs {
+		file := prefab.path
+		data, _ := os.ReadFile(asset_path(file))
+		report := func(key string, format string, args ...any) {
+			line, column := 0, 0
+			if offset := bytes.Index(data, []byte(`"`+key+`"`)); offset >= 0 {
+				line, column = offset_line_column(data, int64(offset))
+			}
+			issues = append(issues, ValidationIssue{file, line, column, fmt.Sprintf(format, args...)})
+		}
+
+		if render := prefab.Components.Render; render != nil {
+			if _, err := os.Stat(texture_path(render.Texture)); err != nil {
+				report("texture", "texture %q not found", render.Texture)
+			}
+			if render.Scale[0] <= 0 || render.Scale[1] <= 0 {
+				report("scale", "scale must be positive, got %v", render.Scale)
+			}
+			if render.Palette != "" {
+				if _, err := os.Stat(texture_path(render.Palette)); err != nil {
+					report("palette", "palette %q not found", render.Palette)
+				}
+			}
+			if render.Variant < 0 {
+				report("variant", "variant can't be negative, got %d", render.Variant)
+			}
+		}
+		if billboard := prefab.Components.Billboard; billboard != nil {
+			if _, err := os.Stat(texture_path(billboard.Texture)); err != nil {
+				report("texture", "billboard texture %q not found", billboard.Texture)
+			}
+			if billboard.Size[0] <= 0 || billboard.Size[1] <= 0 {
+				report("size", "billboard size must be positive, got %v", billboard.Size)
+			}
+		}
+		if collider := prefab.Components.Collider; collider != nil {
+			if !(collider.HalfSize[0] > 0 && collider.HalfSize[1] > 0) {
+				report("half_size", "collider half_size must be positive, got %v", collider.HalfSize)
+			}
+		}
+		if pickup := prefab.Components.Pickup; pickup != nil {
+			if pickup.Duration > 0 && !(pickup.TimeScale >= 0 && pickup.TimeScale < 1) {
+				report("time_scale", "pickup time_scale must be from 0 up to 1, got %v", pickup.TimeScale)
+			}
+			if prefab.Components.Collider == nil {
+				report("pickup", "pickup needs a collider to be touched")
+			}
+		}
+		if spawner := prefab.Components.Spawner; spawner != nil {
+			for _, wave := range spawner.Waves {
+				for _, enemy := range wave.Enemies {
+					if spawned, ok := g_Prefabs[enemy.Prefab]; !ok {
+						report("enemies", "unknown enemy prefab %q", enemy.Prefab)
+					} else if spawned.Components.Enemy == nil {
+						report("enemies", "%s can't be defeated, the wave would never be cleared", enemy.Prefab)
+					}
+					if enemy.Count <= 0 {
+						report("count", "enemy count must be positive, got %d", enemy.Count)
+					}
+				}
+				if wave.Interval < 0 || wave.Delay < 0 {
+					report("waves", "wave interval and delay can't be negative")
+				}
+				if wave.Trigger != "" && wave.Trigger != waveTriggerCleared && wave.Trigger != waveTriggerTimer {
+					report("trigger", "unknown wave trigger %q", wave.Trigger)
+				}
+			}
+		}
+		if projectile := prefab.Components.Projectile; projectile != nil {
+			if projectile.Speed <= 0 || projectile.Lifetime <= 0 {
+				report("projectile", "projectile speed and lifetime must be positive")
+			}
+		}
+		if steering := prefab.Components.Steering; steering != nil {
+			if steering.MaxSpeed <= 0 || steering.MaxForce <= 0 {
+				report("steering", "steering max_speed and max_force must be positive")
+			}
+			for _, force := range steering.Forces {
+				switch force.Type {
+				case steerSeek, steerFlee, steerArrive, steerWander, steerAvoid:
+				default:
+					report("forces", "unknown steering force %q", force.Type)
+				}
+				if force.Target != "" && force.Target != steerTargetPlayer && force.Target != steerTargetOrigin {
+					report("target", "unknown steering target %q", force.Target)
+				}
+			}
+		}
+		if flock := prefab.Components.Flock; flock != nil {
+			if prefab.Components.Steering == nil {
+				report("flock", "flock needs a steering component to move")
+			}
+			if flock.Radius <= 0 || flock.SeparationRadius < 0 {
+				report("radius", "flock radius must be positive")
+			}
+		}
+		if wind := prefab.Components.Wind; wind != nil {
+			if prefab.Components.Steering == nil {
+				report("wind", "wind needs a steering component to move")
+			}
+			if wind.Drag < 0 || wind.Drag > 1 {
+				report("drag", "wind drag must be from 0 up to 1, got %g", wind.Drag)
+			}
+		}
+		if perception := prefab.Components.Perception; perception != nil {
+			if perception.SightRange < 0 || perception.Hearing < 0 || perception.SeeRate < 0 || perception.HearAlert < 0 || perception.Decay < 0 {
+				report("perception", "perception values can't be negative")
+			}
+			if perception.SightAngle < 0 || perception.SightAngle > 360 {
+				report("sight_angle", "sight_angle must be from 0 up to 360 degrees, got %g", perception.SightAngle)
+			}
+		}
+		if boss := prefab.Components.Boss; boss != nil {
+			if prefab.Components.Enemy == nil {
+				report("boss", "boss needs an enemy component to be defeated")
+			}
+			if _, ok := g_Prefabs[boss.Wall]; !ok {
+				report("wall", "unknown wall prefab %q", boss.Wall)
+			}
+			if !(boss.Arena[0] < boss.Arena[2] && boss.Arena[1] < boss.Arena[3]) {
+				report("arena", "arena must be min x, min y, max x, max y, got %v", boss.Arena)
+			}
+			if len(boss.Phases) == 0 {
+				report("phases", "boss needs at least one phase")
+			}
+			for i, phase := range boss.Phases {
+				if i > 0 && phase.Health >= boss.Phases[i-1].Health {
+					report("phases", "phase health thresholds must go from highest to lowest")
+				}
+				for _, attack := range phase.Attacks {
+					switch attack.Type {
+					case bossAttackSpread:
+						if projectile, ok := g_Prefabs[attack.Projectile]; !ok {
+							report("projectile", "unknown projectile prefab %q", attack.Projectile)
+						} else if projectile.Components.Projectile == nil {
+							report("projectile", "%s has no projectile component", attack.Projectile)
+						}
+						if attack.Count <= 0 {
+							report("count", "spread count must be positive, got %d", attack.Count)
+						}
+					case bossAttackCharge:
+						if attack.Speed <= 0 || attack.Duration <= 0 {
+							report("attacks", "charge speed and duration must be positive")
+						}
+					default:
+						report("attacks", "unknown attack type %q", attack.Type)
+					}
+				}
+			}
+		}
+		for _, child := range prefab.Children {
+			if _, ok := g_Prefabs[child.Prefab]; !ok {
+				report("children", "unknown child prefab %q", child.Prefab)
+			}
+		}
+	}
+	return issues
+}
+
+// Min below max on both axes and no NaN or infinite corners
+func bounding_box_valid(bb BoundingBox2D) bool {
+	for _, value := range []float32{bb.top_left.x, bb.top_left.y, bb.bottom_right.x, bb.bottom_right.y} {
+		if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+			return false
+		}
+	}
+	return bb.top_left.x < bb.bottom_right.x && bb.bottom_right.y < bb.top_left.y
+}
+
+// Adds the line and column to JSON syntax and type errors
+func json_error_context(data []byte, err error) error {
+	offset := int64(-1)
+	var syntax_error *json.SyntaxError
+	var type_error *json.UnmarshalTypeError
+	if errors.As(err, &syntax_error) {
+		offset = syntax_error.Offset
+	} else if errors.As(err, &type_error) {
+		offset = type_error.Offset
+	}
+	if offset < 0 {
+		return err
+	}
+
+	line, column := offset_line_column(data, offset)
+	return fmt.Errorf("line %d column %d: %w", line, column, err)
+}
+
+func offset_line_column(data []byte, offset int64) (int, int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// Byte offsets of the elements of a top level array, for pointing issues at a line
+func json_array_offsets(data []byte, key string) ([]int64, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, errors.New("not a JSON object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if name, _ := token.(string); name != key {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return nil, fmt.Errorf("%s is not an array", key)
+		}
+		offsets := []int64{}
+		for decoder.More() {
+			// InputOffset is just past the previous token, skip to where the element starts
+			offset := decoder.InputOffset()
+			for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n,", rune(data[offset])) {
+				offset++
+			}
+			offsets = append(offsets, offset)
+
+			var element json.RawMessage
+			if err := decoder.Decode(&element); err != nil {
+				return nil, err
+			}
+		}
+		return offsets, nil
+	}
+	return nil, nil
+}
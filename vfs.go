@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/assets"
+)
+
+var g_VFS = assets.NewVFS()
+
+const modsDirectory = "mods"
+
+// Mounts the base assets, then every enabled mod in mods/ in directory name order
+func init_vfs() {
+	g_VFS = assets.NewVFS()
+	g_VFS.Mount("base", filepath.Join(g_GameDir, "assets"))
+
+	for _, mod := range list_mods() {
+		if g_Settings.mods_disabled[mod] {
+			log.Printf("mods: %s is disabled", mod)
+			continue
+		}
+		g_VFS.Mount(mod, filepath.Join(g_GameDir, modsDirectory, mod))
+		log.Printf("mods: mounted %s", mod)
+	}
+
+	register_command("mods", "list installed mods", func() {
+		for _, mod := range list_mods() {
+			state := "enabled"
+			if g_Settings.mods_disabled[mod] {
+				state = "disabled"
+			}
+			console_print("%s (%s)", mod, state)
+		}
+	})
+	register_command("mod_enable", "enable a mod after the next restart", func(mod string) {
+		set_mod_enabled(mod, true)
+	})
+	register_command("mod_disable", "disable a mod after the next restart", func(mod string) {
+		set_mod_enabled(mod, false)
+	})
+}
+
+func list_mods() []string {
+	entries, err := os.ReadDir(filepath.Join(g_GameDir, modsDirectory))
+	if err != nil {
+		return nil
+	}
+
+	mods := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			mods = append(mods, entry.Name())
+		}
+	}
+	return mods
+}
+
+// Content is already loaded from the current mounts, so changes only apply on the next start
+func set_mod_enabled(mod string, enabled bool) {
+	g_Settings.mods_disabled[mod] = !enabled
+	if enabled {
+		console_print("%s will be enabled after a restart", mod)
+	} else {
+		console_print("%s will be disabled after a restart", mod)
+	}
+}
+
+// Read before init_vfs mounts the mods
+func load_disabled_mods() {
+	g_Settings.mods_disabled = map[string]bool{}
+	for _, mod := range strings.Split(g_Config.String("mods_disabled"), ",") {
+		if mod = strings.TrimSpace(mod); mod != "" {
+			g_Settings.mods_disabled[mod] = true
+		}
+	}
+}
+
+// Comma separated and sorted, for the settings file
+func disabled_mod_list() string {
+	mods := []string{}
+	for mod, disabled := range g_Settings.mods_disabled {
+		if disabled {
+			mods = append(mods, mod)
+		}
+	}
+	sort.Strings(mods)
+	return strings.Join(mods, ",")
+}
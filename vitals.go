@@ -0,0 +1,54 @@
+package main
+
+// Value drained by gameplay and refilled over time, like health or stamina
+type Resource struct {
+	current float32
+	max     float32
+	// Per second, only while regenerating
+	regen float32
+}
+
+const playerMaxHealth = 100.0
+const playerMaxStamina = 100.0
+const playerStaminaRegen = 50.0
+const jumpStaminaCost = 20.0
+
+func (resource Resource) fraction() float32 {
+	if resource.max <= 0 {
+		return 0
+	}
+	return resource.current / resource.max
+}
+
+// Takes amount if there is enough of it left
+func (resource *Resource) spend(amount float32) bool {
+	if resource.current < amount {
+		return false
+	}
+	resource.current -= amount
+	return true
+}
+
+func (resource *Resource) step(dt float32) {
+	resource.current = min(resource.current+resource.regen*dt, resource.max)
+}
+
+func (resource *Resource) refill() {
+	resource.current = resource.max
+}
+
+func reset_player_vitals(player *Player) {
+	player.health = Resource{current: playerMaxHealth, max: playerMaxHealth}
+	player.stamina = Resource{current: playerMaxStamina, max: playerMaxStamina, regen: playerStaminaRegen}
+}
+
+func damage_player(player *Player, amount float32) {
+	if g_Cheats.god {
+		return
+	}
+	player.health.current = max(player.health.current-amount, 0)
+	emit_event(GameEvent{kind: EVENT_PLAYER_DAMAGED, pos: player.pos, magnitude: amount})
+	if player.health.current <= 0 {
+		kill_player(player)
+	}
+}
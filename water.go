@@ -0,0 +1,282 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// Pools of water placed by the level. Their surface waves, they're see through and deeper water
+// is darker. The player floats in them and can swim, moving entities are slowed down.
+type WaterRegion struct {
+	bounds BoundingBox2D
+}
+
+type Water struct {
+	program uint32
+	vao     uint32
+	vbo     uint32
+	// Reuploaded after the map is rebuilt
+	dirty        bool
+	vertex_count int32
+
+	projection_uniform int32
+	camera_uniform     int32
+	time_uniform       int32
+
+	reflection_uniform         int32
+	reflection_enabled_uniform int32
+	reflection_plane_uniform   int32
+	resolution_uniform         int32
+
+	time float32
+}
+
+var g_Water = Water{}
+
+// Upwards acceleration when fully under, gravity is 50 so the player floats a bit over half under
+const waterBuoyancy = 90.0
+
+// Fraction of the velocity lost per second when fully under
+const waterDrag = 3.0
+
+// Swimming is slower than running, and strokes up only start once rising slowed down
+const waterSwimAccel = 40.0
+const waterStrokeSpeed = 14.0
+
+// The surface is cut into columns this wide so it can wave
+const waterColumnWidth = 0.25
+
+var waterVertexShader = `
+#version 330
+
+uniform mat4 projection;
+uniform mat4 camera;
+uniform float time;
+
+// x, y, 1 on the surface and 0 at the bottom, the surface's y
+in vec4 vert;
+
+out vec2 world;
+out float depth;
+out float surface;
+
+void main() {
+    vec2 pos = vert.xy;
+    pos.y += vert.z * (0.08 * sin(pos.x * 1.3 + time * 1.7) + 0.05 * sin(pos.x * 2.9 - time * 2.3));
+    world = pos;
+    depth = vert.w - vert.y;
+    surface = vert.w;
+    // Just in front of the blocks' faces, so what's in the water shows through it
+    gl_Position = projection * camera * vec4(pos, 1.05, 1);
+}
+` + "\x00"
+
+var waterFragmentShader = `
+#version 330
+
+uniform float time;
+
+// Only the pool whose surface is at reflection_plane has its reflection rendered
+uniform sampler2D reflection;
+uniform bool reflection_enabled;
+uniform float reflection_plane;
+uniform vec2 resolution;
+
+in vec2 world;
+in float depth;
+in float surface;
+
+out vec4 outputColor;
+
+// Slopes of three waves scrolling in different directions
+vec3 surface_normal(vec2 pos) {
+    vec2 slope = 0.3 * vec2(2.1, 0.9) * cos(dot(pos, vec2(2.1, 0.9)) + time * 1.3)
+        + 0.2 * vec2(-1.2, 2.7) * cos(dot(pos, vec2(-1.2, 2.7)) - time * 1.7)
+        + 0.1 * vec2(4.3, -3.1) * cos(dot(pos, vec2(4.3, -3.1)) + time * 2.9);
+    return normalize(vec3(-slope, 4.0));
+}
+
+void main() {
+    vec3 normal = surface_normal(world);
+    vec3 light = normalize(vec3(-0.4, 0.6, 1.0));
+    float diffuse = 0.75 + 0.25 * dot(normal, light);
+    float specular = pow(max(dot(reflect(-light, normal), vec3(0, 0, 1)), 0.0), 24.0);
+
+    float deep = clamp(depth / 3.0, 0.0, 1.0);
+    vec3 color = mix(vec3(0.25, 0.6, 0.75), vec3(0.05, 0.2, 0.4), deep) * diffuse + vec3(specular * 0.6);
+    float alpha = mix(0.45, 0.8, deep);
+
+    if (reflection_enabled && abs(surface - reflection_plane) < 0.01) {
+        // Ripples shift where it's sampled, and deeper water shows less of it
+        vec2 coord = gl_FragCoord.xy / resolution + normal.xy * 0.03;
+        color = mix(color, texture(reflection, coord).rgb, 0.45 * (1.0 - deep));
+    }
+
+    // Light line along the surface
+    float edge = 1.0 - smoothstep(0.0, 0.12, depth);
+    color = mix(color, vec3(0.85, 0.95, 1.0), edge * 0.7);
+    alpha = max(alpha, edge * 0.9);
+
+    outputColor = vec4(color * alpha, alpha);
+}
+` + "\x00"
+
+func init_water() {
+	program, err := render.NewProgram(waterVertexShader, waterFragmentShader)
+	if err != nil {
+		panic("water: " + err.Error())
+	}
+	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+	g_Water.program = program
+	g_Water.projection_uniform = gl.GetUniformLocation(program, gl.Str("projection\x00"))
+	g_Water.camera_uniform = gl.GetUniformLocation(program, gl.Str("camera\x00"))
+	g_Water.time_uniform = gl.GetUniformLocation(program, gl.Str("time\x00"))
+	g_Water.reflection_uniform = gl.GetUniformLocation(program, gl.Str("reflection\x00"))
+	g_Water.reflection_enabled_uniform = gl.GetUniformLocation(program, gl.Str("reflection_enabled\x00"))
+	g_Water.reflection_plane_uniform = gl.GetUniformLocation(program, gl.Str("reflection_plane\x00"))
+	g_Water.resolution_uniform = gl.GetUniformLocation(program, gl.Str("resolution\x00"))
+
+	gl.GenVertexArrays(1, &g_Water.vao)
+	gl.BindVertexArray(g_Water.vao)
+	gl.GenBuffers(1, &g_Water.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, g_Water.vbo)
+	vert := uint32(gl.GetAttribLocation(program, gl.Str("vert\x00")))
+	gl.EnableVertexAttribArray(vert)
+	gl.VertexAttribPointerWithOffset(vert, 4, gl.FLOAT, false, 4*4, 0)
+}
+
+func spawn_level_water() {
+	g_Map.water = nil
+	for _, bounds := range g_Level.Water {
+		g_Map.water = append(g_Map.water, WaterRegion{make_bounding_box_2d_xy(bounds[0], bounds[2], bounds[1], bounds[3])})
+	}
+	g_Water.dirty = true
+}
+
+// Fraction of bb under water, from 0 to 1
+func submerged(bb BoundingBox2D) float32 {
+	fraction := float32(0)
+	for _, region := range g_Map.water {
+		if !region.bounds.intersects(bb) {
+			continue
+		}
+		lower := max(bb.min_corner().y, region.bounds.min_corner().y)
+		upper := min(bb.max_corner().y, region.bounds.max_corner().y)
+		fraction = max(fraction, (upper-lower)/bb.size().y)
+	}
+	return fraction
+}
+
+// After the player stepped, floats and slows them down while in the water
+func step_player_swimming(player *Player, dt float32) {
+	fraction := submerged(player.bb)
+	if fraction <= 0 {
+		return
+	}
+	player.vel.y += waterBuoyancy * fraction * dt
+	player.vel = player.vel.mul_scalar(1 - min(waterDrag*fraction*dt, 1))
+	// Floating rests like running does, pools have no floor so strokes are the only way out
+	if player.state != RUNNING {
+		player.stamina.step(dt)
+	}
+}
+
+// Moving entities are dragged, those steering also float. Without a collider there's nothing to
+// submerge, e.g. wisps.
+func step_entity_swimming(entity *DynamicEntity, dt float32) {
+	if entity.prefab.Components.Collider == nil {
+		return
+	}
+	if entity.velocity == (Vector2DF{}) && entity.prefab.Components.Steering == nil {
+		return
+	}
+	fraction := submerged(dynamic_entity_bounding_box(entity))
+	if fraction <= 0 {
+		return
+	}
+	if entity.prefab.Components.Steering != nil {
+		entity.velocity.y += waterBuoyancy * fraction * dt
+	}
+	entity.velocity = entity.velocity.mul_scalar(1 - min(waterDrag*fraction*dt, 1))
+}
+
+func player_swimming(player *Player) bool {
+	return player.state != RUNNING && submerged(player.bb) > 0
+}
+
+// A stroke up, only once the last one stopped carrying the player up
+func player_swim_stroke(player *Player) {
+	if player.vel.y > 0 || !player.stamina.spend(jumpStaminaCost) {
+		return
+	}
+	player.vel.y = waterStrokeSpeed
+}
+
+// Columns of two triangles each, the top corners wave in the vertex shader
+func upload_water() {
+	g_Water.dirty = false
+	vertices := []float32{}
+	for _, region := range g_Map.water {
+		lower, upper := region.bounds.min_corner(), region.bounds.max_corner()
+		columns := max(int(math.Ceil(float64((upper.x-lower.x)/waterColumnWidth))), 1)
+		width := (upper.x - lower.x) / float32(columns)
+		for i := 0; i < columns; i++ {
+			left, right := lower.x+float32(i)*width, lower.x+float32(i+1)*width
+			vertices = append(vertices,
+				left, lower.y, 0, upper.y,
+				right, lower.y, 0, upper.y,
+				left, upper.y, 1, upper.y,
+				left, upper.y, 1, upper.y,
+				right, lower.y, 0, upper.y,
+				right, upper.y, 1, upper.y,
+			)
+		}
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, g_Water.vbo)
+	if len(vertices) > 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, gl.Ptr(vertices), gl.STATIC_DRAW)
+	}
+	g_Water.vertex_count = int32(len(vertices) / 4)
+}
+
+// After the opaque geometry, blended over it without writing depth
+func render_water(projection mgl32.Mat4, dt float32) {
+	g_Water.time += dt
+	if g_Water.dirty {
+		upload_water()
+	}
+	if g_Water.vertex_count == 0 {
+		return
+	}
+
+	gl.UseProgram(g_Water.program)
+	camera := camera_view_matrix()
+	gl.UniformMatrix4fv(g_Water.projection_uniform, 1, false, &projection[0])
+	gl.UniformMatrix4fv(g_Water.camera_uniform, 1, false, &camera[0])
+	gl.Uniform1f(g_Water.time_uniform, g_Water.time)
+
+	reflection_enabled := int32(0)
+	if g_Reflection.active {
+		reflection_enabled = 1
+		gl.ActiveTexture(gl.TEXTURE0 + reflectionTextureUnit)
+		gl.BindTexture(gl.TEXTURE_2D, g_Reflection.target.texture)
+		gl.ActiveTexture(gl.TEXTURE0)
+	}
+	gl.Uniform1i(g_Water.reflection_uniform, reflectionTextureUnit)
+	gl.Uniform1i(g_Water.reflection_enabled_uniform, reflection_enabled)
+	gl.Uniform1f(g_Water.reflection_plane_uniform, g_Reflection.plane)
+	gl.Uniform2f(g_Water.resolution_uniform, g_Reflection.screen.x, g_Reflection.screen.y)
+
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
+	gl.BindVertexArray(g_Water.vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, g_Water.vertex_count)
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+}
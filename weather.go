@@ -0,0 +1,395 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/guiteixeirapimentel/small-game-go/engine/noise"
+	"github.com/guiteixeirapimentel/small-game-go/engine/render"
+)
+
+// One stretch of a level's weather. The phases play in order and loop, each fading into the next.
+type WeatherPhase struct {
+	// weatherClear, weatherRain or weatherSnow
+	Type string `json:"type"`
+	// 0 to 1, how much falls and how loud it is
+	Intensity float32 `json:"intensity"`
+	// World units per second, positive blows to the right
+	Wind float32 `json:"wind"`
+	// Seconds, 0 lasts forever
+	Duration float32 `json:"duration"`
+}
+
+type WeatherKind int
+
+const (
+	WEATHER_CLEAR WeatherKind = iota
+	WEATHER_RAIN
+	WEATHER_SNOW
+	WEATHER_KIND_COUNT
+)
+
+const weatherClear = "clear"
+const weatherRain = "rain"
+const weatherSnow = "snow"
+
+var weatherKinds = map[string]WeatherKind{weatherClear: WEATHER_CLEAR, weatherRain: WEATHER_RAIN, weatherSnow: WEATHER_SNOW}
+
+// Looped under the weather at its intensity, relative to the assets
+var weatherAmbience = [WEATHER_KIND_COUNT]string{"", "sounds/rain_loop.wav", "sounds/snow_loop.wav"}
+
+const weatherWindAmbience = "sounds/wind_loop.wav"
+
+// Lets light entities drift with the wind, on top of their steering
+type WindComponent struct {
+	// Fraction of the wind speed they're carried at
+	Drag float32 `json:"drag"`
+}
+
+type WeatherParticle struct {
+	kind WeatherKind
+	pos  Vector2DF
+	// Depth, particles fall in front of and behind the blocks
+	z        float32
+	velocity Vector2DF
+	// Snowflakes sway along their own noise row
+	sway float32
+}
+
+type Weather struct {
+	phases []WeatherPhase
+	phase  int
+	time   float32
+	// Set from the console, replaces the level's phases until cleared
+	override *WeatherPhase
+
+	// Eased towards the phase's values, every kind fades on its own so changes cross over
+	intensity [WEATHER_KIND_COUNT]float32
+	wind      float32
+	age       float32
+
+	particles []WeatherParticle
+
+	ambience      [WEATHER_KIND_COUNT]*Voice
+	wind_ambience *Voice
+	// Missing loops are only reported once
+	missing map[string]bool
+
+	vao      uint32
+	vbo      uint32
+	vertices []float32
+	textures [WEATHER_KIND_COUNT]uint32
+}
+
+var g_Weather = Weather{missing: map[string]bool{}}
+
+// Gusts on top of the phase's wind
+var g_WeatherNoise = noise.NewSimplex(2)
+
+// Seconds to fade into a phase at full intensity
+const weatherTransition = 6.0
+
+// Wind change per second while easing to a phase's
+const weatherWindChange = 1.5
+
+// Gust strength as a fraction of the wind, and how often they come
+const weatherGustStrength = 0.5
+const weatherGustRate = 0.25
+
+// Wind loop gain reaches 1 at this wind speed
+const weatherStrongWind = 10.0
+
+// At intensity 1, across the whole view
+var weatherMaxParticles = [WEATHER_KIND_COUNT]int{0, 800, 500}
+
+// Particles live in a box around the camera this much bigger than the view
+const weatherViewMargin = 1.3
+const weatherNearZ = -4.0
+const weatherFarZ = 6.0
+
+const weatherRainSpeed = 22.0
+const weatherRainLength = 0.03
+const weatherRainWidth = 0.03
+const weatherSnowSpeed = 2.0
+const weatherSnowSize = 0.12
+const weatherSnowSway = 1.5
+
+var weatherColors = [WEATHER_KIND_COUNT]color.RGBA{{}, {120, 140, 170, 140}, {235, 235, 240, 230}}
+
+func init_weather(program uint32) {
+	gl.GenVertexArrays(1, &g_Weather.vao)
+	gl.BindVertexArray(g_Weather.vao)
+	gl.GenBuffers(1, &g_Weather.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, g_Weather.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, slices.Max(weatherMaxParticles[:])*6*5*4, nil, gl.DYNAMIC_DRAW)
+	config_vertex_data(program)
+
+	for kind, c := range weatherColors {
+		g_Weather.textures[kind] = render.SolidTexture(c)
+	}
+
+	register_command("weather", "[clear|rain|snow] [intensity] [wind]: change the weather, \"level\" goes back to the level's", func(args []string) {
+		if len(args) == 0 {
+			console_print("weather: %s, wind %.1f", describe_weather(), g_Weather.wind)
+			return
+		}
+		if args[0] == "level" {
+			g_Weather.override = nil
+			return
+		}
+		if _, ok := weatherKinds[args[0]]; !ok {
+			console_print("unknown weather %q, expected clear, rain, snow or level", args[0])
+			return
+		}
+
+		phase := WeatherPhase{Type: args[0], Intensity: 1}
+		for i, field := range []*float32{&phase.Intensity, &phase.Wind} {
+			if len(args) <= i+1 {
+				break
+			}
+			value, err := strconv.ParseFloat(args[i+1], 32)
+			if err != nil {
+				console_print("invalid number %q", args[i+1])
+				return
+			}
+			*field = float32(value)
+		}
+		phase.Intensity = min(max(phase.Intensity, 0), 1)
+		g_Weather.override = &phase
+	})
+}
+
+// The level's phases, falling back to its pack's
+func level_weather(level LevelInfo) []WeatherPhase {
+	if len(level.Weather) > 0 {
+		return level.Weather
+	}
+	if pack := level_pack(level); pack != nil {
+		return pack.Weather
+	}
+	return nil
+}
+
+// Called once the map is built, starts the level's weather at its first phase right away
+func reset_weather() {
+	g_Weather.phases = level_weather(g_Level)
+	g_Weather.phase = 0
+	g_Weather.time = 0
+	g_Weather.particles = g_Weather.particles[:0]
+
+	phase := current_weather_phase()
+	g_Weather.intensity = [WEATHER_KIND_COUNT]float32{}
+	g_Weather.intensity[weatherKinds[phase.Type]] = phase.Intensity
+	g_Weather.wind = phase.Wind
+}
+
+func current_weather_phase() WeatherPhase {
+	if g_Weather.override != nil {
+		return *g_Weather.override
+	}
+	if len(g_Weather.phases) == 0 {
+		return WeatherPhase{Type: weatherClear}
+	}
+	return g_Weather.phases[g_Weather.phase]
+}
+
+func describe_weather() string {
+	phase := current_weather_phase()
+	return fmt.Sprintf("%s at %.0f%%", phase.Type, phase.Intensity*100)
+}
+
+// Wind felt at pos, gusting around the phase's
+func wind_at(pos Vector2DF) Vector2DF {
+	gust := g_WeatherNoise.Noise2(float64(g_Weather.age*weatherGustRate), float64(pos.x*0.05))
+	return Vector2DF{g_Weather.wind * (1 + weatherGustStrength*float32(gust)), 0}
+}
+
+func step_weather(dt float32) {
+	g_Weather.age += dt
+	if len(g_Weather.phases) > 0 {
+		g_Weather.time += dt
+		if duration := g_Weather.phases[g_Weather.phase].Duration; duration > 0 && g_Weather.time >= duration {
+			g_Weather.time = 0
+			g_Weather.phase = (g_Weather.phase + 1) % len(g_Weather.phases)
+		}
+	}
+
+	phase := current_weather_phase()
+	for kind := range g_Weather.intensity {
+		target := float32(0)
+		if WeatherKind(kind) == weatherKinds[phase.Type] {
+			target = phase.Intensity
+		}
+		g_Weather.intensity[kind] = move_towards_value(g_Weather.intensity[kind], target, dt/weatherTransition)
+	}
+	g_Weather.wind = move_towards_value(g_Weather.wind, phase.Wind, weatherWindChange*dt)
+
+	// Only seen and heard, the simulation doesn't depend on either
+	if g_Headless {
+		return
+	}
+	step_weather_particles(dt)
+	step_weather_ambience()
+}
+
+func move_towards_value(value float32, target float32, step float32) float32 {
+	if value < target {
+		return min(value+step, target)
+	}
+	return max(value-step, target)
+}
+
+// Keeps as many particles of each kind as its intensity asks for inside the box around the
+// camera, those leaving it come back in on the opposite side
+func step_weather_particles(dt float32) {
+	half := camera_visible_half_extents().mul_scalar(weatherViewMargin)
+	lower, upper := g_Camera.pos2D.subtract(half), g_Camera.pos2D.add(half)
+
+	counts := [WEATHER_KIND_COUNT]int{}
+	kept := g_Weather.particles[:0]
+	for _, particle := range g_Weather.particles {
+		if counts[particle.kind] >= int(g_Weather.intensity[particle.kind]*float32(weatherMaxParticles[particle.kind])) {
+			continue
+		}
+		counts[particle.kind]++
+
+		wind := wind_at(particle.pos)
+		switch particle.kind {
+		case WEATHER_RAIN:
+			particle.velocity = Vector2DF{wind.x, -weatherRainSpeed}
+		case WEATHER_SNOW:
+			sway := g_WeatherNoise.Noise2(float64(g_Weather.age), float64(particle.sway)) * weatherSnowSway
+			target := Vector2DF{wind.x + float32(sway), -weatherSnowSpeed}
+			particle.velocity = particle.velocity.lerp(target, min(dt*2, 1))
+		}
+		particle.pos = particle.pos.add(particle.velocity.mul_scalar(dt))
+
+		if particle.pos.y < lower.y {
+			particle.pos.y += upper.y - lower.y
+		} else if particle.pos.y > upper.y {
+			particle.pos.y -= upper.y - lower.y
+		}
+		if particle.pos.x < lower.x {
+			particle.pos.x += upper.x - lower.x
+		} else if particle.pos.x > upper.x {
+			particle.pos.x -= upper.x - lower.x
+		}
+		kept = append(kept, particle)
+	}
+	g_Weather.particles = kept
+
+	// New ones start anywhere in the box, so a shower starting fills the view at once
+	for kind := range counts {
+		for ; counts[kind] < int(g_Weather.intensity[kind]*float32(weatherMaxParticles[kind])); counts[kind]++ {
+			g_Weather.particles = append(g_Weather.particles, WeatherParticle{
+				kind: WeatherKind(kind),
+				pos:  Vector2DF{lower.x + rand.Float32()*(upper.x-lower.x), lower.y + rand.Float32()*(upper.y-lower.y)},
+				z:    weatherNearZ + rand.Float32()*(weatherFarZ-weatherNearZ),
+				sway: rand.Float32() * 100,
+			})
+		}
+	}
+}
+
+// Each kind's loop plays at its intensity, the wind loop at the wind's strength
+func step_weather_ambience() {
+	for kind, name := range weatherAmbience {
+		if name != "" {
+			g_Weather.ambience[kind] = fade_ambience(g_Weather.ambience[kind], name, g_Weather.intensity[kind])
+		}
+	}
+	g_Weather.wind_ambience = fade_ambience(g_Weather.wind_ambience, weatherWindAmbience, min(float32(math.Abs(float64(g_Weather.wind)))/weatherStrongWind, 1))
+}
+
+// Starts the loop when it becomes audible, fades it to gain, and stops it once silent
+func fade_ambience(voice *Voice, name string, gain float32) *Voice {
+	if voice == nil {
+		if gain <= 0 || g_Weather.missing[name] {
+			return nil
+		}
+		stream, err := open_audio_stream(asset_path(name))
+		if err != nil {
+			log.Println("weather:", err)
+			g_Weather.missing[name] = true
+			return nil
+		}
+		voice = new_voice(AUDIO_BUS_SFX, stream, 0, true)
+		mixer_add_voice(voice)
+	}
+
+	g_Mixer.mutex.Lock()
+	defer g_Mixer.mutex.Unlock()
+	if gain <= 0 {
+		voice.fade(0, 0.5, true)
+		return nil
+	}
+	if voice.fade_to != gain {
+		voice.fade(gain, 0.5, false)
+	}
+	return voice
+}
+
+// Rain as streaks along its velocity, snow as flakes, all in one draw per kind
+func render_weather() {
+	if len(g_Weather.particles) == 0 {
+		return
+	}
+
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
+	model := mgl32.Ident4()
+	gl.UniformMatrix4fv(g_Overlay.model_uniform, 1, false, &model[0])
+	gl.BindVertexArray(g_Weather.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, g_Weather.vbo)
+	gl.ActiveTexture(gl.TEXTURE0)
+
+	for kind := range g_Weather.textures {
+		vertices := g_Weather.vertices[:0]
+		for _, particle := range g_Weather.particles {
+			if particle.kind != WeatherKind(kind) {
+				continue
+			}
+			along, across := Vector2DF{0, weatherSnowSize}, Vector2DF{weatherSnowSize, 0}
+			if particle.kind == WEATHER_RAIN {
+				along = particle.velocity.mul_scalar(weatherRainLength)
+				direction := particle.velocity.normalized()
+				across = Vector2DF{-direction.y, direction.x}.mul_scalar(weatherRainWidth)
+			}
+			vertices = append_weather_quad(vertices, particle.pos, particle.z, along, across)
+		}
+		g_Weather.vertices = vertices
+		if len(vertices) == 0 {
+			continue
+		}
+
+		gl.BufferSubData(gl.ARRAY_BUFFER, 0, len(vertices)*4, gl.Ptr(vertices))
+		gl.BindTexture(gl.TEXTURE_2D, g_Weather.textures[kind])
+		gl.DrawArrays(gl.TRIANGLES, 0, int32(len(vertices)/5))
+	}
+
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+}
+
+// Two triangles centered on pos, along and across are half extents
+func append_weather_quad(vertices []float32, pos Vector2DF, z float32, along Vector2DF, across Vector2DF) []float32 {
+	corners := [4]Vector2DF{
+		pos.subtract(along).subtract(across),
+		pos.subtract(along).add(across),
+		pos.add(along).subtract(across),
+		pos.add(along).add(across),
+	}
+	for _, i := range [6]int{0, 1, 2, 2, 1, 3} {
+		vertices = append(vertices, corners[i].x, corners[i].y, z, 0.5, 0.5)
+	}
+	return vertices
+}
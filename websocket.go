@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Just enough of RFC 6455 for a server pushing text messages to browsers
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	websocketOpText  = 0x1
+	websocketOpClose = 0x8
+	websocketOpPing  = 0x9
+	websocketOpPong  = 0xA
+)
+
+type WebSocketConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// The read loop answers pings while the sender pushes messages, frames mustn't interleave
+	write_lock sync.Mutex
+}
+
+func websocket_upgrade(w http.ResponseWriter, r *http.Request) (*WebSocketConn, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "expected a websocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("not a websocket request")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("missing websocket key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection can't be hijacked")
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(hash[:])
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &WebSocketConn{conn: conn, reader: buffered.Reader}, nil
+}
+
+func (ws *WebSocketConn) write_frame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	ws.write_lock.Lock()
+	defer ws.write_lock.Unlock()
+	if _, err := ws.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := ws.conn.Write(payload)
+	return err
+}
+
+func (ws *WebSocketConn) write_text(message []byte) error {
+	return ws.write_frame(websocketOpText, message)
+}
+
+// Reads the next data frame, answering pings; returns io.EOF once the peer closes
+func (ws *WebSocketConn) read_frame() (byte, []byte, error) {
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(ws.reader, header); err != nil {
+			return 0, nil, err
+		}
+
+		opcode := header[0] & 0x0f
+		masked := header[1]&0x80 != 0
+		length := uint64(header[1] & 0x7f)
+
+		switch length {
+		case 126:
+			extended := make([]byte, 2)
+			if _, err := io.ReadFull(ws.reader, extended); err != nil {
+				return 0, nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(extended))
+		case 127:
+			extended := make([]byte, 8)
+			if _, err := io.ReadFull(ws.reader, extended); err != nil {
+				return 0, nil, err
+			}
+			length = binary.BigEndian.Uint64(extended)
+		}
+
+		if length > 1<<16 {
+			return 0, nil, errors.New("websocket frame too large")
+		}
+
+		mask := make([]byte, 4)
+		if masked {
+			if _, err := io.ReadFull(ws.reader, mask); err != nil {
+				return 0, nil, err
+			}
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(ws.reader, payload); err != nil {
+			return 0, nil, err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case websocketOpClose:
+			ws.write_frame(websocketOpClose, nil)
+			return 0, nil, io.EOF
+		case websocketOpPing:
+			ws.write_frame(websocketOpPong, payload)
+		case websocketOpPong:
+		default:
+			return opcode, payload, nil
+		}
+	}
+}
+
+func (ws *WebSocketConn) close() {
+	ws.conn.Close()
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// Wave function collapse, the overlapping model: every 3x3 window of a small hand drawn sample is
+// a pattern, and the output is filled so every 3x3 window of it is one of those patterns. Starting
+// with every pattern possible everywhere, the cell with the fewest options left is collapsed to one
+// of them at random, weighted by how often it shows up in the sample, and the patterns that no
+// longer overlap consistently with it are removed from its neighbors, and theirs, and so on.
+//
+// Samples are text, one row per line from the top: '#' is a block, anything else is open.
+type WFCSample struct {
+	width, height int
+	// Row major, top row first
+	tiles []byte
+}
+
+type WFCPattern struct {
+	tiles  []byte
+	weight float64
+	// Sides of the sample the pattern touches, patterns on an edge of the output must touch the same one
+	edges uint8
+}
+
+const (
+	WFC_EDGE_LEFT = 1 << iota
+	WFC_EDGE_RIGHT
+	WFC_EDGE_TOP
+	WFC_EDGE_BOTTOM
+)
+
+const wfcPatternSize = 3
+const wfcWidth = 56
+const wfcHeight = 22
+
+// Contradictions happen, each attempt starts over with the next numbers of the same rng
+const wfcAttempts = 20
+
+// The exit has to be at least this far across the map from the spawn, in cells
+const wfcMinCrossing = wfcWidth / 2
+
+// Rows of blocks a jump climbs, it tops out at 4 units which is two cells with no room to spare
+const wfcClimb = 1
+
+// Cells of gap a running jump clears, it stays in the air for 0.8 seconds
+const wfcLeap = 2
+
+// Left, down, right, up, with y pointing down like the sample
+var wfcDirectionX = [4]int{-1, 0, 1, 0}
+var wfcDirectionY = [4]int{0, 1, 0, -1}
+var wfcOpposite = [4]int{2, 3, 0, 1}
+
+func read_wfc_sample(file string) (WFCSample, error) {
+	data, err := os.ReadFile(asset_path(file))
+	if err != nil {
+		return WFCSample{}, err
+	}
+
+	sample := WFCSample{}
+	for i, line := range strings.Split(strings.TrimRight(string(data), "\r\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if sample.width == 0 {
+			sample.width = len(line)
+		}
+		if len(line) != sample.width {
+			return WFCSample{}, fmt.Errorf("%s:%d: row is %d tiles wide, the first row is %d", file, i+1, len(line), sample.width)
+		}
+		sample.tiles = append(sample.tiles, line...)
+		sample.height++
+	}
+	if sample.width < wfcPatternSize || sample.height < wfcPatternSize {
+		return WFCSample{}, fmt.Errorf("%s: sample must be at least %dx%d tiles", file, wfcPatternSize, wfcPatternSize)
+	}
+	return sample, nil
+}
+
+// Every distinct window of the sample, repeats add to the weight
+func learn_wfc_patterns(sample WFCSample) []WFCPattern {
+	patterns := []WFCPattern{}
+	indices := map[string]int{}
+
+	for y := 0; y+wfcPatternSize <= sample.height; y++ {
+		for x := 0; x+wfcPatternSize <= sample.width; x++ {
+			tiles := make([]byte, 0, wfcPatternSize*wfcPatternSize)
+			for dy := 0; dy < wfcPatternSize; dy++ {
+				start := (y+dy)*sample.width + x
+				tiles = append(tiles, sample.tiles[start:start+wfcPatternSize]...)
+			}
+
+			edges := uint8(0)
+			if x == 0 {
+				edges |= WFC_EDGE_LEFT
+			}
+			if x+wfcPatternSize == sample.width {
+				edges |= WFC_EDGE_RIGHT
+			}
+			if y == 0 {
+				edges |= WFC_EDGE_TOP
+			}
+			if y+wfcPatternSize == sample.height {
+				edges |= WFC_EDGE_BOTTOM
+			}
+
+			index, ok := indices[string(tiles)]
+			if !ok {
+				index = len(patterns)
+				indices[string(tiles)] = index
+				patterns = append(patterns, WFCPattern{tiles: tiles})
+			}
+			patterns[index].weight++
+			patterns[index].edges |= edges
+		}
+	}
+	return patterns
+}
+
+// Whether b placed dx, dy away from a agrees with it where the two overlap
+func wfc_patterns_agree(a []byte, b []byte, dx int, dy int) bool {
+	for y := max(0, dy); y < min(wfcPatternSize, wfcPatternSize+dy); y++ {
+		for x := max(0, dx); x < min(wfcPatternSize, wfcPatternSize+dx); x++ {
+			if a[x+y*wfcPatternSize] != b[x-dx+(y-dy)*wfcPatternSize] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type WFC struct {
+	patterns []WFCPattern
+	// For each direction and pattern, the patterns that may sit next to it in that direction
+	propagator [4][][]int
+
+	// Positions of pattern corners, the last patterns also cover the tiles past them
+	width, height int
+	wave          [][]bool
+	// For each position, pattern and direction, how many patterns of the neighbor in the opposite
+	// direction still allow it, it's banned once that reaches 0
+	compatible [][][4]int
+	remaining  []int
+	stack      [][2]int
+}
+
+func new_wfc(patterns []WFCPattern, width int, height int) *WFC {
+	wfc := &WFC{patterns: patterns, width: width - wfcPatternSize + 1, height: height - wfcPatternSize + 1}
+	for direction := range wfc.propagator {
+		wfc.propagator[direction] = make([][]int, len(patterns))
+		for a := range patterns {
+			for b := range patterns {
+				if wfc_patterns_agree(patterns[a].tiles, patterns[b].tiles, wfcDirectionX[direction], wfcDirectionY[direction]) {
+					wfc.propagator[direction][a] = append(wfc.propagator[direction][a], b)
+				}
+			}
+		}
+	}
+	return wfc
+}
+
+func (wfc *WFC) clear() {
+	positions := wfc.width * wfc.height
+	wfc.wave = make([][]bool, positions)
+	wfc.compatible = make([][][4]int, positions)
+	wfc.remaining = make([]int, positions)
+	wfc.stack = wfc.stack[:0]
+
+	for i := 0; i < positions; i++ {
+		wfc.wave[i] = make([]bool, len(wfc.patterns))
+		wfc.compatible[i] = make([][4]int, len(wfc.patterns))
+		wfc.remaining[i] = len(wfc.patterns)
+		for pattern := range wfc.patterns {
+			wfc.wave[i][pattern] = true
+			for direction := 0; direction < 4; direction++ {
+				wfc.compatible[i][pattern][direction] = len(wfc.propagator[wfcOpposite[direction]][pattern])
+			}
+		}
+	}
+}
+
+func (wfc *WFC) ban(position int, pattern int) {
+	wfc.wave[position][pattern] = false
+	wfc.compatible[position][pattern] = [4]int{}
+	wfc.remaining[position]--
+	wfc.stack = append(wfc.stack, [2]int{position, pattern})
+}
+
+// False on a contradiction, a position left with no pattern at all
+func (wfc *WFC) propagate() bool {
+	for len(wfc.stack) > 0 {
+		top := wfc.stack[len(wfc.stack)-1]
+		wfc.stack = wfc.stack[:len(wfc.stack)-1]
+		position, pattern := top[0], top[1]
+		x, y := position%wfc.width, position/wfc.width
+
+		for direction := 0; direction < 4; direction++ {
+			nx, ny := x+wfcDirectionX[direction], y+wfcDirectionY[direction]
+			if nx < 0 || ny < 0 || nx >= wfc.width || ny >= wfc.height {
+				continue
+			}
+			neighbor := nx + ny*wfc.width
+			for _, other := range wfc.propagator[direction][pattern] {
+				compatible := &wfc.compatible[neighbor][other][direction]
+				*compatible--
+				if *compatible == 0 {
+					wfc.ban(neighbor, other)
+				}
+			}
+			if wfc.remaining[neighbor] == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Keeps the output's borders like the sample's, so a sample with walls and a floor gives a closed map
+func (wfc *WFC) constrain_edges() {
+	for position := range wfc.wave {
+		x, y := position%wfc.width, position/wfc.width
+		edges := uint8(0)
+		if x == 0 {
+			edges |= WFC_EDGE_LEFT
+		}
+		if x == wfc.width-1 {
+			edges |= WFC_EDGE_RIGHT
+		}
+		if y == 0 {
+			edges |= WFC_EDGE_TOP
+		}
+		if y == wfc.height-1 {
+			edges |= WFC_EDGE_BOTTOM
+		}
+		for pattern := range wfc.patterns {
+			if wfc.wave[position][pattern] && wfc.patterns[pattern].edges&edges != edges {
+				wfc.ban(position, pattern)
+			}
+		}
+	}
+}
+
+// Weighted entropy of each undecided position, with a little noise so ties break at random
+func (wfc *WFC) lowest_entropy(rng *rand.Rand) int {
+	lowest, lowest_entropy := -1, math.Inf(1)
+	for position := range wfc.wave {
+		if wfc.remaining[position] <= 1 {
+			continue
+		}
+		sum, sum_log := 0.0, 0.0
+		for pattern, possible := range wfc.wave[position] {
+			if possible {
+				weight := wfc.patterns[pattern].weight
+				sum += weight
+				sum_log += weight * math.Log(weight)
+			}
+		}
+		entropy := math.Log(sum) - sum_log/sum + rng.Float64()*1e-6
+		if entropy < lowest_entropy {
+			lowest, lowest_entropy = position, entropy
+		}
+	}
+	return lowest
+}
+
+func (wfc *WFC) observe(rng *rand.Rand, position int) {
+	sum := 0.0
+	for pattern, possible := range wfc.wave[position] {
+		if possible {
+			sum += wfc.patterns[pattern].weight
+		}
+	}
+
+	pick := rng.Float64() * sum
+	chosen := -1
+	for pattern, possible := range wfc.wave[position] {
+		if !possible {
+			continue
+		}
+		pick -= wfc.patterns[pattern].weight
+		if chosen < 0 && pick < 0 {
+			chosen = pattern
+		}
+	}
+	if chosen < 0 {
+		// Rounding left pick at 0, take the last one still possible
+		for pattern, possible := range wfc.wave[position] {
+			if possible {
+				chosen = pattern
+			}
+		}
+	}
+
+	for pattern, possible := range wfc.wave[position] {
+		if possible && pattern != chosen {
+			wfc.ban(position, pattern)
+		}
+	}
+}
+
+// Tiles of a width x height output, top row first
+func (wfc *WFC) run(rng *rand.Rand) ([]byte, error) {
+	wfc.clear()
+	wfc.constrain_edges()
+	if !wfc.propagate() {
+		return nil, errors.New("the sample's edges don't fit together")
+	}
+
+	for {
+		position := wfc.lowest_entropy(rng)
+		if position < 0 {
+			break
+		}
+		wfc.observe(rng, position)
+		if !wfc.propagate() {
+			return nil, errors.New("contradiction")
+		}
+	}
+
+	width, height := wfc.width+wfcPatternSize-1, wfc.height+wfcPatternSize-1
+	tiles := make([]byte, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			px, py := min(x, wfc.width-1), min(y, wfc.height-1)
+			pattern := 0
+			for pattern = range wfc.wave[px+py*wfc.width] {
+				if wfc.wave[px+py*wfc.width][pattern] {
+					break
+				}
+			}
+			tiles[x+y*width] = wfc.patterns[pattern].tiles[(x-px)+(y-py)*wfcPatternSize]
+		}
+	}
+	return tiles, nil
+}
+
+// Fills the map from the level's sample, the player starts on the leftmost floor and finishes on
+// the rightmost one it can get to. Falls back to platforms when no attempt gets far enough across.
+func generate_wfc(level_map *Map, level LevelInfo, rng *rand.Rand, tileset string) {
+	sample_file := level.Sample
+	if sample_file == "" {
+		sample_file = levelDefaultSample
+	}
+	sample, err := read_wfc_sample(sample_file)
+	if err != nil {
+		log.Println("wfc:", err)
+		build_platform_map(level_map, level, rng, tileset)
+		return
+	}
+
+	wfc := new_wfc(learn_wfc_patterns(sample), wfcWidth, wfcHeight)
+	for attempt := 0; attempt < wfcAttempts; attempt++ {
+		tiles, err := wfc.run(rng)
+		if err != nil {
+			continue
+		}
+
+		// The output is top row first, the grid y up
+		grid := new_dungeon_grid(wfcWidth, wfcHeight)
+		for y := 0; y < wfcHeight; y++ {
+			for x := 0; x < wfcWidth; x++ {
+				if tiles[x+y*wfcWidth] != '#' {
+					grid.carve(DungeonCell{x, wfcHeight - 1 - y})
+				}
+			}
+		}
+
+		if build_wfc_map(level_map, level, rng, &grid, tileset) {
+			return
+		}
+	}
+
+	log.Printf("wfc: no usable map from %s in %d attempts", sample_file, wfcAttempts)
+	build_platform_map(level_map, level, rng, tileset)
+}
+
+// False when the reachable floors don't span enough of the map
+func build_wfc_map(level_map *Map, level LevelInfo, rng *rand.Rand, grid *DungeonGrid, tileset string) bool {
+	floors := []DungeonCell{}
+	for x := 0; x < grid.width; x++ {
+		for y := 1; y < grid.height; y++ {
+			if cell := (DungeonCell{x, y}); grid.is_floor(cell) {
+				floors = append(floors, cell)
+			}
+		}
+	}
+	if len(floors) == 0 {
+		return false
+	}
+
+	// Only floors the player can walk, jump and drop to, open space above a ledge doesn't count
+	spawn := floors[0]
+	distances := grid.flood_floors(spawn, wfcClimb, wfcLeap)
+	reachable := []Vector2DF{}
+	exit := spawn
+	for _, cell := range floors {
+		if distances[cell.y*grid.width+cell.x] < 0 {
+			continue
+		}
+		reachable = append(reachable, DungeonCell{cell.x, cell.y - 1}.world())
+		if cell.x > exit.x {
+			exit = cell
+		}
+	}
+	if exit.x-spawn.x < wfcMinCrossing {
+		return false
+	}
+
+	grid.add_blocks(level_map, tileset)
+	level_map.spawn = spawn.world()
+	exit_bb := make_bounding_box_2d_centered(exit.world(), Vector2DF{1, 1})
+	level_map.exit = &exit_bb
+	level_map.goal_x = exit.world().x
+	level_map.generated_entities = append(level_map.generated_entities, LevelEntity{Prefab: "marker", X: exit.world().x, Y: exit.world().y + 1})
+
+	place_collectibles_above(level_map, level.Collectibles, rng, reachable)
+	return true
+}
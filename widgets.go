@@ -0,0 +1,356 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+type Label struct {
+	widget *Widget
+	text   string
+	scale  float32
+	color  color.RGBA
+}
+
+// Sized to its text, changing the text resizes it on the next layout
+func new_label(text string, scale float32, c color.RGBA) *Label {
+	label := &Label{text: text, scale: scale, color: c}
+	label.widget = &Widget{pivot: uiTopLeft}
+	label.widget.update = func(widget *Widget) {
+		widget.size = Vector2DF{text_width(label.text, label.scale), text_line_height(label.scale)}
+	}
+	label.widget.draw = func(widget *Widget) {
+		draw_text(label.text, widget.rect.x, widget.rect.y, label.scale, label.color)
+	}
+	return label
+}
+
+type Button struct {
+	widget   *Widget
+	text     string
+	on_click func()
+	// Optional, left/right on the focused button and dragging with the mouse held
+	on_adjust func(step int)
+	on_drag   func(pointer Vector2DF)
+
+	hovered bool
+	pressed bool
+	focused bool
+}
+
+const buttonHeight = 28
+const buttonPadding = 12
+
+type WidgetTheme struct {
+	button  NineSlice
+	hovered NineSlice
+	pressed NineSlice
+	focus   NineSlice
+	panel   NineSlice
+	tooltip NineSlice
+	// Behind selected text in text boxes
+	selection NineSlice
+}
+
+var g_WidgetTheme = WidgetTheme{}
+
+func new_button(text string, width float32, on_click func()) *Button {
+	button := &Button{text: text, on_click: on_click}
+	button.widget = &Widget{size: Vector2DF{width, button_height()}}
+	button.widget.draw = func(widget *Widget) { draw_button(button) }
+	return button
+}
+
+func draw_button(button *Button) {
+	rect := button.widget.rect
+	slice := g_WidgetTheme.button
+	if button.pressed {
+		slice = g_WidgetTheme.pressed
+	} else if button.hovered {
+		slice = g_WidgetTheme.hovered
+	}
+
+	if button.focused {
+		draw_nine_slice(g_WidgetTheme.focus, rect.x-2, rect.y-2, rect.w+4, rect.h+4)
+	}
+	draw_nine_slice(slice, rect.x, rect.y, rect.w, rect.h)
+
+	line_height := text_line_height(1)
+	draw_text(button.text, rect.x+buttonPadding, rect.y+(rect.h-line_height)/2, 1, color.RGBA{255, 255, 255, 255})
+}
+
+// Grows with the text scale so labels keep their padding
+func button_height() float32 {
+	return max(buttonHeight, text_line_height(1)+buttonPadding)
+}
+
+// Buttons stacked top to bottom. The focused one is moved with the arrow keys or the
+// d-pad and activated with Enter or the A button, the mouse works on any of them.
+type Menu struct {
+	widget  *Widget
+	buttons []*Button
+	spacing float32
+	focused int
+
+	// Only takes input while this context is active and the menu is visible
+	context InputContext
+	// Escape or the B button, optional
+	on_cancel func()
+	// Set from input callbacks, the click runs in step_ui so the key press that
+	// triggered it doesn't also reach whatever the click opens
+	pending_click  *Button
+	pending_cancel bool
+}
+
+type WidgetInput struct {
+	menus []*Menu
+	// Contexts with a key handler already routing to menus
+	contexts map[InputContext]bool
+
+	// Pressed gamepad buttons last frame, for edges
+	gamepad_buttons map[glfw.GamepadButton]bool
+}
+
+var g_WidgetInput = WidgetInput{contexts: map[InputContext]bool{}, gamepad_buttons: map[glfw.GamepadButton]bool{}}
+
+func init_widgets() {
+	load_ui_skin("ui/skin.json")
+	g_WidgetTheme.button = ui_skin("button", color.RGBA{40, 40, 60, 255})
+	g_WidgetTheme.hovered = ui_skin("button_hovered", color.RGBA{60, 60, 90, 255})
+	g_WidgetTheme.pressed = ui_skin("button_pressed", color.RGBA{30, 30, 45, 255})
+	g_WidgetTheme.focus = ui_skin("focus", color.RGBA{255, 210, 80, 255})
+	g_WidgetTheme.panel = ui_skin("panel", color.RGBA{10, 10, 20, 220})
+	g_WidgetTheme.tooltip = ui_skin("tooltip", color.RGBA{15, 15, 25, 230})
+	g_WidgetTheme.selection = ui_skin("selection", color.RGBA{50, 80, 150, 255})
+}
+
+func new_menu(context InputContext, spacing float32) *Menu {
+	menu := &Menu{context: context, spacing: spacing}
+	menu.widget = &Widget{}
+	menu.widget.update = func(widget *Widget) { layout_menu(menu) }
+	g_WidgetInput.menus = append(g_WidgetInput.menus, menu)
+
+	if !g_WidgetInput.contexts[context] {
+		g_WidgetInput.contexts[context] = true
+		add_key_input_handler(context, func(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+			if action == glfw.Release {
+				return
+			}
+			for _, menu := range g_WidgetInput.menus {
+				if menu.context == context && widget_visible(menu.widget) {
+					menu_key(menu, key, action)
+				}
+			}
+		})
+	}
+	return menu
+}
+
+func add_menu_button(menu *Menu, button *Button) *Button {
+	menu.buttons = append(menu.buttons, button)
+	add_widget(menu.widget, button.widget)
+	return button
+}
+
+// Hidden buttons take no space and are skipped by focus
+func layout_menu(menu *Menu) {
+	if menu.focused >= len(menu.buttons) || (len(menu.buttons) > 0 && menu.buttons[menu.focused].widget.hidden) {
+		menu.focused = 0
+		menu_move_focus(menu, 0)
+	}
+
+	width, y := float32(0), float32(0)
+	for i, button := range menu.buttons {
+		if button.widget.hidden {
+			continue
+		}
+		button.widget.anchor, button.widget.pivot = uiTopLeft, uiTopLeft
+		button.widget.offset = Vector2DF{0, y}
+		button.widget.size.y = button_height()
+		button.focused = i == menu.focused
+		y += button.widget.size.y + menu.spacing
+		width = max(width, button.widget.size.x)
+	}
+	menu.widget.size = Vector2DF{width, max(y-menu.spacing, 0)}
+}
+
+// Moves to the next visible button in the step's direction, a step of 0 only leaves hidden ones
+func menu_move_focus(menu *Menu, step int) {
+	count := len(menu.buttons)
+	if count == 0 {
+		return
+	}
+	direction := max(min(step, 1), -1)
+	if direction == 0 {
+		direction = 1
+	} else {
+		menu.focused = (menu.focused + direction + count) % count
+	}
+	for i := 0; i < count && menu.buttons[menu.focused].widget.hidden; i++ {
+		menu.focused = (menu.focused + direction + count) % count
+	}
+}
+
+func menu_key(menu *Menu, key glfw.Key, action glfw.Action) {
+	switch key {
+	case glfw.KeyUp:
+		menu_move_focus(menu, -1)
+	case glfw.KeyDown, glfw.KeyTab:
+		menu_move_focus(menu, 1)
+	case glfw.KeyLeft, glfw.KeyRight:
+		if button := menu_focused_button(menu); button != nil && button.on_adjust != nil {
+			button.on_adjust(map[glfw.Key]int{glfw.KeyLeft: -1, glfw.KeyRight: 1}[key])
+		}
+	case glfw.KeyEnter, glfw.KeySpace:
+		if action == glfw.Press {
+			menu.pending_click = menu_focused_button(menu)
+		}
+	case glfw.KeyEscape:
+		menu.pending_cancel = action == glfw.Press
+	}
+}
+
+func menu_focused_button(menu *Menu) *Button {
+	if menu.focused < len(menu.buttons) {
+		return menu.buttons[menu.focused]
+	}
+	return nil
+}
+
+// Edges of the first connected gamepad's buttons
+func gamepad_just_pressed(state *glfw.GamepadState, button glfw.GamepadButton) bool {
+	pressed := state != nil && state.Buttons[button] == glfw.Press
+	was_pressed := g_WidgetInput.gamepad_buttons[button]
+	g_WidgetInput.gamepad_buttons[button] = pressed
+	return pressed && !was_pressed
+}
+
+// Runs clicks queued by keys and handles the mouse and gamepad, once per frame after polling input
+func step_ui() {
+	var gamepad *glfw.GamepadState
+	if glfw.Joystick1.IsGamepad() {
+		gamepad = glfw.Joystick1.GetGamepadState()
+	}
+	gamepad_up := gamepad_just_pressed(gamepad, glfw.ButtonDpadUp)
+	gamepad_down := gamepad_just_pressed(gamepad, glfw.ButtonDpadDown)
+	gamepad_left := gamepad_just_pressed(gamepad, glfw.ButtonDpadLeft)
+	gamepad_right := gamepad_just_pressed(gamepad, glfw.ButtonDpadRight)
+	gamepad_accept := gamepad_just_pressed(gamepad, glfw.ButtonA)
+	gamepad_cancel := gamepad_just_pressed(gamepad, glfw.ButtonB)
+
+	pointer := ui_pointer()
+	for _, menu := range g_WidgetInput.menus {
+		pending, cancel := menu.pending_click, menu.pending_cancel
+		menu.pending_click, menu.pending_cancel = nil, false
+
+		active := widget_visible(menu.widget) && active_input_context() == menu.context
+		if !active {
+			for _, button := range menu.buttons {
+				button.hovered, button.pressed = false, false
+			}
+			continue
+		}
+
+		if gamepad_up {
+			menu_move_focus(menu, -1)
+		}
+		if gamepad_down {
+			menu_move_focus(menu, 1)
+		}
+		if gamepad_accept {
+			pending = menu_focused_button(menu)
+		}
+		cancel = cancel || gamepad_cancel
+		if button := menu_focused_button(menu); button != nil && button.on_adjust != nil {
+			if gamepad_left {
+				button.on_adjust(-1)
+			}
+			if gamepad_right {
+				button.on_adjust(1)
+			}
+		}
+
+		for i, button := range menu.buttons {
+			// Hidden buttons keep the rect they were last laid out at
+			button.hovered = widget_visible(button.widget) && button.widget.rect.contains(pointer)
+			if button.hovered && action_just_pressed(ACTION_POINTER_PRIMARY) {
+				button.pressed = true
+				menu.focused = i
+			}
+			if button.pressed && button.on_drag != nil {
+				button.on_drag(pointer)
+			}
+			if !action_held(ACTION_POINTER_PRIMARY) {
+				// Released over the button it was pressed on
+				if button.pressed && button.hovered {
+					pending = button
+				}
+				button.pressed = false
+			}
+		}
+
+		if pending != nil && pending.on_click != nil {
+			pending.on_click()
+		}
+		if cancel && menu.on_cancel != nil {
+			menu.on_cancel()
+		}
+	}
+}
+
+// Button showing an on/off state, clicking or left/right flips it
+func new_toggle(text string, width float32, get func() bool, set func(bool)) *Button {
+	toggle := new_button(text, width, func() { set(!get()) })
+	toggle.on_adjust = func(step int) { set(step > 0) }
+	toggle.widget.update = func(widget *Widget) {
+		state := "off"
+		if get() {
+			state = "on"
+		}
+		toggle.text = text + ": " + state
+	}
+	return toggle
+}
+
+// Button cycling through named options, clicking or right steps forward and left back
+func new_choice(text string, width float32, options []string, get func() int, set func(int)) *Button {
+	choice := new_button(text, width, func() { set((get() + 1) % len(options)) })
+	choice.on_adjust = func(step int) { set((get() + step + len(options)) % len(options)) }
+	choice.widget.update = func(widget *Widget) {
+		choice.text = text + ": " + options[get()]
+	}
+	return choice
+}
+
+// Button with a filled track, adjusted by steps with the keys or dragged with the mouse
+func new_slider(text string, width float32, lower float32, upper float32, step float32, get func() float32, set func(float32)) *Button {
+	slider := new_button(text, width, nil)
+	// Values stay on the steps so they read back cleanly
+	snap := func(value float32) float32 {
+		return min(max(lower+float32(math.Round(float64((value-lower)/step)))*step, lower), upper)
+	}
+	slider.on_adjust = func(direction int) { set(snap(get() + float32(direction)*step)) }
+	slider.on_drag = func(pointer Vector2DF) {
+		track := slider_track(slider.widget.rect)
+		fraction := min(max((pointer.x-track.x)/track.w, 0), 1)
+		set(snap(lower + fraction*(upper-lower)))
+	}
+	slider.widget.draw = func(widget *Widget) {
+		draw_button(slider)
+
+		track := slider_track(widget.rect)
+		fraction := (get() - lower) / max(upper-lower, 1e-6)
+		draw_nine_slice(g_WidgetTheme.pressed, track.x, track.y, track.w, track.h)
+		draw_nine_slice(g_WidgetTheme.focus, track.x, track.y, track.w*fraction, track.h)
+		draw_text(strconv.FormatFloat(float64(get()), 'f', 2, 32), track.x+track.w+6, widget.rect.y+(widget.rect.h-text_line_height(1))/2, 1, color.RGBA{255, 255, 255, 255})
+	}
+	return slider
+}
+
+// Right half of the slider, the left half holds the label
+func slider_track(rect UIRect) UIRect {
+	return UIRect{rect.x + rect.w*0.45, rect.y + rect.h/2 - 3, rect.w*0.4 - 6, 6}
+}